@@ -38,4 +38,24 @@ export async function GoogleOAuthTryOutLogin() {
   if (!response.ok) throw new Error("Failed to initiate demo OAuth");
   const { googleUrl } = await response.json();
   return googleUrl;
-}
\ No newline at end of file
+}
+// Exchanges the single-use code the OAuth callback redirected with for the
+// session tokens, which so never appear in URLs.
+export async function ExchangeOAuthCode(code: string) {
+  const response = await fetch(`${apiUrl}/auth/oauth/exchange`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    credentials: "include",
+    body: JSON.stringify({ code }),
+  });
+
+  if (!response.ok) {
+    const data = await response.json().catch(() => null);
+    throw {
+      status: response.status,
+      data,
+    };
+  }
+
+  return response.json();
+}
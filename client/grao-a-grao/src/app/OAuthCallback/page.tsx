@@ -1,13 +1,16 @@
 "use client";
 
+import { ExchangeOAuthCode } from "@/api/auth_api";
 import { setIsUserLoggedIn } from "@/util/util";
 import { Heading } from "@radix-ui/themes";
 import { useRouter, useSearchParams } from "next/navigation";
-import { useEffect, Suspense } from "react";
+import { useEffect, useRef, Suspense } from "react";
 
 function OAuthCallbackContent() {
   const router = useRouter();
   const searchParams = useSearchParams();
+  // Login codes work once, so the effect must not exchange one twice
+  const exchanged = useRef(false);
 
   useEffect(() => {
     const errorParam = searchParams.get("error");
@@ -16,37 +19,46 @@ function OAuthCallbackContent() {
       router.push(`/login?google_auth_error=${encodeURIComponent(errorParam)}`);
     }
 
-    const token = searchParams.get("token");
-    const name = searchParams.get("name");
-    const email = searchParams.get("email");
-    const avatar_url = searchParams.get("user_picture_url");
-    const isTryOut = searchParams.get("isTryOut")
-    const uuid = searchParams.get("uuid")
+    const code = searchParams.get("code");
+    const isTryOut = searchParams.get("isTryOut");
 
-    if (token) {
-      localStorage.setItem("authToken", token);
-      localStorage.setItem("userName", name || "");
-      localStorage.setItem("userEmail", email || "");
-      localStorage.setItem("userPictureUrl", avatar_url || "");
-      setIsUserLoggedIn(true);
-
-      if (isTryOut === "true" && window.opener) {
-        // send data back to the opener window
-        window.opener.postMessage(
-          {
-            token,
-            uuid,
-            name,
-            email,
-            avatar_url,
-          },
-          window.location.origin
-        );
-        window.close();
-      } else {
-        router.push("/");
-      }
+    if (!code || exchanged.current) {
+      return;
     }
+    exchanged.current = true;
+
+    ExchangeOAuthCode(code)
+      .then((data) => {
+        const { token, uuid, name, email } = data;
+        const avatar_url = data.user_picture_url;
+
+        localStorage.setItem("authToken", token);
+        localStorage.setItem("userName", name || "");
+        localStorage.setItem("userEmail", email || "");
+        localStorage.setItem("userPictureUrl", avatar_url || "");
+        setIsUserLoggedIn(true);
+
+        if (isTryOut === "true" && window.opener) {
+          // send data back to the opener window
+          window.opener.postMessage(
+            {
+              token,
+              uuid,
+              name,
+              email,
+              avatar_url,
+            },
+            window.location.origin
+          );
+          window.close();
+        } else {
+          router.push("/");
+        }
+      })
+      .catch((err) => {
+        const detail = err?.data ? JSON.stringify(err.data) : "login failed";
+        router.push(`/login?google_auth_error=${encodeURIComponent(detail)}`);
+      });
   }, [router, searchParams]);
 
   return (
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/api_key_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/store_repository"
+	mapper "github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
+	dtoRequest "github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
+	dtoResponse "github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/auth_service"
+	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAPIKey godoc
+// @Summary      Create an API key
+// @Description  Creates a key that integrations send in the X-API-Key header. It acts on behalf of the authenticated user and can do no more than its scopes and the user's role allow, and, with a store id, only in that store. The full key is in this response only.
+// @Security     BearerAuth
+// @Tags         API Keys
+// @Accept       json
+// @Produce      json
+// @Param        data  body  dtoRequest.CreateAPIKeyRequest  true  "API key"
+// @Success      201  {object}  dtoResponse.CreatedAPIKeyResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input or expiry in the past"
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Store not found"
+// @Failure      422  {object}  dtoResponse.ErrorResponse "Validation error"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /api-keys [post]
+func CreateAPIKey(c *gin.Context) {
+	logger.Log.Info("CreateAPIKey")
+
+	req := c.MustGet("dto").(*dtoRequest.CreateAPIKeyRequest)
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "expires_at must be in the future"})
+		return
+	}
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	if req.StoreID != nil {
+		store, err := store_repository.GetStoreByID(conn, *req.StoreID)
+		if err != nil {
+			logger.Log.Error("Error getting store: ", err)
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+			return
+		} else if store == nil {
+			c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Store not found"})
+			return
+		}
+	}
+
+	key := mapper.CreateAPIKeyToModel(req)
+	key.OrganizationID = user.Organization.ID
+	key.CreatedBy = &user.ID
+
+	secret, err := auth_service.CreateAPIKey(key)
+	if err != nil {
+		logger.Log.Error("Error creating API key: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, dtoResponse.CreatedAPIKeyResponse{
+		APIKeyResponse: mapper.ToAPIKeyResponse(key),
+		Key:            secret,
+	})
+}
+
+// ListAPIKeys godoc
+// @Summary      List API keys
+// @Description  Lists every API key of the organization, newest first, with when and from where each was last used. Keys are shown by their prefix only.
+// @Security     BearerAuth
+// @Tags         API Keys
+// @Produce      json
+// @Success      200  {array}   dtoResponse.APIKeyResponse
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /api-keys [get]
+func ListAPIKeys(c *gin.Context) {
+	logger.Log.Info("ListAPIKeys")
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	keys, err := api_key_repository.ListAPIKeys(user.Organization.ID)
+	if err != nil {
+		logger.Log.Error("Error listing API keys: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToAPIKeyResponseList(keys))
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke an API key
+// @Description  Stops the key from working immediately.
+// @Security     BearerAuth
+// @Tags         API Keys
+// @Param        id   path  int  true  "API key ID"
+// @Success      204  "API key revoked"
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "API key not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /api-keys/{id} [delete]
+func RevokeAPIKey(c *gin.Context) {
+	logger.Log.Info("RevokeAPIKey")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Id must be a number"})
+		return
+	}
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	found, err := api_key_repository.RevokeAPIKey(uint(id), user.Organization.ID, user.ID)
+	if err != nil {
+		logger.Log.Error("Error revoking API key: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "API key not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/audit_repository"
+	mapper "github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
+	dtoResponse "github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuditLog godoc
+// @Summary      List the audit log
+// @Description  Lists the changes made to the organization's data, newest first, including its settings, API keys and invitations. Each entry has the user (and API key) that made it, the changed entity and the row before and after the change. The log spans every store, so API keys restricted to a store are denied.
+// @Security     BearerAuth
+// @Tags         Audit
+// @Accept       json
+// @Produce      json
+// @Param        limit       query   int     false  "Page size (1-200, default 20)"
+// @Param        cursor      query   string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset      query   int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort        query   string  false  "Comma-separated sort fields, prefix with - for descending (id, occurred_at)"
+// @Param        filter.{field}  query  string  false  "Filter by entity_type, entity_id, action, user_id, api_key_id or occurred_at; comma-separate values to match any, numeric and date fields also accept .min/.max"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
+// @Success      200  {array}   dtoResponse.AuditEntryResponse
+// @Failure      400  {object}  dtoResponse.InvalidListQueryErrorResponse "Invalid list query"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied, or API key restricted to a store"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /audit [get]
+func ListAuditLog(c *gin.Context) {
+	logger.Log.Info("ListAuditLog")
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	lq, ok := handler_util.ParseListQuery(c, audit_repository.AuditListSpec)
+	if !ok {
+		return
+	}
+
+	entries, page, err := audit_repository.ListAuditLog(conn, lq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to retrieve audit log"})
+		return
+	}
+
+	resp := make([]dtoResponse.AuditEntryResponse, len(entries))
+	for i, e := range entries {
+		resp[i] = mapper.ToAuditEntryResponse(e)
+	}
+
+	handler_util.SetPageHeaders(c, page)
+	c.JSON(http.StatusOK, resp)
+}
@@ -3,12 +3,16 @@ package handler
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
 
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/user_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
 	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/auth_service"
 
 	"net/http"
 
@@ -22,121 +26,600 @@ import (
 
 // GoogleAuthHandler handles the initiation of the Google OAuth authentication process.
 // It generates a unique OAuth state, stores it in a cookie, and returns the Google authentication URL.
-// The handler distinguishes between development ("DEV") and production ("PROD") environments to set cookies appropriately:
-//   - In "DEV", cookies are set with default domain and security settings.
-//   - In "PROD", cookies are set with the API domain, secure, and SameSite=None attributes for cross-site requests.
 //
 // GoogleAuthHandler godoc
 // @Summary Initiates the Google OAuth2 authentication process
-// @Description Generates a new OAuth2 state, stores it in cookies, and returns a Google authentication URL.
+// @Description Records a new OAuth2 login with a PKCE verifier on the server, stores its state in a cookie, and returns a Google authentication URL.
 // @Tags Auth
 // @Produce  json
 // @Param isTryOut query string false "Flag to indicate if the user is creating a try-out environment"
+// @Param invitationToken query string false "Token of an organization invitation to accept with the Google account"
 // @Success 200 {object} response.GoogleInitOAuthResponse
+// @Failure 404 {object} response.ErrorResponse "Google login not configured"
 // @Failure 500 {object} response.ErrorResponse
 // @Router /auth/google [get]
 func GoogleAuthHandler(c *gin.Context) {
 	logger.Log.Info("GoogleAuthHandler")
 
-	// Generate a new state for each auth request
-	state, err := util.GenerateOAuthState(32)
-	if err != nil {
-		logger.Log.Error("failed to generate oauth state")
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to generate state"})
+	authURL, ok := startOAuth(c, auth.GoogleProviderName)
+	if !ok {
 		return
 	}
 
+	// Send redirect url as googleUrl
+	c.JSON(http.StatusOK, response.GoogleInitOAuthResponse{GoogleUrl: authURL})
+}
+
+// OAuthHandler godoc
+// @Summary Initiates the login with an identity provider
+// @Description Records a new OAuth2 login with a nonce and PKCE verifier on the server, stores its state in a cookie, and returns the authentication URL of the provider, e.g. google or an OpenID Connect provider such as Microsoft Entra ID or Keycloak configured on the server.
+// @Tags Auth
+// @Produce  json
+// @Param provider path string true "Identity provider name, see /auth/providers"
+// @Param isTryOut query string false "Flag to indicate if the user is creating a try-out environment"
+// @Param invitationToken query string false "Token of an organization invitation to accept with the provider account"
+// @Success 200 {object} response.OAuthInitResponse
+// @Failure 404 {object} response.ErrorResponse "Identity provider not found"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /auth/oauth/{provider} [get]
+func OAuthHandler(c *gin.Context) {
+	logger.Log.Info("OAuthHandler")
+
+	authURL, ok := startOAuth(c, c.Param("provider"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, response.OAuthInitResponse{URL: authURL})
+}
+
+// ListAuthProvidersHandler godoc
+// @Summary List identity providers
+// @Description Lists the names of the identity providers users can log in with through /auth/oauth/{provider}.
+// @Tags Auth
+// @Produce  json
+// @Success 200 {object} response.AuthProvidersResponse
+// @Router /auth/providers [get]
+func ListAuthProvidersHandler(c *gin.Context) {
+	logger.Log.Info("ListAuthProvidersHandler")
+	c.JSON(http.StatusOK, response.AuthProvidersResponse{Providers: auth.ProviderNames()})
+}
+
+// startOAuth records a login with the provider and stores its state in a
+// cookie, binding the callback to this browser. It returns the provider's
+// authentication URL.
+// The handler distinguishes between development ("DEV") and production ("PROD") environments to set cookies appropriately:
+//   - In "DEV", cookies are set with default domain and security settings.
+//   - In "PROD", cookies are set with the API domain, secure, and SameSite=None attributes for cross-site requests.
+func startOAuth(c *gin.Context, providerName string) (string, bool) {
+	provider, ok := auth.GetProvider(providerName)
+	if !ok {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "identity provider not found"})
+		return "", false
+	}
+
 	// The "isTryOut" parameter indicates whether the user is attempting to create a try-out environment to test the system.
-	// This value is also stored in a cookie for later use during the authentication flow.
-	isTryOut := c.Query("isTryOut")
+	// "invitationToken" is set when the user follows an invitation link, the
+	// callback then accepts the invitation with the provider account.
+	// Both are kept with the login on the server until the callback.
+	isTryOut := c.Query("isTryOut") == "true"
+	invitationToken := c.Query("invitationToken")
+
+	authURL, state, err := auth_service.BeginOAuth(provider, isTryOut, invitationToken)
+	if err != nil {
+		logger.Log.Error("failed to start oauth login: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to generate state"})
+		return "", false
+	}
+
+	maxAge := int(auth_service.OAuthStateTTL.Seconds())
 	stage := util.GetStage()
 	if stage == "DEV" {
-		// Store the state in a cookie (valid for 1 hour)
-		c.SetCookie("oauthstate", state, 3600, "", "", false, true)
-		c.SetCookie("isTryOut", isTryOut, 3600, "", "", false, true)
+		c.SetCookie("oauthstate", state, maxAge, "", "", false, true)
 	} else if stage == "PROD" {
 		APIDomain := os.Getenv("API_DOMAIN")
 		c.SetSameSite(http.SameSiteNoneMode)
-		c.SetCookie("oauthstate", state, 3600, "/", APIDomain, true, true)
-		c.SetCookie("isTryOut", isTryOut, 3600, "/", APIDomain, true, true)
+		c.SetCookie("oauthstate", state, maxAge, "/", APIDomain, true, true)
 	}
 
-	// Send redirect url as googleUrl
-	url := auth.GetAuthURL(state)
-	c.JSON(http.StatusOK, response.GoogleInitOAuthResponse{GoogleUrl: url})
+	return authURL, true
 }
 
 // GoogleAuthCallBackHandler godoc
 // @Summary OAuth2 callback handler for Google login
-// @Description This endpoint is used internally by Google OAuth2. It validates the OAuth state, retrieves user info, and redirects the user to the frontend with a JWT or error information. **This endpoint should not be called directly.**
+// @Description This endpoint is used internally by Google OAuth2. It validates the OAuth state, retrieves user info, accepts a pending invitation if the login started from one, and redirects the user to the frontend with a single-use login code, to exchange at /auth/oauth/exchange, or error information. **This endpoint should not be called directly.**
 // @Tags Auth
 // @Produce json
 // @Param state query string true "OAuth2 state"
 // @Param code query string true "OAuth2 authorization code"
-// @Success 302 "Redirects to frontend with JWT or error payload in query parameters"
+// @Success 302 "Redirects to frontend with a login code or error payload in query parameters"
 // @Failure 400 {object} response.ErrorResponse "Invalid OAuth2 state or failed to exchange code"
+// @Failure 404 {object} response.ErrorResponse "Google login not configured"
 // @Failure 500 {object} response.ErrorResponse "Internal server error"
 // @Router /auth/google/callback [get]
 func GoogleAuthCallBackHandler(c *gin.Context) {
 	logger.Log.Info("GoogleAuthCallBackHandler")
+	oauthCallback(c, auth.GoogleProviderName)
+}
+
+// OAuthCallBackHandler godoc
+// @Summary OAuth2 callback handler for identity provider logins
+// @Description This endpoint is used internally by the identity provider. It validates the OAuth state, verifies the identity, accepts a pending invitation if the login started from one, and redirects the user to the frontend with a single-use login code, to exchange at /auth/oauth/exchange, or error information. **This endpoint should not be called directly.**
+// @Tags Auth
+// @Produce json
+// @Param provider path string true "Identity provider name"
+// @Param state query string true "OAuth2 state"
+// @Param code query string true "OAuth2 authorization code"
+// @Success 302 "Redirects to frontend with a login code or error payload in query parameters"
+// @Failure 400 {object} response.ErrorResponse "Invalid OAuth2 state, failed to exchange code or invalid ID token"
+// @Failure 404 {object} response.ErrorResponse "Identity provider not found"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/oauth/{provider}/callback [get]
+func OAuthCallBackHandler(c *gin.Context) {
+	logger.Log.Info("OAuthCallBackHandler")
+	oauthCallback(c, c.Param("provider"))
+}
+
+func oauthCallback(c *gin.Context, providerName string) {
 	frontendURL := os.Getenv("FRONTEND_URL")
 
-	// Step 1: Validate state and exchange code for token
-	googleUserInfoStruct, err := auth_handler_util.ValidateOAuthStateAndGetUser(c)
+	provider, ok := auth.GetProvider(providerName)
+	if !ok {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "identity provider not found"})
+		return
+	}
+
+	// Step 1: Validate state and exchange code for the identity
+	identity, oauthState, err := auth_handler_util.ValidateOAuthStateAndGetIdentity(c, provider)
 	if err != nil {
 		return
 	}
 
-	// Step 2: Try to retrieve user by Google ID
-	userStruct, err := user_repository.GetUserByGoogleID(googleUserInfoStruct.ID)
+	// Step 2: Accept the invitation the login started from, if any
+	if oauthState.InvitationToken != "" {
+		if handleInvitationFlow(c, oauthState.InvitationToken, identity, frontendURL) {
+			return
+		}
+	}
+
+	// Step 3: Try to retrieve the user linked to the identity
+	userStruct, err := user_repository.GetUserByIdentity(identity.Provider, identity.Subject)
 	if err != nil {
-		var googleUserNotFound *errorCodes.GoogleIDUserNotFound
-		if errors.As(err, &googleUserNotFound) {
-			logger.Log.Info("Google user not found in DB.")
+		logger.Log.Error("Error retrieving user by identity: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error retrieving user by identity"})
+		return
+	}
+
+	if userStruct == nil {
+		logger.Log.Infof("%s identity not linked to any user.", identity.Provider)
 
-			isTryOut, _ := c.Cookie("isTryOut")
-			if isTryOut == "true" {
-				HandleTryOutFlow(c, googleUserInfoStruct, frontendURL)
-				return
-			}
+		if oauthState.IsTryOut {
+			HandleTryOutFlow(c, identity, frontendURL)
+			return
+		}
 
+		if identity.Provider == auth.GoogleProviderName {
 			auth_handler_util.RedirectWithError(c, frontendURL, "No user is associated with this Google account.", errorCodes.CodeGoogleUserNotFound)
+		} else {
+			auth_handler_util.RedirectWithError(c, frontendURL, "No user is associated with this account.", errorCodes.CodeIdentityUserNotFound)
+		}
+		return
+	}
+
+	handleExistingUserFlow(c, userStruct, frontendURL)
+}
+
+// handleInvitationFlow accepts the invitation with the provider account and
+// logs the user in. It returns false when the user already belongs to the
+// inviting organization, so the regular login goes on.
+func handleInvitationFlow(c *gin.Context, token string, identity model.Identity, frontendURL string) bool {
+	user, err := auth_service.AcceptInvitationWithIdentity(token, identity)
+	if err != nil {
+		if errors.Is(err, auth_service.ErrAlreadyMember) {
+			return false
+		}
+
+		if _, resp, ok := invitationErrorResponse(err); ok {
+			auth_handler_util.RedirectWithError(c, frontendURL, resp.Error, resp.InternalCode)
+			return true
+		}
+
+		logger.Log.Error("Error accepting invitation: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error accepting invitation"})
+		return true
+	}
+
+	handleExistingUserFlow(c, user, frontendURL)
+	return true
+}
+
+// handleExistingUserFlow redirects the user to the frontend with a login
+// code. The tokens are only handed out by ExchangeLoginCodeHandler, so they
+// never show up in URLs.
+func handleExistingUserFlow(c *gin.Context, user *model.User, frontendURL string) {
+	code, err := auth_service.IssueLoginCode(user.ID, "")
+	if err != nil {
+		logger.Log.Error("failed to issue login code: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to generate token"})
+		return
+	}
+
+	redirectURL := fmt.Sprintf("%s/OAuthCallback?code=%s", frontendURL, url.QueryEscape(code))
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// ExchangeLoginCodeHandler godoc
+// @Summary Exchange an OAuth login code for tokens
+// @Description Redeems the single-use code the OAuth callback redirected to the frontend with, and starts the session. Codes expire after a minute. For logins that started a try-out environment, uuid is the job to poll at /tryOut/status.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body request.ExchangeLoginCodeRequest true "Login code"
+// @Success 200 {object} response.LoginResponse
+// @Failure 400 {object} response.ErrorResponse "Invalid request body"
+// @Failure 401 {object} response.AuthErrorResponse "Invalid, used or expired code"
+// @Failure 422 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/oauth/exchange [post]
+func ExchangeLoginCodeHandler(c *gin.Context) {
+	logger.Log.Info("ExchangeLoginCodeHandler")
+
+	req := c.MustGet("dto").(*request.ExchangeLoginCodeRequest)
+
+	user, tokens, tryoutUUID, err := auth_service.ExchangeLoginCode(req.Code, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		if errors.Is(err, auth_service.ErrInvalidLoginCode) {
+			c.JSON(http.StatusUnauthorized, response.AuthErrorResponse{
+				Error:        "invalid or expired login code",
+				InternalCode: errorCodes.CodeInvalidLoginCode,
+			})
 			return
 		}
 
-		logger.Log.Error("Error retrieving user by Google ID: ", err)
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error retrieving user by Google ID"})
+		logger.Log.Error("Error exchanging login code: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to generate token"})
 		return
 	}
 
-	handleExistingUserFlow(c, userStruct, googleUserInfoStruct, frontendURL)
+	c.JSON(http.StatusOK, response.LoginResponse{
+		Token:          tokens.AccessToken,
+		RefreshToken:   tokens.RefreshToken,
+		ExpiresAt:      tokens.AccessTokenExpiresAt.Unix(),
+		Name:           user.GivenName,
+		Email:          user.Email,
+		UserPictureURL: user.PictureURL,
+		IsTryOut:       user.Organization.IsTryOut,
+		Uuid:           tryoutUUID,
+	})
 }
 
-func handleExistingUserFlow(c *gin.Context, user *model.User, googleUser model.GoogleUserInfo, frontendURL string) {
-	var jwt string
-	var err error
+// RegisterHandler godoc
+// @Summary Sign up with email and password
+// @Description Creates a local user in a new try-out environment and mails an email verification link. Poll /tryOut/status with the returned uuid while the environment is being set up. The user can log in once the email is verified.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body request.RegisterRequest true "New user"
+// @Success 202 {object} response.RegisterResponse
+// @Failure 400 {object} response.ErrorResponse "Invalid request body"
+// @Failure 409 {object} response.AuthErrorResponse "Email already registered"
+// @Failure 422 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/register [post]
+func RegisterHandler(c *gin.Context) {
+	logger.Log.Info("RegisterHandler")
+
+	req := c.MustGet("dto").(*request.RegisterRequest)
 
-	if user.Organization.IsTryOut {
-		jwt, err = util.GenerateTryOutJWT(*user, *user.Organization.ExpiresAt)
-	} else {
-		jwt, err = util.GenerateJWT(*user)
+	user := &model.User{
+		GivenName:  req.GivenName,
+		FamilyName: req.FamilyName,
+		Email:      req.Email,
 	}
 
+	job, err := auth_service.Register(user, req.Password)
 	if err != nil {
-		logger.Log.Error("failed to generate JWT: ", err.Error())
+		if errors.Is(err, auth_service.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, response.AuthErrorResponse{
+				Error:        "email already registered",
+				InternalCode: errorCodes.CodeEmailAlreadyRegistered,
+			})
+			return
+		}
+
+		logger.Log.Error("Error registering user: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to start try-out environment"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, response.RegisterResponse{
+		Uuid:  job.TryoutUUID,
+		Email: user.Email,
+	})
+}
+
+// LoginHandler godoc
+// @Summary Log in with email and password
+// @Description Checks the credentials of a local user and returns a JWT. Users with 2FA, or whose organization requires it, get a token to send with their code to /auth/2fa/login instead. After too many failed attempts for an email or from an address, logins are refused for a while.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body request.LoginRequest true "Credentials"
+// @Success 200 {object} response.LoginResponse
+// @Success 202 {object} response.TwoFactorChallengeResponse "Second factor needed"
+// @Failure 400 {object} response.ErrorResponse "Invalid request body"
+// @Failure 401 {object} response.AuthErrorResponse "Invalid email or password"
+// @Failure 403 {object} response.AuthErrorResponse "Email not verified"
+// @Failure 422 {object} response.ErrorResponse "Validation error"
+// @Failure 429 {object} response.AuthErrorResponse "Too many failed attempts"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/login [post]
+func LoginHandler(c *gin.Context) {
+	logger.Log.Info("LoginHandler")
+
+	req := c.MustGet("dto").(*request.LoginRequest)
+
+	user, err := auth_service.Login(req.Email, req.Password, c.ClientIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, auth_service.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, response.AuthErrorResponse{
+				Error:        "invalid email or password",
+				InternalCode: errorCodes.CodeInvalidCredentials,
+			})
+		case errors.Is(err, auth_service.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, response.AuthErrorResponse{
+				Error:        "email address not verified",
+				InternalCode: errorCodes.CodeEmailNotVerified,
+			})
+		case errors.Is(err, auth_service.ErrTooManyAttempts):
+			c.Header("Retry-After", strconv.Itoa(int(auth_service.LoginThrottleWindow.Seconds())))
+			c.JSON(http.StatusTooManyRequests, response.AuthErrorResponse{
+				Error:        "too many failed login attempts, try again later",
+				InternalCode: errorCodes.CodeTooManyLoginAttempts,
+			})
+		default:
+			logger.Log.Error("Error logging in: ", err)
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Internal Server Error"})
+		}
+		return
+	}
+
+	if auth_service.TwoFactorRequired(user) {
+		sendTwoFactorChallenge(c, user)
+		return
+	}
+
+	tokens, err := auth_service.StartSession(user, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		logger.Log.Error("failed to start session: ", err.Error())
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to generate token"})
 		return
 	}
 
 	user_repository.StampNowLastLogin(user.ID)
 
-	redirectURL := fmt.Sprintf(
-		"%s/OAuthCallback?token=%s&name=%s&email=%s&user_picture_url=%s",
-		frontendURL,
-		jwt,
-		googleUser.Name,
-		googleUser.Email,
-		googleUser.Picture,
-	)
-	c.Redirect(http.StatusFound, redirectURL)
+	c.JSON(http.StatusOK, response.LoginResponse{
+		Token:          tokens.AccessToken,
+		RefreshToken:   tokens.RefreshToken,
+		ExpiresAt:      tokens.AccessTokenExpiresAt.Unix(),
+		Name:           user.GivenName,
+		Email:          user.Email,
+		UserPictureURL: user.PictureURL,
+		IsTryOut:       user.Organization.IsTryOut,
+	})
+}
+
+// VerifyEmailHandler godoc
+// @Summary Confirm an email address
+// @Description Consumes the token from an email verification link. Tokens work once and expire after 24 hours.
+// @Tags Auth
+// @Accept json
+// @Param body body request.VerifyEmailRequest true "Verification token"
+// @Success 204 "Email verified"
+// @Failure 400 {object} response.AuthErrorResponse "Invalid or expired token"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/verify-email [post]
+func VerifyEmailHandler(c *gin.Context) {
+	logger.Log.Info("VerifyEmailHandler")
+
+	req := c.MustGet("dto").(*request.VerifyEmailRequest)
+
+	if err := auth_service.VerifyEmail(req.Token); err != nil {
+		handleAuthTokenError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ResendEmailVerificationHandler godoc
+// @Summary Resend the email verification link
+// @Description Mails a new verification link and invalidates earlier ones. The response is the same whether or not the email has an unverified account.
+// @Tags Auth
+// @Accept json
+// @Param body body request.EmailRequest true "Email"
+// @Success 202 "Email sent if the account exists"
+// @Failure 422 {object} response.ErrorResponse "Validation error"
+// @Router /auth/verify-email/resend [post]
+func ResendEmailVerificationHandler(c *gin.Context) {
+	logger.Log.Info("ResendEmailVerificationHandler")
+
+	req := c.MustGet("dto").(*request.EmailRequest)
+
+	if err := auth_service.SendEmailVerification(req.Email); err != nil {
+		logger.Log.Error("Error sending verification email: ", err)
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// ForgotPasswordHandler godoc
+// @Summary Request a password reset
+// @Description Mails a password reset link that works once and expires after an hour. The response is the same whether or not the email has an account.
+// @Tags Auth
+// @Accept json
+// @Param body body request.EmailRequest true "Email"
+// @Success 202 "Email sent if the account exists"
+// @Failure 422 {object} response.ErrorResponse "Validation error"
+// @Router /auth/password/forgot [post]
+func ForgotPasswordHandler(c *gin.Context) {
+	logger.Log.Info("ForgotPasswordHandler")
+
+	req := c.MustGet("dto").(*request.EmailRequest)
+
+	if err := auth_service.RequestPasswordReset(req.Email); err != nil {
+		logger.Log.Error("Error sending password reset email: ", err)
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// ResetPasswordHandler godoc
+// @Summary Set a new password
+// @Description Consumes the token from a password reset link and replaces the password.
+// @Tags Auth
+// @Accept json
+// @Param body body request.ResetPasswordRequest true "Reset token and new password"
+// @Success 204 "Password changed"
+// @Failure 400 {object} response.AuthErrorResponse "Invalid or expired token"
+// @Failure 422 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/password/reset [post]
+func ResetPasswordHandler(c *gin.Context) {
+	logger.Log.Info("ResetPasswordHandler")
+
+	req := c.MustGet("dto").(*request.ResetPasswordRequest)
+
+	if err := auth_service.ResetPassword(req.Token, req.Password); err != nil {
+		handleAuthTokenError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func handleAuthTokenError(c *gin.Context, err error) {
+	if errors.Is(err, auth_service.ErrInvalidToken) {
+		c.JSON(http.StatusBadRequest, response.AuthErrorResponse{
+			Error:        "invalid or expired token",
+			InternalCode: errorCodes.CodeInvalidAuthToken,
+		})
+		return
+	}
+
+	logger.Log.Error(err)
+	c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Internal Server Error"})
+}
+
+// RefreshTokenHandler godoc
+// @Summary Renew the access token
+// @Description Exchanges a refresh token for a new access token and a new refresh token. Each refresh token works once; presenting a used one again revokes its session.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body request.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} response.TokenResponse
+// @Failure 401 {object} response.AuthErrorResponse "Invalid, expired or reused refresh token"
+// @Failure 422 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/refresh [post]
+func RefreshTokenHandler(c *gin.Context) {
+	logger.Log.Info("RefreshTokenHandler")
+
+	req := c.MustGet("dto").(*request.RefreshTokenRequest)
+
+	tokens, err := auth_service.RefreshSession(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth_service.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, response.AuthErrorResponse{
+				Error:        "invalid or expired refresh token",
+				InternalCode: errorCodes.CodeInvalidRefreshToken,
+			})
+			return
+		}
+
+		logger.Log.Error("Error refreshing session: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.AccessTokenExpiresAt.Unix(),
+	})
+}
+
+// LogoutHandler godoc
+// @Summary Log out
+// @Description Revokes the session of the access token. Its access and refresh tokens stop working immediately.
+// @Tags Auth
+// @Security BearerAuth
+// @Success 204 "Logged out"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/logout [post]
+func LogoutHandler(c *gin.Context) {
+	logger.Log.Info("LogoutHandler")
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	sessionID, err := util.GetSessionIDFromContext(c)
+	if err != nil {
+		logger.Log.Error(err)
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	if err := auth_service.Logout(user.ID, sessionID); err != nil {
+		logger.Log.Error("Error logging out: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAllHandler godoc
+// @Summary Log out of all sessions
+// @Description Revokes every session of the authenticated user, on every device, including the current one.
+// @Tags Auth
+// @Security BearerAuth
+// @Success 204 "Logged out everywhere"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/logout-all [post]
+func LogoutAllHandler(c *gin.Context) {
+	logger.Log.Info("LogoutAllHandler")
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	if err := auth_service.LogoutAll(user.ID); err != nil {
+		logger.Log.Error("Error logging out of all sessions: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
 }
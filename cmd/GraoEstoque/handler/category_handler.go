@@ -4,9 +4,11 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/category_repository"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/error_handler"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
@@ -14,6 +16,7 @@ import (
 	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
 	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // GetCategories godoc
@@ -23,8 +26,17 @@ import (
 // @Tags         Category
 // @Produce      json
 // @Param        X-Store-ID  header    string  true  "Store ID"
+// @Param        include_archived  query  bool  false  "Include archived categories"
+// @Param        tree        query     bool    false  "Nest categories under their parents; returns the whole tree, ignoring pagination and filters"
+// @Param        limit       query     int     false  "Page size (1-200, default 20)"
+// @Param        cursor      query     string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset      query     int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort        query     string  false  "Comma-separated sort fields, prefix with - for descending (id, description, created_at)"
+// @Param        filter.{field}  query  string  false  "Filter by parent_id or created_at; comma-separate values to match any, numeric and date fields also accept .min/.max"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
 // @Success      200  {array} response.CategoryResponse
-// @Failure      400  {object} response.ErrorResponse "Invalid or missing store ID"
+// @Failure      400  {object} response.InvalidListQueryErrorResponse "Invalid or missing store ID, or invalid list query"
 // @Failure      401  {object} response.ErrorResponse "Unauthorized"
 // @Failure      500  {object} response.ErrorResponse "Internal server error"
 // @Router       /items/categories [get]
@@ -55,17 +67,36 @@ func GetCategories(c *gin.Context) {
 		return
 	}
 
+	includeArchived, _ := strconv.ParseBool(c.DefaultQuery("include_archived", "false"))
+	tree, _ := strconv.ParseBool(c.DefaultQuery("tree", "false"))
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	cats, err := category_repository.ListCategories(conn, user.ID, storeID)
+	// A tree needs every category, so paging only applies to the flat list
+	lq := list_query.Unpaged(category_repository.CategoryListSpec)
+	if !tree {
+		var ok bool
+		if lq, ok = handler_util.ParseListQuery(c, category_repository.CategoryListSpec); !ok {
+			return
+		}
+	}
+
+	cats, page, err := category_repository.ListCategories(conn, user.ID, storeID, includeArchived, lq)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "could not list categories"})
 		return
 	}
 
+	handler_util.SetPageHeaders(c, page)
+
+	if tree {
+		c.JSON(http.StatusOK, mapper.ToCategoryTreeResponse(cats))
+		return
+	}
+
 	// map slice of model → slice of DTO
 	resp := make([]response.CategoryResponse, len(cats))
 	for i, cat := range cats {
@@ -95,12 +126,17 @@ func GetCategoryByID(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	cat, err := category_repository.GetCategoryByID(conn, uint(id))
+	cat, err := category_repository.GetCategoryByID(conn, uint(id), storeID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to fetch"})
 		return
@@ -123,6 +159,7 @@ func GetCategoryByID(c *gin.Context) {
 // @Success 200 {object} response.CategoryResponse
 // @Failure      400  {object} response.ErrorResponse "Invalid or missing store ID"
 // @Failure      401  {object} response.ErrorResponse "Unauthorized"
+// @Failure      422  {object} response.InvalidCategoryHierarchyErrorResponse "Invalid parent category"
 // @Failure 	 500  {object} response.ErrorResponse "Internal Server Error"
 // @Router /items/categories [post]
 func CreateCategory(c *gin.Context) {
@@ -163,6 +200,9 @@ func CreateCategory(c *gin.Context) {
 	}
 
 	if err := category_repository.SaveCategory(conn, modelCat); err != nil {
+		if error_handler.HandleCategoryHierarchyError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "could not save"})
 		return
 	}
@@ -203,11 +243,17 @@ func UpdateCategory(c *gin.Context) {
 	cat := c.MustGet("dto").(*request.UpdateCategoryRequest)
 	catModel := mapper.UpdateCategoryToModel(cat, user.ID)
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
+	catModel.Store.ID = storeID
 	updatedCategory, err := category_repository.UpdateCategory(conn, user.ID, catModel)
 
 	if err != nil {
@@ -242,27 +288,186 @@ func DeleteCategory(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	if err := category_repository.DeleteCategory(conn, uint(id)); err != nil {
+	if err := category_repository.DeleteCategory(conn, uint(id), storeID); err != nil {
 		logger.Log.Error("Error DeleteCategory: ", err)
 		error_handler.HandleDBErrorWithReferencingFetcher(c,
 			err,
 			uint(id),
-			category_repository.GetReferencingItems,
+			func(conn *pgxpool.Conn, id uint) (any, error) {
+				// A category is blocked either by its items or by its subcategories
+				items, err := category_repository.GetReferencingItems(conn, id)
+				if err != nil || len(items.([]model.Item)) > 0 {
+					return items, err
+				}
+				return category_repository.GetChildCategories(conn, id)
+			},
 			func(entities any) any {
-				internal := entities.([]model.Item)
-				var dtos []response.ItemResponse
-				for _, i := range internal {
-					dtos = append(dtos, mapper.ToItemResponse(&i))
+				switch internal := entities.(type) {
+				case []model.Item:
+					var dtos []response.ItemResponse
+					for _, i := range internal {
+						dtos = append(dtos, mapper.ToItemResponse(&i))
+					}
+					return dtos
+				case []*model.Category:
+					var dtos []*response.CategoryResponse
+					for _, cat := range internal {
+						dtos = append(dtos, mapper.ToCategoryResponse(cat))
+					}
+					return dtos
 				}
-				return dtos
+				return entities
 			})
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
+
+// MoveCategory godoc
+// @Summary      Move a category
+// @Description  Re-parents a category together with its whole subtree. A null parent_id makes it a root category. Moving a category under itself or one of its descendants is rejected.
+// @Security     BearerAuth
+// @Tags         Category
+// @Accept       json
+// @Produce      json
+// @Param        id          path      int                          true  "Category ID"
+// @Param        X-Store-ID  header    string                       true  "Store ID"
+// @Param        data        body      request.MoveCategoryRequest  true  "New parent"
+// @Success      200  {object} response.CategoryResponse
+// @Failure      400  {object} response.ErrorResponse "Invalid ID"
+// @Failure      404  {object} response.ErrorResponse "Category not found"
+// @Failure      422  {object} response.InvalidCategoryHierarchyErrorResponse "Move would create a cycle"
+// @Failure      500  {object} response.ErrorResponse "Internal server error"
+// @Router       /items/categories/move/{id} [patch]
+func MoveCategory(c *gin.Context) {
+	logger.Log.Info("MoveCategory")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid id"})
+		return
+	}
+
+	// pulled from middleware
+	req := c.MustGet("dto").(*request.MoveCategoryRequest)
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	cat, err := category_repository.MoveCategory(conn, uint(id), storeID, req.ParentID)
+	if err != nil {
+		if error_handler.HandleCategoryHierarchyError(c, err) {
+			return
+		}
+		error_handler.HandleDBErrorWithReferencingFetcher(c, err, uint(id), nil, nil)
+		return
+	} else if cat == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToCategoryResponse(cat))
+}
+
+// ArchiveCategory godoc
+// @Summary      Archive a category
+// @Description  Archives a category instead of deleting it. Archived categories are hidden from listings and cannot be assigned to items, but existing items keep them.
+// @Security     BearerAuth
+// @Tags         Category
+// @Produce      json
+// @Param        id          path      int     true  "Category ID"
+// @Param        X-Store-ID  header    string  true  "Store ID"
+// @Success      200  {object} response.CategoryResponse
+// @Failure      400  {object} response.ErrorResponse "Invalid ID"
+// @Failure      404  {object} response.ErrorResponse "Category not found"
+// @Failure      500  {object} response.ErrorResponse "Internal server error"
+// @Router       /items/categories/archive/{id} [patch]
+func ArchiveCategory(c *gin.Context) {
+	logger.Log.Info("ArchiveCategory")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid id"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	cat, err := category_repository.ArchiveCategory(conn, uint(id), storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "could not archive"})
+		return
+	} else if cat == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToCategoryResponse(cat))
+}
+
+// UnarchiveCategory godoc
+// @Summary      Unarchive a category
+// @Description  Restores an archived category so it can be assigned to items again
+// @Security     BearerAuth
+// @Tags         Category
+// @Produce      json
+// @Param        id          path      int     true  "Category ID"
+// @Param        X-Store-ID  header    string  true  "Store ID"
+// @Success      200  {object} response.CategoryResponse
+// @Failure      400  {object} response.ErrorResponse "Invalid ID"
+// @Failure      404  {object} response.ErrorResponse "Category not found"
+// @Failure      500  {object} response.ErrorResponse "Internal server error"
+// @Router       /items/categories/unarchive/{id} [patch]
+func UnarchiveCategory(c *gin.Context) {
+	logger.Log.Info("UnarchiveCategory")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid id"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	cat, err := category_repository.UnarchiveCategory(conn, uint(id), storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "could not unarchive"})
+		return
+	} else if cat == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToCategoryResponse(cat))
+}
@@ -0,0 +1,383 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/invitation_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/store_repository"
+	mapper "github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
+	dtoRequest "github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
+	dtoResponse "github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/auth_service"
+	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateInvitation godoc
+// @Summary      Invite a user into the organization
+// @Description  Mails an invitation link to the email. Accepting it attaches the user to the organization with the role, in the whole organization or only in the given store. Links expire after 7 days; an expired invitation for the email is replaced.
+// @Security     BearerAuth
+// @Tags         Invitations
+// @Accept       json
+// @Produce      json
+// @Param        data  body  dtoRequest.CreateInvitationRequest  true  "Invitation"
+// @Success      201  {object}  dtoResponse.InvitationResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input"
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Store not found"
+// @Failure      409  {object}  dtoResponse.AuthErrorResponse "Already a member, or an invitation is already pending for the email"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /invitations [post]
+func CreateInvitation(c *gin.Context) {
+	logger.Log.Info("CreateInvitation")
+
+	req := c.MustGet("dto").(*dtoRequest.CreateInvitationRequest)
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	if req.StoreID != nil {
+		store, err := store_repository.GetStoreByID(conn, *req.StoreID)
+		if err != nil {
+			logger.Log.Error("Error getting store: ", err)
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+			return
+		} else if store == nil {
+			c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Store not found"})
+			return
+		}
+	}
+
+	invitation := &model.Invitation{
+		Organization: model.Organization{ID: user.Organization.ID},
+		Email:        req.Email,
+		Role:         model.Role(req.Role),
+		StoreID:      req.StoreID,
+		InvitedBy:    &user.ID,
+	}
+
+	if err := auth_service.CreateInvitation(invitation); err != nil {
+		handleInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, mapper.ToInvitationResponse(invitation))
+}
+
+// ListInvitations godoc
+// @Summary      List invitations
+// @Description  Lists every invitation of the organization, newest first, with its status.
+// @Security     BearerAuth
+// @Tags         Invitations
+// @Produce      json
+// @Success      200  {array}   dtoResponse.InvitationResponse
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /invitations [get]
+func ListInvitations(c *gin.Context) {
+	logger.Log.Info("ListInvitations")
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	invitations, err := invitation_repository.ListInvitations(user.Organization.ID)
+	if err != nil {
+		logger.Log.Error("Error listing invitations: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToInvitationResponseList(invitations))
+}
+
+// ResendInvitation godoc
+// @Summary      Resend an invitation
+// @Description  Mails a new link for an open invitation, even an expired one, and gives it another 7 days. Earlier links stop working.
+// @Security     BearerAuth
+// @Tags         Invitations
+// @Produce      json
+// @Param        id   path  int  true  "Invitation ID"
+// @Success      200  {object}  dtoResponse.InvitationResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Open invitation not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /invitations/{id}/resend [post]
+func ResendInvitation(c *gin.Context) {
+	logger.Log.Info("ResendInvitation")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Id must be a number"})
+		return
+	}
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	invitation, err := auth_service.ResendInvitation(uint(id), user.Organization.ID, user.ID)
+	if err != nil {
+		logger.Log.Error("Error resending invitation: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+	if invitation == nil {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Open invitation not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToInvitationResponse(invitation))
+}
+
+// RevokeInvitation godoc
+// @Summary      Revoke an invitation
+// @Description  Stops an open invitation from being accepted.
+// @Security     BearerAuth
+// @Tags         Invitations
+// @Param        id   path  int  true  "Invitation ID"
+// @Success      204  "Invitation revoked"
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Open invitation not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /invitations/{id} [delete]
+func RevokeInvitation(c *gin.Context) {
+	logger.Log.Info("RevokeInvitation")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Id must be a number"})
+		return
+	}
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	found, err := invitation_repository.RevokeInvitation(uint(id), user.Organization.ID, user.ID)
+	if err != nil {
+		logger.Log.Error("Error revoking invitation: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Open invitation not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetInvitationHandler godoc
+// @Summary      Look up an invitation
+// @Description  Returns the organization, email and role of an invitation link, so the invitee can decide how to accept it.
+// @Tags         Auth
+// @Produce      json
+// @Param        token  path  string  true  "Invitation token"
+// @Success      200  {object}  dtoResponse.InvitationPreviewResponse
+// @Failure      400  {object}  dtoResponse.AuthErrorResponse "Invalid, expired, revoked or accepted invitation"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /auth/invitations/{token} [get]
+func GetInvitationHandler(c *gin.Context) {
+	logger.Log.Info("GetInvitationHandler")
+
+	invitation, err := auth_service.GetInvitation(c.Param("token"))
+	if err != nil {
+		handleInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToInvitationPreviewResponse(invitation))
+}
+
+// AcceptInvitationHandler godoc
+// @Summary      Accept an invitation
+// @Description  Attaches the authenticated user to the inviting organization. Only users still in a try-out can switch organizations. Every session of the user is revoked, the returned tokens belong to a new one.
+// @Tags         Auth
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        data  body  dtoRequest.AcceptInvitationRequest  true  "Invitation token"
+// @Success      200  {object}  dtoResponse.LoginResponse
+// @Success      202  {object}  dtoResponse.TwoFactorChallengeResponse "Second factor needed"
+// @Failure      400  {object}  dtoResponse.AuthErrorResponse "Invalid or expired invitation"
+// @Failure      403  {object}  dtoResponse.AuthErrorResponse "Invitation sent to another email"
+// @Failure      409  {object}  dtoResponse.AuthErrorResponse "Already a member, or a member of another organization"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /auth/invitations/accept [post]
+func AcceptInvitationHandler(c *gin.Context) {
+	logger.Log.Info("AcceptInvitationHandler")
+
+	req := c.MustGet("dto").(*dtoRequest.AcceptInvitationRequest)
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	member, err := auth_service.AcceptInvitation(req.Token, user.ID)
+	if err != nil {
+		handleInvitationError(c, err)
+		return
+	}
+
+	loginInvitedUser(c, member, http.StatusOK)
+}
+
+// RegisterWithInvitationHandler godoc
+// @Summary      Sign up from an invitation
+// @Description  Creates a local user in the inviting organization with the invited email and role. The invitation proves the email address, so the user is logged in right away.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        data  body  dtoRequest.RegisterWithInvitationRequest  true  "Invitation token and new user"
+// @Success      201  {object}  dtoResponse.LoginResponse
+// @Success      202  {object}  dtoResponse.TwoFactorChallengeResponse "Second factor needed"
+// @Failure      400  {object}  dtoResponse.AuthErrorResponse "Invalid or expired invitation"
+// @Failure      409  {object}  dtoResponse.AuthErrorResponse "Email already registered"
+// @Failure      422  {object}  dtoResponse.ErrorResponse "Validation error"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /auth/invitations/register [post]
+func RegisterWithInvitationHandler(c *gin.Context) {
+	logger.Log.Info("RegisterWithInvitationHandler")
+
+	req := c.MustGet("dto").(*dtoRequest.RegisterWithInvitationRequest)
+
+	user := &model.User{
+		GivenName:  req.GivenName,
+		FamilyName: req.FamilyName,
+	}
+
+	member, err := auth_service.RegisterWithInvitation(req.Token, user, req.Password)
+	if err != nil {
+		handleInvitationError(c, err)
+		return
+	}
+
+	loginInvitedUser(c, member, http.StatusCreated)
+}
+
+func loginInvitedUser(c *gin.Context, user *model.User, status int) {
+	if auth_service.TwoFactorRequired(user) {
+		sendTwoFactorChallenge(c, user)
+		return
+	}
+
+	tokens, err := auth_service.StartSession(user, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		logger.Log.Error("failed to start session: ", err.Error())
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to generate token"})
+		return
+	}
+
+	c.JSON(status, dtoResponse.LoginResponse{
+		Token:          tokens.AccessToken,
+		RefreshToken:   tokens.RefreshToken,
+		ExpiresAt:      tokens.AccessTokenExpiresAt.Unix(),
+		Name:           user.GivenName,
+		Email:          user.Email,
+		UserPictureURL: user.PictureURL,
+		IsTryOut:       user.Organization.IsTryOut,
+	})
+}
+
+// invitationErrorResponse maps the invitation errors of auth_service to a
+// status and body. ok is false for any other error.
+func invitationErrorResponse(err error) (status int, resp dtoResponse.AuthErrorResponse, ok bool) {
+	switch {
+	case errors.Is(err, auth_service.ErrInvalidInvitation):
+		return http.StatusBadRequest, dtoResponse.AuthErrorResponse{
+			Error:        "invalid or expired invitation",
+			InternalCode: errorCodes.CodeInvalidInvitation,
+		}, true
+	case errors.Is(err, auth_service.ErrInvitationEmailMismatch):
+		return http.StatusForbidden, dtoResponse.AuthErrorResponse{
+			Error:        "the invitation was sent to another email",
+			InternalCode: errorCodes.CodeInvitationEmailMismatch,
+		}, true
+	case errors.Is(err, auth_service.ErrInvitationPending):
+		return http.StatusConflict, dtoResponse.AuthErrorResponse{
+			Error:        "the email already has a pending invitation, resend it instead",
+			InternalCode: errorCodes.CodeInvitationAlreadyOpen,
+		}, true
+	case errors.Is(err, auth_service.ErrAlreadyMember):
+		return http.StatusConflict, dtoResponse.AuthErrorResponse{
+			Error:        "the user already belongs to the organization",
+			InternalCode: errorCodes.CodeAlreadyOrganizationMember,
+		}, true
+	case errors.Is(err, auth_service.ErrUserInAnotherOrganization):
+		return http.StatusConflict, dtoResponse.AuthErrorResponse{
+			Error:        "the user already belongs to another organization",
+			InternalCode: errorCodes.CodeUserInAnotherOrganization,
+		}, true
+	case errors.Is(err, auth_service.ErrEmailTaken):
+		return http.StatusConflict, dtoResponse.AuthErrorResponse{
+			Error:        "email already registered, log in to accept the invitation",
+			InternalCode: errorCodes.CodeEmailAlreadyRegistered,
+		}, true
+	}
+
+	return 0, dtoResponse.AuthErrorResponse{}, false
+}
+
+func handleInvitationError(c *gin.Context, err error) {
+	if status, resp, ok := invitationErrorResponse(err); ok {
+		c.JSON(status, resp)
+		return
+	}
+
+	logger.Log.Error(err)
+	c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+}
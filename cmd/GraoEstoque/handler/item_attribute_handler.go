@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	dataErrors "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/item_attribute_service"
+	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/item_attribute_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/error_handler"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+)
+
+// ListItemAttributeDefinitions godoc
+// @Summary      List item attribute definitions
+// @Description  Retrieves the custom attribute fields defined for items of the store
+// @Security     BearerAuth
+// @Tags         Item Attribute
+// @Produce      json
+// @Param        X-Store-ID  header    string  true   "Store ID"
+// @Param        limit       query   int     false  "Page size (1-200, default 20)"
+// @Param        cursor      query   string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset      query   int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort        query   string  false  "Comma-separated sort fields, prefix with - for descending (id, key, label, created_at)"
+// @Param        filter.{field}  query  string  false  "Filter by key, type, is_required or created_at; comma-separate values to match any, date fields also accept .min/.max"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
+// @Success      200  {array}  response.ItemAttributeDefinitionResponse
+// @Failure      400  {object}  response.InvalidListQueryErrorResponse "Invalid or missing store ID, or invalid list query"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /items/attributes [get]
+func ListItemAttributeDefinitions(c *gin.Context) {
+	logger.Log.Info("ListItemAttributeDefinitions")
+
+	storeID, err := util.GetStoreIDFromContext(c)
+	if err != nil {
+		if err == util.ErrNoStoreID {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "store id not found"})
+		} else {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid store id"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	lq, ok := handler_util.ParseListQuery(c, item_attribute_repository.ItemAttributeDefinitionListSpec)
+	if !ok {
+		return
+	}
+
+	defs, page, err := item_attribute_repository.ListItemAttributeDefinitions(conn, storeID, lq)
+	if err != nil {
+		logger.Log.Error("Error listing item attribute definitions: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error listing item attribute definitions"})
+		return
+	}
+
+	resp := make([]response.ItemAttributeDefinitionResponse, len(defs))
+	for i, d := range defs {
+		resp[i] = mapper.ToItemAttributeDefinitionResponse(&d)
+	}
+
+	handler_util.SetPageHeaders(c, page)
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetItemAttributeDefinitionByID godoc
+// @Summary      Get item attribute definition by ID
+// @Description  Retrieves a specific custom attribute field by its ID
+// @Security     BearerAuth
+// @Tags         Item Attribute
+// @Produce      json
+// @Param        id          path     int     true  "Attribute definition ID"
+// @Param        X-Store-ID  header   string  true  "Store ID"
+// @Success      200  {object}  response.ItemAttributeDefinitionResponse
+// @Failure      400  {object}  response.ErrorResponse "Invalid ID"
+// @Failure      404  {object}  response.ErrorResponse "Item attribute definition not found"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /items/attributes/{id} [get]
+func GetItemAttributeDefinitionByID(c *gin.Context) {
+	logger.Log.Info("GetItemAttributeDefinitionByID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	def, err := item_attribute_repository.GetItemAttributeDefinitionByID(conn, uint(id), storeID)
+	if err != nil {
+		logger.Log.Error("Error retrieving item attribute definition: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error retrieving item attribute definition"})
+		return
+	}
+	if def == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Item attribute definition not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToItemAttributeDefinitionResponse(def))
+}
+
+// CreateItemAttributeDefinition godoc
+// @Summary      Create an item attribute definition
+// @Description  Defines a new typed custom attribute (text, number, enum or date) for items of the store
+// @Security     BearerAuth
+// @Tags         Item Attribute
+// @Accept       json
+// @Produce      json
+// @Param        X-Store-ID  header  string                                        true  "Store ID"
+// @Param        data        body    request.CreateItemAttributeDefinitionRequest  true  "Attribute definition payload"
+// @Success      201  {object}  response.ItemAttributeDefinitionResponse
+// @Failure      400  {object}  response.ErrorResponse "Invalid input or store ID"
+// @Failure      401  {object}  response.ErrorResponse "Unauthorized"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /items/attributes [post]
+func CreateItemAttributeDefinition(c *gin.Context) {
+	logger.Log.Info("CreateItemAttributeDefinition")
+
+	req := c.MustGet("dto").(*request.CreateItemAttributeDefinitionRequest)
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	storeID, err := util.GetStoreIDFromContext(c)
+	if err != nil {
+		if err == util.ErrNoStoreID {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "store id not found"})
+		} else {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid store id"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	def := mapper.CreateItemAttributeDefinitionToModel(req, user.ID, storeID)
+	if err := item_attribute_repository.SaveItemAttributeDefinition(conn, def); err != nil {
+		logger.Log.Error("Error saving item attribute definition: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error saving item attribute definition"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, mapper.ToItemAttributeDefinitionResponse(def))
+}
+
+// UpdateItemAttributeDefinition godoc
+// @Summary      Update an item attribute definition
+// @Description  Updates label, enum options, requirement and bounds of a custom attribute. Key and type cannot change.
+// @Security     BearerAuth
+// @Tags         Item Attribute
+// @Accept       json
+// @Produce      json
+// @Param        X-Store-ID  header  string                                        true  "Store ID"
+// @Param        data        body    request.UpdateItemAttributeDefinitionRequest  true  "Attribute definition update payload"
+// @Success      200  {object}  response.ItemAttributeDefinitionResponse
+// @Failure      400  {object}  response.ErrorResponse "Invalid input"
+// @Failure      404  {object}  response.ErrorResponse "Item attribute definition not found"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /items/attributes [put]
+func UpdateItemAttributeDefinition(c *gin.Context) {
+	logger.Log.Info("UpdateItemAttributeDefinition")
+
+	req := c.MustGet("dto").(*request.UpdateItemAttributeDefinitionRequest)
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	def := mapper.UpdateItemAttributeDefinitionToModel(req)
+	def.StoreID = storeID
+
+	updated, err := item_attribute_repository.UpdateItemAttributeDefinition(conn, def)
+	if err != nil {
+		logger.Log.Error("Error updating item attribute definition: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error updating item attribute definition"})
+		return
+	}
+	if updated == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Item attribute definition not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToItemAttributeDefinitionResponse(updated))
+}
+
+// DeleteItemAttributeDefinition godoc
+// @Summary      Delete an item attribute definition
+// @Description  Deletes a custom attribute field. Returns 409 if items still hold a value for it.
+// @Security     BearerAuth
+// @Tags         Item Attribute
+// @Produce      json
+// @Param        id          path     int     true  "Attribute definition ID"
+// @Param        X-Store-ID  header   string  true  "Store ID"
+// @Success      204  "Item attribute definition deleted successfully"
+// @Failure      400  {object}  response.ErrorResponse "Invalid ID"
+// @Failure      409  {object}  response.ForeignKeyDeleteReferencedErrorResponse "Attribute is still set on items"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /items/attributes/{id} [delete]
+func DeleteItemAttributeDefinition(c *gin.Context) {
+	logger.Log.Info("DeleteItemAttributeDefinition")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	if err := item_attribute_repository.DeleteItemAttributeDefinition(conn, uint(id), storeID); err != nil {
+		logger.Log.Error("Error deleting item attribute definition: ", err)
+		error_handler.HandleDBErrorWithReferencingFetcher(c,
+			err,
+			uint(id),
+			item_attribute_repository.GetReferencingItems,
+			func(entities any) any {
+				internal := entities.([]model.Item)
+				var dtos []response.ItemResponse
+				for _, i := range internal {
+					dtos = append(dtos, mapper.ToItemResponse(&i))
+				}
+				return dtos
+			})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// parseItemAttributeFilters reads attr.* query parameters against the store's
+// attribute definitions. On invalid filters it writes a 400 and returns false.
+func parseItemAttributeFilters(c *gin.Context, conn *pgxpool.Conn, storeID uint) ([]model.ItemAttributeFilter, bool) {
+	query := c.Request.URL.Query()
+	if !item_attribute_service.HasFilters(query) {
+		return nil, true
+	}
+
+	defs, err := item_attribute_repository.AllItemAttributeDefinitions(conn, storeID)
+	if err != nil {
+		logger.Log.Error("Error fetching item attribute definitions: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Internal Server Error"})
+		return nil, false
+	}
+
+	filters, err := item_attribute_service.ParseFilters(defs, query)
+	if err != nil {
+		logger.Log.Error("Invalid item attribute filter: ", err)
+		resp := response.InvalidItemAttributeErrorResponse{
+			Error:        "Invalid item attribute filter.",
+			InternalCode: dataErrors.CodeInvalidItemAttribute,
+			Details:      err.Error(),
+		}
+		if attrErr, ok := err.(*dataErrors.InvalidItemAttribute); ok {
+			resp.Attribute = attrErr.Key
+		}
+		c.JSON(http.StatusBadRequest, resp)
+		return nil, false
+	}
+
+	return filters, true
+}
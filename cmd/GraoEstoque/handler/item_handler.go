@@ -4,7 +4,9 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/item_attribute_repository"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/item_repository"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/error_handler"
 	mapper "github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
@@ -12,6 +14,7 @@ import (
 	dtoResponse "github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/item_attribute_service"
 	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
 	"github.com/gin-gonic/gin"
 )
@@ -23,8 +26,18 @@ import (
 // @Tags         Item
 // @Produce      json
 // @Param        X-Store-ID  header    string  true  "Store ID"
+// @Param        include_archived  query  bool  false  "Include archived items"
+// @Param        q  query  string  false  "Accent-insensitive, typo-tolerant search on description, EAN and category; results are ranked by relevance"
+// @Param        attr.{key}  query  string  false  "Filter by custom attribute; number and date attributes also accept attr.{key}.min and attr.{key}.max"
+// @Param        limit       query   int     false  "Page size (1-200, default 20)"
+// @Param        cursor      query   string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset      query   int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort        query   string  false  "Comma-separated sort fields, prefix with - for descending (id, description, created_at, updated_at, relevance); defaults to relevance when searching"
+// @Param        filter.{field}  query  string  false  "Filter by category_id, unit_id, ean13, is_fractionable or created_at; comma-separate values to match any, numeric and date fields also accept .min/.max"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
 // @Success      200  {array}  dtoResponse.ItemResponse
-// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid or missing store ID"
+// @Failure      400  {object}  dtoResponse.InvalidItemAttributeErrorResponse "Invalid or missing store ID, invalid attribute filter or invalid list query"
 // @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /items [get]
@@ -55,12 +68,29 @@ func GetItems(c *gin.Context) {
 		return
 	}
 
+	includeArchived, _ := strconv.ParseBool(c.DefaultQuery("include_archived", "false"))
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	items, err := item_repository.ListItems(conn, user.ID, storeID)
+	filters, ok := parseItemAttributeFilters(c, conn, storeID)
+	if !ok {
+		return
+	}
+
+	search := c.Query("q")
+	spec := item_repository.ItemListSpec
+	if search != "" {
+		spec = item_repository.ItemSearchListSpec
+	}
+	lq, ok := handler_util.ParseListQuery(c, spec)
+	if !ok {
+		return
+	}
+
+	items, page, err := item_repository.ListItems(conn, user.ID, storeID, includeArchived, filters, search, lq)
 	if err != nil {
 		logger.Log.Error("Error fetching items: ", err)
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
@@ -73,6 +103,7 @@ func GetItems(c *gin.Context) {
 		rep[i] = mapper.ToItemResponse(&item)
 	}
 
+	handler_util.SetPageHeaders(c, page)
 	c.JSON(http.StatusOK, rep)
 }
 
@@ -97,12 +128,17 @@ func GetItemByID(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	item, err := item_repository.GetItemByID(conn, uint(id))
+	item, err := item_repository.GetItemByID(conn, uint(id), storeID)
 	if err != nil {
 		logger.Log.Error("Error fetching item: ", err)
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
@@ -127,6 +163,9 @@ func GetItemByID(c *gin.Context) {
 // @Success      201  {object}  dtoResponse.ItemResponse
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input or missing store ID"
 // @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      422  {object}  dtoResponse.ArchivedEntityReferencedErrorResponse "Category or unit is archived"
+// @Failure      422  {object}  dtoResponse.InvalidItemAttributeErrorResponse "Invalid custom attribute value"
+// @Failure      422  {object}  dtoResponse.StoreMismatchErrorResponse "Category or unit belongs to another store"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /items [post]
 func CreateItem(c *gin.Context) {
@@ -167,8 +206,31 @@ func CreateItem(c *gin.Context) {
 		return
 	}
 
+	defs, err := item_attribute_repository.AllItemAttributeDefinitions(conn, storeID)
+	if err != nil {
+		logger.Log.Error("Error fetching item attribute definitions: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	modelItem.Attributes, err = item_attribute_service.ResolveValues(defs, req.Attributes)
+	if err != nil {
+		logger.Log.Error("Invalid item attributes: ", err)
+		error_handler.HandleItemAttributeError(c, err)
+		return
+	}
+
 	if err := item_repository.SaveItem(conn, modelItem); err != nil {
 		logger.Log.Error("Error saving item: ", err)
+		if error_handler.HandleArchivedReferenceError(c, err) {
+			return
+		}
+		if error_handler.HandleStoreMismatchError(c, err) {
+			return
+		}
+		if error_handler.HandleItemAttributeError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
 		return
 	}
@@ -178,7 +240,7 @@ func CreateItem(c *gin.Context) {
 
 // UpdateItem godoc
 // @Summary      Update an item
-// @Description  Updates an existing item for the authenticated user
+// @Description  Updates an existing item for the authenticated user. When attributes is sent it replaces every custom attribute of the item; when omitted they are kept.
 // @Security     BearerAuth
 // @Tags         Item
 // @Accept       json
@@ -188,6 +250,9 @@ func CreateItem(c *gin.Context) {
 // @Success      200  {object}  dtoResponse.ItemResponse
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input or store ID"
 // @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      422  {object}  dtoResponse.ArchivedEntityReferencedErrorResponse "Category or unit is archived"
+// @Failure      422  {object}  dtoResponse.InvalidItemAttributeErrorResponse "Invalid custom attribute value"
+// @Failure      422  {object}  dtoResponse.StoreMismatchErrorResponse "Category or unit belongs to another store"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /items [put]
 func UpdateItem(c *gin.Context) {
@@ -209,15 +274,46 @@ func UpdateItem(c *gin.Context) {
 	itemReq := c.MustGet("dto").(*dtoRequest.UpdateItemRequest)
 	itemModel := mapper.UpdateItemToModel(itemReq, user.ID)
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+	itemModel.Store.ID = storeID
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
+	if itemReq.Attributes != nil {
+		defs, err := item_attribute_repository.AllItemAttributeDefinitions(conn, storeID)
+		if err != nil {
+			logger.Log.Error("Error fetching item attribute definitions: ", err)
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+			return
+		}
+
+		itemModel.Attributes, err = item_attribute_service.ResolveValues(defs, itemReq.Attributes)
+		if err != nil {
+			logger.Log.Error("Invalid item attributes: ", err)
+			error_handler.HandleItemAttributeError(c, err)
+			return
+		}
+	}
+
 	updatedItem, err := item_repository.UpdateItem(conn, itemModel)
 
 	if err != nil {
 		logger.Log.Error("Error updating item: ", err)
+		if error_handler.HandleArchivedReferenceError(c, err) {
+			return
+		}
+		if error_handler.HandleStoreMismatchError(c, err) {
+			return
+		}
+		if error_handler.HandleItemAttributeError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
 		return
 	}
@@ -248,12 +344,17 @@ func DeleteItem(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	if err := item_repository.DeleteItem(conn, uint(id)); err != nil {
+	if err := item_repository.DeleteItem(conn, uint(id), storeID); err != nil {
 		logger.Log.Error("Error deleting item: ", err)
 		error_handler.HandleDBErrorWithReferencingFetcher(c,
 			err,
@@ -272,3 +373,91 @@ func DeleteItem(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// ArchiveItem godoc
+// @Summary      Archive an item
+// @Description  Archives an item instead of deleting it. Archived items are hidden from listings and cannot be used on new stock documents, but stay resolvable on existing ones.
+// @Security     BearerAuth
+// @Tags         Item
+// @Produce      json
+// @Param        id          path      int     true  "Item ID"
+// @Param        X-Store-ID  header    string  true  "Store ID"
+// @Success      200  {object}  dtoResponse.ItemResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid item ID"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Item not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /items/archive/{id} [patch]
+func ArchiveItem(c *gin.Context) {
+	logger.Log.Info("ArchiveItem")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Id should be an integer"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	item, err := item_repository.ArchiveItem(conn, uint(id), storeID)
+	if err != nil {
+		logger.Log.Error("Error archiving item: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	} else if item == nil {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Item not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToItemResponse(item))
+}
+
+// UnarchiveItem godoc
+// @Summary      Unarchive an item
+// @Description  Restores an archived item so it shows up in listings and can be used on new stock documents again
+// @Security     BearerAuth
+// @Tags         Item
+// @Produce      json
+// @Param        id          path      int     true  "Item ID"
+// @Param        X-Store-ID  header    string  true  "Store ID"
+// @Success      200  {object}  dtoResponse.ItemResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid item ID"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Item not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /items/unarchive/{id} [patch]
+func UnarchiveItem(c *gin.Context) {
+	logger.Log.Info("UnarchiveItem")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Id should be an integer"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	item, err := item_repository.UnarchiveItem(conn, uint(id), storeID)
+	if err != nil {
+		logger.Log.Error("Error unarchiving item: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	} else if item == nil {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Item not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToItemResponse(item))
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
@@ -11,13 +12,17 @@ import (
 	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
 
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/item_packaging_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/item_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/error_handler"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/packaging_service"
 	"github.com/gin-gonic/gin"
 )
 
 // CreateItemPackaging godoc
 // @Summary      Create a new item packaging
-// @Description  Creates a new packaging configuration for an item
+// @Description  Creates a new packaging configuration for an item. A packaging either holds quantity base units or inner_quantity units of another packaging of the same item, in which case quantity is derived.
 // @Security     BearerAuth
 // @Tags         Item Packaging
 // @Accept       json
@@ -27,6 +32,9 @@ import (
 // @Success      201  {object}  response.ItemPackagingResponse
 // @Failure      400  {object}  response.ErrorResponse "Invalid input or store ID"
 // @Failure      401  {object}  response.ErrorResponse "Unauthorized"
+// @Failure      422  {object}  response.ArchivedEntityReferencedErrorResponse "Item is archived"
+// @Failure      422  {object}  response.InvalidPackagingHierarchyErrorResponse "Inner packaging of another item or nesting cycle"
+// @Failure      422  {object}  response.StoreMismatchErrorResponse "Item belongs to another store"
 // @Failure      500  {object}  response.ErrorResponse "Internal server error"
 // @Router       /items/packaging [post]
 func CreateItemPackaging(c *gin.Context) {
@@ -65,6 +73,15 @@ func CreateItemPackaging(c *gin.Context) {
 
 	modelPackaging := mapper.CreateItemPackagingToModel(req, user.ID, storeID)
 	if err := item_packaging_repository.SaveItemPackaging(conn, modelPackaging); err != nil {
+		if error_handler.HandleArchivedReferenceError(c, err) {
+			return
+		}
+		if error_handler.HandleStoreMismatchError(c, err) {
+			return
+		}
+		if error_handler.HandlePackagingHierarchyError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error saving packaging"})
 		return
 	}
@@ -95,12 +112,17 @@ func GetItemPackagingByID(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	packaging, err := item_packaging_repository.GetItemPackagingByID(conn, uint(id))
+	packaging, err := item_packaging_repository.GetItemPackagingByID(conn, uint(id), storeID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error retrieving packaging"})
 		return
@@ -121,10 +143,16 @@ func GetItemPackagingByID(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        X-Store-ID  header    string  true   "Store ID"
-// @Param        offset      query     int     false  "Pagination offset"
-// @Param        limit       query     int     false  "Pagination limit"
+// @Param        include_archived  query  bool  false  "Include archived packagings"
+// @Param        limit       query   int     false  "Page size (1-200, default 20)"
+// @Param        cursor      query   string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset      query   int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort        query   string  false  "Comma-separated sort fields, prefix with - for descending (id, description, item_description, quantity, created_at)"
+// @Param        filter.{field}  query  string  false  "Filter by item_id, inner_packaging_id, quantity or created_at; comma-separate values to match any, numeric and date fields also accept .min/.max"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
 // @Success      200  {array}  response.ItemPackagingResponse
-// @Failure      400  {object}  response.ErrorResponse "Invalid store ID"
+// @Failure      400  {object}  response.InvalidListQueryErrorResponse "Invalid store ID or invalid list query"
 // @Failure      401  {object}  response.ErrorResponse "Unauthorized"
 // @Failure      500  {object}  response.ErrorResponse "Internal server error"
 // @Router       /items/packaging [get]
@@ -155,15 +183,19 @@ func ListItemPackagings(c *gin.Context) {
 		return
 	}
 
-	offset, _ := strconv.ParseUint(c.DefaultQuery("offset", "0"), 10, 0)
-	limit, _ := strconv.ParseUint(c.DefaultQuery("limit", "20"), 10, 0)
+	includeArchived, _ := strconv.ParseBool(c.DefaultQuery("include_archived", "false"))
+
+	lq, ok := handler_util.ParseListQuery(c, item_packaging_repository.ItemPackagingListSpec)
+	if !ok {
+		return
+	}
 
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 
 	}
-	packagings, err := item_packaging_repository.ListItemPackagingsPaginated(conn, user.ID, storeID, uint(offset), uint(limit))
+	packagings, page, err := item_packaging_repository.ListItemPackagings(conn, user.ID, storeID, includeArchived, lq)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error listing packagings"})
 		return
@@ -174,12 +206,13 @@ func ListItemPackagings(c *gin.Context) {
 		resp[i] = mapper.ToItemPackagingResponse(&p)
 	}
 
+	handler_util.SetPageHeaders(c, page)
 	c.JSON(http.StatusOK, resp)
 }
 
 // UpdateItemPackaging godoc
 // @Summary      Update an item packaging
-// @Description  Updates an existing item packaging configuration
+// @Description  Updates an existing item packaging configuration. Packagings containing this one are re-derived.
 // @Security     BearerAuth
 // @Tags         Item Packaging
 // @Accept       json
@@ -188,6 +221,9 @@ func ListItemPackagings(c *gin.Context) {
 // @Param        data        body    request.UpdateItemPackagingRequest  true  "Item packaging update payload"
 // @Success      200  {object}  response.ItemPackagingResponse
 // @Failure      400  {object}  response.ErrorResponse "Invalid input"
+// @Failure      422  {object}  response.ArchivedEntityReferencedErrorResponse "Item is archived"
+// @Failure      422  {object}  response.InvalidPackagingHierarchyErrorResponse "Inner packaging of another item or nesting cycle"
+// @Failure      422  {object}  response.StoreMismatchErrorResponse "Item belongs to another store"
 // @Failure      500  {object}  response.ErrorResponse "Internal server error"
 // @Router       /items/packaging [put]
 func UpdateItemPackaging(c *gin.Context) {
@@ -196,13 +232,28 @@ func UpdateItemPackaging(c *gin.Context) {
 	req := c.MustGet("dto").(*request.UpdateItemPackagingRequest)
 	itemPackModel := mapper.UpdateItemPackagingToModel(req)
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
+	itemPackModel.Store.ID = storeID
 	updated, err := item_packaging_repository.UpdateItemPackaging(conn, itemPackModel)
 	if err != nil {
+		if error_handler.HandleArchivedReferenceError(c, err) {
+			return
+		}
+		if error_handler.HandleStoreMismatchError(c, err) {
+			return
+		}
+		if error_handler.HandlePackagingHierarchyError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error updating packaging"})
 		return
 	}
@@ -232,15 +283,187 @@ func DeleteItemPackaging(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	if err := item_packaging_repository.DeleteItemPackaging(conn, uint(id)); err != nil {
+	if err := item_packaging_repository.DeleteItemPackaging(conn, uint(id), storeID); err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error deleting packaging"})
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
+
+// ArchiveItemPackaging godoc
+// @Summary      Archive an item packaging
+// @Description  Archives a packaging instead of deleting it. Archived packagings are hidden from listings and cannot be used on new stock documents.
+// @Security     BearerAuth
+// @Tags         Item Packaging
+// @Produce      json
+// @Param        id          path    int     true  "Item packaging ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Success      200  {object}  response.ItemPackagingResponse
+// @Failure      400  {object}  response.ErrorResponse "Invalid ID"
+// @Failure      404  {object}  response.ErrorResponse "Packaging not found"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /items/packaging/archive/{id} [patch]
+func ArchiveItemPackaging(c *gin.Context) {
+	logger.Log.Info("ArchiveItemPackaging")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	packaging, err := item_packaging_repository.ArchiveItemPackaging(conn, uint(id), storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error archiving packaging"})
+		return
+	}
+	if packaging == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Packaging not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToItemPackagingResponse(packaging))
+}
+
+// UnarchiveItemPackaging godoc
+// @Summary      Unarchive an item packaging
+// @Description  Restores an archived packaging so it can be used on new stock documents again
+// @Security     BearerAuth
+// @Tags         Item Packaging
+// @Produce      json
+// @Param        id          path    int     true  "Item packaging ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Success      200  {object}  response.ItemPackagingResponse
+// @Failure      400  {object}  response.ErrorResponse "Invalid ID"
+// @Failure      404  {object}  response.ErrorResponse "Packaging not found"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /items/packaging/unarchive/{id} [patch]
+func UnarchiveItemPackaging(c *gin.Context) {
+	logger.Log.Info("UnarchiveItemPackaging")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	packaging, err := item_packaging_repository.UnarchiveItemPackaging(conn, uint(id), storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error unarchiving packaging"})
+		return
+	}
+	if packaging == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Packaging not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToItemPackagingResponse(packaging))
+}
+
+// SuggestPackagingBreakdown godoc
+// @Summary      Suggest a packaging breakdown
+// @Description  Proposes how many packages of each active packaging of an item make up a total quantity, e.g. for a stock-out line. An exact match is preferred, then the fewest packages. With use_stock only packages currently held in the store are used. When no exact combination exists, exact is false and the suggestion falls short by remainder.
+// @Security     BearerAuth
+// @Tags         Item Packaging
+// @Accept       json
+// @Produce      json
+// @Param        X-Store-ID  header  string  true   "Store ID"
+// @Param        item_id     query   int     true   "Item ID"
+// @Param        quantity    query   number  true   "Total quantity in base units"
+// @Param        use_stock   query   bool    false  "Only use packages currently held in stock"
+// @Success      200  {object}  response.PackagingBreakdownResponse
+// @Failure      400  {object}  response.ErrorResponse "Invalid item ID, quantity or store ID"
+// @Failure      404  {object}  response.ErrorResponse "Item not found"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /items/packaging/breakdown [get]
+func SuggestPackagingBreakdown(c *gin.Context) {
+	logger.Log.Info("SuggestPackagingBreakdown")
+
+	storeID, err := util.GetStoreIDFromContext(c)
+	if err != nil {
+		if err == util.ErrNoStoreID {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "store id not found"})
+		} else {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid store id"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Query("item_id"), 10, 0)
+	if err != nil || itemID == 0 {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid item ID"})
+		return
+	}
+
+	quantity, err := decimal.NewFromString(c.Query("quantity"))
+	if err != nil || quantity.Sign() <= 0 {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid quantity"})
+		return
+	}
+
+	useStock, _ := strconv.ParseBool(c.DefaultQuery("use_stock", "false"))
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	item, err := item_repository.GetItemByID(conn, uint(itemID), storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error retrieving item"})
+		return
+	}
+	if item == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Item not found"})
+		return
+	}
+
+	packagings, err := item_packaging_repository.ListActiveItemPackagingsByItem(conn, item.ID, storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error listing packagings"})
+		return
+	}
+
+	var held map[uint]int64
+	if useStock {
+		held, err = item_packaging_repository.GetHeldPackagesByItem(conn, item.ID, storeID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error retrieving held packages"})
+			return
+		}
+	}
+
+	breakdown := packaging_service.SuggestBreakdown(item.ID, packagings, held, quantity)
+	c.JSON(http.StatusOK, mapper.ToPackagingBreakdownResponse(breakdown))
+}
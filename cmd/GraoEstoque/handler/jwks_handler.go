@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"net/http"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	mapper "github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler godoc
+// @Summary      Access token verification keys
+// @Description  Publishes the public keys access tokens are signed with, as a JSON Web Key Set, so other services can verify them. Tokens name their key in the kid header. During a key rotation both the old and the new key are listed.
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object}  response.JWKSResponse
+// @Router       /.well-known/jwks.json [get]
+func JWKSHandler(c *gin.Context) {
+	logger.Log.Info("JWKSHandler")
+
+	// Short enough for verifiers to pick up a new key before it signs
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, mapper.ToJWKSResponse(util.JWTVerificationKeys()))
+}
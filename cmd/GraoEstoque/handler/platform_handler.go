@@ -0,0 +1,356 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/organization_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/user_repository"
+	mapper "github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
+	dtoRequest "github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
+	dtoResponse "github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/tenant_migration_service"
+	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// ListPlatformOrganizations godoc
+// @Summary      List all organizations
+// @Description  Lists every organization of the platform with its usage: users, active sessions, API keys, last login and the amount of data in its schema. Platform admins only.
+// @Security     BearerAuth
+// @Tags         Platform
+// @Accept       json
+// @Produce      json
+// @Param        limit       query   int     false  "Page size (1-200, default 20)"
+// @Param        cursor      query   string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset      query   int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort        query   string  false  "Comma-separated sort fields, prefix with - for descending (id, name, user_count)"
+// @Param        filter.{field}  query  string  false  "Filter by is_active, is_try_out, expires_at, domain or user_count; comma-separate values to match any, numeric and date fields also accept .min/.max"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
+// @Success      200  {array}   dtoResponse.PlatformOrganizationResponse
+// @Failure      400  {object}  dtoResponse.InvalidListQueryErrorResponse "Invalid list query"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      403  {object}  dtoResponse.AuthErrorResponse "Not a platform admin"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /platform/organizations [get]
+func ListPlatformOrganizations(c *gin.Context) {
+	logger.Log.Info("ListPlatformOrganizations")
+
+	lq, ok := handler_util.ParseListQuery(c, organization_repository.OrganizationListSpec)
+	if !ok {
+		return
+	}
+
+	orgs, page, err := organization_repository.ListOrganizationsWithUsage(lq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to retrieve organizations"})
+		return
+	}
+
+	resp := make([]dtoResponse.PlatformOrganizationResponse, len(orgs))
+	for i, o := range orgs {
+		resp[i] = mapper.ToPlatformOrganizationResponse(o)
+	}
+
+	handler_util.SetPageHeaders(c, page)
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListPlatformOrganizationUsers godoc
+// @Summary      List the users of an organization
+// @Description  Lists the users of any organization with their organization-wide role. Platform admins only.
+// @Security     BearerAuth
+// @Tags         Platform
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Organization ID"
+// @Success      200  {array}   dtoResponse.PlatformUserResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      403  {object}  dtoResponse.AuthErrorResponse "Not a platform admin"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Organization not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /platform/organizations/{id}/users [get]
+func ListPlatformOrganizationUsers(c *gin.Context) {
+	logger.Log.Info("ListPlatformOrganizationUsers")
+
+	org, ok := platformOrganization(c)
+	if !ok {
+		return
+	}
+
+	users, err := user_repository.ListOrganizationUsers(org)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to retrieve users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToPlatformUserResponseList(users))
+}
+
+// DeactivateOrganization godoc
+// @Summary      Deactivate an organization
+// @Description  Deactivates an organization. Its users are logged out, their sessions revoked, and neither they nor its API keys can use the API until it is reactivated. Platform admins only.
+// @Security     BearerAuth
+// @Tags         Platform
+// @Accept       json
+// @Produce      json
+// @Param        id   path  int  true  "Organization ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      403  {object}  dtoResponse.AuthErrorResponse "Not a platform admin"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Organization not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /platform/organizations/{id}/deactivate [post]
+func DeactivateOrganization(c *gin.Context) {
+	logger.Log.Info("DeactivateOrganization")
+	setOrganizationActive(c, false)
+}
+
+// ReactivateOrganization godoc
+// @Summary      Reactivate an organization
+// @Description  Reactivates a deactivated organization, so its users can log in again. Platform admins only.
+// @Security     BearerAuth
+// @Tags         Platform
+// @Accept       json
+// @Produce      json
+// @Param        id   path  int  true  "Organization ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      403  {object}  dtoResponse.AuthErrorResponse "Not a platform admin"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Organization not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /platform/organizations/{id}/reactivate [post]
+func ReactivateOrganization(c *gin.Context) {
+	logger.Log.Info("ReactivateOrganization")
+	setOrganizationActive(c, true)
+}
+
+// ExtendOrganizationTryOut godoc
+// @Summary      Extend a try-out
+// @Description  Moves the expiration of a try-out organization to a later date. Platform admins only.
+// @Security     BearerAuth
+// @Tags         Platform
+// @Accept       json
+// @Produce      json
+// @Param        id    path  int                              true  "Organization ID"
+// @Param        data  body  dtoRequest.ExtendTryOutRequest  true  "New expiration"
+// @Success      204  "No Content"
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input, or expiration not in the future"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      403  {object}  dtoResponse.AuthErrorResponse "Not a platform admin"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Organization not found"
+// @Failure      409  {object}  dtoResponse.AuthErrorResponse "Organization is not a try-out"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /platform/organizations/{id}/expiration [patch]
+func ExtendOrganizationTryOut(c *gin.Context) {
+	logger.Log.Info("ExtendOrganizationTryOut")
+
+	req := c.MustGet("dto").(*dtoRequest.ExtendTryOutRequest)
+
+	if !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "expires_at must be in the future"})
+		return
+	}
+
+	org, ok := platformOrganization(c)
+	if !ok {
+		return
+	}
+
+	if !org.IsTryOut {
+		c.JSON(http.StatusConflict, dtoResponse.AuthErrorResponse{
+			Error:        "The organization is not a try-out.",
+			InternalCode: errorCodes.CodeOrganizationNotTryOut,
+		})
+		return
+	}
+
+	admin, ok := platformAdmin(c)
+	if !ok {
+		return
+	}
+
+	updated, err := organization_repository.ExtendTryOut(org.ID, req.ExpiresAt, admin.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to extend try-out"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, dtoResponse.AuthErrorResponse{
+			Error:        "The organization is not a try-out.",
+			InternalCode: errorCodes.CodeOrganizationNotTryOut,
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// MigrateOrganization godoc
+// @Summary      Run the pending migrations of an organization
+// @Description  Applies the per-tenant migrations the organization's schema has not run yet, all or none. Platform admins only.
+// @Security     BearerAuth
+// @Tags         Platform
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Organization ID"
+// @Success      200  {object}  dtoResponse.TenantMigrationResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      403  {object}  dtoResponse.AuthErrorResponse "Not a platform admin"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Organization not found"
+// @Failure      500  {object}  dtoResponse.TenantMigrationResponse "Migration failed, nothing was applied"
+// @Router       /platform/organizations/{id}/migrations [post]
+func MigrateOrganization(c *gin.Context) {
+	logger.Log.Info("MigrateOrganization")
+
+	org, ok := platformOrganization(c)
+	if !ok {
+		return
+	}
+
+	scripts, err := tenant_migration_service.Scripts()
+	if err != nil {
+		logger.Log.Errorf("Error fetching scripts paths: %v", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to read migrations"})
+		return
+	}
+
+	resp := migrateOrganization(*org, scripts)
+	if resp.Error != nil {
+		c.JSON(http.StatusInternalServerError, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// MigrateAllOrganizations godoc
+// @Summary      Run the pending migrations of every organization
+// @Description  Applies the pending per-tenant migrations to every active organization, one schema at a time. A failing schema is rolled back and reported without stopping the others. Platform admins only.
+// @Security     BearerAuth
+// @Tags         Platform
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   dtoResponse.TenantMigrationResponse
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      403  {object}  dtoResponse.AuthErrorResponse "Not a platform admin"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /platform/migrations [post]
+func MigrateAllOrganizations(c *gin.Context) {
+	logger.Log.Info("MigrateAllOrganizations")
+
+	scripts, err := tenant_migration_service.Scripts()
+	if err != nil {
+		logger.Log.Errorf("Error fetching scripts paths: %v", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to read migrations"})
+		return
+	}
+
+	orgs, err := tenant_migration_service.ActiveOrganizations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to retrieve organizations"})
+		return
+	}
+
+	resp := make([]dtoResponse.TenantMigrationResponse, len(orgs))
+	for i, org := range orgs {
+		resp[i] = migrateOrganization(org, scripts)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// migrateOrganization applies the pending scripts to the organization. The
+// migration is not tied to the request, so a client that hangs up does not
+// roll it back.
+func migrateOrganization(org model.Organization, scripts []string) dtoResponse.TenantMigrationResponse {
+	resp := dtoResponse.TenantMigrationResponse{
+		OrganizationID: org.ID,
+		Schema:         org.DBSchema,
+		Applied:        []string{},
+	}
+
+	applied, err := tenant_migration_service.ApplyPending(context.Background(), org, scripts)
+	if err != nil {
+		logger.Log.Errorf("[MIGRATION] ❌ Failed schema %s: %v", org.DBSchema, err)
+		msg := err.Error()
+		resp.Error = &msg
+		return resp
+	}
+
+	if applied != nil {
+		resp.Applied = applied
+	}
+	return resp
+}
+
+func setOrganizationActive(c *gin.Context, active bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Id must be a number"})
+		return
+	}
+
+	admin, ok := platformAdmin(c)
+	if !ok {
+		return
+	}
+
+	ok, err = organization_repository.SetOrganizationActive(uint(id), active, admin.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to update organization"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Organization not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// platformAdmin returns the platform admin making the request, who is
+// recorded in the organization's audit log. It writes the error response and
+// returns false when there is none.
+func platformAdmin(c *gin.Context) (model.User, bool) {
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		logger.Log.Error(err)
+		c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
+		return model.User{}, false
+	}
+	return user, true
+}
+
+// platformOrganization loads the organization of the :id parameter. It
+// writes the error response and returns false when there is none.
+func platformOrganization(c *gin.Context) (*model.Organization, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Id must be a number"})
+		return nil, false
+	}
+
+	org, err := organization_repository.GetOrganizationByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to retrieve organization"})
+		return nil, false
+	}
+	if org == nil {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Organization not found"})
+		return nil, false
+	}
+
+	return org, true
+}
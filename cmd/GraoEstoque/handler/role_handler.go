@@ -0,0 +1,305 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/role_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/store_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/error_handler"
+	mapper "github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
+	dtoRequest "github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
+	dtoResponse "github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// GetMyRoles godoc
+// @Summary      Get the authenticated user's roles
+// @Description  Returns the user's organization role and its per-store overrides, with the permissions each grants.
+// @Security     BearerAuth
+// @Tags         Roles
+// @Produce      json
+// @Success      200  {object}  dtoResponse.MyRolesResponse
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /roles/me [get]
+func GetMyRoles(c *gin.Context) {
+	logger.Log.Info("GetMyRoles")
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	assignments, err := role_repository.ListUserRoles(conn, user.ID)
+	if err != nil {
+		logger.Log.Error("Error listing user roles: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToMyRolesResponse(assignments))
+}
+
+// ListOrganizationRoles godoc
+// @Summary      List organization roles
+// @Description  Lists the organization-wide role of every user that has one.
+// @Security     BearerAuth
+// @Tags         Roles
+// @Produce      json
+// @Success      200  {array}   dtoResponse.RoleAssignmentResponse
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /roles [get]
+func ListOrganizationRoles(c *gin.Context) {
+	logger.Log.Info("ListOrganizationRoles")
+	listRoleAssignments(c, nil)
+}
+
+// AssignOrganizationRole godoc
+// @Summary      Assign an organization role
+// @Description  Sets the user's role in the whole organization. Store roles of the user still override it in their stores.
+// @Security     BearerAuth
+// @Tags         Roles
+// @Accept       json
+// @Produce      json
+// @Param        userId  path  int                           true  "User ID"
+// @Param        data    body  dtoRequest.AssignRoleRequest  true  "Role"
+// @Success      200  {object}  dtoResponse.RoleAssignmentResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input"
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "User not found in the organization"
+// @Failure      422  {object}  dtoResponse.LastOwnerErrorResponse "The organization would have no owner left"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /roles/{userId} [put]
+func AssignOrganizationRole(c *gin.Context) {
+	logger.Log.Info("AssignOrganizationRole")
+	assignRole(c, nil)
+}
+
+// RemoveOrganizationRole godoc
+// @Summary      Remove an organization role
+// @Description  Removes the user's organization-wide role. The user keeps only its store roles.
+// @Security     BearerAuth
+// @Tags         Roles
+// @Param        userId  path  int  true  "User ID"
+// @Success      204  "Role removed"
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Role not found"
+// @Failure      422  {object}  dtoResponse.LastOwnerErrorResponse "The organization would have no owner left"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /roles/{userId} [delete]
+func RemoveOrganizationRole(c *gin.Context) {
+	logger.Log.Info("RemoveOrganizationRole")
+	removeRole(c, nil)
+}
+
+// ListStoreRoles godoc
+// @Summary      List store roles
+// @Description  Lists the users whose role is overridden in the store.
+// @Security     BearerAuth
+// @Tags         Roles
+// @Produce      json
+// @Param        id   path  int  true  "Store ID"
+// @Success      200  {array}   dtoResponse.RoleAssignmentResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Store not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stores/{id}/roles [get]
+func ListStoreRoles(c *gin.Context) {
+	logger.Log.Info("ListStoreRoles")
+
+	storeID, ok := storeFromParam(c)
+	if !ok {
+		return
+	}
+	listRoleAssignments(c, &storeID)
+}
+
+// AssignStoreRole godoc
+// @Summary      Assign a store role
+// @Description  Sets the user's role in the store, overriding its organization role there.
+// @Security     BearerAuth
+// @Tags         Roles
+// @Accept       json
+// @Produce      json
+// @Param        id      path  int                           true  "Store ID"
+// @Param        userId  path  int                           true  "User ID"
+// @Param        data    body  dtoRequest.AssignRoleRequest  true  "Role"
+// @Success      200  {object}  dtoResponse.RoleAssignmentResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input"
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Store or user not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stores/{id}/roles/{userId} [put]
+func AssignStoreRole(c *gin.Context) {
+	logger.Log.Info("AssignStoreRole")
+
+	storeID, ok := storeFromParam(c)
+	if !ok {
+		return
+	}
+	assignRole(c, &storeID)
+}
+
+// RemoveStoreRole godoc
+// @Summary      Remove a store role
+// @Description  Removes the user's role override in the store. The organization role applies there again.
+// @Security     BearerAuth
+// @Tags         Roles
+// @Param        id      path  int  true  "Store ID"
+// @Param        userId  path  int  true  "User ID"
+// @Success      204  "Role removed"
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "Permission denied"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Role not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stores/{id}/roles/{userId} [delete]
+func RemoveStoreRole(c *gin.Context) {
+	logger.Log.Info("RemoveStoreRole")
+
+	storeID, ok := storeFromParam(c)
+	if !ok {
+		return
+	}
+	removeRole(c, &storeID)
+}
+
+// storeFromParam checks that the store in the "id" path parameter exists.
+// It writes the error response and returns false otherwise.
+func storeFromParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Id must be a number"})
+		return 0, false
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return 0, false
+	}
+
+	store, err := store_repository.GetStoreByID(conn, uint(id))
+	if err != nil {
+		logger.Log.Error("Error getting store: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return 0, false
+	} else if store == nil {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Store not found"})
+		return 0, false
+	}
+
+	return store.ID, true
+}
+
+func listRoleAssignments(c *gin.Context, storeID *uint) {
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	assignments, err := role_repository.ListRoleAssignments(conn, storeID)
+	if err != nil {
+		logger.Log.Error("Error listing role assignments: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToRoleAssignmentResponseList(assignments))
+}
+
+func assignRole(c *gin.Context, storeID *uint) {
+	req := c.MustGet("dto").(*dtoRequest.AssignRoleRequest)
+
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "User id must be a number"})
+		return
+	}
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	assignment := &model.RoleAssignment{
+		User:       model.User{ID: uint(userID)},
+		StoreID:    storeID,
+		Role:       model.Role(req.Role),
+		AssignedBy: &user.ID,
+	}
+
+	found, err := role_repository.SaveRoleAssignment(conn, assignment, user.Organization.ID)
+	if err != nil {
+		if error_handler.HandleLastOwnerError(c, err) {
+			return
+		}
+		logger.Log.Error("Error saving role assignment: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "User not found in the organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToRoleAssignmentResponse(assignment))
+}
+
+func removeRole(c *gin.Context, storeID *uint) {
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "User id must be a number"})
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	found, err := role_repository.DeleteRoleAssignment(conn, uint(userID), storeID)
+	if err != nil {
+		if error_handler.HandleLastOwnerError(c, err) {
+			return
+		}
+		logger.Log.Error("Error deleting role assignment: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Role not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
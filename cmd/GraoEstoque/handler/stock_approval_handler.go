@@ -0,0 +1,294 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_approval_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/error_handler"
+	dtoMapper "github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
+	dtoRequest "github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
+	dtoResponse "github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// approvalAction is one of the stock_approval_repository decision functions.
+type approvalAction func(conn *pgxpool.Conn, docType model.StockDocumentType, id int, storeID uint, comment *string, userID uint) (*model.ApprovalDecision, error)
+
+// SubmitStockInByID godoc
+// @Summary      Submit a stock-in for approval
+// @Description  Sends a draft stock-in for review. Drafts that were never submitted or were rejected can be submitted. Submitted drafts cannot be edited until rejected.
+// @Security     BearerAuth
+// @Tags         Stock In
+// @Accept       json
+// @Produce      json
+// @Param        id          path    int     true  "Stock-in ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        data        body    dtoRequest.StockDocumentDecisionRequest  true  "Optional comment"
+// @Success      200  {object}  dtoResponse.ApprovalDecisionResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-in ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-in not found"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "The document's approval state does not allow this action"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/in/submit/{id} [patch]
+func SubmitStockInByID(c *gin.Context) {
+	logger.Log.Info("SubmitStockInByID")
+
+	// Retrieved from BindAndValidate middleware
+	req := c.MustGet("dto").(*dtoRequest.StockDocumentDecisionRequest)
+	decideStockDocument(c, model.StockInDocument, stock_approval_repository.SubmitStockDocument, req.Comment)
+}
+
+// SubmitStockOutByID godoc
+// @Summary      Submit a stock-out for approval
+// @Description  Sends a draft stock-out for review. Drafts that were never submitted or were rejected can be submitted. Submitted drafts cannot be edited until rejected.
+// @Security     BearerAuth
+// @Tags         Stock Out
+// @Accept       json
+// @Produce      json
+// @Param        id          path    int     true  "Stock-out ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        data        body    dtoRequest.StockDocumentDecisionRequest  true  "Optional comment"
+// @Success      200  {object}  dtoResponse.ApprovalDecisionResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-out ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-out not found"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "The document's approval state does not allow this action"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/out/submit/{id} [patch]
+func SubmitStockOutByID(c *gin.Context) {
+	logger.Log.Info("SubmitStockOutByID")
+
+	// Retrieved from BindAndValidate middleware
+	req := c.MustGet("dto").(*dtoRequest.StockDocumentDecisionRequest)
+	decideStockDocument(c, model.StockOutDocument, stock_approval_repository.SubmitStockDocument, req.Comment)
+}
+
+// SubmitStockWasteByID godoc
+// @Summary      Submit a stock-waste for approval
+// @Description  Sends a draft stock-waste for review. Drafts that were never submitted or were rejected can be submitted. Submitted drafts cannot be edited until rejected.
+// @Security     BearerAuth
+// @Tags         Stock Waste
+// @Accept       json
+// @Produce      json
+// @Param        id          path    int     true  "Stock-waste ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        data        body    dtoRequest.StockDocumentDecisionRequest  true  "Optional comment"
+// @Success      200  {object}  dtoResponse.ApprovalDecisionResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-waste ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-waste not found"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "The document's approval state does not allow this action"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/waste/submit/{id} [patch]
+func SubmitStockWasteByID(c *gin.Context) {
+	logger.Log.Info("SubmitStockWasteByID")
+
+	// Retrieved from BindAndValidate middleware
+	req := c.MustGet("dto").(*dtoRequest.StockDocumentDecisionRequest)
+	decideStockDocument(c, model.StockWasteDocument, stock_approval_repository.SubmitStockDocument, req.Comment)
+}
+
+// ApproveStockInByID godoc
+// @Summary      Approve a stock-in
+// @Description  Approves a submitted stock-in so it can be finalized. Unless the store policy allows it, neither the creator nor the latest submitter can approve it.
+// @Security     BearerAuth
+// @Tags         Stock In
+// @Accept       json
+// @Produce      json
+// @Param        id          path    int     true  "Stock-in ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        data        body    dtoRequest.StockDocumentDecisionRequest  true  "Optional comment"
+// @Success      200  {object}  dtoResponse.ApprovalDecisionResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-in ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-in not found"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "The document's approval state does not allow this action"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/in/approve/{id} [patch]
+func ApproveStockInByID(c *gin.Context) {
+	logger.Log.Info("ApproveStockInByID")
+
+	// Retrieved from BindAndValidate middleware
+	req := c.MustGet("dto").(*dtoRequest.StockDocumentDecisionRequest)
+	decideStockDocument(c, model.StockInDocument, stock_approval_repository.ApproveStockDocument, req.Comment)
+}
+
+// ApproveStockOutByID godoc
+// @Summary      Approve a stock-out
+// @Description  Approves a submitted stock-out so it can be finalized. Unless the store policy allows it, neither the creator nor the latest submitter can approve it.
+// @Security     BearerAuth
+// @Tags         Stock Out
+// @Accept       json
+// @Produce      json
+// @Param        id          path    int     true  "Stock-out ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        data        body    dtoRequest.StockDocumentDecisionRequest  true  "Optional comment"
+// @Success      200  {object}  dtoResponse.ApprovalDecisionResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-out ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-out not found"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "The document's approval state does not allow this action"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/out/approve/{id} [patch]
+func ApproveStockOutByID(c *gin.Context) {
+	logger.Log.Info("ApproveStockOutByID")
+
+	// Retrieved from BindAndValidate middleware
+	req := c.MustGet("dto").(*dtoRequest.StockDocumentDecisionRequest)
+	decideStockDocument(c, model.StockOutDocument, stock_approval_repository.ApproveStockDocument, req.Comment)
+}
+
+// ApproveStockWasteByID godoc
+// @Summary      Approve a stock-waste
+// @Description  Approves a submitted stock-waste so it can be finalized. Unless the store policy allows it, neither the creator nor the latest submitter can approve it.
+// @Security     BearerAuth
+// @Tags         Stock Waste
+// @Accept       json
+// @Produce      json
+// @Param        id          path    int     true  "Stock-waste ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        data        body    dtoRequest.StockDocumentDecisionRequest  true  "Optional comment"
+// @Success      200  {object}  dtoResponse.ApprovalDecisionResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-waste ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-waste not found"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "The document's approval state does not allow this action"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/waste/approve/{id} [patch]
+func ApproveStockWasteByID(c *gin.Context) {
+	logger.Log.Info("ApproveStockWasteByID")
+
+	// Retrieved from BindAndValidate middleware
+	req := c.MustGet("dto").(*dtoRequest.StockDocumentDecisionRequest)
+	decideStockDocument(c, model.StockWasteDocument, stock_approval_repository.ApproveStockDocument, req.Comment)
+}
+
+// RejectStockInByID godoc
+// @Summary      Reject a stock-in
+// @Description  Sends a submitted stock-in back for changes. The comment should tell what to change.
+// @Security     BearerAuth
+// @Tags         Stock In
+// @Accept       json
+// @Produce      json
+// @Param        id          path    int     true  "Stock-in ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        data        body    dtoRequest.RejectStockDocumentRequest  true  "Rejection comment"
+// @Success      200  {object}  dtoResponse.ApprovalDecisionResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-in ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-in not found"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "The document's approval state does not allow this action"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/in/reject/{id} [patch]
+func RejectStockInByID(c *gin.Context) {
+	logger.Log.Info("RejectStockInByID")
+
+	// Retrieved from BindAndValidate middleware
+	req := c.MustGet("dto").(*dtoRequest.RejectStockDocumentRequest)
+	decideStockDocument(c, model.StockInDocument, stock_approval_repository.RejectStockDocument, &req.Comment)
+}
+
+// RejectStockOutByID godoc
+// @Summary      Reject a stock-out
+// @Description  Sends a submitted stock-out back for changes. The comment should tell what to change.
+// @Security     BearerAuth
+// @Tags         Stock Out
+// @Accept       json
+// @Produce      json
+// @Param        id          path    int     true  "Stock-out ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        data        body    dtoRequest.RejectStockDocumentRequest  true  "Rejection comment"
+// @Success      200  {object}  dtoResponse.ApprovalDecisionResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-out ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-out not found"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "The document's approval state does not allow this action"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/out/reject/{id} [patch]
+func RejectStockOutByID(c *gin.Context) {
+	logger.Log.Info("RejectStockOutByID")
+
+	// Retrieved from BindAndValidate middleware
+	req := c.MustGet("dto").(*dtoRequest.RejectStockDocumentRequest)
+	decideStockDocument(c, model.StockOutDocument, stock_approval_repository.RejectStockDocument, &req.Comment)
+}
+
+// RejectStockWasteByID godoc
+// @Summary      Reject a stock-waste
+// @Description  Sends a submitted stock-waste back for changes. The comment should tell what to change.
+// @Security     BearerAuth
+// @Tags         Stock Waste
+// @Accept       json
+// @Produce      json
+// @Param        id          path    int     true  "Stock-waste ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        data        body    dtoRequest.RejectStockDocumentRequest  true  "Rejection comment"
+// @Success      200  {object}  dtoResponse.ApprovalDecisionResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-waste ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-waste not found"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "The document's approval state does not allow this action"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/waste/reject/{id} [patch]
+func RejectStockWasteByID(c *gin.Context) {
+	logger.Log.Info("RejectStockWasteByID")
+
+	// Retrieved from BindAndValidate middleware
+	req := c.MustGet("dto").(*dtoRequest.RejectStockDocumentRequest)
+	decideStockDocument(c, model.StockWasteDocument, stock_approval_repository.RejectStockDocument, &req.Comment)
+}
+
+// decideStockDocument runs an approval action on the document in the id path
+// parameter and writes the recorded decision.
+func decideStockDocument(c *gin.Context, docType model.StockDocumentType, action approvalAction, comment *string) {
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Log.Errorf("Invalid %s ID: %v", docType, err)
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Invalid " + string(docType) + " ID"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	decision, err := action(conn, docType, id, storeID, comment, user.ID)
+	if err != nil {
+		logger.Log.Errorf("Failed to record approval decision: %v", err)
+		if error_handler.HandleApprovalError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	} else if decision == nil {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Document not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dtoMapper.ToApprovalDecisionResponse(decision))
+}
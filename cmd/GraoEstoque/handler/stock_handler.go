@@ -4,11 +4,14 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/storage_location_repository"
 	mapper "github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
 	dtoResponse "github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
 	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
 	"github.com/gin-gonic/gin"
 )
@@ -20,8 +23,17 @@ import (
 // @Tags         Stock
 // @Produce      json
 // @Param        X-Store-ID  header    string  true  "Store ID"
+// @Param        by_location  query   bool    false  "Break quantities down by storage location"
+// @Param        attr.{key}  query  string  false  "Filter by custom item attribute; number and date attributes also accept attr.{key}.min and attr.{key}.max"
+// @Param        limit       query   int     false  "Page size (1-200, default 20)"
+// @Param        cursor      query   string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset      query   int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort        query   string  false  "Comma-separated sort fields, prefix with - for descending (id, description, current_stock)"
+// @Param        filter.{field}  query  string  false  "Filter by item_id, category_id, unit_id, ean13, current_stock or updated_at; comma-separate values to match any, numeric and date fields also accept .min/.max"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
 // @Success      200  {array}  dtoResponse.StockResponse
-// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid or missing store ID"
+// @Failure      400  {object}  dtoResponse.InvalidItemAttributeErrorResponse "Invalid or missing store ID, invalid attribute filter or invalid list query"
 // @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock [get]
@@ -57,41 +69,96 @@ func GetStock(c *gin.Context) {
 		return
 	}
 
-	stock, err := stock_repository.GetStock(conn, user.ID, storeID)
+	filters, ok := parseItemAttributeFilters(c, conn, storeID)
+	if !ok {
+		return
+	}
+
+	lq, ok := handler_util.ParseListQuery(c, stock_repository.StockListSpec)
+	if !ok {
+		return
+	}
+
+	stock, page, err := stock_repository.GetStock(conn, user.ID, storeID, filters, lq)
 	if err != nil {
 		logger.Log.Error("Error fetching stock: ", err)
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
 		return
 	}
 
+	byLocation, _ := strconv.ParseBool(c.DefaultQuery("by_location", "false"))
+	if byLocation {
+		locations, err := storage_location_repository.GetStockLocations(conn, storeID)
+		if err != nil {
+			logger.Log.Error("Error fetching stock locations: ", err)
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+			return
+		}
+
+		// Whatever is not held at a location is reported as unassigned
+		for i := range stock {
+			stock[i].Locations = []model.StockLocation{}
+			stock[i].Unassigned = stock[i].CurrentStock
+			for _, l := range locations[stock[i].Item.ID] {
+				stock[i].Locations = append(stock[i].Locations, l)
+				stock[i].Unassigned = stock[i].Unassigned.Sub(l.Quantity)
+			}
+		}
+	}
+
 	// Map domain models to response DTOs
 	rep := make([]dtoResponse.StockResponse, len(stock))
 	for i, st := range stock {
 		rep[i] = *mapper.ToStockResponse(&st)
 	}
 
+	handler_util.SetPageHeaders(c, page)
 	c.JSON(http.StatusOK, rep)
 }
 
+// GetStockByCategory godoc
+// @Summary      Get stock by category
+// @Description  Retrieves the current stock for items in the given category and all of its subcategories
+// @Security     BearerAuth
+// @Tags         Stock
+// @Produce      json
+// @Param        categoryId  path      int     true  "Category ID"
+// @Param        X-Store-ID  header    string  true  "Store ID"
+// @Success      200  {array}  dtoResponse.StockResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid category or store ID"
+// @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/{categoryId} [get]
 func GetStockByCategory(c *gin.Context) {
 	logger.Log.Info("GetStockByCategory")
 
 	user, err := util.GetUserFromContext(c)
 	if err != nil {
 		if err == util.ErrNoUser {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to get user"})
 		}
 		logger.Log.Error(err)
 		c.Abort()
 		return
 	}
 
-	categoryID, err := strconv.Atoi(c.Param("categoryId"))
+	storeID, err := util.GetStoreIDFromContext(c)
+	if err != nil {
+		if err == util.ErrNoStoreID {
+			c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "store id not found"})
+		} else {
+			c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "invalid store id"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
 
+	categoryID, err := strconv.ParseUint(c.Param("categoryId"), 10, 0)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Id should be a integer"})
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Id should be a integer"})
 		return
 	}
 
@@ -100,12 +167,58 @@ func GetStockByCategory(c *gin.Context) {
 		return
 	}
 
-	stock, err := stock_repository.GetStockByCategory(conn, user.ID, categoryID)
+	stock, err := stock_repository.GetStockByCategory(conn, user.ID, storeID, uint(categoryID))
 	if err != nil {
 		logger.Log.Error("Error fetching stock: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	rep := make([]dtoResponse.StockResponse, len(stock))
+	for i, st := range stock {
+		rep[i] = *mapper.ToStockResponse(&st)
+	}
+
+	c.JSON(http.StatusOK, rep)
+}
+
+// GetCategoryStockSummary godoc
+// @Summary      Get stock summary by category tree
+// @Description  Returns the category tree with item count, quantity and value per category. total_* fields roll subtotals up from all subcategories. Values use the weighted average buy price of finalized stock-ins.
+// @Security     BearerAuth
+// @Tags         Stock
+// @Produce      json
+// @Param        X-Store-ID  header    string  true  "Store ID"
+// @Success      200  {array}  dtoResponse.CategoryStockSummaryResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid or missing store ID"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/categories/summary [get]
+func GetCategoryStockSummary(c *gin.Context) {
+	logger.Log.Info("GetCategoryStockSummary")
+
+	storeID, err := util.GetStoreIDFromContext(c)
+	if err != nil {
+		if err == util.ErrNoStoreID {
+			c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "store id not found"})
+		} else {
+			c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "invalid store id"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	summaries, err := stock_repository.GetCategoryStockSummary(conn, storeID)
+	if err != nil {
+		logger.Log.Error("Error fetching category stock summary: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
 		return
 	}
 
-	c.JSON(http.StatusOK, stock)
+	c.JSON(http.StatusOK, mapper.ToCategoryStockSummaryTreeResponse(summaries))
 }
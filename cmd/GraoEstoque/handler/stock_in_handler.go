@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -12,10 +13,14 @@ import (
 
 	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
 
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/item_packaging_repository"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_in_repository"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/error_handler"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/stock_validation_service"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
 // CreateStockIn godoc
@@ -30,6 +35,8 @@ import (
 // @Success      201  {object}  dtoResponse.StockInResponse
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input or missing store ID"
 // @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      422  {object}  dtoResponse.ArchivedEntityReferencedErrorResponse "Item or packaging is archived, or location belongs to another store"
+// @Failure      422  {object}  dtoResponse.StoreMismatchErrorResponse "Item or packaging belongs to another store"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/in [post]
 func CreateStockIn(c *gin.Context) {
@@ -71,6 +78,15 @@ func CreateStockIn(c *gin.Context) {
 	err = stock_in_repository.SaveStockIn(conn, mcir, user.ID, storeID)
 	if err != nil {
 		logger.Log.Errorf("Failed to save stock in: %v", err)
+		if error_handler.HandleArchivedReferenceError(c, err) {
+			return
+		}
+		if error_handler.HandleStoreMismatchError(c, err) {
+			return
+		}
+		if error_handler.HandleStorageLocationError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to save stock in"})
 		return
 	}
@@ -102,12 +118,17 @@ func GetStockInByID(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	stockIn, err := stock_in_repository.GetStockInByID(conn, id)
+	stockIn, err := stock_in_repository.GetStockInByID(conn, id, storeID)
 	if err != nil {
 		logger.Log.Errorf("Failed to retrieve stock in: %v", err)
 		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockIn not found"})
@@ -125,8 +146,15 @@ func GetStockInByID(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        limit       query   int     false  "Page size (1-200, default 20)"
+// @Param        cursor      query   string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset      query   int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort        query   string  false  "Comma-separated sort fields, prefix with - for descending (id, created_at, updated_at)"
+// @Param        filter.{field}  query  string  false  "Filter by status, created_at or finalized_at; comma-separate values to match any, numeric and date fields also accept .min/.max"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
 // @Success      200  {array}   dtoResponse.StockInResponse
-// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid or missing store ID"
+// @Failure      400  {object}  dtoResponse.InvalidListQueryErrorResponse "Invalid or missing store ID, or invalid list query"
 // @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/in [get]
@@ -162,7 +190,12 @@ func ListAllStockIn(c *gin.Context) {
 		return
 	}
 
-	stockIns, err := stock_in_repository.ListAllStockIn(conn, user.ID, storeID)
+	lq, ok := handler_util.ParseListQuery(c, stock_in_repository.StockInListSpec)
+	if !ok {
+		return
+	}
+
+	stockIns, page, err := stock_in_repository.ListAllStockIn(conn, user.ID, storeID, lq)
 	if err != nil {
 		logger.Log.Errorf("Error listing stock in: %v", err)
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to retrieve stock in list"})
@@ -175,6 +208,7 @@ func ListAllStockIn(c *gin.Context) {
 		rep[i] = *dtoMapper.ToStockInResponse(sti)
 	}
 
+	handler_util.SetPageHeaders(c, page)
 	c.JSON(http.StatusOK, rep)
 }
 
@@ -189,6 +223,8 @@ func ListAllStockIn(c *gin.Context) {
 // @Param        X-Store-ID  header  string  true  "Store ID"
 // @Success      204 "Stock-in deleted successfully"
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-in ID"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-in not found"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "Document is finalized or under approval"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/in/{id} [delete]
 func DeleteStockIn(c *gin.Context) {
@@ -202,14 +238,26 @@ func DeleteStockIn(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	err = stock_in_repository.DeleteStockIn(conn, id)
+	err = stock_in_repository.DeleteStockIn(conn, id, storeID)
 	if err != nil {
 		logger.Log.Errorf("Failed to delete stock in: %v", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockIn not found"})
+			return
+		}
+		if error_handler.HandleApprovalError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to delete stock in"})
 		return
 	}
@@ -226,8 +274,12 @@ func DeleteStockIn(c *gin.Context) {
 // @Produce      json
 // @Param        id          path    int     true  "Stock-in ID"
 // @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        dry_run     query   bool    false  "Run the finalization in a rolled back transaction and return the resulting stock levels"
 // @Success      204  "Stock-in finalized successfully"
+// @Success      200  {object}  dtoResponse.FinalizePreviewResponse "Dry run: stock levels after finalization, nothing committed"
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-in ID"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "Document needs an approval before it can be finalized"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-in not found"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/in/finalize/{id} [patch]
 func FinalizeStockInByID(c *gin.Context) {
@@ -241,14 +293,45 @@ func FinalizeStockInByID(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	err = stock_in_repository.FinalizeStockInByID(conn, id)
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if dryRun {
+		changes, err := stock_in_repository.PreviewFinalizeStockInByID(conn, id, storeID)
+		if err != nil {
+			logger.Log.Errorf("Failed to preview stock in finalization: %v", err)
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockIn not found"})
+				return
+			}
+			if error_handler.HandleApprovalError(c, err) {
+				return
+			}
+			error_handler.HandleDBError(c, err, id)
+			return
+		}
+		c.JSON(http.StatusOK, dtoMapper.ToFinalizePreviewResponse(uint(id), changes))
+		return
+	}
+
+	err = stock_in_repository.FinalizeStockInByID(conn, id, storeID)
 	if err != nil {
 		logger.Log.Errorf("Failed to finalize stock in: %v", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockIn not found"})
+			return
+		}
+		if error_handler.HandleApprovalError(c, err) {
+			return
+		}
 		error_handler.HandleDBError(c, err, id)
 		return
 	}
@@ -267,6 +350,10 @@ func FinalizeStockInByID(c *gin.Context) {
 // @Param        data        body    dtoRequest.UpdateStockInRequest  true  "Stock-in update payload"
 // @Success      200  {object}  dtoResponse.StockInResponse
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input"
+// @Failure      422  {object}  dtoResponse.ArchivedEntityReferencedErrorResponse "Item or packaging is archived, or location belongs to another store"
+// @Failure      422  {object}  dtoResponse.UnknownDocumentLineErrorResponse "Item or packaging line does not belong to the document"
+// @Failure      422  {object}  dtoResponse.StoreMismatchErrorResponse "Item or packaging belongs to another store"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-in not found"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/in [put]
 func UpdateStockIn(c *gin.Context) {
@@ -276,18 +363,100 @@ func UpdateStockIn(c *gin.Context) {
 	stockInReq := c.MustGet("dto").(*dtoRequest.UpdateStockInRequest)
 	stockInModel := dtoMapper.UpdateStockInToModel(stockInReq)
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	err := stock_in_repository.UpdateStockIn(conn, stockInModel)
+	err := stock_in_repository.UpdateStockIn(conn, stockInModel, storeID)
 
 	if err != nil {
 		logger.Log.Error("Error updating item: ", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockIn not found"})
+			return
+		}
+		if error_handler.HandleArchivedReferenceError(c, err) {
+			return
+		}
+		if error_handler.HandleStoreMismatchError(c, err) {
+			return
+		}
+		if error_handler.HandleApprovalError(c, err) {
+			return
+		}
+		if error_handler.HandleStorageLocationError(c, err) {
+			return
+		}
+		if error_handler.HandleUnknownDocumentLineError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
 		return
 	}
 
 	c.JSON(http.StatusOK, dtoMapper.ToStockInResponse(stockInModel))
 }
+
+// ValidateStockInByID godoc
+// @Summary      Validate a stock-in draft
+// @Description  Checks a stock-in the way finalization does, without changing it, and reports every problem per line instead of the first one only. Problems with severity error make finalization fail.
+// @Security     BearerAuth
+// @Tags         Stock In
+// @Accept       json
+// @Produce      json
+// @Param        id          path    int     true  "Stock-in ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Success      200  {object}  dtoResponse.StockDocumentValidationResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-in ID"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-in not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/in/{id}/validate [get]
+func ValidateStockInByID(c *gin.Context) {
+	logger.Log.Info("ValidateStockInByID")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Invalid stock_in ID"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	stockIn, err := stock_in_repository.GetStockInByID(conn, id, storeID)
+	if err != nil {
+		logger.Log.Errorf("Failed to retrieve stock in: %v", err)
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockIn not found"})
+		return
+	}
+
+	var packagingIDs []uint
+	for _, it := range stockIn.Items {
+		for _, p := range it.Packagings {
+			packagingIDs = append(packagingIDs, p.ItemPackaging.ID)
+		}
+	}
+
+	packagings, err := item_packaging_repository.GetItemPackagingsByIDs(conn, packagingIDs)
+	if err != nil {
+		logger.Log.Errorf("Failed to load packagings: %v", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	validation := stock_validation_service.ValidateStockIn(stockIn, packagings)
+	c.JSON(http.StatusOK, dtoMapper.ToStockDocumentValidationResponse(validation))
+}
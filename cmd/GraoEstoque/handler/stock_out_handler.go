@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -11,10 +12,15 @@ import (
 
 	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
 
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/item_packaging_repository"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_out_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_repository"
 	error_handler "github.com/IlfGauhnith/GraoAGrao/pkg/db/error_handler"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/stock_validation_service"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
 // CreateStockOut godoc
@@ -29,6 +35,8 @@ import (
 // @Success      201  {object}  dtoResponse.StockOutResponse
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input or store ID"
 // @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      422  {object}  dtoResponse.ArchivedEntityReferencedErrorResponse "Item or packaging is archived, or location belongs to another store"
+// @Failure      422  {object}  dtoResponse.StoreMismatchErrorResponse "Item or packaging belongs to another store"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/out [post]
 func CreateStockOut(c *gin.Context) {
@@ -70,6 +78,15 @@ func CreateStockOut(c *gin.Context) {
 	err = stock_out_repository.SaveStockOut(conn, mcor, user.ID, storeID)
 	if err != nil {
 		logger.Log.Errorf("Failed to save stock out: %v", err)
+		if error_handler.HandleArchivedReferenceError(c, err) {
+			return
+		}
+		if error_handler.HandleStoreMismatchError(c, err) {
+			return
+		}
+		if error_handler.HandleStorageLocationError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to save stock out"})
 		return
 	}
@@ -101,12 +118,17 @@ func GetStockOutByID(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	stockOut, err := stock_out_repository.GetStockOutByID(conn, id)
+	stockOut, err := stock_out_repository.GetStockOutByID(conn, id, storeID)
 	if err != nil {
 		logger.Log.Errorf("Failed to retrieve stock out: %v", err)
 		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockOut not found"})
@@ -124,8 +146,15 @@ func GetStockOutByID(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        limit       query   int     false  "Page size (1-200, default 20)"
+// @Param        cursor      query   string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset      query   int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort        query   string  false  "Comma-separated sort fields, prefix with - for descending (id, created_at, updated_at)"
+// @Param        filter.{field}  query  string  false  "Filter by status, created_at or finalized_at; comma-separate values to match any, numeric and date fields also accept .min/.max"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
 // @Success      200  {array}   dtoResponse.StockOutResponse
-// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid or missing store ID"
+// @Failure      400  {object}  dtoResponse.InvalidListQueryErrorResponse "Invalid or missing store ID, or invalid list query"
 // @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/out [get]
@@ -161,7 +190,12 @@ func ListAllStockOut(c *gin.Context) {
 		return
 	}
 
-	outs, err := stock_out_repository.ListAllStockOut(conn, user.ID, storeID)
+	lq, ok := handler_util.ParseListQuery(c, stock_out_repository.StockOutListSpec)
+	if !ok {
+		return
+	}
+
+	outs, page, err := stock_out_repository.ListAllStockOut(conn, user.ID, storeID, lq)
 	if err != nil {
 		logger.Log.Errorf("Error listing stock out: %v", err)
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to retrieve stock out list"})
@@ -173,6 +207,7 @@ func ListAllStockOut(c *gin.Context) {
 		rep[i] = *dtoMapper.ToStockOutResponse(so)
 	}
 
+	handler_util.SetPageHeaders(c, page)
 	c.JSON(http.StatusOK, rep)
 }
 
@@ -187,6 +222,8 @@ func ListAllStockOut(c *gin.Context) {
 // @Param        X-Store-ID  header  string  true  "Store ID"
 // @Success      204  "Stock-out deleted successfully"
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-out ID"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-out not found"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "Document is finalized or under approval"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/out/{id} [delete]
 func DeleteStockOut(c *gin.Context) {
@@ -200,14 +237,26 @@ func DeleteStockOut(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	err = stock_out_repository.DeleteStockOut(conn, id)
+	err = stock_out_repository.DeleteStockOut(conn, id, storeID)
 	if err != nil {
 		logger.Log.Errorf("Failed to delete stock out: %v", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockOut not found"})
+			return
+		}
+		if error_handler.HandleApprovalError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to delete stock out"})
 		return
 	}
@@ -224,8 +273,13 @@ func DeleteStockOut(c *gin.Context) {
 // @Produce      json
 // @Param        id          path    int     true  "Stock-out ID"
 // @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        dry_run     query   bool    false  "Run the finalization in a rolled back transaction and return the resulting stock levels"
 // @Success      204  "Stock-out finalized successfully"
+// @Success      200  {object}  dtoResponse.FinalizePreviewResponse "Dry run: stock levels after finalization, nothing committed"
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-out ID"
+// @Failure      422  {object}  dtoResponse.StorageLocationErrorResponse "Not enough stock at a pick location"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "Document needs an approval before it can be finalized"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-out not found"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/out/finalize/{id} [patch]
 func FinalizeStockOutByID(c *gin.Context) {
@@ -239,14 +293,51 @@ func FinalizeStockOutByID(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	err = stock_out_repository.FinalizeStockOutByID(conn, id)
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if dryRun {
+		changes, err := stock_out_repository.PreviewFinalizeStockOutByID(conn, id, storeID)
+		if err != nil {
+			logger.Log.Errorf("Failed to preview stock out finalization: %v", err)
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockOut not found"})
+				return
+			}
+			if error_handler.HandleStorageLocationError(c, err) {
+				return
+			}
+			if error_handler.HandleApprovalError(c, err) {
+				return
+			}
+			error_handler.HandleDBError(c, err, id)
+			return
+		}
+		c.JSON(http.StatusOK, dtoMapper.ToFinalizePreviewResponse(uint(id), changes))
+		return
+	}
+
+	err = stock_out_repository.FinalizeStockOutByID(conn, id, storeID)
 	if err != nil {
 		logger.Log.Errorf("Failed to finalize stock out: %v", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockOut not found"})
+			return
+		}
+		if error_handler.HandleStorageLocationError(c, err) {
+			return
+		}
+		if error_handler.HandleApprovalError(c, err) {
+			return
+		}
 		error_handler.HandleDBError(c, err, id)
 		return
 	}
@@ -265,6 +356,10 @@ func FinalizeStockOutByID(c *gin.Context) {
 // @Param        data        body    dtoRequest.UpdateStockOutRequest  true  "Stock-out update payload"
 // @Success      200  {object}  dtoResponse.StockOutResponse
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input"
+// @Failure      422  {object}  dtoResponse.ArchivedEntityReferencedErrorResponse "Item or packaging is archived, or location belongs to another store"
+// @Failure      422  {object}  dtoResponse.UnknownDocumentLineErrorResponse "Item or packaging line does not belong to the document"
+// @Failure      422  {object}  dtoResponse.StoreMismatchErrorResponse "Item or packaging belongs to another store"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-out not found"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/out [put]
 func UpdateStockOut(c *gin.Context) {
@@ -274,17 +369,107 @@ func UpdateStockOut(c *gin.Context) {
 	stockOutReq := c.MustGet("dto").(*dtoRequest.UpdateStockOutRequest)
 	stockOutModel := dtoMapper.UpdateStockOutToModel(stockOutReq)
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	err := stock_out_repository.UpdateStockOut(conn, stockOutModel)
+	err := stock_out_repository.UpdateStockOut(conn, stockOutModel, storeID)
 	if err != nil {
 		logger.Log.Error("Error updating stock out: ", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockOut not found"})
+			return
+		}
+		if error_handler.HandleArchivedReferenceError(c, err) {
+			return
+		}
+		if error_handler.HandleStoreMismatchError(c, err) {
+			return
+		}
+		if error_handler.HandleApprovalError(c, err) {
+			return
+		}
+		if error_handler.HandleStorageLocationError(c, err) {
+			return
+		}
+		if error_handler.HandleUnknownDocumentLineError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
 		return
 	}
 
 	c.JSON(http.StatusOK, dtoMapper.ToStockOutResponse(stockOutModel))
 }
+
+// ValidateStockOutByID godoc
+// @Summary      Validate a stock-out draft
+// @Description  Checks a stock-out the way finalization does, without changing it, and reports every problem per line instead of the first one only. Problems with severity error make finalization fail; taking more than the store holds is reported as a warning.
+// @Security     BearerAuth
+// @Tags         Stock Out
+// @Accept       json
+// @Produce      json
+// @Param        id          path    int     true  "Stock-out ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Success      200  {object}  dtoResponse.StockDocumentValidationResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-out ID"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-out not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/out/{id}/validate [get]
+func ValidateStockOutByID(c *gin.Context) {
+	logger.Log.Info("ValidateStockOutByID")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Invalid stock_out ID"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	stockOut, err := stock_out_repository.GetStockOutByID(conn, id, storeID)
+	if err != nil {
+		logger.Log.Errorf("Failed to retrieve stock out: %v", err)
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockOut not found"})
+		return
+	}
+
+	var itemIDs, packagingIDs []uint
+	for _, it := range stockOut.Items {
+		itemIDs = append(itemIDs, it.Item.ID)
+		for _, p := range it.Packagings {
+			packagingIDs = append(packagingIDs, p.ItemPackaging.ID)
+		}
+	}
+
+	packagings, err := item_packaging_repository.GetItemPackagingsByIDs(conn, packagingIDs)
+	if err != nil {
+		logger.Log.Errorf("Failed to load packagings: %v", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	levels, err := stock_repository.GetStockLevels(conn, itemIDs)
+	if err != nil {
+		logger.Log.Errorf("Failed to load stock levels: %v", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	validation := stock_validation_service.ValidateStockOut(stockOut, packagings, levels)
+	c.JSON(http.StatusOK, dtoMapper.ToStockDocumentValidationResponse(validation))
+}
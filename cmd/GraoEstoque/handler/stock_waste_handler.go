@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -12,10 +13,14 @@ import (
 
 	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
 
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_repository"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_waste_repository"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/error_handler"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/stock_validation_service"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
 // CreateStockWaste godoc
@@ -30,6 +35,8 @@ import (
 // @Success      201  {object}  dtoResponse.StockWasteResponse
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input or missing store ID"
 // @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      422  {object}  dtoResponse.ArchivedEntityReferencedErrorResponse "Item or packaging is archived, or location belongs to another store"
+// @Failure      422  {object}  dtoResponse.StoreMismatchErrorResponse "Item belongs to another store"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/waste [post]
 func CreateStockWaste(c *gin.Context) {
@@ -72,6 +79,15 @@ func CreateStockWaste(c *gin.Context) {
 	err = stock_waste_repository.SaveStockWaste(conn, model, storeID)
 	if err != nil {
 		logger.Log.Errorf("Failed to save stock waste: %v", err)
+		if error_handler.HandleArchivedReferenceError(c, err) {
+			return
+		}
+		if error_handler.HandleStoreMismatchError(c, err) {
+			return
+		}
+		if error_handler.HandleStorageLocationError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to save stock waste"})
 		return
 	}
@@ -103,12 +119,17 @@ func GetStockWasteByID(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	waste, err := stock_waste_repository.GetStockWasteByID(conn, id)
+	waste, err := stock_waste_repository.GetStockWasteByID(conn, id, storeID)
 	if err != nil {
 		logger.Log.Errorf("Failed to retrieve stock waste: %v", err)
 		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockWaste not found"})
@@ -125,9 +146,17 @@ func GetStockWasteByID(c *gin.Context) {
 // @Tags         Stock Waste
 // @Accept       json
 // @Produce      json
-// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        X-Store-ID   header  string  true   "Store ID"
+// @Param        limit        query   int     false  "Page size (1-200, default 20)"
+// @Param        cursor       query   string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset       query   int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort         query   string  false  "Comma-separated sort fields, prefix with - for descending (id, created_at, wasted_quantity, item_description)"
+// @Param        filter.{field}  query  string  false  "Filter by status, item_id, wasted_quantity, created_at or finalized_at; comma-separate values to match any, numeric and date fields also accept .min/.max"
+// @Param        category_id  query   int     false  "Only waste of items in this category or its subcategories"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
 // @Success      200  {array}   dtoResponse.StockWasteResponse
-// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid or missing store ID"
+// @Failure      400  {object}  dtoResponse.InvalidListQueryErrorResponse "Invalid or missing store ID, or invalid list query"
 // @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/waste [get]
@@ -151,10 +180,23 @@ func ListStockWaste(c *gin.Context) {
 		return
 	}
 
-	offset, _ := strconv.ParseUint(c.DefaultQuery("offset", "0"), 10, 0)
-	limit, _ := strconv.ParseUint(c.DefaultQuery("limit", "20"), 10, 0)
+	lq, ok := handler_util.ParseListQuery(c, stock_waste_repository.StockWasteListSpec)
+	if !ok {
+		return
+	}
+
+	var categoryID *uint
+	if raw := c.Query("category_id"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "category_id should be an integer"})
+			return
+		}
+		id := uint(parsed)
+		categoryID = &id
+	}
 
-	stockWastes, err := stock_waste_repository.ListStockWaste(conn, uint(offset), uint(limit), storeID)
+	stockWastes, page, err := stock_waste_repository.ListStockWaste(conn, storeID, categoryID, lq)
 	if err != nil {
 		logger.Log.Errorf("Error listing stock waste: %v", err)
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to retrieve stock waste list"})
@@ -167,6 +209,7 @@ func ListStockWaste(c *gin.Context) {
 		rep[i] = dtoMapper.ToStockWasteResponse(sw)
 	}
 
+	handler_util.SetPageHeaders(c, page)
 	c.JSON(http.StatusOK, rep)
 }
 
@@ -181,6 +224,8 @@ func ListStockWaste(c *gin.Context) {
 // @Param        X-Store-ID  header  string  true  "Store ID"
 // @Success      204 "Stock-waste deleted successfully"
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-waste ID"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock waste not found"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "Document is finalized or under approval"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/waste/{id} [delete]
 func DeleteStockWaste(c *gin.Context) {
@@ -194,14 +239,26 @@ func DeleteStockWaste(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	err = stock_waste_repository.DeleteStockWasteByID(conn, id)
+	err = stock_waste_repository.DeleteStockWasteByID(conn, id, storeID)
 	if err != nil {
 		logger.Log.Errorf("Failed to delete stock waste: %v", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockWaste not found"})
+			return
+		}
+		if error_handler.HandleApprovalError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Failed to delete stock waste"})
 		return
 	}
@@ -218,8 +275,13 @@ func DeleteStockWaste(c *gin.Context) {
 // @Produce      json
 // @Param        id          path    int     true  "Stock-waste ID"
 // @Param        X-Store-ID  header  string  true  "Store ID"
+// @Param        dry_run     query   bool    false  "Run the finalization in a rolled back transaction and return the resulting stock levels"
 // @Success      204  "Stock-waste finalized successfully"
+// @Success      200  {object}  dtoResponse.FinalizePreviewResponse "Dry run: stock levels after finalization, nothing committed"
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-waste ID"
+// @Failure      422  {object}  dtoResponse.StorageLocationErrorResponse "Not enough stock at the waste location or in unassigned stock"
+// @Failure      422  {object}  dtoResponse.ApprovalErrorResponse "Document needs an approval before it can be finalized"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock waste not found"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/waste/finalize/{id} [patch]
 func FinalizeStockWasteByID(c *gin.Context) {
@@ -233,14 +295,51 @@ func FinalizeStockWasteByID(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	err = stock_waste_repository.FinalizeStockWasteByID(conn, id)
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if dryRun {
+		changes, err := stock_waste_repository.PreviewFinalizeStockWasteByID(conn, id, storeID)
+		if err != nil {
+			logger.Log.Errorf("Failed to preview stock waste finalization: %v", err)
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockWaste not found"})
+				return
+			}
+			if error_handler.HandleStorageLocationError(c, err) {
+				return
+			}
+			if error_handler.HandleApprovalError(c, err) {
+				return
+			}
+			error_handler.HandleDBError(c, err, id)
+			return
+		}
+		c.JSON(http.StatusOK, dtoMapper.ToFinalizePreviewResponse(uint(id), changes))
+		return
+	}
+
+	err = stock_waste_repository.FinalizeStockWasteByID(conn, id, storeID)
 	if err != nil {
 		logger.Log.Errorf("Failed to finalize stock waste: %v", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockWaste not found"})
+			return
+		}
+		if error_handler.HandleStorageLocationError(c, err) {
+			return
+		}
+		if error_handler.HandleApprovalError(c, err) {
+			return
+		}
 		error_handler.HandleDBError(c, err, id)
 		return
 	}
@@ -259,6 +358,9 @@ func FinalizeStockWasteByID(c *gin.Context) {
 // @Param        data        body    dtoRequest.UpdateStockWasteRequest true  "Stock-waste update payload"
 // @Success      200  {object}  dtoResponse.StockWasteResponse
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input"
+// @Failure      422  {object}  dtoResponse.ArchivedEntityReferencedErrorResponse "Item or packaging is archived, or location belongs to another store"
+// @Failure      422  {object}  dtoResponse.StoreMismatchErrorResponse "Item belongs to another store"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock waste not found"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stock/waste [put]
 func UpdateStockWaste(c *gin.Context) {
@@ -268,17 +370,89 @@ func UpdateStockWaste(c *gin.Context) {
 	wasteReq := c.MustGet("dto").(*dtoRequest.UpdateStockWasteRequest)
 	wasteModel := dtoMapper.UpdateStockWasteToModel(wasteReq, 0) // You can replace 0 with real user ID if needed
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	err := stock_waste_repository.UpdateStockWaste(conn, wasteModel)
+	err := stock_waste_repository.UpdateStockWaste(conn, wasteModel, storeID)
 	if err != nil {
 		logger.Log.Errorf("Error updating stock waste: %v", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockWaste not found"})
+			return
+		}
+		if error_handler.HandleArchivedReferenceError(c, err) {
+			return
+		}
+		if error_handler.HandleStoreMismatchError(c, err) {
+			return
+		}
+		if error_handler.HandleStorageLocationError(c, err) {
+			return
+		}
+		if error_handler.HandleApprovalError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
 		return
 	}
 
 	c.JSON(http.StatusOK, dtoMapper.ToStockWasteResponse(wasteModel))
 }
+
+// ValidateStockWasteByID godoc
+// @Summary      Validate a stock-waste draft
+// @Description  Checks a stock-waste entry before finalization without changing it and reports every problem found, e.g. wasting more than the store holds.
+// @Security     BearerAuth
+// @Tags         Stock Waste
+// @Accept       json
+// @Produce      json
+// @Param        id          path    int     true  "Stock-waste ID"
+// @Param        X-Store-ID  header  string  true  "Store ID"
+// @Success      200  {object}  dtoResponse.StockDocumentValidationResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid stock-waste ID"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Stock-waste not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stock/waste/{id}/validate [get]
+func ValidateStockWasteByID(c *gin.Context) {
+	logger.Log.Info("ValidateStockWasteByID")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Invalid stock_waste ID"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	waste, err := stock_waste_repository.GetStockWasteByID(conn, id, storeID)
+	if err != nil {
+		logger.Log.Errorf("Failed to retrieve stock waste: %v", err)
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "StockWaste not found"})
+		return
+	}
+
+	levels, err := stock_repository.GetStockLevels(conn, []uint{waste.Item.ID})
+	if err != nil {
+		logger.Log.Errorf("Failed to load stock levels: %v", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	validation := stock_validation_service.ValidateStockWaste(waste, levels)
+	c.JSON(http.StatusOK, dtoMapper.ToStockDocumentValidationResponse(validation))
+}
@@ -0,0 +1,400 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/storage_location_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/error_handler"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+)
+
+// ListStorageLocations godoc
+// @Summary      List storage locations
+// @Description  Retrieves every storage location (stockroom, shelf area, cold room...) of the store
+// @Security     BearerAuth
+// @Tags         Storage Location
+// @Produce      json
+// @Param        X-Store-ID  header    string  true   "Store ID"
+// @Param        limit       query   int     false  "Page size (1-200, default 20)"
+// @Param        cursor      query   string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset      query   int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort        query   string  false  "Comma-separated sort fields, prefix with - for descending (id, name, created_at)"
+// @Param        filter.{field}  query  string  false  "Filter by name or created_at; comma-separate values to match any, date fields also accept .min/.max"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
+// @Success      200  {array}  response.StorageLocationResponse
+// @Failure      400  {object}  response.InvalidListQueryErrorResponse "Invalid or missing store ID, or invalid list query"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /stock/locations [get]
+func ListStorageLocations(c *gin.Context) {
+	logger.Log.Info("ListStorageLocations")
+
+	storeID, err := util.GetStoreIDFromContext(c)
+	if err != nil {
+		if err == util.ErrNoStoreID {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "store id not found"})
+		} else {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid store id"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	lq, ok := handler_util.ParseListQuery(c, storage_location_repository.StorageLocationListSpec)
+	if !ok {
+		return
+	}
+
+	models, page, err := storage_location_repository.ListStorageLocations(conn, storeID, lq)
+	if err != nil {
+		logger.Log.Error("Error listing storage locations: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error listing storage locations"})
+		return
+	}
+
+	resp := make([]response.StorageLocationResponse, len(models))
+	for i, m := range models {
+		resp[i] = *mapper.ToStorageLocationResponse(&m)
+	}
+
+	handler_util.SetPageHeaders(c, page)
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetStorageLocationByID godoc
+// @Summary      Get storage location by ID
+// @Description  Retrieves a specific storage location by its ID
+// @Security     BearerAuth
+// @Tags         Storage Location
+// @Produce      json
+// @Param        id          path     int     true  "Location ID"
+// @Param        X-Store-ID  header   string  true  "Store ID"
+// @Success      200  {object}  response.StorageLocationResponse
+// @Failure      400  {object}  response.ErrorResponse "Invalid ID"
+// @Failure      404  {object}  response.ErrorResponse "Storage location not found"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /stock/locations/{id} [get]
+func GetStorageLocationByID(c *gin.Context) {
+	logger.Log.Info("GetStorageLocationByID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	location, err := storage_location_repository.GetStorageLocationByID(conn, uint(id), storeID)
+	if err != nil {
+		logger.Log.Error("Error retrieving storage location: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error retrieving storage location"})
+		return
+	}
+	if location == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Storage location not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToStorageLocationResponse(location))
+}
+
+// CreateStorageLocation godoc
+// @Summary      Create a storage location
+// @Description  Creates a new storage location in the store
+// @Security     BearerAuth
+// @Tags         Storage Location
+// @Accept       json
+// @Produce      json
+// @Param        X-Store-ID  header  string                                true  "Store ID"
+// @Param        data        body    request.CreateStorageLocationRequest  true  "Storage location creation payload"
+// @Success      201  {object}  response.StorageLocationResponse
+// @Failure      400  {object}  response.ErrorResponse "Invalid input or store ID"
+// @Failure      401  {object}  response.ErrorResponse "Unauthorized"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /stock/locations [post]
+func CreateStorageLocation(c *gin.Context) {
+	logger.Log.Info("CreateStorageLocation")
+
+	req := c.MustGet("dto").(*request.CreateStorageLocationRequest)
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	storeID, err := util.GetStoreIDFromContext(c)
+	if err != nil {
+		if err == util.ErrNoStoreID {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "store id not found"})
+		} else {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid store id"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	location := mapper.CreateStorageLocationToModel(req, user.ID, storeID)
+	if err := storage_location_repository.SaveStorageLocation(conn, location); err != nil {
+		logger.Log.Error("Error saving storage location: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error saving storage location"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, mapper.ToStorageLocationResponse(location))
+}
+
+// UpdateStorageLocation godoc
+// @Summary      Update a storage location
+// @Description  Renames an existing storage location
+// @Security     BearerAuth
+// @Tags         Storage Location
+// @Accept       json
+// @Produce      json
+// @Param        X-Store-ID  header  string                                true  "Store ID"
+// @Param        data        body    request.UpdateStorageLocationRequest  true  "Storage location update payload"
+// @Success      200  {object}  response.StorageLocationResponse
+// @Failure      400  {object}  response.ErrorResponse "Invalid input"
+// @Failure      404  {object}  response.ErrorResponse "Storage location not found"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /stock/locations [put]
+func UpdateStorageLocation(c *gin.Context) {
+	logger.Log.Info("UpdateStorageLocation")
+
+	req := c.MustGet("dto").(*request.UpdateStorageLocationRequest)
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	location := mapper.UpdateStorageLocationToModel(req)
+	location.StoreID = storeID
+
+	updated, err := storage_location_repository.UpdateStorageLocation(conn, location)
+	if err != nil {
+		logger.Log.Error("Error updating storage location: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error updating storage location"})
+		return
+	}
+	if updated == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Storage location not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToStorageLocationResponse(updated))
+}
+
+// DeleteStorageLocation godoc
+// @Summary      Delete a storage location
+// @Description  Deletes an empty storage location. Returns 409 if it still holds stock or is used by stock documents.
+// @Security     BearerAuth
+// @Tags         Storage Location
+// @Produce      json
+// @Param        id          path     int     true  "Location ID"
+// @Param        X-Store-ID  header   string  true  "Store ID"
+// @Success      204  "Storage location deleted successfully"
+// @Failure      400  {object}  response.ErrorResponse "Invalid ID"
+// @Failure      409  {object}  response.ForeignKeyDeleteReferencedErrorResponse "Location is still referenced by other records"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /stock/locations/{id} [delete]
+func DeleteStorageLocation(c *gin.Context) {
+	logger.Log.Info("DeleteStorageLocation")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	if err := storage_location_repository.DeleteStorageLocation(conn, uint(id), storeID); err != nil {
+		logger.Log.Error("Error deleting storage location: ", err)
+		error_handler.HandleDBErrorWithReferencingFetcher(c,
+			err,
+			uint(id),
+			storage_location_repository.GetReferencingItems,
+			func(entities any) any {
+				internal := entities.([]model.Item)
+				var dtos []response.ItemResponse
+				for _, i := range internal {
+					dtos = append(dtos, mapper.ToItemResponse(&i))
+				}
+				return dtos
+			})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListStockRelocations godoc
+// @Summary      List stock relocations
+// @Description  Retrieves a paginated history of internal stock moves between locations of the store
+// @Security     BearerAuth
+// @Tags         Storage Location
+// @Produce      json
+// @Param        X-Store-ID  header    string  true   "Store ID"
+// @Param        limit       query   int     false  "Page size (1-200, default 20)"
+// @Param        cursor      query   string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset      query   int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort        query   string  false  "Comma-separated sort fields, prefix with - for descending (id, created_at, quantity, item_description)"
+// @Param        filter.{field}  query  string  false  "Filter by item_id, from_location_id, to_location_id, quantity, created_by or created_at; comma-separate values to match any, numeric and date fields also accept .min/.max"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
+// @Success      200  {array}  response.StockRelocationResponse
+// @Failure      400  {object}  response.InvalidListQueryErrorResponse "Invalid or missing store ID, or invalid list query"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /stock/relocations [get]
+func ListStockRelocations(c *gin.Context) {
+	logger.Log.Info("ListStockRelocations")
+
+	storeID, err := util.GetStoreIDFromContext(c)
+	if err != nil {
+		if err == util.ErrNoStoreID {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "store id not found"})
+		} else {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid store id"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	lq, ok := handler_util.ParseListQuery(c, storage_location_repository.StockRelocationListSpec)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	models, page, err := storage_location_repository.ListStockRelocations(conn, storeID, lq)
+	if err != nil {
+		logger.Log.Error("Error listing stock relocations: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error listing stock relocations"})
+		return
+	}
+
+	resp := make([]response.StockRelocationResponse, len(models))
+	for i, m := range models {
+		resp[i] = *mapper.ToStockRelocationResponse(&m)
+	}
+
+	handler_util.SetPageHeaders(c, page)
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateStockRelocation godoc
+// @Summary      Relocate stock
+// @Description  Moves a quantity of an item from one location to another. Omitting from_location_id puts away stock that is not assigned to any location yet.
+// @Security     BearerAuth
+// @Tags         Storage Location
+// @Accept       json
+// @Produce      json
+// @Param        X-Store-ID  header  string                                true  "Store ID"
+// @Param        data        body    request.CreateStockRelocationRequest  true  "Relocation payload"
+// @Success      201  {object}  response.StockRelocationResponse
+// @Failure      400  {object}  response.ErrorResponse "Invalid input or store ID"
+// @Failure      401  {object}  response.ErrorResponse "Unauthorized"
+// @Failure      422  {object}  response.StorageLocationErrorResponse "Location belongs to another store or lacks stock"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /stock/relocations [post]
+func CreateStockRelocation(c *gin.Context) {
+	logger.Log.Info("CreateStockRelocation")
+
+	req := c.MustGet("dto").(*request.CreateStockRelocationRequest)
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	storeID, err := util.GetStoreIDFromContext(c)
+	if err != nil {
+		if err == util.ErrNoStoreID {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "store id not found"})
+		} else {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid store id"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	relocation := mapper.CreateStockRelocationToModel(req, user.ID, storeID)
+	if err := storage_location_repository.SaveStockRelocation(conn, relocation); err != nil {
+		logger.Log.Error("Error saving stock relocation: ", err)
+		if error_handler.HandleStorageLocationError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error saving stock relocation"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, mapper.ToStockRelocationResponse(relocation))
+}
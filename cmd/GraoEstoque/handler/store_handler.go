@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_approval_repository"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/store_repository"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/error_handler"
 	mapper "github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
@@ -16,13 +18,22 @@ import (
 
 // GetStores godoc
 // @Summary      List all stores
-// @Description  Retrieves all stores created by the authenticated user
+// @Description  Retrieves the stores the authenticated user is a member of and has a role in, or every store for organization owners. An API key restricted to a store only lists that store.
 // @Security     BearerAuth
 // @Tags         Store
 // @Accept       json
 // @Produce      json
+// @Param        limit       query   int     false  "Page size (1-200, default 20)"
+// @Param        cursor      query   string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset      query   int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort        query   string  false  "Comma-separated sort fields, prefix with - for descending (id, name, created_at)"
+// @Param        filter.{field}  query  string  false  "Filter by name or created_at; comma-separate values to match any, date fields also accept .min/.max"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
 // @Success      200  {array}  dtoResponse.StoreResponse
+// @Failure      400  {object}  dtoResponse.InvalidListQueryErrorResponse "Invalid list query"
 // @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      403  {object}  dtoResponse.PermissionDeniedResponse "API key without the store:read scope"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stores [get]
 func GetStores(c *gin.Context) {
@@ -45,7 +56,18 @@ func GetStores(c *gin.Context) {
 		return
 	}
 
-	stores, err := store_repository.ListStoresPaginated(conn, user.ID, 0, 100)
+	lq, ok := handler_util.ParseListQuery(c, store_repository.StoreListSpec)
+	if !ok {
+		return
+	}
+
+	// A key restricted to a store only lists that store
+	var onlyStoreID *uint
+	if key, isKey := util.GetAPIKeyFromContext(c); isKey {
+		onlyStoreID = key.StoreID
+	}
+
+	stores, page, err := store_repository.ListStoresPaginated(conn, user.ID, onlyStoreID, lq)
 	if err != nil {
 		logger.Log.Error("Error fetching stores: ", err)
 		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
@@ -57,6 +79,7 @@ func GetStores(c *gin.Context) {
 		res = append(res, mapper.ToStoreResponse(&s))
 	}
 
+	handler_util.SetPageHeaders(c, page)
 	c.JSON(http.StatusOK, res)
 }
 
@@ -147,7 +170,7 @@ func CreateStore(c *gin.Context) {
 
 // UpdateStore godoc
 // @Summary      Update a store
-// @Description  Updates an existing store for the authenticated user
+// @Description  Updates an existing store the authenticated user is a member of
 // @Security     BearerAuth
 // @Tags         Store
 // @Accept       json
@@ -156,6 +179,7 @@ func CreateStore(c *gin.Context) {
 // @Success      200  {object}  dtoResponse.StoreResponse
 // @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input"
 // @Failure      401  {object}  dtoResponse.ErrorResponse "Unauthorized"
+// @Failure      403  {object}  dtoResponse.StoreAccessDeniedResponse "Not a member of the store, or the API key is restricted to another store"
 // @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
 // @Router       /stores [put]
 func UpdateStore(c *gin.Context) {
@@ -178,6 +202,7 @@ func UpdateStore(c *gin.Context) {
 	if conn == nil {
 		return
 	}
+
 	store := mapper.UpdateStoreToModel(req, user.ID)
 	updated, err := store_repository.UpdateStore(conn, store)
 	if err != nil {
@@ -228,3 +253,100 @@ func DeleteStore(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// GetApprovalPolicy godoc
+// @Summary      Get a store's approval policy
+// @Description  Returns which stock documents of the store need an approval before finalization. Stores that never saved a policy get a disabled one.
+// @Security     BearerAuth
+// @Tags         Store
+// @Accept       json
+// @Produce      json
+// @Param        id   path  int  true  "Store ID"
+// @Success      200  {object}  dtoResponse.ApprovalPolicyResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Store not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stores/{id}/approval-policy [get]
+func GetApprovalPolicy(c *gin.Context) {
+	logger.Log.Info("GetApprovalPolicy")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Id must be a number"})
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	store, err := store_repository.GetStoreByID(conn, uint(id))
+	if err != nil {
+		logger.Log.Error("Error getting store: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	} else if store == nil {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Store not found"})
+		return
+	}
+
+	policy, err := stock_approval_repository.GetApprovalPolicy(conn, store.ID)
+	if err != nil {
+		logger.Log.Error("Error getting approval policy: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToApprovalPolicyResponse(policy))
+}
+
+// UpdateApprovalPolicy godoc
+// @Summary      Update a store's approval policy
+// @Description  Replaces the store's approval policy. When enabled, waste and stock-outs can require an approval, and stock-ins whose value (buy price times quantity) exceeds the threshold do.
+// @Security     BearerAuth
+// @Tags         Store
+// @Accept       json
+// @Produce      json
+// @Param        id    path  int                                      true  "Store ID"
+// @Param        data  body  dtoRequest.UpdateApprovalPolicyRequest  true  "Approval policy"
+// @Success      200  {object}  dtoResponse.ApprovalPolicyResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid input"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Store not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stores/{id}/approval-policy [put]
+func UpdateApprovalPolicy(c *gin.Context) {
+	logger.Log.Info("UpdateApprovalPolicy")
+
+	req := c.MustGet("dto").(*dtoRequest.UpdateApprovalPolicyRequest)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Id must be a number"})
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	store, err := store_repository.GetStoreByID(conn, uint(id))
+	if err != nil {
+		logger.Log.Error("Error getting store: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	} else if store == nil {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Store not found"})
+		return
+	}
+
+	policy := mapper.UpdateApprovalPolicyToModel(req, store.ID)
+	if err := stock_approval_repository.SaveApprovalPolicy(conn, policy); err != nil {
+		logger.Log.Error("Error saving approval policy: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToApprovalPolicyResponse(policy))
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/store_member_repository"
+	mapper "github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
+	dtoResponse "github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// ListStoreMembers godoc
+// @Summary      List store members
+// @Description  Lists the users that may use the store. Organization owners may use every store without being listed.
+// @Security     BearerAuth
+// @Tags         Store
+// @Produce      json
+// @Param        id   path  int  true  "Store ID"
+// @Success      200  {array}   dtoResponse.StoreMemberResponse
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      403  {object}  dtoResponse.StoreAccessDeniedResponse "Not a member of the store"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stores/{id}/members [get]
+func ListStoreMembers(c *gin.Context) {
+	logger.Log.Info("ListStoreMembers")
+
+	storeID, ok := storeFromParam(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	members, err := store_member_repository.ListStoreMembers(conn, storeID)
+	if err != nil {
+		logger.Log.Error("Error listing store members: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToStoreMemberResponseList(members))
+}
+
+// AddStoreMember godoc
+// @Summary      Add a store member
+// @Description  Lets the user use the store. Adding an existing member does nothing.
+// @Security     BearerAuth
+// @Tags         Store
+// @Param        id      path  int  true  "Store ID"
+// @Param        userId  path  int  true  "User ID"
+// @Success      204  "Member added"
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      403  {object}  dtoResponse.StoreAccessDeniedResponse "Not a member of the store"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "User not found in the organization"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stores/{id}/members/{userId} [put]
+func AddStoreMember(c *gin.Context) {
+	logger.Log.Info("AddStoreMember")
+
+	storeID, ok := storeFromParam(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "User id must be a number"})
+		return
+	}
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, dtoResponse.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	found, err := store_member_repository.AddStoreMember(conn, storeID, uint(userID), user.ID, user.Organization.ID)
+	if err != nil {
+		logger.Log.Error("Error adding store member: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "User not found in the organization"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveStoreMember godoc
+// @Summary      Remove a store member
+// @Description  Revokes the user's access to the store, along with its role there.
+// @Security     BearerAuth
+// @Tags         Store
+// @Param        id      path  int  true  "Store ID"
+// @Param        userId  path  int  true  "User ID"
+// @Success      204  "Member removed"
+// @Failure      400  {object}  dtoResponse.ErrorResponse "Invalid ID"
+// @Failure      403  {object}  dtoResponse.StoreAccessDeniedResponse "Not a member of the store"
+// @Failure      404  {object}  dtoResponse.ErrorResponse "Member not found"
+// @Failure      500  {object}  dtoResponse.ErrorResponse "Internal server error"
+// @Router       /stores/{id}/members/{userId} [delete]
+func RemoveStoreMember(c *gin.Context) {
+	logger.Log.Info("RemoveStoreMember")
+
+	storeID, ok := storeFromParam(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "User id must be a number"})
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	found, err := store_member_repository.RemoveStoreMember(conn, storeID, uint(userID))
+	if err != nil {
+		logger.Log.Error("Error removing store member: ", err)
+		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, dtoResponse.ErrorResponse{Error: "Member not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
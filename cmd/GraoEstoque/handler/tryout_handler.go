@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -13,6 +14,7 @@ import (
 	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/auth_service"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/service/tryout_service"
 	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
 	"github.com/gin-gonic/gin"
@@ -46,33 +48,27 @@ func GetTryOutJobStatus(c *gin.Context) {
 	})
 }
 
-func HandleTryOutFlow(c *gin.Context, googleUser model.GoogleUserInfo, frontendURL string) {
-	user := util.NewUserFromGoogleUserInfo(googleUser)
+func HandleTryOutFlow(c *gin.Context, identity model.Identity, frontendURL string) {
+	user := util.NewUserFromIdentity(identity)
 
-	job, err := tryout_service.PublishTryOutEnvironmentJob(user)
+	job, err := tryout_service.PublishTryOutEnvironmentJob(user, &identity)
 	if err != nil {
 		logger.Log.Error("Try-out setup failed: ", err)
 		auth_handler_util.RedirectWithError(c, frontendURL, "Failed to start try-out environment.", errorCodes.CodeStartTryOutEnvironment)
 		return
 	}
 
-	jwt, err := util.GenerateTryOutJWT(job.CreatedBy, *job.Organization.ExpiresAt)
+	code, err := auth_service.IssueLoginCode(job.CreatedBy.ID, job.TryoutUUID)
 	if err != nil {
-		logger.Log.Error("failed to generate JWT: ", err)
+		logger.Log.Error("failed to issue login code: ", err)
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to generate token"})
 		return
 	}
 
-	user_repository.StampNowLastLogin(job.CreatedBy.ID)
-
 	redirectURL := fmt.Sprintf(
-		"%s/OAuthCallback?isTryOut=true&uuid=%s&token=%s&name=%s&email=%s&user_picture_url=%s",
+		"%s/OAuthCallback?isTryOut=true&code=%s",
 		frontendURL,
-		job.TryoutUUID,
-		jwt,
-		url.QueryEscape(user.GivenName),
-		url.QueryEscape(user.Email),
-		url.QueryEscape(user.PictureURL),
+		url.QueryEscape(code),
 	)
 
 	c.Redirect(http.StatusFound, redirectURL)
@@ -87,6 +83,7 @@ func HandleTryOutFlow(c *gin.Context, googleUser model.GoogleUserInfo, frontendU
 // @Produce      json
 // @Success      204  "Try-out environment destroyed successfully"
 // @Failure      401  {object}  response.ErrorResponse "Unauthorized"
+// @Failure      409  {object}  response.AuthErrorResponse "Organization is not a try-out"
 // @Failure      500  {object}  response.ErrorResponse "Error destroying try-out environment"
 // @Router       /tryOut/destroyEnv [post]
 func DestroyTryOutEnvironment(c *gin.Context) {
@@ -104,13 +101,91 @@ func DestroyTryOutEnvironment(c *gin.Context) {
 		return
 	}
 
-	err = tryout_job_repository.DestroyTryOutEnvironment(user.Organization.ID)
+	destroyed, err := tryout_job_repository.DestroyTryOutEnvironment(user.Organization.ID)
 
 	if err != nil {
 		logger.Log.Errorf("Error destroying organization id:%d", user.Organization.ID)
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error destroying organization environment"})
 		return
 	}
+	if !destroyed {
+		c.JSON(http.StatusConflict, response.AuthErrorResponse{
+			Error:        "The organization is not a try-out.",
+			InternalCode: errorCodes.CodeOrganizationNotTryOut,
+		})
+		return
+	}
 
 	c.Status(http.StatusNoContent)
 }
+
+// ConvertTryOutEnvironment godoc
+// @Summary      Convert the try-out into a permanent organization
+// @Description  Makes the authenticated user's try-out organization permanent, keeping all its data. A schema with a try-out name is renamed after the organization. Every session of the organization's users ends; the caller gets a new session with regular tokens. Organization owners only.
+// @Tags         Try Out Environment
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  response.LoginResponse
+// @Failure      401  {object}  response.ErrorResponse "Unauthorized"
+// @Failure      403  {object}  response.PermissionDeniedResponse "Permission denied"
+// @Failure      409  {object}  response.AuthErrorResponse "Organization is not a try-out"
+// @Failure      500  {object}  response.ErrorResponse "Error converting try-out environment"
+// @Router       /tryOut/convert [post]
+func ConvertTryOutEnvironment(c *gin.Context) {
+	logger.Log.Info("ConvertTryOutEnvironment")
+
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return
+	}
+
+	current, err := user_repository.GetUserWithOrganizationByID(user.ID)
+	if err != nil || current == nil {
+		logger.Log.Errorf("Error loading user id:%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error converting try-out environment"})
+		return
+	}
+
+	schema, err := tryout_service.ConvertTryOutEnvironment(current.Organization)
+	if err != nil {
+		if errors.Is(err, tryout_service.ErrNotTryOut) {
+			c.JSON(http.StatusConflict, response.AuthErrorResponse{
+				Error:        "The organization is not a try-out.",
+				InternalCode: errorCodes.CodeOrganizationNotTryOut,
+			})
+			return
+		}
+		logger.Log.Errorf("Error converting organization id:%d: %v", current.Organization.ID, err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error converting try-out environment"})
+		return
+	}
+
+	current.Organization.IsTryOut = false
+	current.Organization.ExpiresAt = nil
+	current.Organization.DBSchema = schema
+
+	tokens, err := auth_service.StartSession(current, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		logger.Log.Error("failed to start session: ", err.Error())
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.LoginResponse{
+		Token:          tokens.AccessToken,
+		RefreshToken:   tokens.RefreshToken,
+		ExpiresAt:      tokens.AccessTokenExpiresAt.Unix(),
+		Name:           current.GivenName,
+		Email:          current.Email,
+		UserPictureURL: current.PictureURL,
+		IsTryOut:       false,
+	})
+}
@@ -0,0 +1,390 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/user_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/auth_service"
+	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorLoginHandler godoc
+// @Summary Complete a password login with a second factor
+// @Description Exchanges the token of a password login that needed a second factor, and a code from the authenticator app or a backup code, for the session tokens. Users enrolling because the organization requires 2FA confirm the enrollment with the code, and get their backup codes in this response. Failed codes count as failed logins.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body request.TwoFactorLoginRequest true "Login token and code"
+// @Success 200 {object} response.LoginResponse
+// @Failure 400 {object} response.AuthErrorResponse "Invalid or expired login token"
+// @Failure 401 {object} response.AuthErrorResponse "Invalid code"
+// @Failure 409 {object} response.AuthErrorResponse "Two-factor enrollment required"
+// @Failure 422 {object} response.ErrorResponse "Validation error"
+// @Failure 429 {object} response.AuthErrorResponse "Too many failed attempts"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/2fa/login [post]
+func TwoFactorLoginHandler(c *gin.Context) {
+	logger.Log.Info("TwoFactorLoginHandler")
+
+	req := c.MustGet("dto").(*request.TwoFactorLoginRequest)
+
+	user, backupCodes, err := auth_service.CompleteTwoFactorLogin(req.TwoFactorToken, req.Code, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, auth_service.ErrTooManyAttempts) {
+			c.Header("Retry-After", strconv.Itoa(int(auth_service.LoginThrottleWindow.Seconds())))
+			c.JSON(http.StatusTooManyRequests, response.AuthErrorResponse{
+				Error:        "too many failed login attempts, try again later",
+				InternalCode: errorCodes.CodeTooManyLoginAttempts,
+			})
+			return
+		}
+		handleTwoFactorError(c, err)
+		return
+	}
+
+	tokens, err := auth_service.StartSession(user, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		logger.Log.Error("failed to start session: ", err.Error())
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to generate token"})
+		return
+	}
+
+	user_repository.StampNowLastLogin(user.ID)
+
+	c.JSON(http.StatusOK, response.LoginResponse{
+		Token:          tokens.AccessToken,
+		RefreshToken:   tokens.RefreshToken,
+		ExpiresAt:      tokens.AccessTokenExpiresAt.Unix(),
+		Name:           user.GivenName,
+		Email:          user.Email,
+		UserPictureURL: user.PictureURL,
+		IsTryOut:       user.Organization.IsTryOut,
+		BackupCodes:    backupCodes,
+	})
+}
+
+// TwoFactorLoginSetupHandler godoc
+// @Summary Enroll in 2FA during a password login
+// @Description For users whose organization requires 2FA and who have none yet. Returns a new TOTP secret for the token of their password login; sending a code of it to /auth/2fa/login enables 2FA and completes the login.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param body body request.TwoFactorTokenRequest true "Login token"
+// @Success 200 {object} response.TwoFactorSetupResponse
+// @Failure 400 {object} response.AuthErrorResponse "Invalid or expired login token"
+// @Failure 409 {object} response.AuthErrorResponse "2FA already enabled"
+// @Failure 422 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/2fa/login/setup [post]
+func TwoFactorLoginSetupHandler(c *gin.Context) {
+	logger.Log.Info("TwoFactorLoginSetupHandler")
+
+	req := c.MustGet("dto").(*request.TwoFactorTokenRequest)
+
+	setup, err := auth_service.SetupTwoFactorForLogin(req.TwoFactorToken)
+	if err != nil {
+		handleTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TwoFactorSetupResponse{
+		Secret:     setup.Secret,
+		OTPAuthURI: setup.ProvisioningURI,
+	})
+}
+
+// GetTwoFactorStatusHandler godoc
+// @Summary Get the user's 2FA status
+// @Description Tells whether the authenticated user has 2FA enabled, how many backup codes are left and whether the organization requires 2FA.
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} response.TwoFactorStatusResponse
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/2fa [get]
+func GetTwoFactorStatusHandler(c *gin.Context) {
+	logger.Log.Info("GetTwoFactorStatusHandler")
+
+	user, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	member, err := user_repository.GetUserWithOrganizationByID(user.ID)
+	if err != nil || member == nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to get user"})
+		return
+	}
+
+	tf, err := auth_service.GetTwoFactor(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	status := response.TwoFactorStatusResponse{Required: member.Organization.RequireTwoFactor}
+	if tf != nil {
+		status.Enabled = tf.EnabledAt != nil
+		status.Pending = tf.EnabledAt == nil
+		if status.Enabled {
+			status.BackupCodesLeft = tf.BackupCodesLeft
+		}
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SetupTwoFactorHandler godoc
+// @Summary Start 2FA enrollment
+// @Description Returns a new TOTP secret and its otpauth URI, to show as a QR code. 2FA is enabled once /auth/2fa/confirm gets a code of it. Only for users who log in with a password.
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} response.TwoFactorSetupResponse
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 403 {object} response.AuthErrorResponse "User has no password login"
+// @Failure 409 {object} response.AuthErrorResponse "2FA already enabled"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/2fa/setup [post]
+func SetupTwoFactorHandler(c *gin.Context) {
+	logger.Log.Info("SetupTwoFactorHandler")
+
+	user, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	setup, err := auth_service.SetupTwoFactor(user.ID)
+	if err != nil {
+		handleTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TwoFactorSetupResponse{
+		Secret:     setup.Secret,
+		OTPAuthURI: setup.ProvisioningURI,
+	})
+}
+
+// ConfirmTwoFactorHandler godoc
+// @Summary Confirm 2FA enrollment
+// @Description Enables 2FA with the first code of the authenticator app and returns the backup codes. They are not shown again.
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body request.TwoFactorCodeRequest true "Authenticator code"
+// @Success 200 {object} response.BackupCodesResponse
+// @Failure 401 {object} response.AuthErrorResponse "Invalid code"
+// @Failure 409 {object} response.AuthErrorResponse "No enrollment to confirm"
+// @Failure 422 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/2fa/confirm [post]
+func ConfirmTwoFactorHandler(c *gin.Context) {
+	logger.Log.Info("ConfirmTwoFactorHandler")
+
+	user, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	req := c.MustGet("dto").(*request.TwoFactorCodeRequest)
+
+	codes, err := auth_service.ConfirmTwoFactor(user.ID, req.Code)
+	if err != nil {
+		handleTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.BackupCodesResponse{BackupCodes: codes})
+}
+
+// DisableTwoFactorHandler godoc
+// @Summary Disable 2FA
+// @Description Turns 2FA off and drops the backup codes, after checking the password and an authenticator or backup code. Not allowed while the organization requires 2FA.
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Param body body request.TwoFactorReauthRequest true "Password and code"
+// @Success 204 "2FA disabled"
+// @Failure 401 {object} response.AuthErrorResponse "Invalid password or code"
+// @Failure 409 {object} response.AuthErrorResponse "2FA not enabled or required by the organization"
+// @Failure 422 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/2fa/disable [post]
+func DisableTwoFactorHandler(c *gin.Context) {
+	logger.Log.Info("DisableTwoFactorHandler")
+
+	user, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	req := c.MustGet("dto").(*request.TwoFactorReauthRequest)
+
+	if err := auth_service.DisableTwoFactor(user.ID, req.Password, req.Code); err != nil {
+		handleTwoFactorError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RegenerateBackupCodesHandler godoc
+// @Summary Replace the 2FA backup codes
+// @Description Replaces all backup codes, used or not, after checking the password and an authenticator or backup code. The new codes are not shown again.
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body request.TwoFactorReauthRequest true "Password and code"
+// @Success 200 {object} response.BackupCodesResponse
+// @Failure 401 {object} response.AuthErrorResponse "Invalid password or code"
+// @Failure 409 {object} response.AuthErrorResponse "2FA not enabled"
+// @Failure 422 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /auth/2fa/backup-codes [post]
+func RegenerateBackupCodesHandler(c *gin.Context) {
+	logger.Log.Info("RegenerateBackupCodesHandler")
+
+	user, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	req := c.MustGet("dto").(*request.TwoFactorReauthRequest)
+
+	codes, err := auth_service.RegenerateBackupCodes(user.ID, req.Password, req.Code)
+	if err != nil {
+		handleTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.BackupCodesResponse{BackupCodes: codes})
+}
+
+// SetOrganizationTwoFactorHandler godoc
+// @Summary Require 2FA in the organization
+// @Description Sets whether every user of the organization who logs in with a password needs a second factor. Users without 2FA enroll on their next login. Users logging in with an identity provider are not affected.
+// @Tags Organization
+// @Security BearerAuth
+// @Accept json
+// @Param body body request.RequireTwoFactorRequest true "Requirement"
+// @Success 204 "Requirement updated"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 403 {object} response.PermissionDeniedResponse "Permission denied"
+// @Failure 422 {object} response.ErrorResponse "Validation error"
+// @Failure 500 {object} response.ErrorResponse "Internal server error"
+// @Router /organization/two-factor [put]
+func SetOrganizationTwoFactorHandler(c *gin.Context) {
+	logger.Log.Info("SetOrganizationTwoFactorHandler")
+
+	user, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	req := c.MustGet("dto").(*request.RequireTwoFactorRequest)
+
+	if err := auth_service.SetOrganizationRequireTwoFactor(user.Organization.ID, *req.Required, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// sendTwoFactorChallenge answers a password login that needs a second
+// factor with a token for /auth/2fa/login instead of the session tokens.
+func sendTwoFactorChallenge(c *gin.Context, user *model.User) {
+	token, err := auth_service.BeginTwoFactorLogin(user)
+	if err != nil {
+		logger.Log.Error("failed to begin two-factor login: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Internal Server Error"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, response.TwoFactorChallengeResponse{
+		TwoFactorToken: token,
+		ExpiresAt:      time.Now().Add(auth_service.TwoFactorLoginTTL).Unix(),
+		SetupRequired:  user.TwoFactorEnabledAt == nil,
+	})
+}
+
+func twoFactorUser(c *gin.Context) (model.User, bool) {
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		if err == util.ErrNoUser {
+			c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to get user"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return model.User{}, false
+	}
+	return user, true
+}
+
+func handleTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth_service.ErrInvalidToken):
+		c.JSON(http.StatusBadRequest, response.AuthErrorResponse{
+			Error:        "invalid or expired token",
+			InternalCode: errorCodes.CodeInvalidAuthToken,
+		})
+	case errors.Is(err, auth_service.ErrInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, response.AuthErrorResponse{
+			Error:        "invalid password",
+			InternalCode: errorCodes.CodeInvalidCredentials,
+		})
+	case errors.Is(err, auth_service.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusUnauthorized, response.AuthErrorResponse{
+			Error:        "invalid two-factor code",
+			InternalCode: errorCodes.CodeInvalidTwoFactorCode,
+		})
+	case errors.Is(err, auth_service.ErrTwoFactorUnavailable):
+		c.JSON(http.StatusForbidden, response.AuthErrorResponse{
+			Error:        "two-factor authentication is only for users who log in with a password",
+			InternalCode: errorCodes.CodeTwoFactorUnavailable,
+		})
+	case errors.Is(err, auth_service.ErrTwoFactorEnabled):
+		c.JSON(http.StatusConflict, response.AuthErrorResponse{
+			Error:        "two-factor authentication is already enabled",
+			InternalCode: errorCodes.CodeTwoFactorAlreadyEnabled,
+		})
+	case errors.Is(err, auth_service.ErrTwoFactorNotEnabled):
+		c.JSON(http.StatusConflict, response.AuthErrorResponse{
+			Error:        "two-factor authentication is not enabled",
+			InternalCode: errorCodes.CodeTwoFactorNotEnabled,
+		})
+	case errors.Is(err, auth_service.ErrTwoFactorNotPending):
+		c.JSON(http.StatusConflict, response.AuthErrorResponse{
+			Error:        "no two-factor enrollment to confirm, start one first",
+			InternalCode: errorCodes.CodeTwoFactorNotPending,
+		})
+	case errors.Is(err, auth_service.ErrTwoFactorSetupRequired):
+		c.JSON(http.StatusConflict, response.AuthErrorResponse{
+			Error:        "the organization requires two-factor authentication, enroll first",
+			InternalCode: errorCodes.CodeTwoFactorSetupRequired,
+		})
+	case errors.Is(err, auth_service.ErrTwoFactorRequired):
+		c.JSON(http.StatusConflict, response.AuthErrorResponse{
+			Error:        "the organization requires two-factor authentication",
+			InternalCode: errorCodes.CodeTwoFactorRequired,
+		})
+	default:
+		logger.Log.Error("Error in two-factor authentication: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Internal Server Error"})
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler_util"
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/mapper"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
@@ -26,10 +27,16 @@ import (
 // @Accept       json
 // @Produce      json
 // @Param        X-Store-ID  header    string  true   "Store ID"
-// @Param        offset      query     int     false  "Pagination offset"
-// @Param        limit       query     int     false  "Pagination limit"
+// @Param        include_archived  query  bool  false  "Include archived units"
+// @Param        limit       query   int     false  "Page size (1-200, default 20)"
+// @Param        cursor      query   string  false  "Opaque cursor taken from the X-Next-Cursor header of the previous page"
+// @Param        offset      query   int     false  "Rows to skip; cannot be combined with cursor"
+// @Param        sort        query   string  false  "Comma-separated sort fields, prefix with - for descending (id, description, created_at)"
+// @Param        filter.{field}  query  string  false  "Filter by description or created_at; comma-separate values to match any, date fields also accept .min/.max"
+// @Header       200  {integer}  X-Total-Count  "Rows matching the filters"
+// @Header       200  {string}   X-Next-Cursor  "Cursor of the next page, absent on the last page"
 // @Success      200  {array}  response.UnitOfMeasureResponse
-// @Failure      400  {object}  response.ErrorResponse "Invalid or missing store ID"
+// @Failure      400  {object}  response.InvalidListQueryErrorResponse "Invalid or missing store ID, or invalid list query"
 // @Failure      401  {object}  response.ErrorResponse "Unauthorized"
 // @Failure      500  {object}  response.ErrorResponse "Internal server error"
 // @Router       /items/units [get]
@@ -60,15 +67,19 @@ func ListUnits(c *gin.Context) {
 		return
 	}
 
-	offset, _ := strconv.ParseUint(c.DefaultQuery("offset", "0"), 10, 0)
-	limit, _ := strconv.ParseUint(c.DefaultQuery("limit", "20"), 10, 0)
+	includeArchived, _ := strconv.ParseBool(c.DefaultQuery("include_archived", "false"))
+
+	lq, ok := handler_util.ParseListQuery(c, unit_of_measure_repository.UnitListSpec)
+	if !ok {
+		return
+	}
 
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	models, err := unit_of_measure_repository.ListUnitsPaginated(conn, user.ID, storeID, uint(offset), uint(limit))
+	models, page, err := unit_of_measure_repository.ListUnits(conn, user.ID, storeID, includeArchived, lq)
 	if err != nil {
 		logger.Log.Error("Error listing units: ", err)
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error listing units"})
@@ -80,6 +91,7 @@ func ListUnits(c *gin.Context) {
 		resp[i] = mapper.ToUnitOfMeasureResponse(&m)
 	}
 
+	handler_util.SetPageHeaders(c, page)
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -105,12 +117,17 @@ func GetUnitByID(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	modelUnit, err := unit_of_measure_repository.GetUnitOfMeasureByID(conn, uint(id))
+	modelUnit, err := unit_of_measure_repository.GetUnitOfMeasureByID(conn, uint(id), storeID)
 	if err != nil {
 		logger.Log.Error("Error retrieving unit: ", err)
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error retrieving unit"})
@@ -214,11 +231,17 @@ func UpdateUnit(c *gin.Context) {
 	req := c.MustGet("dto").(*request.UpdateUnitOfMeasureRequest)
 	unitModel := mapper.UpdateUnitOfMeasureToModel(req, user.ID)
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
+	unitModel.Store.ID = storeID
 	updated, err := unit_of_measure_repository.UpdateUnitOfMeasure(conn, unitModel)
 
 	if err != nil {
@@ -252,12 +275,17 @@ func DeleteUnit(c *gin.Context) {
 		return
 	}
 
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
 	conn := util.GetDBConnFromContext(c)
 	if conn == nil {
 		return
 	}
 
-	if err := unit_of_measure_repository.DeleteUnitOfMeasure(conn, uint(id)); err != nil {
+	if err := unit_of_measure_repository.DeleteUnitOfMeasure(conn, uint(id), storeID); err != nil {
 		logger.Log.Error("Error deleting unit: ", err)
 		error_handler.HandleDBErrorWithReferencingFetcher(c,
 			err,
@@ -276,3 +304,93 @@ func DeleteUnit(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// ArchiveUnit godoc
+// @Summary      Archive a unit of measure
+// @Description  Archives a unit of measure instead of deleting it. Archived units are hidden from listings and cannot be assigned to items, but existing items keep them.
+// @Security     BearerAuth
+// @Tags         Unit Of Measure
+// @Produce      json
+// @Param        id          path     int     true  "Unit ID"
+// @Param        X-Store-ID  header   string  true  "Store ID"
+// @Success      200  {object}  response.UnitOfMeasureResponse
+// @Failure      400  {object}  response.ErrorResponse "Invalid ID"
+// @Failure      404  {object}  response.ErrorResponse "Unit not found"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /items/units/archive/{id} [patch]
+func ArchiveUnit(c *gin.Context) {
+	logger.Log.Info("ArchiveUnit")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	modelUnit, err := unit_of_measure_repository.ArchiveUnitOfMeasure(conn, uint(id), storeID)
+	if err != nil {
+		logger.Log.Error("Error archiving unit: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error archiving unit"})
+		return
+	}
+	if modelUnit == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Unit not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToUnitOfMeasureResponse(modelUnit))
+}
+
+// UnarchiveUnit godoc
+// @Summary      Unarchive a unit of measure
+// @Description  Restores an archived unit of measure so it can be assigned to items again
+// @Security     BearerAuth
+// @Tags         Unit Of Measure
+// @Produce      json
+// @Param        id          path     int     true  "Unit ID"
+// @Param        X-Store-ID  header   string  true  "Store ID"
+// @Success      200  {object}  response.UnitOfMeasureResponse
+// @Failure      400  {object}  response.ErrorResponse "Invalid ID"
+// @Failure      404  {object}  response.ErrorResponse "Unit not found"
+// @Failure      500  {object}  response.ErrorResponse "Internal server error"
+// @Router       /items/units/unarchive/{id} [patch]
+func UnarchiveUnit(c *gin.Context) {
+	logger.Log.Info("UnarchiveUnit")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid ID"})
+		return
+	}
+
+	storeID, ok := handler_util.GetStoreID(c)
+	if !ok {
+		return
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return
+	}
+
+	modelUnit, err := unit_of_measure_repository.UnarchiveUnitOfMeasure(conn, uint(id), storeID)
+	if err != nil {
+		logger.Log.Error("Error unarchiving unit: ", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error unarchiving unit"})
+		return
+	}
+	if modelUnit == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Unit not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToUnitOfMeasureResponse(modelUnit))
+}
@@ -2,6 +2,7 @@ package handler_util
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -12,55 +13,44 @@ import (
 	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/auth_service"
+	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
 	"github.com/gin-gonic/gin"
 )
 
-func ValidateOAuthStateAndGetUser(c *gin.Context) (model.GoogleUserInfo, error) {
+// ValidateOAuthStateAndGetIdentity checks the state of the OAuth callback
+// against its cookie and the login recorded on the server, and has the
+// provider exchange the code for the identity of the user. It writes the
+// error response on failure.
+func ValidateOAuthStateAndGetIdentity(c *gin.Context, provider auth.Provider) (model.Identity, *model.OAuthState, error) {
 	expectedState, err := c.Cookie("oauthstate")
 	if err != nil {
 		logger.Log.Error("state cookie not found")
 		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "state cookie not found"})
-		return model.GoogleUserInfo{}, err
+		return model.Identity{}, nil, err
 	}
 
 	state := c.Query("state")
-	if state != expectedState {
+	if state == "" || state != expectedState {
 		logger.Log.Error("invalid oauth state")
 		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "invalid oauth state"})
-		return model.GoogleUserInfo{}, fmt.Errorf("invalid oauth state")
+		return model.Identity{}, nil, fmt.Errorf("invalid oauth state")
 	}
 
 	code := c.Query("code")
-	token, err := auth.ExchangeCode(code)
+	identity, oauthState, err := auth_service.CompleteOAuth(c.Request.Context(), provider, state, code)
 	if err != nil {
-		logger.Log.Error("failed to exchange code: ", err.Error())
-		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: err.Error()})
-		return model.GoogleUserInfo{}, err
-	}
-
-	userInfo, err := auth.GetUserInfo(token)
-	if err != nil {
-		logger.Log.Error("failed to get user info: ", err.Error())
+		if errors.Is(err, auth_service.ErrInvalidOAuthState) {
+			logger.Log.Error("oauth state not found or expired")
+			c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "invalid oauth state"})
+			return model.Identity{}, nil, err
+		}
+		logger.Log.Errorf("failed to get %s identity: %v", provider.Name(), err)
 		c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "Failed to get user info."})
-		return model.GoogleUserInfo{}, err
-	}
-
-	var googleUserInfoStruct model.GoogleUserInfo
-	userInfoBytes, err := json.Marshal(userInfo)
-	if err != nil {
-		logger.Log.Error("failed to marshal user info: ", err.Error())
-		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to marshal user info"})
-		return model.GoogleUserInfo{}, err
-	}
-
-	err = json.Unmarshal(userInfoBytes, &googleUserInfoStruct)
-	if err != nil {
-		logger.Log.Error("failed to unmarshal user info: ", err.Error())
-		c.JSON(http.StatusInternalServerError, dtoResponse.ErrorResponse{Error: "failed to unmarshal user info"})
-		return model.GoogleUserInfo{}, err
+		return model.Identity{}, nil, err
 	}
 
-	return googleUserInfoStruct, nil
+	return identity, oauthState, nil
 }
 
 func RedirectWithError(c *gin.Context, frontendURL, detail string, internalCode errorCodes.ErrorCode) {
@@ -78,3 +68,21 @@ func RedirectWithError(c *gin.Context, frontendURL, detail string, internalCode
 	redirectURL := fmt.Sprintf("%s/OAuthCallback?error=%s", frontendURL, url.QueryEscape(string(jsonBytes)))
 	c.Redirect(http.StatusFound, redirectURL)
 }
+
+// GetStoreID returns the store set by StoreMiddleware. It writes the error
+// response and returns false when there is none.
+func GetStoreID(c *gin.Context) (uint, bool) {
+	storeID, err := util.GetStoreIDFromContext(c)
+	if err != nil {
+		if err == util.ErrNoStoreID {
+			c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "store id not found"})
+		} else {
+			c.JSON(http.StatusBadRequest, dtoResponse.ErrorResponse{Error: "invalid store id"})
+		}
+		logger.Log.Error(err)
+		c.Abort()
+		return 0, false
+	}
+
+	return storeID, true
+}
@@ -0,0 +1,47 @@
+package handler_util
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
+	dtoResponse "github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// Response headers carrying list metadata, so collection bodies stay plain arrays.
+const (
+	HeaderTotalCount = "X-Total-Count"
+	HeaderNextCursor = "X-Next-Cursor"
+)
+
+// ParseListQuery reads the shared pagination, filter and sort parameters
+// against spec. On invalid parameters it writes a 400 and returns false.
+func ParseListQuery(c *gin.Context, spec *list_query.Spec) (*list_query.Query, bool) {
+	q, err := list_query.Parse(spec, c.Request.URL.Query())
+	if err != nil {
+		logger.Log.Error("Invalid list query: ", err)
+		resp := dtoResponse.InvalidListQueryErrorResponse{
+			Error:        "Invalid list query.",
+			InternalCode: errorCodes.CodeInvalidListQuery,
+			Details:      err.Error(),
+		}
+		if listErr, ok := err.(*errorCodes.InvalidListQuery); ok {
+			resp.Parameter = listErr.Param
+		}
+		c.JSON(http.StatusBadRequest, resp)
+		return nil, false
+	}
+	return q, true
+}
+
+// SetPageHeaders exposes the total row count and, when there are more rows,
+// the cursor of the next page.
+func SetPageHeaders(c *gin.Context, page *list_query.PageInfo) {
+	c.Header(HeaderTotalCount, strconv.FormatInt(page.Total, 10))
+	if page.NextCursor != "" {
+		c.Header(HeaderNextCursor, page.NextCursor)
+	}
+}
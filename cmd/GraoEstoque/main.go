@@ -9,6 +9,7 @@ import (
 	"github.com/gin-contrib/cors"
 
 	routes "github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/routes"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/auth"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
@@ -24,11 +25,23 @@ import (
 // @in header
 // @name Authorization
 // @description Type "Bearer" followed by a space and your token, e.g. 'Bearer abc123'
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description Organization API key for integrations, accepted wherever BearerAuth is, within the key's scopes
 func main() {
 
 	// Initializes db
 	db.InitDB()
 
+	// Loads the access token signing keys
+	if err := util.LoadJWTKeys(); err != nil {
+		logger.Log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	// Registers the configured identity providers
+	auth.InitProviders()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080" // fallback
@@ -51,8 +64,8 @@ func main() {
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{frontendURL},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Content-Type", "Authorization", "X-Store-ID"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Content-Type", "Authorization", "X-Store-ID", "X-API-Key"},
+		ExposeHeaders:    []string{"Content-Length", "X-Total-Count", "X-Next-Cursor"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour, // Browser can cache this config for 12 hours
 	}))
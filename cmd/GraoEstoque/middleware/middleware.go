@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -13,15 +14,29 @@ import (
 	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
 
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/auth_session_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/platform_admin_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/role_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/store_member_repository"
+	dtoResponse "github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/auth_service"
 	util "github.com/IlfGauhnith/GraoAGrao/pkg/util"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/validator"
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware checks for a valid JWT token in the Authorization header
+// AuthMiddleware checks for a valid JWT token in the Authorization header,
+// or for an API key in the X-API-Key header.
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Integrations that cannot log in send an API key instead
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, apiKey)
+			return
+		}
+
 		// Get the Authorization header.
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -65,6 +80,31 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Access tokens are short-lived but still checked against their
+		// session, so logouts and deactivated users or organizations take
+		// effect immediately.
+		sessionID, err := util.GetSessionIDFromJWT(*token)
+		if err != nil {
+			logger.Log.Warn(err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.Abort()
+			return
+		}
+
+		active, err := auth_session_repository.IsSessionActive(sessionID, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check session"})
+			c.Abort()
+			return
+		}
+		if !active {
+			logger.Log.Warnf("Revoked session %s used by user id: %d", sessionID, user.ID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session revoked or expired"})
+			c.Abort()
+			return
+		}
+		c.Set("session_id", sessionID)
+
 		// Store the user information in the context for later use.
 		// This can be used in subsequent handlers to access the authenticated user.
 		// For example, you can use c.Get("authenticated") in your handlers.
@@ -88,6 +128,104 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// authenticateAPIKey sets the user an API key acts for, and the key itself,
+// in the context. The key's scopes are checked by RequirePermission.
+func authenticateAPIKey(c *gin.Context, secret string) {
+	key, user, err := auth_service.AuthenticateAPIKey(secret, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, auth_service.ErrInvalidAPIKey) {
+			logger.Log.Warn("Invalid API key")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid, revoked or expired API key"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check API key"})
+		}
+		c.Abort()
+		return
+	}
+
+	c.Set("api_key", key)
+	c.Set("authenticated", user)
+
+	if user.Organization.IsTryOut && user.Organization.ExpiresAt != nil {
+		c.Set("tryout_expires_at", *user.Organization.ExpiresAt)
+	}
+
+	c.Next()
+}
+
+// RequireUserSession aborts with 403 when the request authenticated with an
+// API key, for routes that act on the user's account rather than on the
+// organization's data. It must run after AuthMiddleware.
+func RequireUserSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key, ok := util.GetAPIKeyFromContext(c); ok {
+			logger.Log.Warnf("API key id: %d used on a user-only route", key.ID)
+			c.JSON(http.StatusForbidden, dtoResponse.AuthErrorResponse{
+				Error:        "This action needs a logged in user, API keys are not accepted.",
+				InternalCode: errorCodes.CodeAPIKeyNotAllowed,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireOrganizationWideAPIKey aborts with 403 when the request
+// authenticated with an API key restricted to a store, for routes whose data
+// spans every store. It must run after AuthMiddleware.
+func RequireOrganizationWideAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key, ok := util.GetAPIKeyFromContext(c); ok && key.StoreID != nil {
+			logger.Log.Warnf("Store-restricted API key id: %d used on an organization-wide route", key.ID)
+			c.JSON(http.StatusForbidden, dtoResponse.AuthErrorResponse{
+				Error:        "This API key is restricted to a store and cannot read data of the whole organization.",
+				InternalCode: errorCodes.CodeAPIKeyStoreRestricted,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePlatformAdmin aborts with 403 unless the user is a platform admin.
+// The platform role is granted per user and has nothing to do with the
+// user's roles in the organization. It must run after AuthMiddleware and
+// RequireUserSession.
+func RequirePlatformAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := util.GetUserFromContext(c)
+		if err != nil {
+			logger.Log.Error(err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			c.Abort()
+			return
+		}
+
+		isAdmin, err := platform_admin_repository.IsPlatformAdmin(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load platform role"})
+			c.Abort()
+			return
+		}
+
+		if !isAdmin {
+			logger.Log.Warnf("Platform admin route denied to user id: %d", user.ID)
+			c.JSON(http.StatusForbidden, dtoResponse.AuthErrorResponse{
+				Error:        "This action is reserved to platform admins.",
+				InternalCode: errorCodes.CodePlatformAdminRequired,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // BindAndValidate[T] will:
 // 1) bind JSON → *T
 // 2) run validator.Validate.Struct on it
@@ -176,7 +314,31 @@ func TenantMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 5) store conn in context for downstream use
+		// 5) Record who is acting for the audit log triggers, and clear it
+		// before the pooled conn goes back. Deferred after Release, so it
+		// runs first.
+		apiKeyID := ""
+		if key, ok := util.GetAPIKeyFromContext(c); ok {
+			apiKeyID = strconv.FormatUint(uint64(key.ID), 10)
+		}
+		_, err = conn.Exec(context.Background(),
+			"SELECT set_config('app.user_id', $1, false), set_config('app.api_key_id', $2, false)",
+			strconv.FormatUint(uint64(userModel.ID), 10), apiKeyID)
+		if err != nil {
+			logger.Log.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set audit actor"})
+			c.Abort()
+			return
+		}
+		defer func() {
+			_, err := conn.Exec(context.Background(),
+				"SELECT set_config('app.user_id', '', false), set_config('app.api_key_id', '', false)")
+			if err != nil {
+				logger.Log.Errorf("Failed to clear audit actor: %v", err)
+			}
+		}()
+
+		// 6) store conn in context for downstream use
 		// You can access it using c.MustGet("dbConn") in your handlers.
 		// This is useful for executing queries within the context of the user's organization schema.
 		c.Set("dbConn", conn)
@@ -184,24 +346,30 @@ func TenantMiddleware() gin.HandlerFunc {
 	}
 }
 
+// StoreMiddleware sets the store from the X-Store-ID header and aborts with
+// 403 unless the user may use it. It must run after TenantMiddleware.
 func StoreMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		raw := c.GetHeader("X-Store-ID")
 		if raw == "" {
-			logger.Log.Fatal("X-Store-ID header missing")
+			logger.Log.Warn("X-Store-ID header missing")
 			c.JSON(http.StatusBadRequest, gin.H{"error": "X-Store-ID header missing"})
 			c.Abort()
 			return
 		}
 
 		storeID, err := strconv.Atoi(raw)
-		if err != nil {
+		if err != nil || storeID <= 0 {
 			logger.Log.Error(err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
 			c.Abort()
 			return
 		}
 
+		if !checkStoreAccess(c, uint(storeID)) {
+			return
+		}
+
 		c.Set("storeID", uint(storeID))
 		c.Next()
 	}
@@ -234,3 +402,178 @@ func TenantAccessGuard() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// StoreParamMiddleware sets the store from a path parameter, for routes that
+// address a store in the URL instead of the X-Store-ID header.
+// Like StoreMiddleware, it aborts with 403 unless the user may use the store.
+func StoreParamMiddleware(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		storeID, err := strconv.Atoi(c.Param(param))
+		if err != nil || storeID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+			c.Abort()
+			return
+		}
+
+		if !checkStoreAccess(c, uint(storeID)) {
+			return
+		}
+
+		c.Set("storeID", uint(storeID))
+		c.Next()
+	}
+}
+
+// StoreBodyMiddleware sets the store from the request body, for routes that
+// address a store in the payload. Like StoreMiddleware, it aborts with 403
+// unless the user, or the API key, may use the store. It must run after
+// BindAndValidateMiddleware[T].
+func StoreBodyMiddleware[T any](storeID func(*T) uint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := storeID(c.MustGet("dto").(*T))
+
+		if !checkStoreAccess(c, id) {
+			return
+		}
+
+		c.Set("storeID", id)
+		c.Next()
+	}
+}
+
+// checkStoreAccess aborts with 403 unless the user is a member of the store
+// or an organization owner. A store that does not exist is denied the same
+// way, so its id does not leak.
+func checkStoreAccess(c *gin.Context, storeID uint) bool {
+	user, err := util.GetUserFromContext(c)
+	if err != nil {
+		logger.Log.Error(err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		c.Abort()
+		return false
+	}
+
+	// A key restricted to a store can only be used there
+	if key, isKey := util.GetAPIKeyFromContext(c); isKey && key.StoreID != nil && *key.StoreID != storeID {
+		logger.Log.Warnf("Store id: %d denied to API key id: %d", storeID, key.ID)
+		denyStoreAccess(c, storeID)
+		return false
+	}
+
+	conn := util.GetDBConnFromContext(c)
+	if conn == nil {
+		return false
+	}
+
+	ok, err := store_member_repository.HasStoreAccess(conn, user.ID, storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check store access"})
+		c.Abort()
+		return false
+	}
+
+	if !ok {
+		logger.Log.Warnf("Store id: %d denied to user id: %d", storeID, user.ID)
+		denyStoreAccess(c, storeID)
+		return false
+	}
+
+	return true
+}
+
+func denyStoreAccess(c *gin.Context, storeID uint) {
+	c.JSON(http.StatusForbidden, dtoResponse.StoreAccessDeniedResponse{
+		Error:        "You do not have access to this store.",
+		InternalCode: errorCodes.CodeStoreAccessDenied,
+		StoreID:      storeID,
+	})
+	c.Abort()
+}
+
+// RequireAPIKeyScope aborts with 403 when the request authenticated with an
+// API key that does not have perm among its scopes. Unlike RequirePermission
+// it does not look at the user's role, for routes that filter what they
+// return by the user's roles themselves.
+func RequireAPIKeyScope(perm model.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !checkAPIKeyScope(c, perm) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// checkAPIKeyScope aborts with 403 when the request authenticated with an
+// API key that does not have perm among its scopes.
+func checkAPIKeyScope(c *gin.Context, perm model.Permission) bool {
+	if key, ok := util.GetAPIKeyFromContext(c); ok && !key.Allows(perm) {
+		logger.Log.Warnf("Scope %s denied to API key id: %d", perm, key.ID)
+		c.JSON(http.StatusForbidden, dtoResponse.PermissionDeniedResponse{
+			Error:        "The API key does not have the scope for this action.",
+			InternalCode: errorCodes.CodeAPIKeyScopeDenied,
+			Permission:   string(perm),
+		})
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// RequirePermission aborts with 403 unless the user's role grants perm. The
+// role is the user's role in the request's store when one is set (by
+// StoreMiddleware or StoreParamMiddleware), else the organization-wide role.
+// Requests made with an API key also need perm among the key's scopes.
+// It must run after TenantMiddleware.
+func RequirePermission(perm model.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := util.GetUserFromContext(c)
+		if err != nil {
+			logger.Log.Error(err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			c.Abort()
+			return
+		}
+
+		if !checkAPIKeyScope(c, perm) {
+			return
+		}
+
+		conn := util.GetDBConnFromContext(c)
+		if conn == nil {
+			return
+		}
+
+		var storeID *uint
+		if id, err := util.GetStoreIDFromContext(c); err == nil {
+			storeID = &id
+		}
+
+		role, ok, err := role_repository.GetEffectiveRole(conn, user.ID, storeID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user role"})
+			c.Abort()
+			return
+		}
+
+		if !ok || !role.Can(perm) {
+			logger.Log.Warnf("Permission %s denied to user id: %d (role %q)", perm, user.ID, role)
+
+			resp := dtoResponse.PermissionDeniedResponse{
+				Error:        "You do not have permission to perform this action.",
+				InternalCode: errorCodes.CodePermissionDenied,
+				Permission:   string(perm),
+			}
+			if ok {
+				r := string(role)
+				resp.Role = &r
+			}
+			c.JSON(http.StatusForbidden, resp)
+			c.Abort()
+			return
+		}
+
+		c.Set("role", role)
+		c.Next()
+	}
+}
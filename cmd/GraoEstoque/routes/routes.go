@@ -4,6 +4,7 @@ import (
 	handler "github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/handler"
 	middleware "github.com/IlfGauhnith/GraoAGrao/cmd/GraoEstoque/middleware"
 	dtoRequest "github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,6 +20,9 @@ func InitRoutes(router *gin.Engine) {
 	// Health endpoint
 	router.GET("/health", handler.HealthHandler)
 
+	// Public keys other services verify our access tokens with
+	router.GET("/.well-known/jwks.json", handler.JWKSHandler)
+
 	docsGroup := router.Group("/docs")
 	{
 		// Serve Redoc HTML
@@ -33,6 +37,83 @@ func InitRoutes(router *gin.Engine) {
 	{
 		authGroup.GET("/google", handler.GoogleAuthHandler)
 		authGroup.GET("/google/callback", handler.GoogleAuthCallBackHandler)
+		authGroup.GET("/providers", handler.ListAuthProvidersHandler)
+		authGroup.GET("/oauth/:provider", handler.OAuthHandler)
+		authGroup.GET("/oauth/:provider/callback", handler.OAuthCallBackHandler)
+		authGroup.POST("/oauth/exchange",
+			middleware.BindAndValidateMiddleware[dtoRequest.ExchangeLoginCodeRequest](),
+			handler.ExchangeLoginCodeHandler,
+		)
+		authGroup.POST("/register",
+			middleware.BindAndValidateMiddleware[dtoRequest.RegisterRequest](),
+			handler.RegisterHandler,
+		)
+		authGroup.POST("/login",
+			middleware.BindAndValidateMiddleware[dtoRequest.LoginRequest](),
+			handler.LoginHandler,
+		)
+		authGroup.POST("/verify-email",
+			middleware.BindAndValidateMiddleware[dtoRequest.VerifyEmailRequest](),
+			handler.VerifyEmailHandler,
+		)
+		authGroup.POST("/verify-email/resend",
+			middleware.BindAndValidateMiddleware[dtoRequest.EmailRequest](),
+			handler.ResendEmailVerificationHandler,
+		)
+		authGroup.POST("/password/forgot",
+			middleware.BindAndValidateMiddleware[dtoRequest.EmailRequest](),
+			handler.ForgotPasswordHandler,
+		)
+		authGroup.POST("/password/reset",
+			middleware.BindAndValidateMiddleware[dtoRequest.ResetPasswordRequest](),
+			handler.ResetPasswordHandler,
+		)
+		authGroup.POST("/refresh",
+			middleware.BindAndValidateMiddleware[dtoRequest.RefreshTokenRequest](),
+			handler.RefreshTokenHandler,
+		)
+		authGroup.POST("/logout", middleware.AuthMiddleware(), middleware.RequireUserSession(), handler.LogoutHandler)
+		authGroup.POST("/logout-all", middleware.AuthMiddleware(), middleware.RequireUserSession(), handler.LogoutAllHandler)
+		authGroup.GET("/invitations/:token", handler.GetInvitationHandler)
+		authGroup.POST("/invitations/register",
+			middleware.BindAndValidateMiddleware[dtoRequest.RegisterWithInvitationRequest](),
+			handler.RegisterWithInvitationHandler,
+		)
+		authGroup.POST("/invitations/accept",
+			middleware.AuthMiddleware(),
+			middleware.RequireUserSession(),
+			middleware.BindAndValidateMiddleware[dtoRequest.AcceptInvitationRequest](),
+			handler.AcceptInvitationHandler,
+		)
+
+		// Second factor of password logins
+		authGroup.POST("/2fa/login",
+			middleware.BindAndValidateMiddleware[dtoRequest.TwoFactorLoginRequest](),
+			handler.TwoFactorLoginHandler,
+		)
+		authGroup.POST("/2fa/login/setup",
+			middleware.BindAndValidateMiddleware[dtoRequest.TwoFactorTokenRequest](),
+			handler.TwoFactorLoginSetupHandler,
+		)
+
+		twoFactorGroup := authGroup.Group("/2fa")
+		twoFactorGroup.Use(middleware.AuthMiddleware(), middleware.RequireUserSession())
+		{
+			twoFactorGroup.GET("", handler.GetTwoFactorStatusHandler)
+			twoFactorGroup.POST("/setup", handler.SetupTwoFactorHandler)
+			twoFactorGroup.POST("/confirm",
+				middleware.BindAndValidateMiddleware[dtoRequest.TwoFactorCodeRequest](),
+				handler.ConfirmTwoFactorHandler,
+			)
+			twoFactorGroup.POST("/disable",
+				middleware.BindAndValidateMiddleware[dtoRequest.TwoFactorReauthRequest](),
+				handler.DisableTwoFactorHandler,
+			)
+			twoFactorGroup.POST("/backup-codes",
+				middleware.BindAndValidateMiddleware[dtoRequest.TwoFactorReauthRequest](),
+				handler.RegenerateBackupCodesHandler,
+			)
+		}
 	}
 
 	tryOutGroup := router.Group("/tryOut")
@@ -42,8 +123,17 @@ func InitRoutes(router *gin.Engine) {
 		tryOutGroup.DELETE(
 			"/destroyEnv",
 			middleware.AuthMiddleware(), // Needed to destroy user specific env
+			middleware.RequireUserSession(),
 			handler.DestroyTryOutEnvironment,
 		)
+		tryOutGroup.POST(
+			"/convert",
+			middleware.AuthMiddleware(),
+			middleware.RequireUserSession(),
+			middleware.TenantMiddleware(),
+			middleware.RequirePermission(model.PermStoreManage),
+			handler.ConvertTryOutEnvironment,
+		)
 	}
 
 	// Store endpoints
@@ -54,17 +144,174 @@ func InitRoutes(router *gin.Engine) {
 		middleware.TenantAccessGuard(),
 	)
 	{
-		storeGroup.GET("", handler.GetStores)
-		storeGroup.GET("/:id", handler.GetStoreByID)
-		storeGroup.DELETE("/:id", handler.DeleteStore)
+		// Every role may read stores, users with store roles only see those stores
+		storeGroup.GET("", middleware.RequireAPIKeyScope(model.PermStoreRead), handler.GetStores)
+		storeGroup.GET("/:id",
+			middleware.StoreParamMiddleware("id"),
+			middleware.RequirePermission(model.PermStoreRead),
+			handler.GetStoreByID,
+		)
+		storeGroup.DELETE("/:id",
+			middleware.StoreParamMiddleware("id"),
+			middleware.RequirePermission(model.PermStoreManage),
+			handler.DeleteStore,
+		)
 		storeGroup.POST("",
+			middleware.RequirePermission(model.PermStoreManage),
 			middleware.BindAndValidateMiddleware[dtoRequest.CreateStoreRequest](),
 			handler.CreateStore,
 		)
 		storeGroup.PUT("",
 			middleware.BindAndValidateMiddleware[dtoRequest.UpdateStoreRequest](),
+			middleware.StoreBodyMiddleware(func(r *dtoRequest.UpdateStoreRequest) uint { return r.ID }),
+			middleware.RequirePermission(model.PermStoreWrite),
 			handler.UpdateStore,
 		)
+		storeGroup.GET("/:id/approval-policy",
+			middleware.StoreParamMiddleware("id"),
+			middleware.RequirePermission(model.PermStoreRead),
+			handler.GetApprovalPolicy,
+		)
+		storeGroup.PUT("/:id/approval-policy",
+			middleware.StoreParamMiddleware("id"),
+			middleware.RequirePermission(model.PermStoreManage),
+			middleware.BindAndValidateMiddleware[dtoRequest.UpdateApprovalPolicyRequest](),
+			handler.UpdateApprovalPolicy,
+		)
+		storeGroup.GET("/:id/roles",
+			middleware.StoreParamMiddleware("id"),
+			middleware.RequirePermission(model.PermStoreManage),
+			handler.ListStoreRoles,
+		)
+		storeGroup.PUT("/:id/roles/:userId",
+			middleware.StoreParamMiddleware("id"),
+			middleware.RequirePermission(model.PermStoreManage),
+			middleware.BindAndValidateMiddleware[dtoRequest.AssignRoleRequest](),
+			handler.AssignStoreRole,
+		)
+		storeGroup.DELETE("/:id/roles/:userId",
+			middleware.StoreParamMiddleware("id"),
+			middleware.RequirePermission(model.PermStoreManage),
+			handler.RemoveStoreRole,
+		)
+		storeGroup.GET("/:id/members",
+			middleware.StoreParamMiddleware("id"),
+			middleware.RequirePermission(model.PermStoreManage),
+			handler.ListStoreMembers,
+		)
+		storeGroup.PUT("/:id/members/:userId",
+			middleware.StoreParamMiddleware("id"),
+			middleware.RequirePermission(model.PermStoreManage),
+			handler.AddStoreMember,
+		)
+		storeGroup.DELETE("/:id/members/:userId",
+			middleware.StoreParamMiddleware("id"),
+			middleware.RequirePermission(model.PermStoreManage),
+			handler.RemoveStoreMember,
+		)
+	}
+
+	// Role endpoints. A user's role in a store overrides its organization role.
+	roleGroup := router.Group("/roles")
+	roleGroup.Use(
+		middleware.AuthMiddleware(),
+		middleware.TenantMiddleware(),
+		middleware.TenantAccessGuard(),
+	)
+	{
+		roleGroup.GET("/me", handler.GetMyRoles)
+		roleGroup.GET("", middleware.RequirePermission(model.PermStoreManage), handler.ListOrganizationRoles)
+		roleGroup.PUT("/:userId",
+			middleware.RequirePermission(model.PermStoreManage),
+			middleware.BindAndValidateMiddleware[dtoRequest.AssignRoleRequest](),
+			handler.AssignOrganizationRole,
+		)
+		roleGroup.DELETE("/:userId", middleware.RequirePermission(model.PermStoreManage), handler.RemoveOrganizationRole)
+	}
+
+	// Invitation endpoints. Invitees accept them through /auth/invitations.
+	invitationGroup := router.Group("/invitations")
+	invitationGroup.Use(
+		middleware.AuthMiddleware(),
+		middleware.TenantMiddleware(),
+		middleware.TenantAccessGuard(),
+		middleware.RequirePermission(model.PermStoreManage),
+	)
+	{
+		invitationGroup.GET("", handler.ListInvitations)
+		invitationGroup.POST("",
+			middleware.BindAndValidateMiddleware[dtoRequest.CreateInvitationRequest](),
+			handler.CreateInvitation,
+		)
+		invitationGroup.POST("/:id/resend", handler.ResendInvitation)
+		invitationGroup.DELETE("/:id", handler.RevokeInvitation)
+	}
+
+	// API key endpoints. Keys are for integrations, so they can't manage keys.
+	apiKeyGroup := router.Group("/api-keys")
+	apiKeyGroup.Use(
+		middleware.AuthMiddleware(),
+		middleware.TenantMiddleware(),
+		middleware.TenantAccessGuard(),
+		middleware.RequirePermission(model.PermStoreManage),
+	)
+	{
+		apiKeyGroup.GET("", handler.ListAPIKeys)
+		apiKeyGroup.POST("",
+			middleware.BindAndValidateMiddleware[dtoRequest.CreateAPIKeyRequest](),
+			handler.CreateAPIKey,
+		)
+		apiKeyGroup.DELETE("/:id", handler.RevokeAPIKey)
+	}
+
+	// Organization settings
+	organizationGroup := router.Group("/organization")
+	organizationGroup.Use(
+		middleware.AuthMiddleware(),
+		middleware.RequireUserSession(),
+		middleware.TenantMiddleware(),
+		middleware.TenantAccessGuard(),
+		middleware.RequirePermission(model.PermStoreManage),
+	)
+	{
+		organizationGroup.PUT("/two-factor",
+			middleware.BindAndValidateMiddleware[dtoRequest.RequireTwoFactorRequest](),
+			handler.SetOrganizationTwoFactorHandler,
+		)
+	}
+
+	// Platform administration, across organizations. Platform admins are
+	// granted in public.tb_platform_admin, not through organization roles.
+	platformGroup := router.Group("/platform")
+	platformGroup.Use(
+		middleware.AuthMiddleware(),
+		middleware.RequireUserSession(),
+		middleware.RequirePlatformAdmin(),
+	)
+	{
+		platformGroup.GET("/organizations", handler.ListPlatformOrganizations)
+		platformGroup.GET("/organizations/:id/users", handler.ListPlatformOrganizationUsers)
+		platformGroup.POST("/organizations/:id/deactivate", handler.DeactivateOrganization)
+		platformGroup.POST("/organizations/:id/reactivate", handler.ReactivateOrganization)
+		platformGroup.PATCH("/organizations/:id/expiration",
+			middleware.BindAndValidateMiddleware[dtoRequest.ExtendTryOutRequest](),
+			handler.ExtendOrganizationTryOut,
+		)
+		platformGroup.POST("/organizations/:id/migrations", handler.MigrateOrganization)
+		platformGroup.POST("/migrations", handler.MigrateAllOrganizations)
+	}
+
+	// Audit log of the organization's data
+	auditGroup := router.Group("/audit")
+	auditGroup.Use(
+		middleware.AuthMiddleware(),
+		middleware.RequireOrganizationWideAPIKey(),
+		middleware.TenantMiddleware(),
+		middleware.TenantAccessGuard(),
+		middleware.RequirePermission(model.PermAuditRead),
+	)
+	{
+		auditGroup.GET("", handler.ListAuditLog)
 	}
 
 	// Items endpoints
@@ -74,18 +321,23 @@ func InitRoutes(router *gin.Engine) {
 		middleware.TenantMiddleware(),
 		middleware.TenantAccessGuard(),
 		middleware.StoreMiddleware(),
+		middleware.RequirePermission(model.PermCatalogRead),
 	)
 	{
 		itemGroup.GET("", handler.GetItems)
 		itemGroup.GET("/:id", handler.GetItemByID)
-		itemGroup.DELETE("/:id", handler.DeleteItem)
+		itemGroup.DELETE("/:id", middleware.RequirePermission(model.PermCatalogDelete), handler.DeleteItem)
+		itemGroup.PATCH("/archive/:id", middleware.RequirePermission(model.PermCatalogWrite), handler.ArchiveItem)
+		itemGroup.PATCH("/unarchive/:id", middleware.RequirePermission(model.PermCatalogWrite), handler.UnarchiveItem)
 
 		itemGroup.POST("",
+			middleware.RequirePermission(model.PermCatalogWrite),
 			middleware.BindAndValidateMiddleware[dtoRequest.CreateItemRequest](),
 			handler.CreateItem,
 		)
 
 		itemGroup.PUT("",
+			middleware.RequirePermission(model.PermCatalogWrite),
 			middleware.BindAndValidateMiddleware[dtoRequest.UpdateItemRequest](),
 			handler.UpdateItem,
 		)
@@ -95,16 +347,25 @@ func InitRoutes(router *gin.Engine) {
 		{
 			categoryGroup.GET("", handler.GetCategories)
 			categoryGroup.GET("/:id", handler.GetCategoryByID)
-			categoryGroup.DELETE("/:id", handler.DeleteCategory)
+			categoryGroup.DELETE("/:id", middleware.RequirePermission(model.PermCatalogDelete), handler.DeleteCategory)
+			categoryGroup.PATCH("/archive/:id", middleware.RequirePermission(model.PermCatalogWrite), handler.ArchiveCategory)
+			categoryGroup.PATCH("/unarchive/:id", middleware.RequirePermission(model.PermCatalogWrite), handler.UnarchiveCategory)
 
 			categoryGroup.POST("",
+				middleware.RequirePermission(model.PermCatalogWrite),
 				middleware.BindAndValidateMiddleware[dtoRequest.CreateCategoryRequest](),
 				handler.CreateCategory,
 			)
 			categoryGroup.PUT("",
+				middleware.RequirePermission(model.PermCatalogWrite),
 				middleware.BindAndValidateMiddleware[dtoRequest.UpdateCategoryRequest](),
 				handler.UpdateCategory,
 			)
+			categoryGroup.PATCH("/move/:id",
+				middleware.RequirePermission(model.PermCatalogWrite),
+				middleware.BindAndValidateMiddleware[dtoRequest.MoveCategoryRequest](),
+				handler.MoveCategory,
+			)
 		}
 
 		// Unit endpoints
@@ -112,13 +373,17 @@ func InitRoutes(router *gin.Engine) {
 		{
 			unitGroup.GET("", handler.ListUnits)
 			unitGroup.GET("/:id", handler.GetUnitByID)
-			unitGroup.DELETE("/:id", handler.DeleteUnit)
+			unitGroup.DELETE("/:id", middleware.RequirePermission(model.PermCatalogDelete), handler.DeleteUnit)
+			unitGroup.PATCH("/archive/:id", middleware.RequirePermission(model.PermCatalogWrite), handler.ArchiveUnit)
+			unitGroup.PATCH("/unarchive/:id", middleware.RequirePermission(model.PermCatalogWrite), handler.UnarchiveUnit)
 
 			unitGroup.POST("",
+				middleware.RequirePermission(model.PermCatalogWrite),
 				middleware.BindAndValidateMiddleware[dtoRequest.CreateUnitOfMeasureRequest](),
 				handler.CreateUnit,
 			)
 			unitGroup.PUT("",
+				middleware.RequirePermission(model.PermCatalogWrite),
 				middleware.BindAndValidateMiddleware[dtoRequest.UpdateUnitOfMeasureRequest](),
 				handler.UpdateUnit,
 			)
@@ -128,18 +393,42 @@ func InitRoutes(router *gin.Engine) {
 		itemPackagingGroup := itemGroup.Group("/packaging")
 		{
 			itemPackagingGroup.GET("", handler.ListItemPackagings)
+			itemPackagingGroup.GET("/breakdown", handler.SuggestPackagingBreakdown)
 			itemPackagingGroup.GET("/:id", handler.GetItemPackagingByID)
-			itemPackagingGroup.DELETE("/:id", handler.DeleteItemPackaging)
+			itemPackagingGroup.DELETE("/:id", middleware.RequirePermission(model.PermCatalogDelete), handler.DeleteItemPackaging)
+			itemPackagingGroup.PATCH("/archive/:id", middleware.RequirePermission(model.PermCatalogWrite), handler.ArchiveItemPackaging)
+			itemPackagingGroup.PATCH("/unarchive/:id", middleware.RequirePermission(model.PermCatalogWrite), handler.UnarchiveItemPackaging)
 
 			itemPackagingGroup.POST("",
+				middleware.RequirePermission(model.PermCatalogWrite),
 				middleware.BindAndValidateMiddleware[dtoRequest.CreateItemPackagingRequest](),
 				handler.CreateItemPackaging,
 			)
 			itemPackagingGroup.PUT("",
+				middleware.RequirePermission(model.PermCatalogWrite),
 				middleware.BindAndValidateMiddleware[dtoRequest.UpdateItemPackagingRequest](),
 				handler.UpdateItemPackaging,
 			)
 		}
+
+		// Item attribute definition endpoints
+		attributeGroup := itemGroup.Group("/attributes")
+		{
+			attributeGroup.GET("", handler.ListItemAttributeDefinitions)
+			attributeGroup.GET("/:id", handler.GetItemAttributeDefinitionByID)
+			attributeGroup.DELETE("/:id", middleware.RequirePermission(model.PermCatalogDelete), handler.DeleteItemAttributeDefinition)
+
+			attributeGroup.POST("",
+				middleware.RequirePermission(model.PermCatalogWrite),
+				middleware.BindAndValidateMiddleware[dtoRequest.CreateItemAttributeDefinitionRequest](),
+				handler.CreateItemAttributeDefinition,
+			)
+			attributeGroup.PUT("",
+				middleware.RequirePermission(model.PermCatalogWrite),
+				middleware.BindAndValidateMiddleware[dtoRequest.UpdateItemAttributeDefinitionRequest](),
+				handler.UpdateItemAttributeDefinition,
+			)
+		}
 	}
 
 	stockGroup := router.Group("/stock")
@@ -148,28 +437,77 @@ func InitRoutes(router *gin.Engine) {
 		middleware.TenantMiddleware(),
 		middleware.TenantAccessGuard(),
 		middleware.StoreMiddleware(),
+		middleware.RequirePermission(model.PermStockRead),
 	)
 	{
 
 		// Get stock materialized snapshot
 		stockGroup.GET("", handler.GetStock)
 		stockGroup.GET("/:categoryId", handler.GetStockByCategory)
+		stockGroup.GET("/categories/summary", handler.GetCategoryStockSummary)
+
+		// Storage location endpoints
+		locationGroup := stockGroup.Group("/locations")
+		{
+			locationGroup.GET("", handler.ListStorageLocations)
+			locationGroup.GET("/:id", handler.GetStorageLocationByID)
+			locationGroup.DELETE("/:id", middleware.RequirePermission(model.PermStockWrite), handler.DeleteStorageLocation)
+			locationGroup.POST("",
+				middleware.RequirePermission(model.PermStockWrite),
+				middleware.BindAndValidateMiddleware[dtoRequest.CreateStorageLocationRequest](),
+				handler.CreateStorageLocation,
+			)
+			locationGroup.PUT("",
+				middleware.RequirePermission(model.PermStockWrite),
+				middleware.BindAndValidateMiddleware[dtoRequest.UpdateStorageLocationRequest](),
+				handler.UpdateStorageLocation,
+			)
+		}
+
+		// Relocation endpoints
+		relocationGroup := stockGroup.Group("/relocations")
+		{
+			relocationGroup.GET("", handler.ListStockRelocations)
+			relocationGroup.POST("",
+				middleware.RequirePermission(model.PermStockWrite),
+				middleware.BindAndValidateMiddleware[dtoRequest.CreateStockRelocationRequest](),
+				handler.CreateStockRelocation,
+			)
+		}
 
 		// StockIn endpoints
 		stockInGroup := stockGroup.Group("/in")
 		{
 			stockInGroup.GET("", handler.ListAllStockIn)
 			stockInGroup.GET("/:id", handler.GetStockInByID)
+			stockInGroup.GET("/:id/validate", handler.ValidateStockInByID)
 			stockInGroup.POST("",
+				middleware.RequirePermission(model.PermStockWrite),
 				middleware.BindAndValidateMiddleware[dtoRequest.CreateStockInRequest](),
 				handler.CreateStockIn,
 			)
 			stockInGroup.PUT("",
+				middleware.RequirePermission(model.PermStockWrite),
 				middleware.BindAndValidateMiddleware[dtoRequest.UpdateStockInRequest](),
 				handler.UpdateStockIn,
 			)
-			stockInGroup.PATCH("/finalize/:id", handler.FinalizeStockInByID)
-			stockInGroup.DELETE("/:id", handler.DeleteStockIn)
+			stockInGroup.PATCH("/finalize/:id", middleware.RequirePermission(model.PermStockFinalize), handler.FinalizeStockInByID)
+			stockInGroup.PATCH("/submit/:id",
+				middleware.RequirePermission(model.PermStockWrite),
+				middleware.BindAndValidateMiddleware[dtoRequest.StockDocumentDecisionRequest](),
+				handler.SubmitStockInByID,
+			)
+			stockInGroup.PATCH("/approve/:id",
+				middleware.RequirePermission(model.PermStockApprove),
+				middleware.BindAndValidateMiddleware[dtoRequest.StockDocumentDecisionRequest](),
+				handler.ApproveStockInByID,
+			)
+			stockInGroup.PATCH("/reject/:id",
+				middleware.RequirePermission(model.PermStockApprove),
+				middleware.BindAndValidateMiddleware[dtoRequest.RejectStockDocumentRequest](),
+				handler.RejectStockInByID,
+			)
+			stockInGroup.DELETE("/:id", middleware.RequirePermission(model.PermStockWrite), handler.DeleteStockIn)
 		}
 
 		// StockOut endpoints
@@ -177,16 +515,34 @@ func InitRoutes(router *gin.Engine) {
 		{
 			stockOutGroup.GET("", handler.ListAllStockOut)
 			stockOutGroup.GET("/:id", handler.GetStockOutByID)
+			stockOutGroup.GET("/:id/validate", handler.ValidateStockOutByID)
 			stockOutGroup.POST("",
+				middleware.RequirePermission(model.PermStockWrite),
 				middleware.BindAndValidateMiddleware[dtoRequest.CreateStockOutRequest](),
 				handler.CreateStockOut,
 			)
 			stockOutGroup.PUT("",
+				middleware.RequirePermission(model.PermStockWrite),
 				middleware.BindAndValidateMiddleware[dtoRequest.UpdateStockOutRequest](),
 				handler.UpdateStockOut,
 			)
-			stockOutGroup.PATCH("/finalize/:id", handler.FinalizeStockOutByID)
-			stockOutGroup.DELETE("/:id", handler.DeleteStockOut)
+			stockOutGroup.PATCH("/finalize/:id", middleware.RequirePermission(model.PermStockFinalize), handler.FinalizeStockOutByID)
+			stockOutGroup.PATCH("/submit/:id",
+				middleware.RequirePermission(model.PermStockWrite),
+				middleware.BindAndValidateMiddleware[dtoRequest.StockDocumentDecisionRequest](),
+				handler.SubmitStockOutByID,
+			)
+			stockOutGroup.PATCH("/approve/:id",
+				middleware.RequirePermission(model.PermStockApprove),
+				middleware.BindAndValidateMiddleware[dtoRequest.StockDocumentDecisionRequest](),
+				handler.ApproveStockOutByID,
+			)
+			stockOutGroup.PATCH("/reject/:id",
+				middleware.RequirePermission(model.PermStockApprove),
+				middleware.BindAndValidateMiddleware[dtoRequest.RejectStockDocumentRequest](),
+				handler.RejectStockOutByID,
+			)
+			stockOutGroup.DELETE("/:id", middleware.RequirePermission(model.PermStockWrite), handler.DeleteStockOut)
 		}
 
 		// StockWaste endpoints
@@ -194,16 +550,34 @@ func InitRoutes(router *gin.Engine) {
 		{
 			stockWasteGroup.GET("", handler.ListStockWaste)
 			stockWasteGroup.GET("/:id", handler.GetStockWasteByID)
+			stockWasteGroup.GET("/:id/validate", handler.ValidateStockWasteByID)
 			stockWasteGroup.POST("",
+				middleware.RequirePermission(model.PermStockWrite),
 				middleware.BindAndValidateMiddleware[dtoRequest.CreateStockWasteRequest](),
 				handler.CreateStockWaste,
 			)
 			stockWasteGroup.PUT("",
+				middleware.RequirePermission(model.PermStockWrite),
 				middleware.BindAndValidateMiddleware[dtoRequest.UpdateStockWasteRequest](),
 				handler.UpdateStockWaste,
 			)
-			stockWasteGroup.PATCH("/finalize/:id", handler.FinalizeStockWasteByID)
-			stockWasteGroup.DELETE("/:id", handler.DeleteStockWaste)
+			stockWasteGroup.PATCH("/finalize/:id", middleware.RequirePermission(model.PermStockFinalize), handler.FinalizeStockWasteByID)
+			stockWasteGroup.PATCH("/submit/:id",
+				middleware.RequirePermission(model.PermStockWrite),
+				middleware.BindAndValidateMiddleware[dtoRequest.StockDocumentDecisionRequest](),
+				handler.SubmitStockWasteByID,
+			)
+			stockWasteGroup.PATCH("/approve/:id",
+				middleware.RequirePermission(model.PermStockApprove),
+				middleware.BindAndValidateMiddleware[dtoRequest.StockDocumentDecisionRequest](),
+				handler.ApproveStockWasteByID,
+			)
+			stockWasteGroup.PATCH("/reject/:id",
+				middleware.RequirePermission(model.PermStockApprove),
+				middleware.BindAndValidateMiddleware[dtoRequest.RejectStockDocumentRequest](),
+				handler.RejectStockWasteByID,
+			)
+			stockWasteGroup.DELETE("/:id", middleware.RequirePermission(model.PermStockWrite), handler.DeleteStockWaste)
 		}
 	}
 }
@@ -2,14 +2,12 @@ module github.com/IlfGauhnith/GraoAGrao/cmd/migrate_per_tenant
 
 go 1.24.2
 
-require (
-	github.com/IlfGauhnith/GraoAGrao/pkg v0.0.0-20250531012158-29b7ee8eec26
-	github.com/jackc/pgx/v5 v5.7.4
-)
+require github.com/IlfGauhnith/GraoAGrao/pkg v0.0.0-20250531012158-29b7ee8eec26
 
 require (
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/pgx/v5 v5.7.4 // indirect
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
 	github.com/joho/godotenv v1.5.1 // indirect
 	github.com/lib/pq v1.10.9 // indirect
@@ -4,59 +4,94 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"io"
+	"net/http"
 
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
-var oauthConfig *oauth2.Config
+// GoogleProviderName is the name Google identities are stored under.
+const GoogleProviderName = "google"
 
-func init() {
-	oauthConfig = &oauth2.Config{
-		RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),
-		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
+const googleUserInfoURL = "https://www.googleapis.com/oauth2/v2/userinfo"
+
+// GoogleProvider logs users in with their Google account, read from
+// Google's userinfo endpoint.
+type GoogleProvider struct {
+	config *oauth2.Config
+}
+
+// NewGoogleProvider returns the Google provider for an OAuth client.
+func NewGoogleProvider(clientID, clientSecret, redirectURL string) *GoogleProvider {
+	return &GoogleProvider{
+		config: &oauth2.Config{
+			RedirectURL:  redirectURL,
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Scopes: []string{
+				"https://www.googleapis.com/auth/userinfo.email",
+				"https://www.googleapis.com/auth/userinfo.profile",
+			},
+			Endpoint: google.Endpoint,
 		},
-		Endpoint: google.Endpoint,
 	}
 }
 
-// GetAuthURL generates the URL for Google's OAuth consent page using the provided state.
-func GetAuthURL(state string) string {
-	return oauthConfig.AuthCodeURL(state)
+// Name returns "google".
+func (p *GoogleProvider) Name() string {
+	return GoogleProviderName
 }
 
-// ExchangeCode exchanges the provided code for an access token.
-func ExchangeCode(code string) (*oauth2.Token, error) {
-	token, err := oauthConfig.Exchange(context.Background(), code)
+// AuthURL generates the URL for Google's OAuth consent page using the
+// provided state and the PKCE challenge of verifier. The userinfo flow has
+// no ID token, so nonce is unused.
+func (p *GoogleProvider) AuthURL(state, nonce, verifier string) string {
+	return p.config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+}
+
+// Identity exchanges the code and PKCE verifier for an access token and uses
+// it to retrieve the user info from Google.
+func (p *GoogleProvider) Identity(ctx context.Context, code, nonce, verifier string) (model.Identity, error) {
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
 	if err != nil {
-		return nil, fmt.Errorf("failed to exchange token: %v", err)
+		return model.Identity{}, fmt.Errorf("failed to exchange token: %v", err)
 	}
-	return token, nil
-}
 
-// GetUserInfo uses the access token to retrieve user info from Google.
-func GetUserInfo(token *oauth2.Token) (map[string]interface{}, error) {
-	client := oauthConfig.Client(context.Background(), token)
-	response, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	client := p.config.Client(ctx, token)
+	response, err := client.Get(googleUserInfoURL)
 	if err != nil {
-		return nil, err
+		return model.Identity{}, err
 	}
 	defer response.Body.Close()
-	data, err := ioutil.ReadAll(response.Body)
+
+	if response.StatusCode != http.StatusOK {
+		return model.Identity{}, fmt.Errorf("google userinfo returned %s", response.Status)
+	}
+
+	data, err := io.ReadAll(response.Body)
 	if err != nil {
-		return nil, err
+		return model.Identity{}, err
 	}
-	var userInfo map[string]interface{}
+	var userInfo model.GoogleUserInfo
 	if err := json.Unmarshal(data, &userInfo); err != nil {
-		return nil, err
+		return model.Identity{}, err
 	}
-	return userInfo, nil
+	if userInfo.ID == "" {
+		return model.Identity{}, fmt.Errorf("google userinfo has no id")
+	}
+
+	return model.Identity{
+		Provider:      GoogleProviderName,
+		Subject:       userInfo.ID,
+		Email:         userInfo.Email,
+		EmailVerified: userInfo.VerifiedEmail,
+		Name:          userInfo.Name,
+		GivenName:     userInfo.GivenName,
+		FamilyName:    userInfo.FamilyName,
+		Picture:       userInfo.Picture,
+	}, nil
 }
@@ -0,0 +1,326 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyRefreshInterval limits how often an unknown key id makes the key set
+// be fetched again, so forged tokens cannot flood the provider.
+const keyRefreshInterval = time.Minute
+
+// clockSkew is the leeway given to the expiry and issue time of ID tokens.
+const clockSkew = time.Minute
+
+var errUnknownKey = errors.New("id token signed with an unknown key")
+
+// keySet caches the public keys of a provider's JWKS by key id and fetches
+// them again when a token names a key it does not know, which is how
+// providers rotate keys.
+type keySet struct {
+	client *http.Client
+	url    string
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func newKeySet(client *http.Client, url string) *keySet {
+	return &keySet{client: client, url: url}
+}
+
+func (s *keySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	if time.Since(s.fetchedAt) < keyRefreshInterval {
+		return nil, errUnknownKey
+	}
+
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(ctx, s.client, s.url, &doc); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	s.fetchedAt = time.Now()
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Keys of a type this verifier does not support are skipped
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	s.keys = keys
+
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, errUnknownKey
+}
+
+// lookup returns the key with the given id. Tokens without a key id can
+// only be verified when the set has a single key.
+func (s *keySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("rsa exponent too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("ec point not on curve")
+		}
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// idTokenClaims are the ID token claims an identity is made of.
+type idTokenClaims struct {
+	Issuer        string    `json:"iss"`
+	Subject       string    `json:"sub"`
+	Audience      audience  `json:"aud"`
+	Expiry        int64     `json:"exp"`
+	IssuedAt      int64     `json:"iat"`
+	Nonce         string    `json:"nonce"`
+	Email         string    `json:"email"`
+	EmailVerified claimBool `json:"email_verified"`
+	Name          string    `json:"name"`
+	GivenName     string    `json:"given_name"`
+	FamilyName    string    `json:"family_name"`
+	Picture       string    `json:"picture"`
+}
+
+// audience is the aud claim, a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// claimBool is a boolean claim that some providers send as a string.
+type claimBool bool
+
+func (b *claimBool) UnmarshalJSON(data []byte) error {
+	var v bool
+	if err := json.Unmarshal(data, &v); err == nil {
+		*b = claimBool(v)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*b = claimBool(s == "true")
+	return nil
+}
+
+// verifyIDToken checks the signature of a compact JWS ID token against the
+// key set and validates its claims. Only asymmetric algorithms are accepted.
+func verifyIDToken(ctx context.Context, keys *keySet, raw, issuer, clientID, nonce string, now time.Time) (*idTokenClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed id token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("id token header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("id token signature: %w", err)
+	}
+
+	key, err := keys.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims idTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("id token claims: %w", err)
+	}
+
+	switch {
+	case claims.Issuer != issuer:
+		return nil, fmt.Errorf("id token issued by %q", claims.Issuer)
+	case !claims.Audience.contains(clientID):
+		return nil, fmt.Errorf("id token not issued for this client")
+	case claims.Subject == "":
+		return nil, fmt.Errorf("id token has no subject")
+	case now.After(time.Unix(claims.Expiry, 0).Add(clockSkew)):
+		return nil, fmt.Errorf("id token expired")
+	case claims.IssuedAt != 0 && time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)):
+		return nil, fmt.Errorf("id token issued in the future")
+	case claims.Nonce != nonce:
+		return nil, fmt.Errorf("id token nonce does not match")
+	}
+
+	return &claims, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// signingHashes are the hashes of the accepted JWS algorithms.
+var signingHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	hash, ok := signingHashes[alg]
+	if !ok {
+		return fmt.Errorf("unsupported id token algorithm %q", alg)
+	}
+
+	var digest []byte
+	switch hash {
+	case crypto.SHA256:
+		sum := sha256.Sum256([]byte(signingInput))
+		digest = sum[:]
+	case crypto.SHA384:
+		sum := sha512.Sum384([]byte(signingInput))
+		digest = sum[:]
+	default:
+		sum := sha512.Sum512([]byte(signingInput))
+		digest = sum[:]
+	}
+
+	var valid bool
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "RS") {
+			valid = rsa.VerifyPKCS1v15(pub, hash, digest, signature) == nil
+		} else if strings.HasPrefix(alg, "PS") {
+			valid = rsa.VerifyPSS(pub, hash, digest, signature, nil) == nil
+		} else {
+			return fmt.Errorf("key does not match algorithm %s", alg)
+		}
+
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("key does not match algorithm %s", alg)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			valid = ecdsa.Verify(pub, digest, r, s)
+		}
+	}
+
+	if !valid {
+		return fmt.Errorf("invalid id token signature")
+	}
+	return nil
+}
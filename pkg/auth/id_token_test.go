@@ -0,0 +1,327 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID = "client-1"
+	testNonce    = "nonce-1"
+)
+
+// testKey is a signing key published in the test JWKS under kid.
+type testKey struct {
+	kid  string
+	priv crypto.Signer
+}
+
+func newRSAKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{kid: kid, priv: priv}
+}
+
+func newECKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{kid: kid, priv: priv}
+}
+
+func (k testKey) jwk() map[string]string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch priv := k.priv.(type) {
+	case *rsa.PrivateKey:
+		return map[string]string{
+			"kty": "RSA", "kid": k.kid, "use": "sig",
+			"n": b64(priv.N.Bytes()),
+			"e": b64(big.NewInt(int64(priv.E)).Bytes()),
+		}
+	case *ecdsa.PrivateKey:
+		return map[string]string{
+			"kty": "EC", "kid": k.kid, "use": "sig", "crv": "P-256",
+			"x": b64(priv.X.FillBytes(make([]byte, 32))),
+			"y": b64(priv.Y.FillBytes(make([]byte, 32))),
+		}
+	}
+	panic("unsupported key")
+}
+
+// sign returns a compact JWS of claims. kid is left out of the header when
+// empty.
+func (k testKey) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	input := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	var err error
+	switch priv := k.priv.(type) {
+	case *rsa.PrivateKey:
+		if strings.HasPrefix(alg, "PS") {
+			signature, err = rsa.SignPSS(rand.Reader, priv, crypto.SHA256, digest[:], nil)
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, priv, digest[:])
+		if err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// jwksServer serves the keys it holds and counts the fetches.
+type jwksServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    []testKey
+	fetches int
+}
+
+func newJWKSServer(t *testing.T, keys ...testKey) *jwksServer {
+	s := &jwksServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+		jwks := make([]map[string]string, len(s.keys))
+		for i, k := range s.keys {
+			jwks[i] = k.jwk()
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": jwks})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) setKeys(keys ...testKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksServer) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+func validClaims(issuer string, now time.Time) map[string]any {
+	return map[string]any{
+		"iss":            issuer,
+		"sub":            "subject-1",
+		"aud":            testClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          testNonce,
+		"email":          "user@example.com",
+		"email_verified": "true",
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	const issuer = "https://issuer.example.com"
+	now := time.Now()
+	rsaKey := newRSAKey(t, "rsa")
+	ecKey := newECKey(t, "ec")
+	other := newRSAKey(t, "rsa")
+
+	with := func(key, value string) map[string]any {
+		claims := validClaims(issuer, now)
+		if value == "" {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+	at := func(key string, ts time.Time) map[string]any {
+		claims := validClaims(issuer, now)
+		claims[key] = ts.Unix()
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"RS256", rsaKey.sign(t, "RS256", "rsa", validClaims(issuer, now)), true},
+		{"PS256", rsaKey.sign(t, "PS256", "rsa", validClaims(issuer, now)), true},
+		{"ES256", ecKey.sign(t, "ES256", "ec", validClaims(issuer, now)), true},
+		{"audience array", rsaKey.sign(t, "RS256", "rsa", func() map[string]any {
+			claims := validClaims(issuer, now)
+			claims["aud"] = []string{"other", testClientID}
+			return claims
+		}()), true},
+		{"bad signature", other.sign(t, "RS256", "rsa", validClaims(issuer, now)), false},
+		{"tampered claims", func() string {
+			parts := strings.Split(rsaKey.sign(t, "RS256", "rsa", validClaims(issuer, now)), ".")
+			parts[1] = encodeSegment(t, with("sub", "someone-else"))
+			return strings.Join(parts, ".")
+		}(), false},
+		{"EC algorithm with RSA key", ecKey.sign(t, "ES256", "rsa", validClaims(issuer, now)), false},
+		{"RSA algorithm with EC key", rsaKey.sign(t, "RS256", "ec", validClaims(issuer, now)), false},
+		{"unsupported algorithm", func() string {
+			parts := strings.Split(rsaKey.sign(t, "RS256", "rsa", validClaims(issuer, now)), ".")
+			parts[0] = encodeSegment(t, map[string]string{"alg": "HS256", "kid": "rsa"})
+			return strings.Join(parts, ".")
+		}(), false},
+		{"malformed", "not-a-token", false},
+		{"wrong issuer", rsaKey.sign(t, "RS256", "rsa", with("iss", "https://evil.example.com")), false},
+		{"wrong audience", rsaKey.sign(t, "RS256", "rsa", with("aud", "other")), false},
+		{"wrong nonce", rsaKey.sign(t, "RS256", "rsa", with("nonce", "replayed")), false},
+		{"no nonce", rsaKey.sign(t, "RS256", "rsa", with("nonce", "")), false},
+		{"no subject", rsaKey.sign(t, "RS256", "rsa", with("sub", "")), false},
+		{"expired", rsaKey.sign(t, "RS256", "rsa", at("exp", now.Add(-2*clockSkew))), false},
+		{"expired within skew", rsaKey.sign(t, "RS256", "rsa", at("exp", now.Add(-clockSkew/2))), true},
+		{"issued in the future", rsaKey.sign(t, "RS256", "rsa", at("iat", now.Add(2*clockSkew))), false},
+	}
+
+	server := newJWKSServer(t, rsaKey, ecKey)
+	keys := newKeySet(server.Client(), server.URL)
+
+	for _, tt := range tests {
+		claims, err := verifyIDToken(context.Background(), keys, tt.token, issuer, testClientID, testNonce, now)
+		if tt.ok && err != nil {
+			t.Errorf("%s: returned error: %v", tt.name, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("%s: verified, want error", tt.name)
+		}
+		if tt.ok && err == nil && (claims.Subject != "subject-1" || !bool(claims.EmailVerified)) {
+			t.Errorf("%s: claims = %+v", tt.name, claims)
+		}
+	}
+}
+
+func TestKeySetRefetchesUnknownKeys(t *testing.T) {
+	const issuer = "https://issuer.example.com"
+	now := time.Now()
+	oldKey := newRSAKey(t, "old")
+	newKey := newRSAKey(t, "new")
+
+	server := newJWKSServer(t, oldKey)
+	keys := newKeySet(server.Client(), server.URL)
+	ctx := context.Background()
+
+	if _, err := verifyIDToken(ctx, keys, oldKey.sign(t, "RS256", "old", validClaims(issuer, now)), issuer, testClientID, testNonce, now); err != nil {
+		t.Fatalf("token of the published key: %v", err)
+	}
+
+	// The provider rotates its key; forged kids must not refetch every time
+	server.setKeys(oldKey, newKey)
+	newToken := newKey.sign(t, "RS256", "new", validClaims(issuer, now))
+	for i := 0; i < 3; i++ {
+		if _, err := verifyIDToken(ctx, keys, newToken, issuer, testClientID, testNonce, now); !errors.Is(err, errUnknownKey) {
+			t.Fatalf("token of a key rotated in within the refresh interval: %v, want errUnknownKey", err)
+		}
+	}
+	if got := server.fetchCount(); got != 1 {
+		t.Errorf("JWKS fetched %d times within the refresh interval, want 1", got)
+	}
+
+	keys.mu.Lock()
+	keys.fetchedAt = time.Now().Add(-keyRefreshInterval)
+	keys.mu.Unlock()
+
+	if _, err := verifyIDToken(ctx, keys, newToken, issuer, testClientID, testNonce, now); err != nil {
+		t.Fatalf("token of the rotated key after the refresh interval: %v", err)
+	}
+	if got := server.fetchCount(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+
+	// Known keys are served from the cache
+	if _, err := verifyIDToken(ctx, keys, newToken, issuer, testClientID, testNonce, now); err != nil {
+		t.Fatalf("token of a cached key: %v", err)
+	}
+	if got := server.fetchCount(); got != 2 {
+		t.Errorf("JWKS fetched %d times for a cached key, want 2", got)
+	}
+}
+
+func TestKeySetMissingKid(t *testing.T) {
+	const issuer = "https://issuer.example.com"
+	now := time.Now()
+	first := newRSAKey(t, "first")
+	second := newRSAKey(t, "second")
+	ctx := context.Background()
+
+	single := newJWKSServer(t, first)
+	token := first.sign(t, "RS256", "", validClaims(issuer, now))
+	if _, err := verifyIDToken(ctx, newKeySet(single.Client(), single.URL), token, issuer, testClientID, testNonce, now); err != nil {
+		t.Errorf("token without kid against a single key: %v", err)
+	}
+
+	multiple := newJWKSServer(t, first, second)
+	if _, err := verifyIDToken(ctx, newKeySet(multiple.Client(), multiple.URL), token, issuer, testClientID, testNonce, now); !errors.Is(err, errUnknownKey) {
+		t.Errorf("token without kid against several keys: %v, want errUnknownKey", err)
+	}
+}
+
+func TestKeySetSkipsUnusableKeys(t *testing.T) {
+	sig := newRSAKey(t, "sig")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := sig.jwk()
+		enc["kid"], enc["use"] = "enc", "enc"
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			sig.jwk(),
+			enc,
+			{"kty": "oct", "kid": "secret", "k": "c2VjcmV0"},
+			{"kty": "EC", "kid": "off-curve", "crv": "P-256", "x": "AQ", "y": "AQ"},
+		}})
+	}))
+	defer server.Close()
+
+	keys := newKeySet(server.Client(), server.URL)
+	if _, err := keys.key(context.Background(), "sig"); err != nil {
+		t.Fatalf("signing key: %v", err)
+	}
+	for _, kid := range []string{"enc", "secret", "off-curve"} {
+		if _, ok := keys.lookup(kid); ok {
+			t.Errorf("key %q was accepted", kid)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+
+	"golang.org/x/oauth2"
+)
+
+// OIDCConfig configures an OpenID Connect provider such as Microsoft Entra
+// ID or Keycloak.
+type OIDCConfig struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes default to openid, email and profile.
+	Scopes []string
+	// TrustEmail treats the email of every identity as verified, for
+	// providers that only release addresses they own but do not send the
+	// email_verified claim.
+	TrustEmail bool
+	// HTTPClient is used for discovery, the JWKS and the code exchange.
+	// Defaults to a client with a 10 second timeout.
+	HTTPClient *http.Client
+}
+
+// OIDCProvider logs users in with an OpenID Connect provider found by
+// discovery. Identities come from the ID token, verified against the
+// provider's JWKS.
+type OIDCProvider struct {
+	name       string
+	issuer     string
+	config     *oauth2.Config
+	keys       *keySet
+	client     *http.Client
+	trustEmail bool
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewOIDCProvider reads the provider's discovery document from
+// <issuer>/.well-known/openid-configuration. The document must name the
+// same issuer.
+func NewOIDCProvider(ctx context.Context, cfg OIDCConfig) (*OIDCProvider, error) {
+	if cfg.IssuerURL == "" || cfg.ClientID == "" {
+		return nil, fmt.Errorf("issuer and client id are required")
+	}
+
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	issuer := strings.TrimSuffix(cfg.IssuerURL, "/")
+	var doc discoveryDocument
+	if err := getJSON(ctx, client, issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", doc.Issuer, cfg.IssuerURL)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery: document lacks an endpoint")
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &OIDCProvider{
+		name:   cfg.Name,
+		issuer: doc.Issuer,
+		config: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  doc.AuthorizationEndpoint,
+				TokenURL: doc.TokenEndpoint,
+			},
+		},
+		keys:       newKeySet(client, doc.JWKSURI),
+		client:     client,
+		trustEmail: cfg.TrustEmail,
+	}, nil
+}
+
+// Name returns the configured provider name.
+func (p *OIDCProvider) Name() string {
+	return p.name
+}
+
+// AuthURL returns the provider's authorization URL, asking it to put nonce
+// in the ID token, with the PKCE challenge of verifier.
+func (p *OIDCProvider) AuthURL(state, nonce, verifier string) string {
+	return p.config.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("nonce", nonce),
+		oauth2.S256ChallengeOption(verifier),
+	)
+}
+
+// Identity exchanges the code and PKCE verifier and verifies the ID token of
+// the response: its signature, issuer, audience, expiry and nonce.
+func (p *OIDCProvider) Identity(ctx context.Context, code, nonce, verifier string) (model.Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return model.Identity{}, fmt.Errorf("failed to exchange token: %v", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return model.Identity{}, fmt.Errorf("token response has no id_token")
+	}
+
+	claims, err := verifyIDToken(ctx, p.keys, rawIDToken, p.issuer, p.config.ClientID, nonce, time.Now())
+	if err != nil {
+		return model.Identity{}, err
+	}
+
+	return model.Identity{
+		Provider:      p.name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: p.trustEmail || bool(claims.EmailVerified),
+		Name:          claims.Name,
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
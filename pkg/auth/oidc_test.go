@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newDiscoveryServer serves a discovery document naming issuer, or the
+// server itself when issuer is empty, with its JWKS at /jwks.
+func newDiscoveryServer(t *testing.T, issuer string, keys ...testKey) *httptest.Server {
+	t.Helper()
+	jwks := newJWKSServer(t, keys...)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+		iss := issuer
+		if iss == "" {
+			iss = server.URL
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 iss,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               jwks.URL,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewOIDCProvider(t *testing.T) {
+	key := newRSAKey(t, "rsa")
+	server := newDiscoveryServer(t, "", key)
+	ctx := context.Background()
+
+	p, err := NewOIDCProvider(ctx, OIDCConfig{
+		Name:       "keycloak",
+		IssuerURL:  server.URL + "/",
+		ClientID:   testClientID,
+		HTTPClient: server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider returned error: %v", err)
+	}
+	if p.Name() != "keycloak" || p.issuer != server.URL {
+		t.Errorf("provider = %q issued by %q", p.Name(), p.issuer)
+	}
+
+	// Tokens are checked against the discovered issuer and JWKS
+	now := time.Now()
+	token := key.sign(t, "RS256", "rsa", validClaims(server.URL, now))
+	if _, err := verifyIDToken(ctx, p.keys, token, p.issuer, testClientID, testNonce, now); err != nil {
+		t.Errorf("token of the discovered provider: %v", err)
+	}
+	forged := key.sign(t, "RS256", "rsa", validClaims("https://evil.example.com", now))
+	if _, err := verifyIDToken(ctx, p.keys, forged, p.issuer, testClientID, testNonce, now); err == nil {
+		t.Errorf("token of another issuer verified")
+	}
+}
+
+func TestNewOIDCProviderRejects(t *testing.T) {
+	ctx := context.Background()
+
+	mismatch := newDiscoveryServer(t, "https://evil.example.com")
+	if _, err := NewOIDCProvider(ctx, OIDCConfig{IssuerURL: mismatch.URL, ClientID: testClientID, HTTPClient: mismatch.Client()}); err == nil {
+		t.Errorf("discovery document of another issuer accepted")
+	}
+
+	incomplete := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": "http://" + r.Host})
+	}))
+	defer incomplete.Close()
+	if _, err := NewOIDCProvider(ctx, OIDCConfig{IssuerURL: incomplete.URL, ClientID: testClientID, HTTPClient: incomplete.Client()}); err == nil {
+		t.Errorf("discovery document without endpoints accepted")
+	}
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	if _, err := NewOIDCProvider(ctx, OIDCConfig{IssuerURL: missing.URL, ClientID: testClientID, HTTPClient: missing.Client()}); err == nil {
+		t.Errorf("missing discovery document accepted")
+	}
+
+	if _, err := NewOIDCProvider(ctx, OIDCConfig{IssuerURL: mismatch.URL}); err == nil {
+		t.Errorf("config without client id accepted")
+	}
+}
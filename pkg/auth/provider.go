@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+
+	"golang.org/x/oauth2"
+)
+
+// Provider is an OAuth 2.0 identity provider users can log in with.
+type Provider interface {
+	// Name identifies the provider in routes and in the user identities.
+	Name() string
+	// AuthURL returns the provider's consent page URL. The provider must
+	// send state back to the callback and bind nonce to the identity it
+	// issues, when it supports one. The URL carries the PKCE challenge of
+	// verifier.
+	AuthURL(state, nonce, verifier string) string
+	// Identity exchanges the authorization code of the callback, proving
+	// with verifier that this server started the login, and returns the
+	// verified identity of the user who logged in.
+	Identity(ctx context.Context, code, nonce, verifier string) (model.Identity, error)
+}
+
+// NewCodeVerifier returns a random PKCE code verifier (RFC 7636).
+func NewCodeVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{}
+)
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// RegisterProvider makes p available for logins, replacing any provider
+// with the same name.
+func RegisterProvider(p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[p.Name()] = p
+}
+
+// GetProvider returns the registered provider with the given name.
+func GetProvider(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// ProviderNames returns the names of the registered providers, sorted.
+func ProviderNames() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InitProviders registers the providers configured in the environment:
+// Google when GOOGLE_CLIENT_ID is set, and one OpenID Connect provider for
+// each name in the comma separated OIDC_PROVIDERS, configured by
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET,
+// OIDC_<NAME>_REDIRECT_URL and optionally OIDC_<NAME>_SCOPES and
+// OIDC_<NAME>_TRUST_EMAIL. A provider whose discovery fails is left out.
+func InitProviders() {
+	if os.Getenv("GOOGLE_CLIENT_ID") != "" {
+		RegisterProvider(NewGoogleProvider(
+			os.Getenv("GOOGLE_CLIENT_ID"),
+			os.Getenv("GOOGLE_CLIENT_SECRET"),
+			os.Getenv("GOOGLE_REDIRECT_URL"),
+		))
+	}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !providerNamePattern.MatchString(name) || name == GoogleProviderName {
+			logger.Log.Errorf("Invalid OIDC provider name %q", name)
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		cfg := OIDCConfig{
+			Name:         name,
+			IssuerURL:    os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			TrustEmail:   os.Getenv(prefix+"TRUST_EMAIL") == "true",
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			cfg.Scopes = strings.Fields(scopes)
+		}
+
+		p, err := NewOIDCProvider(context.Background(), cfg)
+		if err != nil {
+			logger.Log.Errorf("Failed to set up OIDC provider %s: %v", name, err)
+			continue
+		}
+		RegisterProvider(p)
+	}
+
+	logger.Log.Infof("Identity providers: %v", ProviderNames())
+}
@@ -0,0 +1,245 @@
+package api_key_repository
+
+import (
+	"context"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/jackc/pgx/v5"
+)
+
+const apiKeyColumns = `
+	k.api_key_id, k.organization_id, k.name, k.key_prefix, k.scopes, k.store_id, k.created_by,
+	k.expires_at, k.last_used_at, k.last_used_ip, k.revoked_at, k.created_at`
+
+// apiKeyDest returns the scan destinations of apiKeyColumns. The scopes are
+// scanned into scopes and copied into the key by setScopes.
+func apiKeyDest(key *model.APIKey, scopes *[]string) []any {
+	return []any{
+		&key.ID,
+		&key.OrganizationID,
+		&key.Name,
+		&key.Prefix,
+		scopes,
+		&key.StoreID,
+		&key.CreatedBy,
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.LastUsedIP,
+		&key.RevokedAt,
+		&key.CreatedAt,
+	}
+}
+
+func setScopes(key *model.APIKey, scopes []string) {
+	key.Scopes = make([]model.Permission, len(scopes))
+	for i, s := range scopes {
+		key.Scopes[i] = model.Permission(s)
+	}
+}
+
+func scopeStrings(key *model.APIKey) []string {
+	out := make([]string, len(key.Scopes))
+	for i, s := range key.Scopes {
+		out[i] = string(s)
+	}
+	return out
+}
+
+// CreateAPIKey stores a new key with the hash of its secret and fills in
+// its id and creation time. The audit log records key.CreatedBy as the
+// actor.
+func CreateAPIKey(key *model.APIKey, keyHash string) error {
+	logger.Log.Infof("CreateAPIKey organization id: %d", key.OrganizationID)
+
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error starting transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if key.CreatedBy != nil {
+		if err := db.SetAuditActor(ctx, tx, *key.CreatedBy); err != nil {
+			logger.Log.Errorf("Error setting audit actor: %v", err)
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO public.tb_api_key (
+			organization_id, name, key_prefix, key_hash, scopes, store_id, created_by, expires_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING api_key_id, created_at`
+
+	err = tx.QueryRow(ctx, query,
+		key.OrganizationID,
+		key.Name,
+		key.Prefix,
+		keyHash,
+		scopeStrings(key),
+		key.StoreID,
+		key.CreatedBy,
+		key.ExpiresAt,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		logger.Log.Errorf("Error creating API key: %v", err)
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		logger.Log.Errorf("Error committing API key: %v", err)
+		return err
+	}
+
+	logger.Log.Info("API key successfully created.")
+	return nil
+}
+
+// ListAPIKeys returns every key of the organization, newest first.
+func ListAPIKeys(organizationID uint) ([]model.APIKey, error) {
+	logger.Log.Infof("ListAPIKeys organization id: %d", organizationID)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM public.tb_api_key k
+		WHERE k.organization_id = $1
+		ORDER BY k.created_at DESC`
+
+	rows, err := conn.Query(context.Background(), query, organizationID)
+	if err != nil {
+		logger.Log.Errorf("Error querying API keys: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []model.APIKey{}
+	for rows.Next() {
+		var key model.APIKey
+		var scopes []string
+		if err := rows.Scan(apiKeyDest(&key, &scopes)...); err != nil {
+			logger.Log.Errorf("Error scanning API key: %v", err)
+			return nil, err
+		}
+		setScopes(&key, scopes)
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey stops a key from working, recording actorID as the user who
+// revoked it. It returns false when the key was already revoked or is not in
+// the organization.
+func RevokeAPIKey(id, organizationID, actorID uint) (bool, error) {
+	logger.Log.Infof("RevokeAPIKey id: %d", id)
+
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return false, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error starting transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := db.SetAuditActor(ctx, tx, actorID); err != nil {
+		logger.Log.Errorf("Error setting audit actor: %v", err)
+		return false, err
+	}
+
+	cmdTag, err := tx.Exec(ctx, `
+		UPDATE public.tb_api_key
+		SET revoked_at = NOW()
+		WHERE api_key_id = $1 AND organization_id = $2 AND revoked_at IS NULL`, id, organizationID)
+	if err != nil {
+		logger.Log.Errorf("Error revoking API key: %v", err)
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		logger.Log.Errorf("Error committing API key revocation: %v", err)
+		return false, err
+	}
+
+	return cmdTag.RowsAffected() > 0, nil
+}
+
+// AuthenticateAPIKey looks up a usable key by the hash of its secret, records
+// its use and returns it with the user it acts for. It returns nil, nil, nil
+// when the key is unknown, revoked or expired, or when its creator or
+// organization is gone or inactive.
+func AuthenticateAPIKey(keyHash, ipAddress string) (*model.APIKey, *model.User, error) {
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return nil, nil, err
+	}
+	defer conn.Release()
+
+	query := `
+		WITH k AS (
+			UPDATE public.tb_api_key
+			SET last_used_at = NOW(), last_used_ip = $2
+			WHERE key_hash = $1
+			  AND revoked_at IS NULL
+			  AND (expires_at IS NULL OR expires_at > NOW())
+			RETURNING *
+		)
+		SELECT ` + apiKeyColumns + `,
+		       u.user_id, u.email, COALESCE(u.given_name, ''), COALESCE(u.family_name, ''), COALESCE(u.picture_url, ''),
+		       o.organization_id, o.schema_name, o.is_try_out, o.expires_at
+		FROM k
+		JOIN public.tb_user u ON u.user_id = k.created_by AND u.organization_id = k.organization_id
+		JOIN public.tb_organization o ON o.organization_id = k.organization_id
+		WHERE u.is_active AND o.is_active`
+
+	key := &model.APIKey{}
+	user := &model.User{}
+	var scopes []string
+	dest := append(apiKeyDest(key, &scopes),
+		&user.ID,
+		&user.Email,
+		&user.GivenName,
+		&user.FamilyName,
+		&user.PictureURL,
+		&user.Organization.ID,
+		&user.Organization.DBSchema,
+		&user.Organization.IsTryOut,
+		&user.Organization.ExpiresAt,
+	)
+
+	err = conn.QueryRow(context.Background(), query, keyHash, ipAddress).Scan(dest...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil, nil
+		}
+		logger.Log.Errorf("Error authenticating API key: %v", err)
+		return nil, nil, err
+	}
+	setScopes(key, scopes)
+
+	return key, user, nil
+}
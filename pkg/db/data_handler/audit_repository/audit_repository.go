@@ -0,0 +1,65 @@
+package audit_repository
+
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AuditListSpec lists the fields ListAuditLog can filter and sort by
+var AuditListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"entity_type": {Column: "entity_type", Type: list_query.FieldText},
+		"entity_id":   {Column: "entity_id", Type: list_query.FieldText},
+		"action":      {Column: "action", Type: list_query.FieldText},
+		"user_id":     {Column: "user_id", Type: list_query.FieldInt},
+		"api_key_id":  {Column: "api_key_id", Type: list_query.FieldInt},
+		"occurred_at": {Column: "occurred_at", Type: list_query.FieldTime},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":          {Column: "audit_id", Type: list_query.FieldInt},
+		"occurred_at": {Column: "occurred_at", Type: list_query.FieldTime},
+	},
+	DefaultSort: "-occurred_at",
+	Key:         list_query.Field{Column: "audit_id", Type: list_query.FieldInt},
+}
+
+// ListAuditLog returns a page of the tenant's audit log
+func ListAuditLog(conn *pgxpool.Conn, lq *list_query.Query) ([]*model.AuditEntry, *list_query.PageInfo, error) {
+	logger.Log.Info("ListAuditLog")
+
+	query := `
+		SELECT audit_id, occurred_at, user_id, api_key_id, entity_type, entity_id, action, before, after
+		FROM tb_audit_log
+	`
+
+	entries := []*model.AuditEntry{}
+	page, err := list_query.Fetch(conn, lq, query, nil, func(rows pgx.Rows, cursor *string) error {
+		var e model.AuditEntry
+		err := rows.Scan(
+			&e.ID,
+			&e.OccurredAt,
+			&e.UserID,
+			&e.APIKeyID,
+			&e.EntityType,
+			&e.EntityID,
+			&e.Action,
+			&e.Before,
+			&e.After,
+			cursor,
+		)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, &e)
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Error querying audit log: %v", err)
+		return nil, nil, err
+	}
+
+	return entries, page, nil
+}
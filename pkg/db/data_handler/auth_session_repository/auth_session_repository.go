@@ -0,0 +1,274 @@
+package auth_session_repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/jackc/pgx/v5"
+)
+
+// Reasons recorded when a session is revoked.
+const (
+	RevokedLogout            = "logout"
+	RevokedLogoutAll         = "logout_all"
+	RevokedRefreshTokenReuse = "refresh_token_reuse"
+	RevokedUserInactive      = "user_inactive"
+	// RevokedTryOutConverted ends the sessions of a converted try-out,
+	// which were capped at its expiration and may name its old schema.
+	RevokedTryOutConverted = "tryout_converted"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown or expired refresh
+	// tokens and for tokens of revoked sessions.
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh
+	// token is presented again. Its session is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// CreateSession starts a session for the user together with its first
+// refresh token.
+func CreateSession(sessionID string, userID uint, userAgent, ipAddress string, expiresAt time.Time, refreshTokenHash string, refreshExpiresAt time.Time) error {
+	logger.Log.Infof("CreateSession user id: %d", userID)
+
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO public.tb_auth_session (session_id, user_id, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`, sessionID, userID, userAgent, ipAddress, expiresAt)
+	if err != nil {
+		logger.Log.Errorf("Error inserting session: %v", err)
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO public.tb_refresh_token (session_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)`, sessionID, refreshTokenHash, refreshExpiresAt)
+	if err != nil {
+		logger.Log.Errorf("Error inserting refresh token: %v", err)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RotateRefreshToken marks a refresh token as used and stores its
+// replacement in the same session. It returns the session and its user.
+// Presenting a token that was already used revokes the whole session, since
+// either the legitimate client or an attacker holds a stolen copy.
+func RotateRefreshToken(tokenHash, newTokenHash string, newExpiresAt time.Time) (sessionID string, userID uint, err error) {
+	logger.Log.Info("RotateRefreshToken")
+
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return "", 0, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Failed to begin transaction: %v", err)
+		return "", 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the token so two concurrent refreshes cannot both rotate it
+	var tokenID int64
+	var usedAt *time.Time
+	var tokenExpiresAt, sessionExpiresAt time.Time
+	var revokedAt *time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT rt.refresh_token_id, rt.used_at, rt.expires_at,
+		       s.session_id::text, s.user_id, s.expires_at, s.revoked_at
+		FROM public.tb_refresh_token rt
+		JOIN public.tb_auth_session s ON s.session_id = rt.session_id
+		WHERE rt.token_hash = $1
+		FOR UPDATE OF rt, s`, tokenHash).
+		Scan(&tokenID, &usedAt, &tokenExpiresAt, &sessionID, &userID, &sessionExpiresAt, &revokedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", 0, ErrInvalidRefreshToken
+		}
+		logger.Log.Errorf("Error loading refresh token: %v", err)
+		return "", 0, err
+	}
+
+	if revokedAt != nil {
+		return "", 0, ErrInvalidRefreshToken
+	}
+
+	if usedAt != nil {
+		logger.Log.Warnf("Refresh token reuse detected, revoking session %s of user id: %d", sessionID, userID)
+		_, err = tx.Exec(ctx, `
+			UPDATE public.tb_auth_session
+			SET revoked_at = NOW(), revoked_reason = $1
+			WHERE session_id = $2`, RevokedRefreshTokenReuse, sessionID)
+		if err != nil {
+			logger.Log.Errorf("Error revoking session: %v", err)
+			return "", 0, err
+		}
+		if err = tx.Commit(ctx); err != nil {
+			return "", 0, err
+		}
+		return "", 0, ErrRefreshTokenReused
+	}
+
+	now := time.Now()
+	if !tokenExpiresAt.After(now) || !sessionExpiresAt.After(now) {
+		return "", 0, ErrInvalidRefreshToken
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE public.tb_refresh_token
+		SET used_at = NOW()
+		WHERE refresh_token_id = $1`, tokenID)
+	if err != nil {
+		logger.Log.Errorf("Error marking refresh token used: %v", err)
+		return "", 0, err
+	}
+
+	if newExpiresAt.After(sessionExpiresAt) {
+		newExpiresAt = sessionExpiresAt
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO public.tb_refresh_token (session_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)`, sessionID, newTokenHash, newExpiresAt)
+	if err != nil {
+		logger.Log.Errorf("Error inserting refresh token: %v", err)
+		return "", 0, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE public.tb_auth_session
+		SET last_used_at = NOW()
+		WHERE session_id = $1`, sessionID)
+	if err != nil {
+		logger.Log.Errorf("Error updating session: %v", err)
+		return "", 0, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		logger.Log.Errorf("Transaction commit failed: %v", err)
+		return "", 0, err
+	}
+
+	return sessionID, userID, nil
+}
+
+// IsSessionActive reports whether the session exists, belongs to the user,
+// is neither revoked nor expired, and both the user and its organization are
+// still active.
+func IsSessionActive(sessionID string, userID uint) (bool, error) {
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return false, err
+	}
+	defer conn.Release()
+
+	var active bool
+	err = conn.QueryRow(context.Background(), `
+		SELECT EXISTS (
+			SELECT 1
+			FROM public.tb_auth_session s
+			JOIN public.tb_user u ON u.user_id = s.user_id
+			JOIN public.tb_organization org ON org.organization_id = u.organization_id
+			WHERE s.session_id = $1
+			  AND s.user_id = $2
+			  AND s.revoked_at IS NULL
+			  AND s.expires_at > NOW()
+			  AND u.is_active IS TRUE
+			  AND org.is_active IS TRUE
+		)`, sessionID, userID).Scan(&active)
+	if err != nil {
+		logger.Log.Errorf("Error checking session: %v", err)
+		return false, err
+	}
+
+	return active, nil
+}
+
+// RevokeSession revokes a single session of the user.
+func RevokeSession(sessionID string, userID uint, reason string) error {
+	logger.Log.Infof("RevokeSession user id: %d", userID)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(context.Background(), `
+		UPDATE public.tb_auth_session
+		SET revoked_at = NOW(), revoked_reason = $1
+		WHERE session_id = $2 AND user_id = $3 AND revoked_at IS NULL`,
+		reason, sessionID, userID)
+	if err != nil {
+		logger.Log.Errorf("Error revoking session: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// RevokeUserSessions revokes every active session of the user.
+func RevokeUserSessions(userID uint, reason string) error {
+	logger.Log.Infof("RevokeUserSessions user id: %d", userID)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(context.Background(), `
+		UPDATE public.tb_auth_session
+		SET revoked_at = NOW(), revoked_reason = $1
+		WHERE user_id = $2 AND revoked_at IS NULL`, reason, userID)
+	if err != nil {
+		logger.Log.Errorf("Error revoking sessions: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// RevokeOrganizationSessionsTx revokes every active session of the users of
+// the organization.
+func RevokeOrganizationSessionsTx(ctx context.Context, tx pgx.Tx, organizationID uint, reason string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE public.tb_auth_session s
+		SET revoked_at = NOW(), revoked_reason = $1
+		FROM public.tb_user u
+		WHERE u.user_id = s.user_id
+		  AND u.organization_id = $2
+		  AND s.revoked_at IS NULL`, reason, organizationID)
+	if err != nil {
+		logger.Log.Errorf("Error revoking organization sessions: %v", err)
+		return err
+	}
+
+	return nil
+}
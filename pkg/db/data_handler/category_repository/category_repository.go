@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
 	"github.com/jackc/pgx/v5"
@@ -15,13 +16,14 @@ func SaveCategory(conn *pgxpool.Conn, category *model.Category) error {
 
 	query := `
 		WITH inserted AS (
-			INSERT INTO tb_category (category_description, created_by, store_id)
-			VALUES ($1, $2, $3)
-			RETURNING category_id, category_description, created_by, created_at, updated_at
+			INSERT INTO tb_category (category_description, created_by, store_id, parent_category_id)
+			VALUES ($1, $2, $3, $4)
+			RETURNING category_id, category_description, parent_category_id, created_by, created_at, updated_at
 		)
 		SELECT 
 			c.category_id,
 			c.category_description,
+			c.parent_category_id,
 			c.created_by,
 			c.created_at,
 			c.updated_at
@@ -32,9 +34,11 @@ func SaveCategory(conn *pgxpool.Conn, category *model.Category) error {
 		category.Description,
 		category.CreatedBy.ID,
 		category.Store.ID,
+		category.ParentID,
 	).Scan(
 		&category.ID,
 		&category.Description,
+		&category.ParentID,
 		&category.CreatedBy.ID,
 		&category.CreatedAt,
 		&category.UpdatedAt,
@@ -49,23 +53,25 @@ func SaveCategory(conn *pgxpool.Conn, category *model.Category) error {
 	return nil
 }
 
-func GetCategoryByID(conn *pgxpool.Conn, id uint) (*model.Category, error) {
+func GetCategoryByID(conn *pgxpool.Conn, id, storeID uint) (*model.Category, error) {
 	logger.Log.Info("GetCategoryByID")
 
 	query := `
-		SELECT c.category_id, c.category_description, u.user_id,
-		       c.created_at, c.updated_at
+		SELECT c.category_id, c.category_description, c.parent_category_id, u.user_id,
+		       c.created_at, c.updated_at, c.archived_at
 		FROM tb_category c
 		JOIN tb_user u ON c.created_by = u.user_id
-		WHERE c.category_id = $1`
+		WHERE c.category_id = $1 AND c.store_id = $2`
 
 	category := &model.Category{}
-	err := conn.QueryRow(context.Background(), query, id).Scan(
+	err := conn.QueryRow(context.Background(), query, id, storeID).Scan(
 		&category.ID,
 		&category.Description,
+		&category.ParentID,
 		&category.CreatedBy.ID,
 		&category.CreatedAt,
 		&category.UpdatedAt,
+		&category.ArchivedAt,
 	)
 
 	if err != nil {
@@ -90,12 +96,15 @@ func UpdateCategory(conn *pgxpool.Conn, ownerID uint, category *model.Category)
         SET category_description = $1
         WHERE category_id = $2
           AND created_by = $3
+          AND store_id = $4
         RETURNING
           category_id,
           category_description,
+          parent_category_id,
           created_by,
           created_at,
-          updated_at
+          updated_at,
+          archived_at
     `
 
 	// Prepare a fresh model to scan into:
@@ -104,15 +113,18 @@ func UpdateCategory(conn *pgxpool.Conn, ownerID uint, category *model.Category)
 		category.Description,
 		category.ID,
 		ownerID,
+		category.Store.ID,
 	)
 
 	// Scan the returned columns into your model fields:
 	if err := row.Scan(
 		&updated.ID,
 		&updated.Description,
+		&updated.ParentID,
 		&updated.CreatedBy.ID,
 		&updated.CreatedAt,
 		&updated.UpdatedAt,
+		&updated.ArchivedAt,
 	); err != nil {
 		logger.Log.Errorf("Error updating category: %v", err)
 		return nil, err
@@ -122,12 +134,12 @@ func UpdateCategory(conn *pgxpool.Conn, ownerID uint, category *model.Category)
 	return updated, nil
 }
 
-func DeleteCategory(conn *pgxpool.Conn, id uint) error {
+func DeleteCategory(conn *pgxpool.Conn, id, storeID uint) error {
 	logger.Log.Info("DeleteCategory")
 
-	query := `DELETE FROM tb_category WHERE category_id = $1`
+	query := `DELETE FROM tb_category WHERE category_id = $1 AND store_id = $2`
 
-	cmdTag, err := conn.Exec(context.Background(), query, id)
+	cmdTag, err := conn.Exec(context.Background(), query, id, storeID)
 	if err != nil {
 		logger.Log.Errorf("Error deleting category: %v", err)
 		return err
@@ -141,47 +153,123 @@ func DeleteCategory(conn *pgxpool.Conn, id uint) error {
 	return nil
 }
 
-func ListCategories(conn *pgxpool.Conn, OwnerID, StoreID uint) ([]*model.Category, error) {
+// CategoryListSpec lists the fields ListCategories can filter and sort by
+var CategoryListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"parent_id":  {Column: "parent_category_id", Type: list_query.FieldInt},
+		"created_at": {Column: "created_at", Type: list_query.FieldTime},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":          {Column: "category_id", Type: list_query.FieldInt},
+		"description": {Column: "category_description", Type: list_query.FieldText},
+		"created_at":  {Column: "created_at", Type: list_query.FieldTime},
+	},
+	DefaultSort: "description",
+	Key:         list_query.Field{Column: "category_id", Type: list_query.FieldInt},
+}
+
+// ListCategories returns the store's categories, skipping archived ones
+// unless includeArchived is set.
+func ListCategories(conn *pgxpool.Conn, OwnerID, StoreID uint, includeArchived bool, lq *list_query.Query) ([]*model.Category, *list_query.PageInfo, error) {
 	logger.Log.Info("ListCategories")
 
 	query := `
-		SELECT c.category_id, c.category_description, u.user_id,
-		       c.created_at, c.updated_at
+		SELECT c.category_id, c.category_description, c.parent_category_id, u.user_id,
+		       c.created_at, c.updated_at, c.archived_at
 		FROM tb_category c
 		JOIN tb_user u ON c.created_by = u.user_id
-		WHERE c.created_by = $1 AND c.store_id = $2`
-
-	rows, err := conn.Query(context.Background(), query, OwnerID, StoreID)
-	if err != nil {
-		logger.Log.Errorf("Error fetching categories: %v", err)
-		return nil, err
-	}
-	defer rows.Close()
+		WHERE c.created_by = $1 AND c.store_id = $2
+		  AND ($3 OR c.archived_at IS NULL)`
 
 	categories := []*model.Category{}
-	for rows.Next() {
+	page, err := list_query.Fetch(conn, lq, query, []any{OwnerID, StoreID, includeArchived}, func(rows pgx.Rows, cursor *string) error {
 		category := &model.Category{}
-		err = rows.Scan(
+		err := rows.Scan(
 			&category.ID,
 			&category.Description,
+			&category.ParentID,
 			&category.CreatedBy.ID,
 			&category.CreatedAt,
 			&category.UpdatedAt,
+			&category.ArchivedAt,
+			cursor,
 		)
 		if err != nil {
-			logger.Log.Errorf("Error scanning category: %v", err)
-			return nil, err
+			return err
 		}
 		categories = append(categories, category)
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Error fetching categories: %v", err)
+		return nil, nil, err
 	}
 
-	if err = rows.Err(); err != nil {
-		logger.Log.Errorf("Error iterating categories: %v", err)
+	logger.Log.Infof("Retrieved %d categories", len(categories))
+	return categories, page, nil
+}
+
+// MoveCategory re-parents a category, or turns it into a root when parentID
+// is nil. Cycles and cross-store parents are rejected by the
+// trg_validate_category_parent trigger. Returns nil, nil when the category
+// does not exist in the store.
+func MoveCategory(conn *pgxpool.Conn, id, storeID uint, parentID *uint) (*model.Category, error) {
+	logger.Log.Info("MoveCategory")
+
+	query := `UPDATE tb_category SET parent_category_id = $1 WHERE category_id = $2 AND store_id = $3`
+	cmdTag, err := conn.Exec(context.Background(), query, parentID, id, storeID)
+	if err != nil {
+		logger.Log.Errorf("Error moving category: %v", err)
 		return nil, err
 	}
+	if cmdTag.RowsAffected() == 0 {
+		logger.Log.Infof("No category found for move with id: %d", id)
+		return nil, nil
+	}
 
-	logger.Log.Infof("Retrieved %d categories", len(categories))
-	return categories, nil
+	logger.Log.Info("Category successfully moved")
+	return GetCategoryByID(conn, id, storeID)
+}
+
+// ArchiveCategory stamps archived_at on a category. Items already classified
+// under it keep resolving it; new or re-classified items are rejected.
+// Returns nil, nil when the category does not exist in the store.
+func ArchiveCategory(conn *pgxpool.Conn, id, storeID uint) (*model.Category, error) {
+	logger.Log.Info("ArchiveCategory")
+
+	query := `UPDATE tb_category SET archived_at = COALESCE(archived_at, NOW()) WHERE category_id = $1 AND store_id = $2`
+	cmdTag, err := conn.Exec(context.Background(), query, id, storeID)
+	if err != nil {
+		logger.Log.Errorf("Error archiving category: %v", err)
+		return nil, err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		logger.Log.Infof("No category found for archive with id: %d", id)
+		return nil, nil
+	}
+
+	logger.Log.Info("Category successfully archived")
+	return GetCategoryByID(conn, id, storeID)
+}
+
+// UnarchiveCategory clears archived_at on a category.
+// Returns nil, nil when the category does not exist in the store.
+func UnarchiveCategory(conn *pgxpool.Conn, id, storeID uint) (*model.Category, error) {
+	logger.Log.Info("UnarchiveCategory")
+
+	query := `UPDATE tb_category SET archived_at = NULL WHERE category_id = $1 AND store_id = $2`
+	cmdTag, err := conn.Exec(context.Background(), query, id, storeID)
+	if err != nil {
+		logger.Log.Errorf("Error unarchiving category: %v", err)
+		return nil, err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		logger.Log.Infof("No category found for unarchive with id: %d", id)
+		return nil, nil
+	}
+
+	logger.Log.Info("Category successfully unarchived")
+	return GetCategoryByID(conn, id, storeID)
 }
 
 func GetReferencingItems(conn *pgxpool.Conn, id uint) (any, error) {
@@ -203,3 +291,26 @@ func GetReferencingItems(conn *pgxpool.Conn, id uint) (any, error) {
 
 	return result, nil
 }
+
+// GetChildCategories returns the direct children of a category. Used to
+// explain why a parent category cannot be deleted.
+func GetChildCategories(conn *pgxpool.Conn, id uint) (any, error) {
+	rows, err := conn.Query(context.Background(), `
+		SELECT category_id, category_description, parent_category_id
+		FROM tb_category WHERE parent_category_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*model.Category
+	for rows.Next() {
+		cat := &model.Category{}
+		if err := rows.Scan(&cat.ID, &cat.Description, &cat.ParentID); err != nil {
+			return nil, err
+		}
+		result = append(result, cat)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,323 @@
+package invitation_repository
+
+import (
+	"context"
+	"time"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/jackc/pgx/v5"
+)
+
+const invitationColumns = `
+	i.invitation_id, i.email, i.role, i.store_id, i.invited_by, i.expires_at, i.last_sent_at,
+	i.accepted_at, i.accepted_by, i.revoked_at, i.created_at,
+	o.organization_id, o.organization_name, o.schema_name, o.is_try_out, o.is_active`
+
+func scanInvitation(row pgx.Row) (*model.Invitation, error) {
+	inv := &model.Invitation{}
+	err := row.Scan(
+		&inv.ID,
+		&inv.Email,
+		&inv.Role,
+		&inv.StoreID,
+		&inv.InvitedBy,
+		&inv.ExpiresAt,
+		&inv.LastSentAt,
+		&inv.AcceptedAt,
+		&inv.AcceptedBy,
+		&inv.RevokedAt,
+		&inv.CreatedAt,
+		&inv.Organization.ID,
+		&inv.Organization.Name,
+		&inv.Organization.DBSchema,
+		&inv.Organization.IsTryOut,
+		&inv.Organization.IsActive,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// CreateInvitation stores a new invitation with the hash of its token and
+// fills in its id, timestamps and organization. An expired invitation still
+// open for the same email is revoked first, so it does not block the new
+// one; a pending one makes the insert fail on uq_organization_invitation_open.
+// The audit log records inv.InvitedBy as the actor.
+func CreateInvitation(inv *model.Invitation, tokenHash string) error {
+	logger.Log.Infof("CreateInvitation organization id: %d", inv.Organization.ID)
+
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error starting transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if inv.InvitedBy != nil {
+		if err := db.SetAuditActor(ctx, tx, *inv.InvitedBy); err != nil {
+			logger.Log.Errorf("Error setting audit actor: %v", err)
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE public.tb_organization_invitation
+		SET revoked_at = NOW()
+		WHERE organization_id = $1 AND lower(email) = lower($2)
+		  AND accepted_at IS NULL AND revoked_at IS NULL
+		  AND expires_at <= NOW()`, inv.Organization.ID, inv.Email)
+	if err != nil {
+		logger.Log.Errorf("Error revoking expired invitation: %v", err)
+		return err
+	}
+
+	query := `
+		WITH i AS (
+			INSERT INTO public.tb_organization_invitation (
+				organization_id, email, role, store_id, token_hash, invited_by, expires_at
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING *
+		)
+		SELECT ` + invitationColumns + `
+		FROM i
+		JOIN public.tb_organization o ON o.organization_id = i.organization_id`
+
+	created, err := scanInvitation(tx.QueryRow(ctx, query,
+		inv.Organization.ID,
+		inv.Email,
+		string(inv.Role),
+		inv.StoreID,
+		tokenHash,
+		inv.InvitedBy,
+		inv.ExpiresAt,
+	))
+	if err != nil {
+		logger.Log.Errorf("Error creating invitation: %v", err)
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		logger.Log.Errorf("Error committing invitation: %v", err)
+		return err
+	}
+
+	*inv = *created
+	logger.Log.Info("Invitation successfully created.")
+	return nil
+}
+
+// ListInvitations returns every invitation of the organization, newest first.
+func ListInvitations(organizationID uint) ([]model.Invitation, error) {
+	logger.Log.Infof("ListInvitations organization id: %d", organizationID)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM public.tb_organization_invitation i
+		JOIN public.tb_organization o ON o.organization_id = i.organization_id
+		WHERE i.organization_id = $1
+		ORDER BY i.created_at DESC`
+
+	rows, err := conn.Query(context.Background(), query, organizationID)
+	if err != nil {
+		logger.Log.Errorf("Error querying invitations: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []model.Invitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			logger.Log.Errorf("Error scanning invitation: %v", err)
+			return nil, err
+		}
+		invitations = append(invitations, *inv)
+	}
+
+	return invitations, rows.Err()
+}
+
+// GetInvitationByTokenHash returns the invitation of a token, whatever its
+// status. It returns nil, nil when no invitation has that token.
+func GetInvitationByTokenHash(tokenHash string) (*model.Invitation, error) {
+	logger.Log.Info("GetInvitationByTokenHash")
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM public.tb_organization_invitation i
+		JOIN public.tb_organization o ON o.organization_id = i.organization_id
+		WHERE i.token_hash = $1`
+
+	inv, err := scanInvitation(conn.QueryRow(context.Background(), query, tokenHash))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logger.Log.Errorf("Error fetching invitation: %v", err)
+		return nil, err
+	}
+
+	return inv, nil
+}
+
+// RenewInvitation replaces the token of an open invitation and extends it,
+// so only the latest mailed link works. actorID is recorded as the user who
+// renewed it. It returns nil, nil when the invitation was accepted, revoked
+// or is not in the organization.
+func RenewInvitation(id, organizationID uint, tokenHash string, expiresAt time.Time, actorID uint) (*model.Invitation, error) {
+	logger.Log.Infof("RenewInvitation id: %d", id)
+
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error starting transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := db.SetAuditActor(ctx, tx, actorID); err != nil {
+		logger.Log.Errorf("Error setting audit actor: %v", err)
+		return nil, err
+	}
+
+	query := `
+		WITH i AS (
+			UPDATE public.tb_organization_invitation
+			SET token_hash = $3, expires_at = $4, last_sent_at = NOW()
+			WHERE invitation_id = $1 AND organization_id = $2
+			  AND accepted_at IS NULL AND revoked_at IS NULL
+			RETURNING *
+		)
+		SELECT ` + invitationColumns + `
+		FROM i
+		JOIN public.tb_organization o ON o.organization_id = i.organization_id`
+
+	inv, err := scanInvitation(tx.QueryRow(ctx, query, id, organizationID, tokenHash, expiresAt))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logger.Log.Errorf("Error renewing invitation: %v", err)
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		logger.Log.Errorf("Error committing invitation renewal: %v", err)
+		return nil, err
+	}
+
+	return inv, nil
+}
+
+// RevokeInvitation stops an open invitation from being accepted, recording
+// actorID as the user who revoked it. It returns false when the invitation
+// was accepted, revoked or is not in the organization.
+func RevokeInvitation(id, organizationID, actorID uint) (bool, error) {
+	logger.Log.Infof("RevokeInvitation id: %d", id)
+
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return false, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error starting transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := db.SetAuditActor(ctx, tx, actorID); err != nil {
+		logger.Log.Errorf("Error setting audit actor: %v", err)
+		return false, err
+	}
+
+	cmdTag, err := tx.Exec(ctx, `
+		UPDATE public.tb_organization_invitation
+		SET revoked_at = NOW()
+		WHERE invitation_id = $1 AND organization_id = $2
+		  AND accepted_at IS NULL AND revoked_at IS NULL`, id, organizationID)
+	if err != nil {
+		logger.Log.Errorf("Error revoking invitation: %v", err)
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		logger.Log.Errorf("Error committing invitation revocation: %v", err)
+		return false, err
+	}
+
+	return cmdTag.RowsAffected() > 0, nil
+}
+
+// LockOpenInvitationTx locks the invitation of a token for accepting it.
+// It returns nil, nil unless the invitation is pending and its organization
+// is active.
+func LockOpenInvitationTx(ctx context.Context, tx pgx.Tx, tokenHash string) (*model.Invitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM public.tb_organization_invitation i
+		JOIN public.tb_organization o ON o.organization_id = i.organization_id
+		WHERE i.token_hash = $1
+		  AND i.accepted_at IS NULL AND i.revoked_at IS NULL
+		  AND i.expires_at > NOW()
+		  AND o.is_active
+		FOR UPDATE OF i`
+
+	inv, err := scanInvitation(tx.QueryRow(ctx, query, tokenHash))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logger.Log.Errorf("Error locking invitation: %v", err)
+		return nil, err
+	}
+
+	return inv, nil
+}
+
+// MarkInvitationAcceptedTx records who accepted the invitation.
+func MarkInvitationAcceptedTx(ctx context.Context, tx pgx.Tx, id, userID uint) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE public.tb_organization_invitation
+		SET accepted_at = NOW(), accepted_by = $2
+		WHERE invitation_id = $1`, id, userID)
+	if err != nil {
+		logger.Log.Errorf("Error accepting invitation: %v", err)
+	}
+	return err
+}
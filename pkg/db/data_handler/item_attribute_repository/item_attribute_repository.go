@@ -0,0 +1,308 @@
+package item_attribute_repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+// SaveItemAttributeDefinition inserts a new attribute definition
+func SaveItemAttributeDefinition(conn *pgxpool.Conn, def *model.ItemAttributeDefinition) error {
+	logger.Log.Info("SaveItemAttributeDefinition")
+
+	query := `
+		INSERT INTO tb_item_attribute_definition
+			(store_id, attribute_key, attribute_label, attribute_type, enum_options, is_required, min_value, max_value, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING attribute_id, created_at, updated_at`
+
+	err := conn.QueryRow(context.Background(), query,
+		def.StoreID,
+		def.Key,
+		def.Label,
+		def.Type,
+		def.Options,
+		def.Required,
+		def.Min,
+		def.Max,
+		def.CreatedBy.ID,
+	).Scan(&def.ID, &def.CreatedAt, &def.UpdatedAt)
+	if err != nil {
+		logger.Log.Errorf("Error saving item attribute definition: %v", err)
+		return err
+	}
+
+	logger.Log.Info("Item attribute definition successfully created")
+	return nil
+}
+
+// ItemAttributeDefinitionListSpec lists the fields
+// ListItemAttributeDefinitions can filter and sort by
+var ItemAttributeDefinitionListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"key":         {Column: "attribute_key", Type: list_query.FieldText},
+		"type":        {Column: "attribute_type", Type: list_query.FieldText},
+		"is_required": {Column: "is_required", Type: list_query.FieldBool},
+		"created_at":  {Column: "created_at", Type: list_query.FieldTime},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":         {Column: "attribute_id", Type: list_query.FieldInt},
+		"key":        {Column: "attribute_key", Type: list_query.FieldText},
+		"label":      {Column: "attribute_label", Type: list_query.FieldText},
+		"created_at": {Column: "created_at", Type: list_query.FieldTime},
+	},
+	DefaultSort: "label",
+	Key:         list_query.Field{Column: "attribute_id", Type: list_query.FieldInt},
+}
+
+// ListItemAttributeDefinitions returns a page of the attribute definitions
+// of a store
+func ListItemAttributeDefinitions(conn *pgxpool.Conn, storeID uint, lq *list_query.Query) ([]model.ItemAttributeDefinition, *list_query.PageInfo, error) {
+	logger.Log.Infof("ListItemAttributeDefinitions storeID=%d", storeID)
+
+	query := `
+		SELECT attribute_id, store_id, attribute_key, attribute_label, attribute_type,
+		       enum_options, is_required, min_value, max_value, created_by, created_at, updated_at
+		FROM tb_item_attribute_definition
+		WHERE store_id = $1`
+
+	var defs []model.ItemAttributeDefinition
+	page, err := list_query.Fetch(conn, lq, query, []any{storeID}, func(rows pgx.Rows, cursor *string) error {
+		var d model.ItemAttributeDefinition
+		err := rows.Scan(
+			&d.ID, &d.StoreID, &d.Key, &d.Label, &d.Type,
+			&d.Options, &d.Required, &d.Min, &d.Max, &d.CreatedBy.ID, &d.CreatedAt, &d.UpdatedAt,
+			cursor,
+		)
+		if err != nil {
+			return err
+		}
+		defs = append(defs, d)
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Error querying item attribute definitions: %v", err)
+		return nil, nil, err
+	}
+
+	return defs, page, nil
+}
+
+// AllItemAttributeDefinitions returns every attribute definition of a
+// store, for validating attribute values and filters
+func AllItemAttributeDefinitions(conn *pgxpool.Conn, storeID uint) ([]model.ItemAttributeDefinition, error) {
+	defs, _, err := ListItemAttributeDefinitions(conn, storeID, list_query.Unpaged(ItemAttributeDefinitionListSpec))
+	return defs, err
+}
+
+// GetItemAttributeDefinitionByID retrieves a single attribute definition of the store
+func GetItemAttributeDefinitionByID(conn *pgxpool.Conn, id, storeID uint) (*model.ItemAttributeDefinition, error) {
+	logger.Log.Infof("GetItemAttributeDefinitionByID: %d", id)
+
+	query := `
+		SELECT attribute_id, store_id, attribute_key, attribute_label, attribute_type,
+		       enum_options, is_required, min_value, max_value, created_by, created_at, updated_at
+		FROM tb_item_attribute_definition
+		WHERE attribute_id = $1 AND store_id = $2`
+
+	var d model.ItemAttributeDefinition
+	err := conn.QueryRow(context.Background(), query, id, storeID).Scan(
+		&d.ID, &d.StoreID, &d.Key, &d.Label, &d.Type,
+		&d.Options, &d.Required, &d.Min, &d.Max, &d.CreatedBy.ID, &d.CreatedAt, &d.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// UpdateItemAttributeDefinition changes label, options, requirement and bounds.
+// Key and type are immutable. Returns nil, nil when the definition does not
+// exist in def.StoreID.
+func UpdateItemAttributeDefinition(conn *pgxpool.Conn, def *model.ItemAttributeDefinition) (*model.ItemAttributeDefinition, error) {
+	logger.Log.Infof("UpdateItemAttributeDefinition: %d", def.ID)
+
+	query := `
+		UPDATE tb_item_attribute_definition
+		SET attribute_label = $1,
+		    enum_options = CASE WHEN attribute_type = 'enum' THEN $2 ELSE NULL END,
+		    is_required = $3,
+		    min_value = $4,
+		    max_value = $5,
+		    updated_at = NOW()
+		WHERE attribute_id = $6 AND store_id = $7`
+
+	cmd, err := conn.Exec(context.Background(), query,
+		def.Label, def.Options, def.Required, def.Min, def.Max, def.ID, def.StoreID)
+	if err != nil {
+		logger.Log.Errorf("Error updating item attribute definition: %v", err)
+		return nil, err
+	}
+	if cmd.RowsAffected() == 0 {
+		return nil, nil
+	}
+
+	return GetItemAttributeDefinitionByID(conn, def.ID, def.StoreID)
+}
+
+// DeleteItemAttributeDefinition removes a definition of the store that no item
+// uses anymore
+func DeleteItemAttributeDefinition(conn *pgxpool.Conn, id, storeID uint) error {
+	logger.Log.Infof("DeleteItemAttributeDefinition: %d", id)
+
+	cmd, err := conn.Exec(context.Background(),
+		`DELETE FROM tb_item_attribute_definition WHERE attribute_id = $1 AND store_id = $2`, id, storeID)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return fmt.Errorf("no item attribute definition deleted")
+	}
+	return nil
+}
+
+// GetReferencingItems lists the items holding a value for the given attribute
+func GetReferencingItems(conn *pgxpool.Conn, id uint) (any, error) {
+
+	rows, err := conn.Query(context.Background(), `
+		SELECT i.item_id, i.item_description
+		FROM tb_item_attribute_value v
+		JOIN tb_item i ON i.item_id = v.item_id
+		WHERE v.attribute_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []model.Item
+	for rows.Next() {
+		var i model.Item
+		if err := rows.Scan(&i.ID, &i.Description); err != nil {
+			return nil, err
+		}
+		result = append(result, i)
+	}
+
+	return result, nil
+}
+
+// GetItemAttributeValues loads the attribute values of the given items keyed by item id
+func GetItemAttributeValues(conn *pgxpool.Conn, itemIDs []int) (map[uint][]model.ItemAttributeValue, error) {
+	byItem := map[uint][]model.ItemAttributeValue{}
+	if len(itemIDs) == 0 {
+		return byItem, nil
+	}
+
+	query := `
+		SELECT v.item_id, v.value_text, v.value_number, v.value_date,
+		       d.attribute_id, d.attribute_key, d.attribute_label, d.attribute_type
+		FROM tb_item_attribute_value v
+		JOIN tb_item_attribute_definition d ON d.attribute_id = v.attribute_id
+		WHERE v.item_id = ANY($1::int[])
+		ORDER BY d.attribute_label`
+
+	logger.Log.DebugSQL(query, itemIDs)
+
+	rows, err := conn.Query(context.Background(), query, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemID uint
+		var v model.ItemAttributeValue
+		err := rows.Scan(
+			&itemID, &v.Text, &v.Number, &v.Date,
+			&v.Definition.ID, &v.Definition.Key, &v.Definition.Label, &v.Definition.Type,
+		)
+		if err != nil {
+			return nil, err
+		}
+		byItem[itemID] = append(byItem[itemID], v)
+	}
+
+	return byItem, nil
+}
+
+// ReplaceItemAttributeValues overwrites every attribute value of an item
+// inside the caller's transaction.
+func ReplaceItemAttributeValues(tx pgx.Tx, itemID uint, values []model.ItemAttributeValue) error {
+	_, err := tx.Exec(context.Background(),
+		`DELETE FROM tb_item_attribute_value WHERE item_id = $1`, itemID)
+	if err != nil {
+		logger.Log.Errorf("Error clearing item attribute values: %v", err)
+		return err
+	}
+
+	insertValue := `
+		INSERT INTO tb_item_attribute_value (item_id, attribute_id, value_text, value_number, value_date)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	for _, v := range values {
+		_, err := tx.Exec(context.Background(), insertValue,
+			itemID, v.Definition.ID, v.Text, v.Number, v.Date)
+		if err != nil {
+			logger.Log.Errorf("Error inserting item attribute value: %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FilterSQL renders filters as AND EXISTS (...) conditions against itemColumn.
+// Placeholders start at firstArg; the returned args must be appended to the
+// caller's arguments in order.
+func FilterSQL(filters []model.ItemAttributeFilter, itemColumn string, firstArg int) (string, []any) {
+	var sb strings.Builder
+	var args []any
+
+	for _, f := range filters {
+		column := "v.value_text"
+		switch f.Definition.Type {
+		case model.AttributeTypeNumber:
+			column = "v.value_number"
+		case model.AttributeTypeDate:
+			column = "v.value_date"
+		}
+
+		op := "="
+		switch f.Op {
+		case model.AttributeFilterMin:
+			op = ">="
+		case model.AttributeFilterMax:
+			op = "<="
+		}
+
+		attrArg := firstArg + len(args)
+		valueArg := attrArg + 1
+
+		cond := fmt.Sprintf("%s %s $%d", column, op, valueArg)
+		if column == "v.value_text" {
+			// Text and enum filters are case-insensitive
+			cond = fmt.Sprintf("lower(v.value_text) = lower($%d)", valueArg)
+		}
+
+		fmt.Fprintf(&sb, `
+		  AND EXISTS (
+		    SELECT 1 FROM tb_item_attribute_value v
+		    WHERE v.item_id = %s AND v.attribute_id = $%d AND %s
+		  )`, itemColumn, attrArg, cond)
+
+		args = append(args, f.Definition.ID, f.Value)
+	}
+
+	return sb.String(), args
+}
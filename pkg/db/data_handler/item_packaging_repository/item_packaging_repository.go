@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
@@ -14,15 +15,16 @@ import (
 )
 
 // SaveItemPackaging inserts a new packaging into the tb_item_packaging table,
-// and returns the item description via a CTE join.
+// and returns the item description via a CTE join. For nested packagings the
+// returned quantity is the base-unit equivalent derived by the database.
 func SaveItemPackaging(conn *pgxpool.Conn, packaging *model.ItemPackaging) error {
 	logger.Log.Info("SaveItemPackaging")
 
 	query := `
 		WITH inserted AS (
-			INSERT INTO tb_item_packaging (item_id, item_packaging_description, quantity, created_by, store_id)
-			VALUES ($1, $2, $3, $4, $5)
-			RETURNING item_packaging_id, item_packaging_description, item_id, created_by, quantity, created_at, updated_at
+			INSERT INTO tb_item_packaging (item_id, item_packaging_description, quantity, created_by, store_id, inner_packaging_id, inner_quantity)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING item_packaging_id, item_packaging_description, item_id, created_by, quantity, inner_packaging_id, inner_quantity, created_at, updated_at
 		)
 		SELECT
 			i.item_packaging_id,
@@ -30,6 +32,8 @@ func SaveItemPackaging(conn *pgxpool.Conn, packaging *model.ItemPackaging) error
 			i.item_id,
 			it.item_description,
 			i.quantity,
+			i.inner_packaging_id,
+			i.inner_quantity,
 			i.created_by,
 			i.created_at,
 			i.updated_at,
@@ -48,12 +52,16 @@ func SaveItemPackaging(conn *pgxpool.Conn, packaging *model.ItemPackaging) error
 		packaging.Quantity,
 		packaging.CreatedBy.ID,
 		packaging.Store.ID,
+		packaging.InnerPackagingID,
+		packaging.InnerQuantity,
 	).Scan(
 		&packaging.ID,
 		&packaging.Description,
 		&packaging.Item.ID,
 		&packaging.Item.Description,
 		&packaging.Quantity,
+		&packaging.InnerPackagingID,
+		&packaging.InnerQuantity,
 		&packaging.CreatedBy.ID,
 		&packaging.CreatedAt,
 		&packaging.UpdatedAt,
@@ -71,42 +79,55 @@ func SaveItemPackaging(conn *pgxpool.Conn, packaging *model.ItemPackaging) error
 	return nil
 }
 
-// ListItemPackagingsPaginated returns a paginated list of packagings
-func ListItemPackagingsPaginated(conn *pgxpool.Conn, ownerID, storeID, offset, limit uint) ([]model.ItemPackaging, error) {
-	logger.Log.Infof("ListItemPackagingsPaginated offset=%d limit=%d", offset, limit)
+// ItemPackagingListSpec lists the fields ListItemPackagings can filter and
+// sort by
+var ItemPackagingListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"item_id":            {Column: "item_id", Type: list_query.FieldInt},
+		"inner_packaging_id": {Column: "inner_packaging_id", Type: list_query.FieldInt},
+		"quantity":           {Column: "quantity", Type: list_query.FieldNumber},
+		"created_at":         {Column: "created_at", Type: list_query.FieldTime},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":               {Column: "item_packaging_id", Type: list_query.FieldInt},
+		"description":      {Column: "item_packaging_description", Type: list_query.FieldText},
+		"item_description": {Column: "item_description", Type: list_query.FieldText},
+		"quantity":         {Column: "quantity", Type: list_query.FieldNumber},
+		"created_at":       {Column: "created_at", Type: list_query.FieldTime},
+	},
+	DefaultSort: "-created_at",
+	Key:         list_query.Field{Column: "item_packaging_id", Type: list_query.FieldInt},
+}
+
+// ListItemPackagings returns a page of the store's packagings, archived
+// ones only when includeArchived is set
+func ListItemPackagings(conn *pgxpool.Conn, ownerID, storeID uint, includeArchived bool, lq *list_query.Query) ([]model.ItemPackaging, *list_query.PageInfo, error) {
+	logger.Log.Info("ListItemPackagings")
 
 	query := `
 		SELECT sp.item_packaging_id, sp.item_packaging_description, sp.quantity,
+		       sp.inner_packaging_id, sp.inner_quantity,
 		       i.item_id, i.item_description,
 		       sp.created_by, sp.created_at, sp.updated_at,
 			   cat.category_id, cat.category_description,
-			   uom.unit_id, uom.unit_description, i.is_fractionable
+			   uom.unit_id, uom.unit_description, i.is_fractionable,
+			   sp.archived_at, i.archived_at AS item_archived_at
 		FROM tb_item_packaging sp
 		JOIN tb_item i ON sp.item_id = i.item_id
 		JOIN tb_category cat ON i.category_id = cat.category_id
 		JOIN tb_unit_of_measure uom ON i.unit_id = uom.unit_id
 		WHERE sp.created_by = $1 AND sp.store_id = $2
-		ORDER BY sp.created_at DESC
-		OFFSET $3 LIMIT $4`
-
-	rows, err := conn.Query(context.Background(), query,
-		ownerID,
-		storeID,
-		offset,
-		limit,
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+		  AND ($3 OR sp.archived_at IS NULL)`
 
 	var results []model.ItemPackaging
-	for rows.Next() {
+	page, err := list_query.Fetch(conn, lq, query, []any{ownerID, storeID, includeArchived}, func(rows pgx.Rows, cursor *string) error {
 		var p model.ItemPackaging
 		err := rows.Scan(
 			&p.ID,
 			&p.Description,
 			&p.Quantity,
+			&p.InnerPackagingID,
+			&p.InnerQuantity,
 			&p.Item.ID,
 			&p.Item.Description,
 			&p.CreatedBy.ID,
@@ -117,37 +138,49 @@ func ListItemPackagingsPaginated(conn *pgxpool.Conn, ownerID, storeID, offset, l
 			&p.Item.UnitOfMeasure.ID,
 			&p.Item.UnitOfMeasure.Description,
 			&p.Item.IsFractionable,
+			&p.ArchivedAt,
+			&p.Item.ArchivedAt,
+			cursor,
 		)
 		if err != nil {
-			continue
+			return err
 		}
 		results = append(results, p)
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Error querying item packagings: %v", err)
+		return nil, nil, err
 	}
 
-	return results, nil
+	return results, page, nil
 }
 
-// GetItemPackagingByID retrieves a single packaging by ID
-func GetItemPackagingByID(conn *pgxpool.Conn, id uint) (*model.ItemPackaging, error) {
+// GetItemPackagingByID retrieves a single packaging of the store by ID
+func GetItemPackagingByID(conn *pgxpool.Conn, id, storeID uint) (*model.ItemPackaging, error) {
 	logger.Log.Infof("GetItemPackagingByID: %d", id)
 
 	query := `
 		SELECT sp.item_packaging_id, sp.item_packaging_description, sp.quantity,
+		       sp.inner_packaging_id, sp.inner_quantity,
 		       i.item_id, i.item_description,
 		       sp.created_by, sp.created_at, sp.updated_at,
 			   cat.category_id, cat.category_description,
-			   uom.unit_id, uom.unit_description, i.is_fractionable
+			   uom.unit_id, uom.unit_description, i.is_fractionable,
+			   sp.archived_at, i.archived_at
 		FROM tb_item_packaging sp
 		JOIN tb_item i ON sp.item_id = i.item_id
 		JOIN tb_category cat ON i.category_id = cat.category_id
 		JOIN tb_unit_of_measure uom ON i.unit_id = uom.unit_id
-		WHERE sp.item_packaging_id = $1`
+		WHERE sp.item_packaging_id = $1 AND sp.store_id = $2`
 
 	var p model.ItemPackaging
-	err := conn.QueryRow(context.Background(), query, id).Scan(
+	err := conn.QueryRow(context.Background(), query, id, storeID).Scan(
 		&p.ID,
 		&p.Description,
 		&p.Quantity,
+		&p.InnerPackagingID,
+		&p.InnerQuantity,
 		&p.Item.ID,
 		&p.Item.Description,
 		&p.CreatedBy.ID,
@@ -158,6 +191,8 @@ func GetItemPackagingByID(conn *pgxpool.Conn, id uint) (*model.ItemPackaging, er
 		&p.Item.UnitOfMeasure.ID,
 		&p.Item.UnitOfMeasure.Description,
 		&p.Item.IsFractionable,
+		&p.ArchivedAt,
+		&p.Item.ArchivedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -180,9 +215,11 @@ func UpdateItemPackaging(conn *pgxpool.Conn, p *model.ItemPackaging) (*model.Ite
 			SET item_id = $1,
 			    item_packaging_description = $2,
 			    quantity = $3,
+			    inner_packaging_id = $5,
+			    inner_quantity = $6,
 			    updated_at = NOW()
-			WHERE item_packaging_id = $4
-			RETURNING item_packaging_id, item_id, item_packaging_description, quantity, created_by, created_at, updated_at
+			WHERE item_packaging_id = $4 AND store_id = $7
+			RETURNING item_packaging_id, item_id, item_packaging_description, quantity, inner_packaging_id, inner_quantity, created_by, created_at, updated_at, archived_at
 		)
 		SELECT
 			u.item_packaging_id,
@@ -190,6 +227,8 @@ func UpdateItemPackaging(conn *pgxpool.Conn, p *model.ItemPackaging) (*model.Ite
 			it.item_description,
 			u.item_packaging_description,
 			u.quantity,
+			u.inner_packaging_id,
+			u.inner_quantity,
 			u.created_by,
 			u.created_at,
 			u.updated_at,
@@ -197,7 +236,8 @@ func UpdateItemPackaging(conn *pgxpool.Conn, p *model.ItemPackaging) (*model.Ite
 			cat.category_description,
 			uom.unit_id, 
 			uom.unit_description,
-			it.is_fractionable
+			it.is_fractionable,
+			u.archived_at
 		FROM updated u
 		JOIN tb_item it ON u.item_id = it.item_id
 		JOIN tb_category cat ON it.category_id = cat.category_id
@@ -210,6 +250,9 @@ func UpdateItemPackaging(conn *pgxpool.Conn, p *model.ItemPackaging) (*model.Ite
 		p.Description,
 		p.Quantity,
 		p.ID,
+		p.InnerPackagingID,
+		p.InnerQuantity,
+		p.Store.ID,
 	)
 
 	err := row.Scan(
@@ -218,6 +261,8 @@ func UpdateItemPackaging(conn *pgxpool.Conn, p *model.ItemPackaging) (*model.Ite
 		&updated.Item.Description,
 		&updated.Description,
 		&updated.Quantity,
+		&updated.InnerPackagingID,
+		&updated.InnerQuantity,
 		&updated.CreatedBy.ID,
 		&updated.CreatedAt,
 		&updated.UpdatedAt,
@@ -226,6 +271,7 @@ func UpdateItemPackaging(conn *pgxpool.Conn, p *model.ItemPackaging) (*model.Ite
 		&p.Item.UnitOfMeasure.ID,
 		&p.Item.UnitOfMeasure.Description,
 		&p.Item.IsFractionable,
+		&updated.ArchivedAt,
 	)
 
 	if err != nil {
@@ -237,12 +283,12 @@ func UpdateItemPackaging(conn *pgxpool.Conn, p *model.ItemPackaging) (*model.Ite
 	return updated, nil
 }
 
-// DeleteItemPackaging removes a packaging record
-func DeleteItemPackaging(conn *pgxpool.Conn, id uint) error {
+// DeleteItemPackaging removes a packaging record of the store
+func DeleteItemPackaging(conn *pgxpool.Conn, id, storeID uint) error {
 	logger.Log.Infof("DeleteItemPackaging: %d", id)
 
 	cmd, err := conn.Exec(context.Background(),
-		`DELETE FROM tb_item_packaging WHERE item_packaging_id = $1`, id)
+		`DELETE FROM tb_item_packaging WHERE item_packaging_id = $1 AND store_id = $2`, id, storeID)
 	if err != nil {
 		return err
 	}
@@ -251,3 +297,182 @@ func DeleteItemPackaging(conn *pgxpool.Conn, id uint) error {
 	}
 	return nil
 }
+
+// ArchiveItemPackaging stamps archived_at on a packaging so new stock documents
+// can no longer use it. Returns nil, nil when the packaging does not exist in
+// the store.
+func ArchiveItemPackaging(conn *pgxpool.Conn, id, storeID uint) (*model.ItemPackaging, error) {
+	logger.Log.Infof("ArchiveItemPackaging: %d", id)
+
+	cmd, err := conn.Exec(context.Background(),
+		`UPDATE tb_item_packaging SET archived_at = COALESCE(archived_at, NOW()) WHERE item_packaging_id = $1 AND store_id = $2`, id, storeID)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.RowsAffected() == 0 {
+		return nil, nil
+	}
+	return GetItemPackagingByID(conn, id, storeID)
+}
+
+// UnarchiveItemPackaging clears archived_at on a packaging.
+// Returns nil, nil when the packaging does not exist in the store.
+func UnarchiveItemPackaging(conn *pgxpool.Conn, id, storeID uint) (*model.ItemPackaging, error) {
+	logger.Log.Infof("UnarchiveItemPackaging: %d", id)
+
+	cmd, err := conn.Exec(context.Background(),
+		`UPDATE tb_item_packaging SET archived_at = NULL WHERE item_packaging_id = $1 AND store_id = $2`, id, storeID)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.RowsAffected() == 0 {
+		return nil, nil
+	}
+	return GetItemPackagingByID(conn, id, storeID)
+}
+
+// ListActiveItemPackagingsByItem returns the non-archived packagings of an item
+// in a store, largest first.
+func ListActiveItemPackagingsByItem(conn *pgxpool.Conn, itemID, storeID uint) ([]model.ItemPackaging, error) {
+	logger.Log.Infof("ListActiveItemPackagingsByItem: item=%d", itemID)
+
+	query := `
+		SELECT sp.item_packaging_id, sp.item_packaging_description, sp.quantity,
+		       sp.inner_packaging_id, sp.inner_quantity,
+		       i.item_id, i.item_description,
+		       sp.created_by, sp.created_at, sp.updated_at,
+			   cat.category_id, cat.category_description,
+			   uom.unit_id, uom.unit_description, i.is_fractionable,
+			   sp.archived_at, i.archived_at
+		FROM tb_item_packaging sp
+		JOIN tb_item i ON sp.item_id = i.item_id
+		JOIN tb_category cat ON i.category_id = cat.category_id
+		JOIN tb_unit_of_measure uom ON i.unit_id = uom.unit_id
+		WHERE sp.item_id = $1 AND sp.store_id = $2
+		  AND sp.archived_at IS NULL
+		ORDER BY sp.quantity DESC, sp.item_packaging_id`
+
+	rows, err := conn.Query(context.Background(), query, itemID, storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []model.ItemPackaging
+	for rows.Next() {
+		var p model.ItemPackaging
+		err := rows.Scan(
+			&p.ID,
+			&p.Description,
+			&p.Quantity,
+			&p.InnerPackagingID,
+			&p.InnerQuantity,
+			&p.Item.ID,
+			&p.Item.Description,
+			&p.CreatedBy.ID,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			&p.Item.Category.ID,
+			&p.Item.Category.Description,
+			&p.Item.UnitOfMeasure.ID,
+			&p.Item.UnitOfMeasure.Description,
+			&p.Item.IsFractionable,
+			&p.ArchivedAt,
+			&p.Item.ArchivedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, p)
+	}
+
+	return results, rows.Err()
+}
+
+// GetHeldPackagesByItem returns, per packaging of an item, how many packages
+// finalized stock-ins brought into the store minus those finalized stock-outs
+// took out.
+func GetHeldPackagesByItem(conn *pgxpool.Conn, itemID, storeID uint) (map[uint]int64, error) {
+	logger.Log.Infof("GetHeldPackagesByItem: item=%d", itemID)
+
+	query := `
+		SELECT ip.item_packaging_id,
+		       COALESCE((
+		           SELECT SUM(sip.quantity)
+		           FROM tb_stock_in_packaging sip
+		           JOIN tb_stock_in_item sii ON sii.stock_in_item_id = sip.stock_in_item_id
+		           JOIN tb_stock_in si ON si.stock_in_id = sii.stock_in_id
+		           WHERE sip.item_packaging_id = ip.item_packaging_id
+		             AND si.store_id = $2 AND si.status = 'finalized'
+		       ), 0)
+		       - COALESCE((
+		           SELECT SUM(sop.quantity)
+		           FROM tb_stock_out_packaging sop
+		           JOIN tb_stock_out_item soi ON soi.stock_out_item_id = sop.stock_out_item_id
+		           JOIN tb_stock_out so ON so.stock_out_id = soi.stock_out_id
+		           WHERE sop.item_packaging_id = ip.item_packaging_id
+		             AND so.store_id = $2 AND so.status = 'finalized'
+		       ), 0) AS held
+		FROM tb_item_packaging ip
+		WHERE ip.item_id = $1 AND ip.store_id = $2`
+
+	rows, err := conn.Query(context.Background(), query, itemID, storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	held := make(map[uint]int64)
+	for rows.Next() {
+		var id uint
+		var n int64
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		held[id] = n
+	}
+
+	return held, rows.Err()
+}
+
+// GetItemPackagingsByIDs loads the given packagings keyed by ID, with
+// BaseQuantity set so callers can reconcile document totals the way the
+// finalize triggers do.
+func GetItemPackagingsByIDs(conn *pgxpool.Conn, ids []uint) (map[uint]model.ItemPackaging, error) {
+	logger.Log.Infof("GetItemPackagingsByIDs: %v", ids)
+
+	query := `
+		SELECT ip.item_packaging_id, ip.item_packaging_description,
+		       ip.quantity, fn_item_packaging_base_quantity(ip.item_packaging_id),
+		       ip.inner_packaging_id, ip.inner_quantity,
+		       ip.item_id, ip.archived_at
+		FROM tb_item_packaging ip
+		WHERE ip.item_packaging_id = ANY($1)`
+
+	rows, err := conn.Query(context.Background(), query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	packagings := make(map[uint]model.ItemPackaging, len(ids))
+	for rows.Next() {
+		var p model.ItemPackaging
+		err := rows.Scan(
+			&p.ID,
+			&p.Description,
+			&p.Quantity,
+			&p.BaseQuantity,
+			&p.InnerPackagingID,
+			&p.InnerQuantity,
+			&p.Item.ID,
+			&p.ArchivedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		packagings[p.ID] = p
+	}
+
+	return packagings, rows.Err()
+}
@@ -4,17 +4,27 @@ package item_repository
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/item_attribute_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// SaveItem inserts a new item into the tb_item table
+// SaveItem inserts a new item into the tb_item table along with its custom attribute values
 func SaveItem(conn *pgxpool.Conn, item *model.Item) error {
 	logger.Log.Info("SaveItem")
 
+	tx, err := conn.Begin(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
 	query := `
 		WITH inserted AS (
   			INSERT INTO tb_item (item_description, ean13, category_id, unit_id, created_by, is_fractionable, store_id)
@@ -33,7 +43,7 @@ func SaveItem(conn *pgxpool.Conn, item *model.Item) error {
 			JOIN tb_unit_of_measure u ON i.unit_id = u.unit_id;	
 	`
 
-	err := conn.QueryRow(context.Background(), query,
+	err = tx.QueryRow(context.Background(), query,
 		item.Description,
 		item.EAN13,
 		item.Category.ID,
@@ -55,29 +65,39 @@ func SaveItem(conn *pgxpool.Conn, item *model.Item) error {
 		return err
 	}
 
+	if err := item_attribute_repository.ReplaceItemAttributeValues(tx, item.ID, item.Attributes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		logger.Log.Errorf("Transaction commit failed: %v", err)
+		return err
+	}
+
 	logger.Log.Info("Item successfully created")
 	return nil
 }
 
-// GetItemByID retrieves an item from the tb_item table by ID
-func GetItemByID(conn *pgxpool.Conn, id uint) (*model.Item, error) {
+// GetItemByID retrieves an item of the store from the tb_item table by ID
+func GetItemByID(conn *pgxpool.Conn, id, storeID uint) (*model.Item, error) {
 	logger.Log.Info("GetItemByID")
 
 	query := `
 		SELECT i.item_id, i.item_description, i.ean13, 
 			c.category_description, c.category_id,
 			unt.unit_description, unt.unit_id,
-		    usr.user_id, i.created_at, i.updated_at, i.is_fractionable
+		    usr.user_id, i.created_at, i.updated_at, i.is_fractionable,
+		    i.archived_at, c.archived_at, unt.archived_at
 		FROM tb_item i
 		JOIN tb_user usr ON i.created_by = usr.user_id
 		JOIN tb_category c ON i.category_id = c.category_id
 		JOIN tb_unit_of_measure unt ON i.unit_id = unt.unit_id
-		WHERE i.item_id = $1`
+		WHERE i.item_id = $1 AND i.store_id = $2`
 
 	item := &model.Item{}
 	owner := model.User{}
 
-	err := conn.QueryRow(context.Background(), query, id).Scan(
+	err := conn.QueryRow(context.Background(), query, id, storeID).Scan(
 		&item.ID,
 		&item.Description,
 		&item.EAN13,
@@ -89,6 +109,9 @@ func GetItemByID(conn *pgxpool.Conn, id uint) (*model.Item, error) {
 		&item.CreatedAt,
 		&item.UpdatedAt,
 		&item.IsFractionable,
+		&item.ArchivedAt,
+		&item.Category.ArchivedAt,
+		&item.UnitOfMeasure.ArchivedAt,
 	)
 
 	if err != nil {
@@ -101,13 +124,30 @@ func GetItemByID(conn *pgxpool.Conn, id uint) (*model.Item, error) {
 	}
 
 	item.CreatedBy = owner
+
+	attributes, err := item_attribute_repository.GetItemAttributeValues(conn, []int{int(item.ID)})
+	if err != nil {
+		logger.Log.Errorf("Error fetching item attributes: %v", err)
+		return nil, err
+	}
+	item.Attributes = attributes[item.ID]
+
 	logger.Log.Info("Item successfully retrieved")
 	return item, nil
 }
 
+// UpdateItem updates an item. Its attribute values are replaced only when
+// item.Attributes is non-nil.
 func UpdateItem(conn *pgxpool.Conn, item *model.Item) (*model.Item, error) {
 	logger.Log.Info("UpdateItem")
 
+	tx, err := conn.Begin(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Failed to begin transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
 	query := `
 		WITH updated AS (
 			UPDATE tb_item
@@ -116,8 +156,8 @@ func UpdateItem(conn *pgxpool.Conn, item *model.Item) (*model.Item, error) {
 				category_id      = $3,
 				unit_id          = $4,
 				is_fractionable  = $5
-			WHERE item_id = $6
-			RETURNING item_id, item_description, ean13, category_id, unit_id, created_by, created_at, updated_at, is_fractionable
+			WHERE item_id = $6 AND store_id = $7
+			RETURNING item_id, item_description, ean13, category_id, unit_id, created_by, created_at, updated_at, is_fractionable, archived_at
 		)
 		SELECT 
 			u.item_id,
@@ -130,23 +170,25 @@ func UpdateItem(conn *pgxpool.Conn, item *model.Item) (*model.Item, error) {
 			c.category_description,
 			u.unit_id,
 			um.unit_description,
-			u.created_by
+			u.created_by,
+			u.archived_at
 		FROM updated u
 		JOIN tb_category c ON u.category_id = c.category_id
 		JOIN tb_unit_of_measure um ON u.unit_id = um.unit_id;
 	`
 
 	updated := &model.Item{}
-	row := conn.QueryRow(context.Background(), query,
+	row := tx.QueryRow(context.Background(), query,
 		item.Description,
 		item.EAN13,
 		item.Category.ID,
 		item.UnitOfMeasure.ID,
 		item.IsFractionable,
 		item.ID,
+		item.Store.ID,
 	)
 
-	err := row.Scan(
+	err = row.Scan(
 		&updated.ID,
 		&updated.Description,
 		&updated.EAN13,
@@ -158,22 +200,41 @@ func UpdateItem(conn *pgxpool.Conn, item *model.Item) (*model.Item, error) {
 		&updated.UnitOfMeasure.ID,
 		&updated.UnitOfMeasure.Description,
 		&updated.CreatedBy.ID,
+		&updated.ArchivedAt,
 	)
 	if err != nil {
 		logger.Log.Errorf("Error scanning updated item: %v", err)
 		return nil, err
 	}
 
+	if item.Attributes != nil {
+		if err := item_attribute_repository.ReplaceItemAttributeValues(tx, updated.ID, item.Attributes); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		logger.Log.Errorf("Transaction commit failed: %v", err)
+		return nil, err
+	}
+
+	attributes, err := item_attribute_repository.GetItemAttributeValues(conn, []int{int(updated.ID)})
+	if err != nil {
+		logger.Log.Errorf("Error fetching item attributes: %v", err)
+		return nil, err
+	}
+	updated.Attributes = attributes[updated.ID]
+
 	logger.Log.Info("Item successfully updated with category and unit info")
 	return updated, nil
 }
 
-// DeleteItem deletes an item from the tb_item table by ID
-func DeleteItem(conn *pgxpool.Conn, id uint) error {
+// DeleteItem deletes an item of the store from the tb_item table by ID
+func DeleteItem(conn *pgxpool.Conn, id, storeID uint) error {
 	logger.Log.Info("DeleteItem")
 
-	query := `DELETE FROM tb_item WHERE item_id = $1`
-	cmdTag, err := conn.Exec(context.Background(), query, id)
+	query := `DELETE FROM tb_item WHERE item_id = $1 AND store_id = $2`
+	cmdTag, err := conn.Exec(context.Background(), query, id, storeID)
 	if err != nil {
 		logger.Log.Errorf("Error deleting item: %v", err)
 		return err
@@ -186,34 +247,132 @@ func DeleteItem(conn *pgxpool.Conn, id uint) error {
 	return nil
 }
 
-// ListItems returns all items with basic user info
-func ListItems(conn *pgxpool.Conn, OwnerID, StoreID uint) ([]model.Item, error) {
+// ArchiveItem stamps archived_at on an item, hiding it from listings and
+// preventing new documents from referencing it. Archiving twice keeps the
+// original timestamp. Returns nil, nil when the item does not exist in the
+// store.
+func ArchiveItem(conn *pgxpool.Conn, id, storeID uint) (*model.Item, error) {
+	logger.Log.Info("ArchiveItem")
+
+	query := `UPDATE tb_item SET archived_at = COALESCE(archived_at, NOW()) WHERE item_id = $1 AND store_id = $2`
+	cmdTag, err := conn.Exec(context.Background(), query, id, storeID)
+	if err != nil {
+		logger.Log.Errorf("Error archiving item: %v", err)
+		return nil, err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		logger.Log.Infof("No item found for archive with id: %d", id)
+		return nil, nil
+	}
+
+	logger.Log.Info("Item successfully archived")
+	return GetItemByID(conn, id, storeID)
+}
+
+// UnarchiveItem clears archived_at, bringing the item back to listings.
+// Returns nil, nil when the item does not exist in the store.
+func UnarchiveItem(conn *pgxpool.Conn, id, storeID uint) (*model.Item, error) {
+	logger.Log.Info("UnarchiveItem")
+
+	query := `UPDATE tb_item SET archived_at = NULL WHERE item_id = $1 AND store_id = $2`
+	cmdTag, err := conn.Exec(context.Background(), query, id, storeID)
+	if err != nil {
+		logger.Log.Errorf("Error unarchiving item: %v", err)
+		return nil, err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		logger.Log.Infof("No item found for unarchive with id: %d", id)
+		return nil, nil
+	}
+
+	logger.Log.Info("Item successfully unarchived")
+	return GetItemByID(conn, id, storeID)
+}
+
+// ItemListSpec lists the fields ListItems can filter and sort by. relevance
+// is only meaningful when searching.
+var ItemListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"category_id":     {Column: "category_id", Type: list_query.FieldInt},
+		"unit_id":         {Column: "unit_id", Type: list_query.FieldInt},
+		"ean13":           {Column: "ean13", Type: list_query.FieldText},
+		"is_fractionable": {Column: "is_fractionable", Type: list_query.FieldBool},
+		"created_at":      {Column: "created_at", Type: list_query.FieldTime},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":          {Column: "item_id", Type: list_query.FieldInt},
+		"description": {Column: "item_description", Type: list_query.FieldText},
+		"created_at":  {Column: "created_at", Type: list_query.FieldTime},
+		"updated_at":  {Column: "updated_at", Type: list_query.FieldTime},
+		"relevance":   {Column: "search_rank", Type: list_query.FieldNumber},
+	},
+	DefaultSort: "description",
+	Key:         list_query.Field{Column: "item_id", Type: list_query.FieldInt},
+}
+
+// ItemSearchListSpec is ItemListSpec with best search matches first
+var ItemSearchListSpec = func() *list_query.Spec {
+	spec := *ItemListSpec
+	spec.DefaultSort = "-relevance,description"
+	return &spec
+}()
+
+// ListItems returns a page of items with basic user info and custom
+// attributes. Archived items are left out unless includeArchived is set;
+// filters narrow the result down by attribute values. A non-empty search
+// keeps only items whose description, EAN or category match it and fills
+// the relevance sort field.
+func ListItems(conn *pgxpool.Conn, OwnerID, StoreID uint, includeArchived bool, filters []model.ItemAttributeFilter, search string, lq *list_query.Query) ([]model.Item, *list_query.PageInfo, error) {
 	logger.Log.Info("ListItems")
 
-	query := `
+	search = strings.TrimSpace(search)
+	args := []any{OwnerID, StoreID, includeArchived}
+
+	rank := "0::numeric"
+	var searchSQL string
+	if search != "" {
+		// Accent-insensitive and typo-tolerant: trigram word similarity on the
+		// normalized description and category, prefix match on the EAN.
+		// Exact EAN hits rank first, then description over category matches.
+		q := fmt.Sprintf("$%d::text", len(args)+1)
+		rank = fmt.Sprintf(`GREATEST(
+				CASE WHEN rtrim(i.ean13) = %[1]s THEN 2 WHEN i.ean13 LIKE %[1]s || '%%' THEN 1 ELSE 0 END,
+				word_similarity(fn_search_normalize(%[1]s), fn_search_normalize(i.item_description)),
+				0.8 * word_similarity(fn_search_normalize(%[1]s), fn_search_normalize(c.category_description))
+			)::numeric`, q)
+		searchSQL = fmt.Sprintf(`
+		  AND (
+		    fn_search_normalize(%[1]s) <%% fn_search_normalize(i.item_description)
+		    OR fn_search_normalize(i.item_description) LIKE '%%' || fn_search_normalize(%[1]s) || '%%'
+		    OR fn_search_normalize(%[1]s) <%% fn_search_normalize(c.category_description)
+		    OR i.ean13 LIKE %[1]s || '%%'
+		  )`, q)
+		args = append(args, search)
+	}
+
+	query := fmt.Sprintf(`
 		SELECT i.item_id, i.item_description, i.ean13, i.is_fractionable,
 		c.category_description, c.category_id, 
 		unt.unit_description, unt.unit_id,
 		u.user_id,
-		i.created_at, i.updated_at
+		i.created_at, i.updated_at, i.archived_at,
+		%s AS search_rank
 		FROM tb_item i
 		JOIN tb_user u ON i.created_by = u.user_id
 		JOIN tb_category c ON i.category_id = c.category_id
 		JOIN tb_unit_of_measure unt ON i.unit_id = unt.unit_id
-		WHERE i.created_by = $1 AND i.store_id = $2`
+		WHERE i.created_by = $1 AND i.store_id = $2
+		  AND ($3 OR i.archived_at IS NULL)%s`, rank, searchSQL)
 
-	rows, err := conn.Query(context.Background(), query, OwnerID, StoreID)
-	if err != nil {
-		logger.Log.Errorf("Error querying items: %v", err)
-		return nil, err
-	}
-	defer rows.Close()
+	filterSQL, filterArgs := item_attribute_repository.FilterSQL(filters, "i.item_id", len(args)+1)
+	query += filterSQL
+	args = append(args, filterArgs...)
 
 	var items []model.Item
-
-	for rows.Next() {
+	page, err := list_query.Fetch(conn, lq, query, args, func(rows pgx.Rows, cursor *string) error {
 		var item model.Item
 		var owner model.User
+		var rank float64
 
 		err := rows.Scan(
 			&item.ID,
@@ -227,17 +386,37 @@ func ListItems(conn *pgxpool.Conn, OwnerID, StoreID uint) ([]model.Item, error)
 			&owner.ID,
 			&item.CreatedAt,
 			&item.UpdatedAt,
+			&item.ArchivedAt,
+			&rank,
+			cursor,
 		)
 		if err != nil {
-			logger.Log.Errorf("Error scanning item row: %v", err)
-			continue
+			return err
 		}
 		item.CreatedBy = owner
 		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Error querying items: %v", err)
+		return nil, nil, err
+	}
+
+	ids := make([]int, len(items))
+	for i, it := range items {
+		ids[i] = int(it.ID)
+	}
+	attributes, err := item_attribute_repository.GetItemAttributeValues(conn, ids)
+	if err != nil {
+		logger.Log.Errorf("Error fetching item attributes: %v", err)
+		return nil, nil, err
+	}
+	for i := range items {
+		items[i].Attributes = attributes[items[i].ID]
 	}
 
 	logger.Log.Infof("Retrieved %d items", len(items))
-	return items, nil
+	return items, page, nil
 }
 
 func GetReferencingItemPackagings(conn *pgxpool.Conn, id uint) (any, error) {
@@ -0,0 +1,86 @@
+package login_attempt_repository
+
+import (
+	"context"
+	"time"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+)
+
+// ThrottleWindow is how far back failed logins are counted. Older attempts
+// are of no use and get deleted.
+const ThrottleWindow = 15 * time.Minute
+
+// SaveLoginAttempt records a password login attempt.
+func SaveLoginAttempt(email, ipAddress string, succeeded bool) error {
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(context.Background(), `
+		INSERT INTO public.tb_login_attempt (email, ip_address, succeeded)
+		VALUES ($1, $2, $3)`, email, ipAddress, succeeded)
+	if err != nil {
+		logger.Log.Errorf("Error saving login attempt: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// CountFailedLoginAttempts counts failed attempts since the given time for
+// the email and for the IP address. A successful login resets the email count.
+func CountFailedLoginAttempts(email, ipAddress string, since time.Time) (byEmail, byIP int, err error) {
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return 0, 0, err
+	}
+	defer conn.Release()
+
+	err = conn.QueryRow(context.Background(), `
+		WITH last_success AS (
+			SELECT COALESCE(MAX(created_at), '-infinity') AS at
+			FROM public.tb_login_attempt
+			WHERE lower(email) = lower($1) AND succeeded
+		)
+		SELECT
+			COUNT(*) FILTER (WHERE lower(a.email) = lower($1) AND a.created_at > ls.at),
+			COUNT(*) FILTER (WHERE a.ip_address = $2)
+		FROM public.tb_login_attempt a, last_success ls
+		WHERE NOT a.succeeded
+		  AND a.created_at > $3
+		  AND (lower(a.email) = lower($1) OR a.ip_address = $2)`,
+		email, ipAddress, since).Scan(&byEmail, &byIP)
+	if err != nil {
+		logger.Log.Errorf("Error counting login attempts: %v", err)
+		return 0, 0, err
+	}
+
+	return byEmail, byIP, nil
+}
+
+// DeleteLoginAttemptsBefore deletes the attempts made before the given time
+// and returns how many it deleted.
+func DeleteLoginAttemptsBefore(before time.Time) (int64, error) {
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return 0, err
+	}
+	defer conn.Release()
+
+	tag, err := conn.Exec(context.Background(),
+		`DELETE FROM public.tb_login_attempt WHERE created_at < $1`, before)
+	if err != nil {
+		logger.Log.Errorf("Error deleting login attempts: %v", err)
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,146 @@
+package oauth_repository
+
+import (
+	"context"
+	"time"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// SaveOAuthState stores a login in flight under the hash of its state, and
+// drops the logins that were abandoned.
+func SaveOAuthState(stateHash string, state *model.OAuthState) error {
+	logger.Log.Infof("SaveOAuthState provider: %s", state.Provider)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(context.Background(), `
+		DELETE FROM public.tb_oauth_state WHERE expires_at < NOW()`)
+	if err != nil {
+		logger.Log.Errorf("Error deleting expired OAuth states: %v", err)
+		return err
+	}
+
+	_, err = conn.Exec(context.Background(), `
+		INSERT INTO public.tb_oauth_state (
+			state_hash, provider, nonce, code_verifier, is_try_out, invitation_token, expires_at
+		)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)`,
+		stateHash,
+		state.Provider,
+		state.Nonce,
+		state.CodeVerifier,
+		state.IsTryOut,
+		state.InvitationToken,
+		state.ExpiresAt,
+	)
+	if err != nil {
+		logger.Log.Errorf("Error inserting OAuth state: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// ConsumeOAuthState deletes and returns the unexpired login of a state, so
+// every state works once. It returns nil, nil when there is none.
+func ConsumeOAuthState(stateHash string) (*model.OAuthState, error) {
+	logger.Log.Info("ConsumeOAuthState")
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	state := &model.OAuthState{}
+	err = conn.QueryRow(context.Background(), `
+		DELETE FROM public.tb_oauth_state
+		WHERE state_hash = $1 AND expires_at > NOW()
+		RETURNING provider, nonce, code_verifier, is_try_out, COALESCE(invitation_token, ''), expires_at`,
+		stateHash,
+	).Scan(
+		&state.Provider,
+		&state.Nonce,
+		&state.CodeVerifier,
+		&state.IsTryOut,
+		&state.InvitationToken,
+		&state.ExpiresAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logger.Log.Errorf("Error consuming OAuth state: %v", err)
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// SaveLoginCode stores the hash of a login code for the user, and drops the
+// codes that were never exchanged.
+func SaveLoginCode(codeHash string, userID uint, tryoutUUID string, expiresAt time.Time) error {
+	logger.Log.Infof("SaveLoginCode user id: %d", userID)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(context.Background(), `
+		DELETE FROM public.tb_oauth_login_code WHERE expires_at < NOW()`)
+	if err != nil {
+		logger.Log.Errorf("Error deleting expired login codes: %v", err)
+		return err
+	}
+
+	_, err = conn.Exec(context.Background(), `
+		INSERT INTO public.tb_oauth_login_code (code_hash, user_id, tryout_uuid, expires_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4)`, codeHash, userID, tryoutUUID, expiresAt)
+	if err != nil {
+		logger.Log.Errorf("Error inserting login code: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// ConsumeLoginCode deletes an unexpired login code and returns its user and
+// try-out job. ok is false when no such code exists, so every code works once.
+func ConsumeLoginCode(codeHash string) (userID uint, tryoutUUID string, ok bool, err error) {
+	logger.Log.Info("ConsumeLoginCode")
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return 0, "", false, err
+	}
+	defer conn.Release()
+
+	err = conn.QueryRow(context.Background(), `
+		DELETE FROM public.tb_oauth_login_code
+		WHERE code_hash = $1 AND expires_at > NOW()
+		RETURNING user_id, COALESCE(tryout_uuid, '')`, codeHash).Scan(&userID, &tryoutUUID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, "", false, nil
+		}
+		logger.Log.Errorf("Error consuming login code: %v", err)
+		return 0, "", false, err
+	}
+
+	return userID, tryoutUUID, true, nil
+}
@@ -2,15 +2,38 @@ package organization_repository
 
 import (
 	"context"
+	"time"
 
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 
 	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// OrganizationListSpec lists the fields ListOrganizationsWithUsage can
+// filter and sort by
+var OrganizationListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"is_active":  {Column: "is_active", Type: list_query.FieldBool},
+		"is_try_out": {Column: "is_try_out", Type: list_query.FieldBool},
+		"expires_at": {Column: "expires_at", Type: list_query.FieldTime},
+		"domain":     {Column: "domain", Type: list_query.FieldText},
+		"user_count": {Column: "user_count", Type: list_query.FieldInt},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":         {Column: "organization_id", Type: list_query.FieldInt},
+		"name":       {Column: "organization_name", Type: list_query.FieldText},
+		"user_count": {Column: "user_count", Type: list_query.FieldInt},
+	},
+	DefaultSort: "id",
+	Key:         list_query.Field{Column: "organization_id", Type: list_query.FieldInt},
+}
+
 func InsertOrganizationTx(ctx context.Context, tx pgx.Tx, org *model.Organization) error {
 	query := `INSERT INTO public.tb_organization (organization_name, organization_key, domain, schema_name, expires_at, is_try_out) VALUES ($1, $2, $3, $4, $5, $6) RETURNING organization_id`
 	return tx.QueryRow(ctx, query, org.Name, org.Key, org.Domain, org.DBSchema, org.ExpiresAt, org.IsTryOut).Scan(&org.ID)
@@ -72,3 +95,247 @@ func ListOrganizations() ([]model.Organization, error) {
 
 	return orgs, nil
 }
+
+// SetRequireTwoFactor sets whether the password logins of the
+// organization's users need a second factor, recording actorID as the user
+// who changed it.
+func SetRequireTwoFactor(organizationID uint, required bool, actorID uint) error {
+	logger.Log.Infof("SetRequireTwoFactor organization id: %d required: %t", organizationID, required)
+
+	_, err := updateOrganization(actorID, `
+		UPDATE public.tb_organization
+		SET require_two_factor = $2
+		WHERE organization_id = $1`, organizationID, required)
+	if err != nil {
+		logger.Log.Errorf("Error updating organization two-factor requirement: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// updateOrganization runs an update of organization rows in a transaction
+// that records actorID as the user making it, for the audit log.
+func updateOrganization(actorID uint, query string, args ...any) (pgconn.CommandTag, error) {
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return pgconn.CommandTag{}, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := db.SetAuditActor(ctx, tx, actorID); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+
+	return tag, tx.Commit(ctx)
+}
+
+// ListOrganizationsWithUsage returns a page of all organizations with their
+// usage figures.
+func ListOrganizationsWithUsage(lq *list_query.Query) ([]*model.OrganizationUsage, *list_query.PageInfo, error) {
+	logger.Log.Info("ListOrganizationsWithUsage")
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return nil, nil, err
+	}
+	defer conn.Release()
+
+	query := `
+		SELECT org.organization_id, org.organization_name, org.organization_key, org.domain,
+		       org.schema_name, org.is_try_out, org.expires_at,
+		       COALESCE(org.is_active, FALSE) AS is_active, org.require_two_factor,
+		       COUNT(us.user_id) AS user_count,
+		       COUNT(us.user_id) FILTER (WHERE us.is_active) AS active_user_count,
+		       MAX(us.last_login) AS last_login_at,
+		       (SELECT COUNT(*)
+		        FROM public.tb_auth_session s
+		        JOIN public.tb_user su ON su.user_id = s.user_id
+		        WHERE su.organization_id = org.organization_id
+		          AND s.revoked_at IS NULL AND s.expires_at > NOW()) AS active_session_count,
+		       (SELECT COUNT(*)
+		        FROM public.tb_api_key k
+		        WHERE k.organization_id = org.organization_id
+		          AND k.revoked_at IS NULL
+		          AND (k.expires_at IS NULL OR k.expires_at > NOW())) AS api_key_count
+		FROM public.tb_organization org
+		LEFT JOIN public.tb_user us ON us.organization_id = org.organization_id
+		GROUP BY org.organization_id
+	`
+
+	orgs := []*model.OrganizationUsage{}
+	page, err := list_query.Fetch(conn, lq, query, nil, func(rows pgx.Rows, cursor *string) error {
+		var o model.OrganizationUsage
+		err := rows.Scan(
+			&o.ID,
+			&o.Name,
+			&o.Key,
+			&o.Domain,
+			&o.DBSchema,
+			&o.IsTryOut,
+			&o.ExpiresAt,
+			&o.IsActive,
+			&o.RequireTwoFactor,
+			&o.UserCount,
+			&o.ActiveUserCount,
+			&o.LastLoginAt,
+			&o.ActiveSessionCount,
+			&o.APIKeyCount,
+			cursor,
+		)
+		if err != nil {
+			return err
+		}
+		orgs = append(orgs, &o)
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Error querying organizations: %v", err)
+		return nil, nil, err
+	}
+
+	for _, o := range orgs {
+		loadTenantUsage(conn, o)
+	}
+
+	return orgs, page, nil
+}
+
+// loadTenantUsage counts the data in the organization's schema. A schema
+// that cannot be read leaves the counts nil rather than failing the list.
+func loadTenantUsage(conn *pgxpool.Conn, o *model.OrganizationUsage) {
+	table := func(name string) string {
+		return pgx.Identifier{o.DBSchema, name}.Sanitize()
+	}
+
+	query := `
+		SELECT (SELECT COUNT(*) FROM ` + table("tb_store") + `),
+		       (SELECT COUNT(*) FROM ` + table("tb_item") + `),
+		       (SELECT COUNT(*) FROM ` + table("tb_stock_in") + `),
+		       (SELECT COUNT(*) FROM ` + table("tb_stock_out") + `)`
+
+	var stores, items, stockIns, stockOuts int
+	err := conn.QueryRow(context.Background(), query).Scan(&stores, &items, &stockIns, &stockOuts)
+	if err != nil {
+		logger.Log.Warnf("Error counting data of schema %s: %v", o.DBSchema, err)
+		return
+	}
+
+	o.StoreCount = &stores
+	o.ItemCount = &items
+	o.StockInCount = &stockIns
+	o.StockOutCount = &stockOuts
+}
+
+// GetOrganizationByID returns an organization. It returns nil, nil when the
+// organization does not exist.
+func GetOrganizationByID(organizationID uint) (*model.Organization, error) {
+	logger.Log.Infof("GetOrganizationByID organization id: %d", organizationID)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	org := &model.Organization{}
+	err = conn.QueryRow(context.Background(), `
+		SELECT organization_id, organization_name, organization_key, domain, schema_name,
+		       is_try_out, expires_at, COALESCE(is_active, FALSE), require_two_factor
+		FROM public.tb_organization
+		WHERE organization_id = $1`, organizationID).Scan(
+		&org.ID,
+		&org.Name,
+		&org.Key,
+		&org.Domain,
+		&org.DBSchema,
+		&org.IsTryOut,
+		&org.ExpiresAt,
+		&org.IsActive,
+		&org.RequireTwoFactor,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logger.Log.Errorf("Error fetching organization: %v", err)
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// SetOrganizationActive deactivates or reactivates an organization,
+// recording actorID as the user who did it. Deactivating it revokes the
+// sessions of its users. ok is false when the organization does not exist.
+func SetOrganizationActive(organizationID uint, active bool, actorID uint) (ok bool, err error) {
+	logger.Log.Infof("SetOrganizationActive organization id: %d active: %t", organizationID, active)
+
+	tag, err := updateOrganization(actorID, `
+		UPDATE public.tb_organization
+		SET is_active = $2
+		WHERE organization_id = $1`, organizationID, active)
+	if err != nil {
+		logger.Log.Errorf("Error updating organization status: %v", err)
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// ExtendTryOut moves the expiration of a try-out organization, recording
+// actorID as the user who did it. ok is false when the organization does not
+// exist or is not a try-out.
+func ExtendTryOut(organizationID uint, expiresAt time.Time, actorID uint) (ok bool, err error) {
+	logger.Log.Infof("ExtendTryOut organization id: %d expires at: %s", organizationID, expiresAt)
+
+	tag, err := updateOrganization(actorID, `
+		UPDATE public.tb_organization
+		SET expires_at = $2
+		WHERE organization_id = $1 AND is_try_out`, organizationID, expiresAt)
+	if err != nil {
+		logger.Log.Errorf("Error extending try-out: %v", err)
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// IsSchemaNameTaken reports whether a schema of that name exists or is
+// assigned to an organization.
+func IsSchemaNameTaken(schema string) (bool, error) {
+	logger.Log.Infof("IsSchemaNameTaken schema: %s", schema)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return false, err
+	}
+	defer conn.Release()
+
+	var taken bool
+	err = conn.QueryRow(context.Background(), `
+		SELECT EXISTS (SELECT 1 FROM pg_catalog.pg_namespace WHERE nspname = $1)
+		    OR EXISTS (SELECT 1 FROM public.tb_organization WHERE schema_name = $1)`, schema).Scan(&taken)
+	if err != nil {
+		logger.Log.Errorf("Error checking schema name: %v", err)
+		return false, err
+	}
+
+	return taken, nil
+}
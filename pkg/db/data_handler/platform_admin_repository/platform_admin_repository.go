@@ -0,0 +1,36 @@
+package platform_admin_repository
+
+import (
+	"context"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+)
+
+// IsPlatformAdmin reports whether the user operates the platform.
+func IsPlatformAdmin(userID uint) (bool, error) {
+	logger.Log.Infof("IsPlatformAdmin user id: %d", userID)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return false, err
+	}
+	defer conn.Release()
+
+	var isAdmin bool
+	err = conn.QueryRow(context.Background(), `
+		SELECT EXISTS (
+			SELECT 1
+			FROM public.tb_platform_admin pa
+			JOIN public.tb_user us ON us.user_id = pa.user_id
+			WHERE pa.user_id = $1 AND us.is_active
+		)`, userID).Scan(&isAdmin)
+	if err != nil {
+		logger.Log.Errorf("Error checking platform admin: %v", err)
+		return false, err
+	}
+
+	return isAdmin, nil
+}
@@ -0,0 +1,192 @@
+package role_repository
+
+import (
+	"context"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GetEffectiveRole returns the user's role in the store, falling back to the
+// organization-wide role. Without a store only the organization-wide role is
+// considered. ok is false when the user has no role at all.
+func GetEffectiveRole(conn *pgxpool.Conn, userID uint, storeID *uint) (role model.Role, ok bool, err error) {
+	logger.Log.Infof("GetEffectiveRole user id: %d", userID)
+
+	query := `
+		SELECT role
+		FROM tb_user_role
+		WHERE user_id = $1
+		  AND (store_id IS NULL OR store_id = $2)
+		ORDER BY store_id NULLS LAST
+		LIMIT 1`
+
+	err = conn.QueryRow(context.Background(), query, userID, storeID).Scan(&role)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", false, nil
+		}
+		logger.Log.Errorf("Error loading user role: %v", err)
+		return "", false, err
+	}
+
+	return role, true, nil
+}
+
+// ListRoleAssignments returns the role assignments of a store, or the
+// organization-wide ones when storeID is nil.
+func ListRoleAssignments(conn *pgxpool.Conn, storeID *uint) ([]model.RoleAssignment, error) {
+	logger.Log.Info("ListRoleAssignments")
+
+	query := `
+		SELECT r.user_role_id, r.store_id, r.role, r.assigned_by, r.created_at, r.updated_at,
+		       u.user_id, u.email, COALESCE(u.given_name, ''), COALESCE(u.family_name, '')
+		FROM tb_user_role r
+		JOIN public.tb_user u ON u.user_id = r.user_id
+		WHERE r.store_id IS NOT DISTINCT FROM $1
+		ORDER BY u.email`
+
+	rows, err := conn.Query(context.Background(), query, storeID)
+	if err != nil {
+		logger.Log.Errorf("Error querying role assignments: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments := []model.RoleAssignment{}
+	for rows.Next() {
+		var a model.RoleAssignment
+		err := rows.Scan(
+			&a.ID,
+			&a.StoreID,
+			&a.Role,
+			&a.AssignedBy,
+			&a.CreatedAt,
+			&a.UpdatedAt,
+			&a.User.ID,
+			&a.User.Email,
+			&a.User.GivenName,
+			&a.User.FamilyName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+
+	return assignments, rows.Err()
+}
+
+// ListUserRoles returns every role assignment of the user, organization-wide
+// first.
+func ListUserRoles(conn *pgxpool.Conn, userID uint) ([]model.RoleAssignment, error) {
+	logger.Log.Infof("ListUserRoles user id: %d", userID)
+
+	query := `
+		SELECT user_role_id, store_id, role, assigned_by, created_at, updated_at
+		FROM tb_user_role
+		WHERE user_id = $1
+		ORDER BY store_id NULLS FIRST`
+
+	rows, err := conn.Query(context.Background(), query, userID)
+	if err != nil {
+		logger.Log.Errorf("Error querying user roles: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments := []model.RoleAssignment{}
+	for rows.Next() {
+		a := model.RoleAssignment{User: model.User{ID: userID}}
+		err := rows.Scan(&a.ID, &a.StoreID, &a.Role, &a.AssignedBy, &a.CreatedAt, &a.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+
+	return assignments, rows.Err()
+}
+
+// SaveRoleAssignment creates or replaces the user's role in the store, or in
+// the organization when StoreID is nil. It returns false when the user does
+// not belong to the organization.
+func SaveRoleAssignment(conn *pgxpool.Conn, a *model.RoleAssignment, organizationID uint) (bool, error) {
+	logger.Log.Infof("SaveRoleAssignment user id: %d", a.User.ID)
+
+	// The partial unique indexes need their own conflict targets
+	conflict := `ON CONFLICT (user_id) WHERE store_id IS NULL`
+	if a.StoreID != nil {
+		conflict = `ON CONFLICT (user_id, store_id) WHERE store_id IS NOT NULL`
+	}
+
+	query := `
+		INSERT INTO tb_user_role (user_id, store_id, role, assigned_by)
+		SELECT u.user_id, $2::int, $3::text, $4::int
+		FROM public.tb_user u
+		WHERE u.user_id = $1 AND u.organization_id = $5
+		` + conflict + ` DO UPDATE SET
+			role = EXCLUDED.role,
+			assigned_by = EXCLUDED.assigned_by
+		RETURNING user_role_id, created_at, updated_at`
+
+	err := conn.QueryRow(context.Background(), query,
+		a.User.ID, a.StoreID, string(a.Role), a.AssignedBy, organizationID,
+	).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		logger.Log.Errorf("Error saving role assignment: %v", err)
+		return false, err
+	}
+
+	logger.Log.Info("Role assignment successfully saved")
+	return true, nil
+}
+
+// SaveRoleAssignmentTx creates or replaces a role assignment inside a
+// transaction whose search_path is already set to the organization's schema.
+func SaveRoleAssignmentTx(ctx context.Context, tx pgx.Tx, a *model.RoleAssignment) error {
+	conflict := `ON CONFLICT (user_id) WHERE store_id IS NULL`
+	if a.StoreID != nil {
+		conflict = `ON CONFLICT (user_id, store_id) WHERE store_id IS NOT NULL`
+	}
+
+	query := `
+		INSERT INTO tb_user_role (user_id, store_id, role, assigned_by)
+		VALUES ($1, $2, $3, $4)
+		` + conflict + ` DO UPDATE SET
+			role = EXCLUDED.role,
+			assigned_by = EXCLUDED.assigned_by
+		RETURNING user_role_id, created_at, updated_at`
+
+	err := tx.QueryRow(ctx, query,
+		a.User.ID, a.StoreID, string(a.Role), a.AssignedBy,
+	).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		logger.Log.Errorf("Error saving role assignment: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteRoleAssignment removes the user's role in the store, or in the
+// organization when storeID is nil. It returns false when there was none.
+func DeleteRoleAssignment(conn *pgxpool.Conn, userID uint, storeID *uint) (bool, error) {
+	logger.Log.Infof("DeleteRoleAssignment user id: %d", userID)
+
+	cmdTag, err := conn.Exec(context.Background(), `
+		DELETE FROM tb_user_role
+		WHERE user_id = $1 AND store_id IS NOT DISTINCT FROM $2`, userID, storeID)
+	if err != nil {
+		logger.Log.Errorf("Error deleting role assignment: %v", err)
+		return false, err
+	}
+
+	return cmdTag.RowsAffected() > 0, nil
+}
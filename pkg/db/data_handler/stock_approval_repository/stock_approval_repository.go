@@ -0,0 +1,270 @@
+package stock_approval_repository
+
+import (
+	"context"
+	"fmt"
+
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type documentTable struct {
+	table string
+	key   string
+}
+
+var documentTables = map[model.StockDocumentType]documentTable{
+	model.StockInDocument:    {table: "tb_stock_in", key: "stock_in_id"},
+	model.StockOutDocument:   {table: "tb_stock_out", key: "stock_out_id"},
+	model.StockWasteDocument: {table: "tb_stock_waste", key: "stock_waste_id"},
+}
+
+// SubmitStockDocument sends a draft for review. Drafts that were never
+// submitted or were rejected can be submitted.
+func SubmitStockDocument(conn *pgxpool.Conn, docType model.StockDocumentType, id int, storeID uint, comment *string, userID uint) (*model.ApprovalDecision, error) {
+	return transition(conn, docType, id, storeID, model.ApprovalSubmitted, "submit", comment, userID)
+}
+
+// ApproveStockDocument approves a submitted draft so it can be finalized.
+func ApproveStockDocument(conn *pgxpool.Conn, docType model.StockDocumentType, id int, storeID uint, comment *string, userID uint) (*model.ApprovalDecision, error) {
+	return transition(conn, docType, id, storeID, model.ApprovalApproved, "approve", comment, userID)
+}
+
+// RejectStockDocument sends a submitted draft back for changes.
+func RejectStockDocument(conn *pgxpool.Conn, docType model.StockDocumentType, id int, storeID uint, comment *string, userID uint) (*model.ApprovalDecision, error) {
+	return transition(conn, docType, id, storeID, model.ApprovalRejected, "reject", comment, userID)
+}
+
+// transition moves a draft to the given approval state and records the
+// decision. verb names the action in error messages. It returns nil, nil when
+// the document does not exist in the store.
+func transition(conn *pgxpool.Conn, docType model.StockDocumentType, id int, storeID uint, action, verb string, comment *string, userID uint) (*model.ApprovalDecision, error) {
+	logger.Log.Infof("StockDocumentApproval %s %s id=%d", action, docType, id)
+
+	doc, ok := documentTables[docType]
+	if !ok {
+		return nil, fmt.Errorf("unknown stock document type %q", docType)
+	}
+
+	tx, err := conn.Begin(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Failed to begin transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	// Lock the document so concurrent decisions are applied one at a time
+	var status string
+	var approvalStatus *string
+	var createdBy uint
+	err = tx.QueryRow(context.Background(), fmt.Sprintf(`
+		SELECT status, approval_status, created_by
+		FROM %s
+		WHERE %s = $1 AND store_id = $2
+		FOR UPDATE`, doc.table, doc.key), id, storeID).
+		Scan(&status, &approvalStatus, &createdBy)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logger.Log.Errorf("Error loading document approval state: %v", err)
+		return nil, err
+	}
+
+	if status != "draft" {
+		return nil, &errorCodes.InvalidApprovalTransition{Action: verb, Reason: fmt.Sprintf("document is %s", status)}
+	}
+
+	current := "not submitted"
+	if approvalStatus != nil {
+		current = *approvalStatus
+	}
+
+	switch action {
+	case model.ApprovalSubmitted:
+		if approvalStatus != nil && *approvalStatus != model.ApprovalRejected {
+			return nil, &errorCodes.InvalidApprovalTransition{Action: verb, Reason: fmt.Sprintf("document is already %s", current)}
+		}
+	case model.ApprovalApproved, model.ApprovalRejected:
+		if approvalStatus == nil || *approvalStatus != model.ApprovalSubmitted {
+			return nil, &errorCodes.InvalidApprovalTransition{Action: verb, Reason: fmt.Sprintf("document is %s", current)}
+		}
+	}
+
+	if action == model.ApprovalApproved {
+		policy, err := getApprovalPolicy(tx, storeID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !policy.AllowSelfApproval {
+			var submittedBy uint
+			err = tx.QueryRow(context.Background(), `
+				SELECT decided_by
+				FROM tb_stock_document_approval
+				WHERE document_type = $1 AND document_id = $2 AND action = 'submitted'
+				ORDER BY created_at DESC, approval_id DESC
+				LIMIT 1`, docType, id).Scan(&submittedBy)
+			if err != nil && err != pgx.ErrNoRows {
+				logger.Log.Errorf("Error loading document submitter: %v", err)
+				return nil, err
+			}
+			// The creator stays the author when someone else resubmits
+			if submittedBy == userID || createdBy == userID {
+				return nil, &errorCodes.InvalidApprovalTransition{Action: verb, Reason: "documents cannot be approved by the user who created or submitted them"}
+			}
+		}
+	}
+
+	_, err = tx.Exec(context.Background(), fmt.Sprintf(`
+		UPDATE %s
+		SET approval_status = $1
+		WHERE %s = $2`, doc.table, doc.key), action, id)
+	if err != nil {
+		logger.Log.Errorf("Error updating document approval status: %v", err)
+		return nil, err
+	}
+
+	decision := &model.ApprovalDecision{
+		DocumentType: docType,
+		DocumentID:   uint(id),
+		Action:       action,
+		Comment:      comment,
+		DecidedBy:    model.User{ID: userID},
+	}
+	err = tx.QueryRow(context.Background(), `
+		INSERT INTO tb_stock_document_approval (document_type, document_id, action, comment, decided_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING approval_id, created_at`,
+		docType, id, action, comment, userID).
+		Scan(&decision.ID, &decision.CreatedAt)
+	if err != nil {
+		logger.Log.Errorf("Error inserting approval decision: %v", err)
+		return nil, err
+	}
+
+	err = tx.Commit(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Transaction commit failed: %v", err)
+		return nil, err
+	}
+
+	logger.Log.Infof("Stock document %s", action)
+	return decision, nil
+}
+
+// ListDecisions returns the approval history of a document, oldest first.
+func ListDecisions(conn *pgxpool.Conn, docType model.StockDocumentType, id uint) ([]model.ApprovalDecision, error) {
+	logger.Log.Infof("ListDecisions %s id=%d", docType, id)
+
+	query := `
+		SELECT a.approval_id, a.action, a.comment, a.created_at,
+		       u.user_id, COALESCE(u.username, ''), COALESCE(u.email, '')
+		FROM tb_stock_document_approval a
+		JOIN public.tb_user u ON u.user_id = a.decided_by
+		WHERE a.document_type = $1 AND a.document_id = $2
+		ORDER BY a.created_at, a.approval_id`
+
+	rows, err := conn.Query(context.Background(), query, docType, id)
+	if err != nil {
+		logger.Log.Errorf("Error querying approval decisions: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	decisions := []model.ApprovalDecision{}
+	for rows.Next() {
+		d := model.ApprovalDecision{DocumentType: docType, DocumentID: id}
+		err := rows.Scan(
+			&d.ID,
+			&d.Action,
+			&d.Comment,
+			&d.CreatedAt,
+			&d.DecidedBy.ID,
+			&d.DecidedBy.Username,
+			&d.DecidedBy.Email,
+		)
+		if err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, d)
+	}
+
+	return decisions, rows.Err()
+}
+
+// rowQuerier is satisfied by both pool connections and transactions.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// GetApprovalPolicy returns the store's approval policy. Stores without one
+// get a disabled policy.
+func GetApprovalPolicy(conn *pgxpool.Conn, storeID uint) (*model.ApprovalPolicy, error) {
+	logger.Log.Infof("GetApprovalPolicy storeID=%d", storeID)
+	return getApprovalPolicy(conn, storeID)
+}
+
+func getApprovalPolicy(q rowQuerier, storeID uint) (*model.ApprovalPolicy, error) {
+	policy := &model.ApprovalPolicy{StoreID: storeID}
+
+	err := q.QueryRow(context.Background(), `
+		SELECT enabled, require_for_waste, require_for_stock_out,
+		       stock_in_value_threshold, allow_self_approval, updated_at
+		FROM tb_approval_policy
+		WHERE store_id = $1`, storeID).Scan(
+		&policy.Enabled,
+		&policy.RequireForWaste,
+		&policy.RequireForStockOut,
+		&policy.StockInValueThreshold,
+		&policy.AllowSelfApproval,
+		&policy.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return policy, nil
+		}
+		logger.Log.Errorf("Error loading approval policy: %v", err)
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// SaveApprovalPolicy creates or replaces the store's approval policy.
+func SaveApprovalPolicy(conn *pgxpool.Conn, policy *model.ApprovalPolicy) error {
+	logger.Log.Infof("SaveApprovalPolicy storeID=%d", policy.StoreID)
+
+	query := `
+		INSERT INTO tb_approval_policy (
+			store_id, enabled, require_for_waste, require_for_stock_out,
+			stock_in_value_threshold, allow_self_approval
+		)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (store_id) DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			require_for_waste = EXCLUDED.require_for_waste,
+			require_for_stock_out = EXCLUDED.require_for_stock_out,
+			stock_in_value_threshold = EXCLUDED.stock_in_value_threshold,
+			allow_self_approval = EXCLUDED.allow_self_approval
+		RETURNING updated_at`
+
+	err := conn.QueryRow(context.Background(), query,
+		policy.StoreID,
+		policy.Enabled,
+		policy.RequireForWaste,
+		policy.RequireForStockOut,
+		policy.StockInValueThreshold,
+		policy.AllowSelfApproval,
+	).Scan(&policy.UpdatedAt)
+	if err != nil {
+		logger.Log.Errorf("Error saving approval policy: %v", err)
+		return err
+	}
+
+	logger.Log.Info("Approval policy successfully saved")
+	return nil
+}
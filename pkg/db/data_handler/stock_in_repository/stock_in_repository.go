@@ -4,8 +4,13 @@ import (
 	"context"
 	"errors"
 
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_approval_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -36,8 +41,8 @@ func SaveStockIn(conn *pgxpool.Conn, stockIn *model.StockIn, ownerID, storeID ui
 
 	// Prepared statements for items and packagings
 	insertItem := `
-		INSERT INTO tb_stock_in_item (stock_in_id, item_id, buy_price, total_quantity)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO tb_stock_in_item (stock_in_id, item_id, buy_price, total_quantity, location_id)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING stock_in_item_id
 	`
 	insertPackaging := `
@@ -50,7 +55,7 @@ func SaveStockIn(conn *pgxpool.Conn, stockIn *model.StockIn, ownerID, storeID ui
 		item := &stockIn.Items[i]
 
 		err := tx.QueryRow(context.Background(), insertItem,
-			stockIn.ID, item.Item.ID, item.BuyPrice, item.TotalQuantity).
+			stockIn.ID, item.Item.ID, item.BuyPrice, item.TotalQuantity, locationID(item.Location)).
 			Scan(&item.ID)
 		if err != nil {
 			logger.Log.Errorf("Error inserting stock in item: %v", err)
@@ -77,25 +82,35 @@ func SaveStockIn(conn *pgxpool.Conn, stockIn *model.StockIn, ownerID, storeID ui
 	return nil
 }
 
-// ListAllStockIn returns all StockIn headers for a given owner (without items)
-func ListAllStockIn(conn *pgxpool.Conn, ownerID, storeID uint) ([]*model.StockIn, error) {
+// StockInListSpec lists the fields ListAllStockIn can filter and sort by
+var StockInListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"status":          {Column: "status", Type: list_query.FieldText},
+		"approval_status": {Column: "approval_status", Type: list_query.FieldText},
+		"created_at":      {Column: "created_at", Type: list_query.FieldTime},
+		"finalized_at":    {Column: "finalized_at", Type: list_query.FieldTime},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":         {Column: "stock_in_id", Type: list_query.FieldInt},
+		"created_at": {Column: "created_at", Type: list_query.FieldTime},
+		"updated_at": {Column: "updated_at", Type: list_query.FieldTime},
+	},
+	DefaultSort: "-created_at",
+	Key:         list_query.Field{Column: "stock_in_id", Type: list_query.FieldInt},
+}
+
+// ListAllStockIn returns a page of StockIn headers for a given owner (without items)
+func ListAllStockIn(conn *pgxpool.Conn, ownerID, storeID uint, lq *list_query.Query) ([]*model.StockIn, *list_query.PageInfo, error) {
 	logger.Log.Infof("ListAllStockIn storeID=%d", storeID)
 
 	query := `
-		SELECT stock_in_id, created_by, created_at, updated_at, status, finalized_at
+		SELECT stock_in_id, created_by, created_at, updated_at, status, approval_status, finalized_at
 		FROM tb_stock_in
 		WHERE created_by = $1 AND store_id = $2
-		ORDER BY created_at DESC
 	`
-	rows, err := conn.Query(context.Background(), query, ownerID, storeID)
-	if err != nil {
-		logger.Log.Errorf("Error querying stock_in list: %v", err)
-		return nil, err
-	}
-	defer rows.Close()
 
 	var stockIns []*model.StockIn
-	for rows.Next() {
+	page, err := list_query.Fetch(conn, lq, query, []any{ownerID, storeID}, func(rows pgx.Rows, cursor *string) error {
 		var s model.StockIn
 		err := rows.Scan(
 			&s.ID,
@@ -103,40 +118,48 @@ func ListAllStockIn(conn *pgxpool.Conn, ownerID, storeID uint) ([]*model.StockIn
 			&s.CreatedAt,
 			&s.UpdatedAt,
 			&s.Status,
+			&s.ApprovalStatus,
 			&s.FinalizedAt,
+			cursor,
 		)
 		if err != nil {
-			logger.Log.Errorf("Error scanning stock_in row: %v", err)
-			return nil, err
+			return err
 		}
 		// Initialize empty items slice
 		s.Items = []model.StockInItem{}
 		stockIns = append(stockIns, &s)
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Error querying stock_in list: %v", err)
+		return nil, nil, err
 	}
 
-	return stockIns, nil
+	return stockIns, page, nil
 }
 
-// GetStockInByID retrieves a StockIn with its items and packaging breakdowns
-func GetStockInByID(conn *pgxpool.Conn, id int) (*model.StockIn, error) {
+// GetStockInByID retrieves a StockIn of the store with its items and
+// packaging breakdowns
+func GetStockInByID(conn *pgxpool.Conn, id int, storeID uint) (*model.StockIn, error) {
 	logger.Log.Info("GetStockInByID")
 
 	// Load parent record
 	stockIn := &model.StockIn{}
 	parentQuery := `
-		SELECT stock_in_id, created_by, created_at, updated_at, status, finalized_at
+		SELECT stock_in_id, created_by, created_at, updated_at, status, approval_status, finalized_at
 		FROM tb_stock_in
-		WHERE stock_in_id = $1
+		WHERE stock_in_id = $1 AND store_id = $2
 	`
 
-	logger.Log.DebugSQL(parentQuery, id)
+	logger.Log.DebugSQL(parentQuery, id, storeID)
 
-	err := conn.QueryRow(context.Background(), parentQuery, id).Scan(
+	err := conn.QueryRow(context.Background(), parentQuery, id, storeID).Scan(
 		&stockIn.ID,
 		&stockIn.CreatedBy.ID,
 		&stockIn.CreatedAt,
 		&stockIn.UpdatedAt,
 		&stockIn.Status,
+		&stockIn.ApprovalStatus,
 		&stockIn.FinalizedAt,
 	)
 	if err != nil {
@@ -149,11 +172,13 @@ func GetStockInByID(conn *pgxpool.Conn, id int) (*model.StockIn, error) {
 		SELECT sii.stock_in_item_id, sii.buy_price, sii.total_quantity,
 		       i.item_id, i.item_description, i.is_fractionable,
 		       cat.category_id, cat.category_description,
-			   uom.unit_id, uom.unit_description
+			   uom.unit_id, uom.unit_description,
+			   loc.location_id, loc.location_name
 		FROM tb_stock_in_item sii
 		JOIN tb_item i ON i.item_id = sii.item_id
 		JOIN tb_category cat ON cat.category_id = i.category_id
 		JOIN tb_unit_of_measure uom ON i.unit_id = uom.unit_id
+		LEFT JOIN tb_storage_location loc ON loc.location_id = sii.location_id
 		WHERE sii.stock_in_id = $1
 	`
 
@@ -170,6 +195,8 @@ func GetStockInByID(conn *pgxpool.Conn, id int) (*model.StockIn, error) {
 	for rows.Next() {
 		var item model.StockInItem
 		var cat model.Category
+		var locID *uint
+		var locName *string
 		err := rows.Scan(
 			&item.ID,
 			&item.BuyPrice,
@@ -181,10 +208,15 @@ func GetStockInByID(conn *pgxpool.Conn, id int) (*model.StockIn, error) {
 			&cat.Description,
 			&item.Item.UnitOfMeasure.ID,
 			&item.Item.UnitOfMeasure.Description,
+			&locID,
+			&locName,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if locID != nil {
+			item.Location = &model.StorageLocation{ID: *locID, Name: *locName}
+		}
 		item.Item.Category = cat
 		item.StockInID = stockIn.ID
 		item.Packagings = []model.StockInPackaging{}
@@ -241,13 +273,21 @@ func GetStockInByID(conn *pgxpool.Conn, id int) (*model.StockIn, error) {
 	}
 
 	stockIn.Items = items
+
+	stockIn.Approvals, err = stock_approval_repository.ListDecisions(conn, model.StockInDocument, stockIn.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	logger.Log.DebugAsJSON(stockIn)
 
 	return stockIn, nil
 }
 
-// UpdateStockIn updates a stock-in, its items, and packagings, including status
-func UpdateStockIn(conn *pgxpool.Conn, stockIn *model.StockIn) error {
+// UpdateStockIn updates a stock-in of the store, its items, and packagings,
+// including status. It returns pgx.ErrNoRows when the stock-in is not in the
+// store.
+func UpdateStockIn(conn *pgxpool.Conn, stockIn *model.StockIn, storeID uint) error {
 	logger.Log.Infof("UpdateStockIn id=%d", stockIn.ID)
 
 	tx, err := conn.Begin(context.Background())
@@ -257,6 +297,10 @@ func UpdateStockIn(conn *pgxpool.Conn, stockIn *model.StockIn) error {
 	}
 	defer tx.Rollback(context.Background())
 
+	if err := lockStockIn(tx, int(stockIn.ID), storeID); err != nil {
+		return err
+	}
+
 	// Fetch existing item IDs
 	existingItems := map[uint]struct{}{}
 	rows1, err := tx.Query(context.Background(),
@@ -272,13 +316,13 @@ func UpdateStockIn(conn *pgxpool.Conn, stockIn *model.StockIn) error {
 	rows1.Close()
 
 	// Prepare statements
-	insertItem := `INSERT INTO tb_stock_in_item (stock_in_id, item_id, buy_price, total_quantity) VALUES ($1, $2, $3, $4) RETURNING stock_in_item_id`
-	updateItem := `UPDATE tb_stock_in_item SET buy_price = $1, total_quantity = $2, updated_at = NOW() WHERE stock_in_item_id = $3`
-	deleteItem := `DELETE FROM tb_stock_in_item WHERE stock_in_item_id = $1`
+	insertItem := `INSERT INTO tb_stock_in_item (stock_in_id, item_id, buy_price, total_quantity, location_id) VALUES ($1, $2, $3, $4, $5) RETURNING stock_in_item_id`
+	updateItem := `UPDATE tb_stock_in_item SET buy_price = $1, total_quantity = $2, location_id = $3, updated_at = NOW() WHERE stock_in_item_id = $4 AND stock_in_id = $5`
+	deleteItem := `DELETE FROM tb_stock_in_item WHERE stock_in_item_id = $1 AND stock_in_id = $2`
 	selectPack := `SELECT stock_in_packaging_id FROM tb_stock_in_packaging WHERE stock_in_item_id = $1`
 	insertPack := `INSERT INTO tb_stock_in_packaging (stock_in_item_id, item_packaging_id, quantity) VALUES ($1, $2, $3)`
-	updatePack := `UPDATE tb_stock_in_packaging SET item_packaging_id = $1, quantity = $2, updated_at = NOW() WHERE stock_in_packaging_id = $3`
-	deletePack := `DELETE FROM tb_stock_in_packaging WHERE stock_in_packaging_id = $1`
+	updatePack := `UPDATE tb_stock_in_packaging SET item_packaging_id = $1, quantity = $2, updated_at = NOW() WHERE stock_in_packaging_id = $3 AND stock_in_item_id = $4`
+	deletePack := `DELETE FROM tb_stock_in_packaging WHERE stock_in_packaging_id = $1 AND stock_in_item_id = $2`
 
 	// Process provided items
 	providedItems := map[uint]struct{}{}
@@ -288,7 +332,7 @@ func UpdateStockIn(conn *pgxpool.Conn, stockIn *model.StockIn) error {
 		if item.ID == 0 {
 			// Insert new item
 			err := tx.QueryRow(context.Background(), insertItem,
-				stockIn.ID, item.Item.ID, item.BuyPrice, item.TotalQuantity).
+				stockIn.ID, item.Item.ID, item.BuyPrice, item.TotalQuantity, locationID(item.Location)).
 				Scan(&item.ID)
 			if err != nil {
 				logger.Log.Errorf("Error inserting stock in item: %v", err)
@@ -296,8 +340,11 @@ func UpdateStockIn(conn *pgxpool.Conn, stockIn *model.StockIn) error {
 			}
 		} else {
 			// Update existing item
+			if _, ok := existingItems[item.ID]; !ok {
+				return &errorCodes.UnknownDocumentLine{Line: "item", ID: item.ID}
+			}
 			_, err = tx.Exec(context.Background(), updateItem,
-				item.BuyPrice, item.TotalQuantity, item.ID)
+				item.BuyPrice, item.TotalQuantity, locationID(item.Location), item.ID, stockIn.ID)
 			if err != nil {
 				logger.Log.Errorf("Error updating stock in item: %v", err)
 				return err
@@ -330,8 +377,11 @@ func UpdateStockIn(conn *pgxpool.Conn, stockIn *model.StockIn) error {
 				}
 			} else {
 				// update existing packaging
+				if _, ok := existingPacks[p.ID]; !ok {
+					return &errorCodes.UnknownDocumentLine{Line: "packaging", ID: p.ID}
+				}
 				_, err = tx.Exec(context.Background(), updatePack,
-					p.ItemPackaging.ID, p.Quantity, p.ID)
+					p.ItemPackaging.ID, p.Quantity, p.ID, item.ID)
 				if err != nil {
 					logger.Log.Errorf("Error updating stock in packaging: %v", err)
 					return err
@@ -343,7 +393,7 @@ func UpdateStockIn(conn *pgxpool.Conn, stockIn *model.StockIn) error {
 		// Delete removed packagings
 		for pid := range existingPacks {
 			if _, ok := providedPacks[pid]; !ok {
-				_, err = tx.Exec(context.Background(), deletePack, pid)
+				_, err = tx.Exec(context.Background(), deletePack, pid, item.ID)
 				if err != nil {
 					logger.Log.Errorf("Error deleting stock in packaging: %v", err)
 					return err
@@ -363,7 +413,7 @@ func UpdateStockIn(conn *pgxpool.Conn, stockIn *model.StockIn) error {
 			}
 
 			// delete item
-			_, err = tx.Exec(context.Background(), deleteItem, id)
+			_, err = tx.Exec(context.Background(), deleteItem, id, stockIn.ID)
 			if err != nil {
 				logger.Log.Errorf("Error deleting stock in item %d: %v", id, err)
 				return err
@@ -382,17 +432,20 @@ func UpdateStockIn(conn *pgxpool.Conn, stockIn *model.StockIn) error {
 	return nil
 }
 
+const finalizeStockInQuery = `
+		UPDATE tb_stock_in
+		SET status = 'finalized', updated_at = NOW()
+		WHERE stock_in_id = $1 AND store_id = $2
+	`
+
 // FinalizeStockInByID sets the status of the given stock-in to 'finalized',
 // triggering the validate_stock_in_packaging_totals trigger in the database.
-func FinalizeStockInByID(conn *pgxpool.Conn, stockInID int) error {
+// It returns pgx.ErrNoRows when the stock-in is not in the store.
+func FinalizeStockInByID(conn *pgxpool.Conn, stockInID int, storeID uint) error {
 	logger.Log.Infof("FinalizeStockIn id=%d", stockInID)
 
 	// Update status to 'finalized' and set updated_at
-	_, err := conn.Exec(context.Background(), `
-		UPDATE tb_stock_in
-		SET status = 'finalized', updated_at = NOW()
-		WHERE stock_in_id = $1
-	`, stockInID)
+	cmd, err := conn.Exec(context.Background(), finalizeStockInQuery, stockInID, storeID)
 	if err != nil {
 		logger.Log.Errorf("Error finalizing stock_in: %v", err)
 
@@ -404,13 +457,43 @@ func FinalizeStockInByID(conn *pgxpool.Conn, stockInID int) error {
 		// otherwise just bubble it up
 		return err
 	}
+	if cmd.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
 
 	logger.Log.Info("StockIn finalized successfully.")
 	return nil
 }
 
-// DeleteStockIn removes a StockIn, its items, and associated packagings
-func DeleteStockIn(conn *pgxpool.Conn, stockInID int) error {
+// PreviewFinalizeStockInByID finalizes the stock-in in a transaction that is
+// rolled back and returns how the stock of its items would change. Trigger
+// errors are returned as they would be by FinalizeStockInByID.
+func PreviewFinalizeStockInByID(conn *pgxpool.Conn, stockInID int, storeID uint) ([]model.StockLevelChange, error) {
+	logger.Log.Infof("PreviewFinalizeStockIn id=%d", stockInID)
+
+	itemsQuery := `SELECT DISTINCT item_id FROM tb_stock_in_item WHERE stock_in_id = $1`
+	changes, err := stock_repository.PreviewFinalization(conn, itemsQuery, stockInID, func(tx pgx.Tx) error {
+		cmd, err := tx.Exec(context.Background(), finalizeStockInQuery, stockInID, storeID)
+		if err == nil && cmd.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return err
+	})
+	if err != nil {
+		logger.Log.Errorf("Error previewing stock_in finalization: %v", err)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			return nil, pgErr
+		}
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// DeleteStockIn removes a StockIn of the store, its items, and associated
+// packagings. It returns pgx.ErrNoRows when the stock-in is not in the store.
+func DeleteStockIn(conn *pgxpool.Conn, stockInID int, storeID uint) error {
 	logger.Log.Infof("DeleteStockIn id=%d", stockInID)
 
 	tx, err := conn.Begin(context.Background())
@@ -420,6 +503,10 @@ func DeleteStockIn(conn *pgxpool.Conn, stockInID int) error {
 	}
 	defer tx.Rollback(context.Background())
 
+	if err := lockStockIn(tx, stockInID, storeID); err != nil {
+		return err
+	}
+
 	// Delete all packagings for this StockIn
 	_, err = tx.Exec(context.Background(),
 		`DELETE FROM tb_stock_in_packaging
@@ -459,3 +546,25 @@ func DeleteStockIn(conn *pgxpool.Conn, stockInID int) error {
 	logger.Log.Infof("StockIn %d deleted successfully", stockInID)
 	return nil
 }
+
+// lockStockIn locks the stock-in row for the rest of the transaction. It
+// returns pgx.ErrNoRows when the stock-in is not in the store.
+func lockStockIn(tx pgx.Tx, stockInID int, storeID uint) error {
+	var id int
+	err := tx.QueryRow(context.Background(),
+		`SELECT stock_in_id FROM tb_stock_in WHERE stock_in_id = $1 AND store_id = $2 FOR UPDATE`,
+		stockInID, storeID).Scan(&id)
+	if err != nil && err != pgx.ErrNoRows {
+		logger.Log.Errorf("Error locking stock_in: %v", err)
+	}
+	return err
+}
+
+// locationID returns the location id to persist for a document line, or nil
+// when the line is not bound to a location.
+func locationID(l *model.StorageLocation) *uint {
+	if l == nil {
+		return nil
+	}
+	return &l.ID
+}
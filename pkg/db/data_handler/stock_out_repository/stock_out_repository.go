@@ -6,8 +6,13 @@ import (
 
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
 
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_approval_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -38,8 +43,8 @@ func SaveStockOut(conn *pgxpool.Conn, stockOut *model.StockOut, ownerID, storeID
 
 	// Prepare statements for items and packagings
 	insertItem := `
-		INSERT INTO tb_stock_out_item (stock_out_id, item_id, total_quantity)
-		VALUES ($1, $2, $3)
+		INSERT INTO tb_stock_out_item (stock_out_id, item_id, total_quantity, location_id)
+		VALUES ($1, $2, $3, $4)
 		RETURNING stock_out_item_id
 	`
 	insertPack := `
@@ -52,7 +57,7 @@ func SaveStockOut(conn *pgxpool.Conn, stockOut *model.StockOut, ownerID, storeID
 		item := &stockOut.Items[i]
 
 		err := tx.QueryRow(context.Background(), insertItem,
-			stockOut.ID, item.Item.ID, item.TotalQuantity).
+			stockOut.ID, item.Item.ID, item.TotalQuantity, locationID(item.Location)).
 			Scan(&item.ID)
 		if err != nil {
 			logger.Log.Errorf("Error inserting stock_out item: %v", err)
@@ -79,25 +84,35 @@ func SaveStockOut(conn *pgxpool.Conn, stockOut *model.StockOut, ownerID, storeID
 	return nil
 }
 
-// ListAllStockOut returns all StockOut headers for a given owner (without items)
-func ListAllStockOut(conn *pgxpool.Conn, ownerID, storeID uint) ([]*model.StockOut, error) {
+// StockOutListSpec lists the fields ListAllStockOut can filter and sort by
+var StockOutListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"status":          {Column: "status", Type: list_query.FieldText},
+		"approval_status": {Column: "approval_status", Type: list_query.FieldText},
+		"created_at":      {Column: "created_at", Type: list_query.FieldTime},
+		"finalized_at":    {Column: "finalized_at", Type: list_query.FieldTime},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":         {Column: "stock_out_id", Type: list_query.FieldInt},
+		"created_at": {Column: "created_at", Type: list_query.FieldTime},
+		"updated_at": {Column: "updated_at", Type: list_query.FieldTime},
+	},
+	DefaultSort: "-created_at",
+	Key:         list_query.Field{Column: "stock_out_id", Type: list_query.FieldInt},
+}
+
+// ListAllStockOut returns a page of StockOut headers for a given owner (without items)
+func ListAllStockOut(conn *pgxpool.Conn, ownerID, storeID uint, lq *list_query.Query) ([]*model.StockOut, *list_query.PageInfo, error) {
 	logger.Log.Infof("ListAllStockOut storeID=%d", storeID)
 
 	query := `
-		SELECT stock_out_id, created_by, created_at, updated_at, status, finalized_at
+		SELECT stock_out_id, created_by, created_at, updated_at, status, approval_status, finalized_at
 		FROM tb_stock_out
 		WHERE created_by = $1 AND store_id = $2
-		ORDER BY created_at DESC
 	`
-	rows, err := conn.Query(context.Background(), query, ownerID, storeID)
-	if err != nil {
-		logger.Log.Errorf("Error querying stock_out list: %v", err)
-		return nil, err
-	}
-	defer rows.Close()
 
 	var outs []*model.StockOut
-	for rows.Next() {
+	page, err := list_query.Fetch(conn, lq, query, []any{ownerID, storeID}, func(rows pgx.Rows, cursor *string) error {
 		var so model.StockOut
 		err := rows.Scan(
 			&so.ID,
@@ -105,36 +120,45 @@ func ListAllStockOut(conn *pgxpool.Conn, ownerID, storeID uint) ([]*model.StockO
 			&so.CreatedAt,
 			&so.UpdatedAt,
 			&so.Status,
+			&so.ApprovalStatus,
 			&so.FinalizedAt,
+			cursor,
 		)
 		if err != nil {
-			logger.Log.Errorf("Error scanning stock_out row: %v", err)
-			return nil, err
+			return err
 		}
+		// Initialize empty items slice
 		so.Items = []model.StockOutItem{}
 		outs = append(outs, &so)
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Error querying stock_out list: %v", err)
+		return nil, nil, err
 	}
 
-	return outs, nil
+	return outs, page, nil
 }
 
-// GetStockOutByID retrieves a StockOut with its items and packaging breakdowns
-func GetStockOutByID(conn *pgxpool.Conn, id int) (*model.StockOut, error) {
+// GetStockOutByID retrieves a StockOut of the store with its items and
+// packaging breakdowns
+func GetStockOutByID(conn *pgxpool.Conn, id int, storeID uint) (*model.StockOut, error) {
 	logger.Log.Info("GetStockOutByID")
 
 	stockOut := &model.StockOut{}
 	parentQuery := `
-		SELECT stock_out_id, created_by, created_at, updated_at, status, finalized_at
+		SELECT stock_out_id, created_by, created_at, updated_at, status, approval_status, finalized_at
 		FROM tb_stock_out
-		WHERE stock_out_id = $1
+		WHERE stock_out_id = $1 AND store_id = $2
 	`
-	logger.Log.DebugSQL(parentQuery, id)
-	err := conn.QueryRow(context.Background(), parentQuery, id).Scan(
+	logger.Log.DebugSQL(parentQuery, id, storeID)
+	err := conn.QueryRow(context.Background(), parentQuery, id, storeID).Scan(
 		&stockOut.ID,
 		&stockOut.CreatedBy.ID,
 		&stockOut.CreatedAt,
 		&stockOut.UpdatedAt,
 		&stockOut.Status,
+		&stockOut.ApprovalStatus,
 		&stockOut.FinalizedAt,
 	)
 	if err != nil {
@@ -146,11 +170,13 @@ func GetStockOutByID(conn *pgxpool.Conn, id int) (*model.StockOut, error) {
 		SELECT soi.stock_out_item_id, soi.total_quantity,
 		       i.item_id, i.item_description, i.is_fractionable,
 		       cat.category_id, cat.category_description,
-		       uom.unit_id, uom.unit_description
+		       uom.unit_id, uom.unit_description,
+		       loc.location_id, loc.location_name
 		FROM tb_stock_out_item soi
 		JOIN tb_item i ON i.item_id = soi.item_id
 		JOIN tb_category cat ON cat.category_id = i.category_id
 		JOIN tb_unit_of_measure uom ON i.unit_id = uom.unit_id
+		LEFT JOIN tb_storage_location loc ON loc.location_id = soi.location_id
 		WHERE soi.stock_out_id = $1
 	`
 	logger.Log.DebugSQL(itemQuery, stockOut.ID)
@@ -164,6 +190,8 @@ func GetStockOutByID(conn *pgxpool.Conn, id int) (*model.StockOut, error) {
 	for rows.Next() {
 		var item model.StockOutItem
 		var cat model.Category
+		var locID *uint
+		var locName *string
 		err := rows.Scan(
 			&item.ID,
 			&item.TotalQuantity,
@@ -174,10 +202,15 @@ func GetStockOutByID(conn *pgxpool.Conn, id int) (*model.StockOut, error) {
 			&cat.Description,
 			&item.Item.UnitOfMeasure.ID,
 			&item.Item.UnitOfMeasure.Description,
+			&locID,
+			&locName,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if locID != nil {
+			item.Location = &model.StorageLocation{ID: *locID, Name: *locName}
+		}
 		item.Item.Category = cat
 		item.StockOutID = stockOut.ID
 		item.Packagings = []model.StockOutPackaging{}
@@ -230,12 +263,19 @@ func GetStockOutByID(conn *pgxpool.Conn, id int) (*model.StockOut, error) {
 	}
 
 	stockOut.Items = items
+
+	stockOut.Approvals, err = stock_approval_repository.ListDecisions(conn, model.StockOutDocument, stockOut.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	logger.Log.DebugAsJSON(stockOut)
 	return stockOut, nil
 }
 
-// UpdateStockOut updates a stock-out, its items, and packagings
-func UpdateStockOut(conn *pgxpool.Conn, stockOut *model.StockOut) error {
+// UpdateStockOut updates a stock-out of the store, its items, and packagings.
+// It returns pgx.ErrNoRows when the stock-out is not in the store.
+func UpdateStockOut(conn *pgxpool.Conn, stockOut *model.StockOut, storeID uint) error {
 	logger.Log.Infof("UpdateStockOut id=%d", stockOut.ID)
 
 	tx, err := conn.Begin(context.Background())
@@ -245,6 +285,10 @@ func UpdateStockOut(conn *pgxpool.Conn, stockOut *model.StockOut) error {
 	}
 	defer tx.Rollback(context.Background())
 
+	if err := lockStockOut(tx, int(stockOut.ID), storeID); err != nil {
+		return err
+	}
+
 	// Fetch existing item IDs
 	existingItems := map[uint]struct{}{}
 	rows1, err := tx.Query(context.Background(),
@@ -260,14 +304,14 @@ func UpdateStockOut(conn *pgxpool.Conn, stockOut *model.StockOut) error {
 	rows1.Close()
 
 	// Prepare statements
-	insertItem := `INSERT INTO tb_stock_out_item (stock_out_id, item_id, total_quantity) VALUES ($1, $2, $3) RETURNING stock_out_item_id`
-	updateItem := `UPDATE tb_stock_out_item SET total_quantity = $1, updated_at = NOW() WHERE stock_out_item_id = $2`
-	deleteItem := `DELETE FROM tb_stock_out_item WHERE stock_out_item_id = $1`
+	insertItem := `INSERT INTO tb_stock_out_item (stock_out_id, item_id, total_quantity, location_id) VALUES ($1, $2, $3, $4) RETURNING stock_out_item_id`
+	updateItem := `UPDATE tb_stock_out_item SET total_quantity = $1, location_id = $2, updated_at = NOW() WHERE stock_out_item_id = $3 AND stock_out_id = $4`
+	deleteItem := `DELETE FROM tb_stock_out_item WHERE stock_out_item_id = $1 AND stock_out_id = $2`
 
 	selectPack := `SELECT stock_out_packaging_id FROM tb_stock_out_packaging WHERE stock_out_item_id = $1`
 	insertPack := `INSERT INTO tb_stock_out_packaging (stock_out_item_id, item_packaging_id, quantity) VALUES ($1, $2, $3)`
-	updatePack := `UPDATE tb_stock_out_packaging SET item_packaging_id = $1, quantity = $2, updated_at = NOW() WHERE stock_out_packaging_id = $3`
-	deletePack := `DELETE FROM tb_stock_out_packaging WHERE stock_out_packaging_id = $1`
+	updatePack := `UPDATE tb_stock_out_packaging SET item_packaging_id = $1, quantity = $2, updated_at = NOW() WHERE stock_out_packaging_id = $3 AND stock_out_item_id = $4`
+	deletePack := `DELETE FROM tb_stock_out_packaging WHERE stock_out_packaging_id = $1 AND stock_out_item_id = $2`
 
 	providedItems := map[uint]struct{}{}
 	for i := range stockOut.Items {
@@ -275,15 +319,18 @@ func UpdateStockOut(conn *pgxpool.Conn, stockOut *model.StockOut) error {
 
 		if item.ID == 0 {
 			err := tx.QueryRow(context.Background(), insertItem,
-				stockOut.ID, item.Item.ID, item.TotalQuantity).
+				stockOut.ID, item.Item.ID, item.TotalQuantity, locationID(item.Location)).
 				Scan(&item.ID)
 			if err != nil {
 				logger.Log.Errorf("Error inserting stock_out item: %v", err)
 				return err
 			}
 		} else {
+			if _, ok := existingItems[item.ID]; !ok {
+				return &errorCodes.UnknownDocumentLine{Line: "item", ID: item.ID}
+			}
 			_, err = tx.Exec(context.Background(), updateItem,
-				item.TotalQuantity, item.ID)
+				item.TotalQuantity, locationID(item.Location), item.ID, stockOut.ID)
 			if err != nil {
 				logger.Log.Errorf("Error updating stock_out item: %v", err)
 				return err
@@ -314,8 +361,11 @@ func UpdateStockOut(conn *pgxpool.Conn, stockOut *model.StockOut) error {
 					return err
 				}
 			} else {
+				if _, ok := existingPacks[p.ID]; !ok {
+					return &errorCodes.UnknownDocumentLine{Line: "packaging", ID: p.ID}
+				}
 				_, err = tx.Exec(context.Background(), updatePack,
-					p.ItemPackaging.ID, p.Quantity, p.ID)
+					p.ItemPackaging.ID, p.Quantity, p.ID, item.ID)
 				if err != nil {
 					logger.Log.Errorf("Error updating stock_out packaging: %v", err)
 					return err
@@ -327,7 +377,7 @@ func UpdateStockOut(conn *pgxpool.Conn, stockOut *model.StockOut) error {
 		// Delete removed packagings
 		for pid := range existingPacks {
 			if _, ok := providedPacks[pid]; !ok {
-				_, err = tx.Exec(context.Background(), deletePack, pid)
+				_, err = tx.Exec(context.Background(), deletePack, pid, item.ID)
 				if err != nil {
 					logger.Log.Errorf("Error deleting stock_out packaging: %v", err)
 					return err
@@ -348,7 +398,7 @@ func UpdateStockOut(conn *pgxpool.Conn, stockOut *model.StockOut) error {
 			}
 
 			// delete item
-			_, err = tx.Exec(context.Background(), deleteItem, id)
+			_, err = tx.Exec(context.Background(), deleteItem, id, stockOut.ID)
 			if err != nil {
 				logger.Log.Errorf("Error deleting stock_out item: %v", err)
 				return err
@@ -366,17 +416,20 @@ func UpdateStockOut(conn *pgxpool.Conn, stockOut *model.StockOut) error {
 	return nil
 }
 
+const finalizeStockOutQuery = `
+		UPDATE tb_stock_out
+		SET status = 'finalized', updated_at = NOW()
+		WHERE stock_out_id = $1 AND store_id = $2
+	`
+
 // FinalizeStockOutByID sets the status of the given stock-out to 'finalized',
-// triggering database-side validation and stock adjustments.
-func FinalizeStockOutByID(conn *pgxpool.Conn, stockOutID int) error {
+// triggering database-side validation and stock adjustments. It returns
+// pgx.ErrNoRows when the stock-out is not in the store.
+func FinalizeStockOutByID(conn *pgxpool.Conn, stockOutID int, storeID uint) error {
 	logger.Log.Infof("FinalizeStockOut id=%d", stockOutID)
 
 	// Update status to 'finalized' and set updated_at
-	_, err := conn.Exec(context.Background(), `
-		UPDATE tb_stock_out
-		SET status = 'finalized', updated_at = NOW()
-		WHERE stock_out_id = $1
-	`, stockOutID)
+	cmd, err := conn.Exec(context.Background(), finalizeStockOutQuery, stockOutID, storeID)
 	if err != nil {
 		logger.Log.Errorf("Error finalizing stock_out: %v", err)
 
@@ -388,13 +441,43 @@ func FinalizeStockOutByID(conn *pgxpool.Conn, stockOutID int) error {
 		// otherwise just bubble it up
 		return err
 	}
+	if cmd.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
 
 	logger.Log.Info("StockOut finalized successfully.")
 	return nil
 }
 
-// DeleteStockOut removes a StockOut, its items, and associated packagings
-func DeleteStockOut(conn *pgxpool.Conn, stockOutID int) error {
+// PreviewFinalizeStockOutByID finalizes the stock-out in a transaction that is
+// rolled back and returns how the stock of its items would change. Trigger
+// errors are returned as they would be by FinalizeStockOutByID.
+func PreviewFinalizeStockOutByID(conn *pgxpool.Conn, stockOutID int, storeID uint) ([]model.StockLevelChange, error) {
+	logger.Log.Infof("PreviewFinalizeStockOut id=%d", stockOutID)
+
+	itemsQuery := `SELECT DISTINCT item_id FROM tb_stock_out_item WHERE stock_out_id = $1`
+	changes, err := stock_repository.PreviewFinalization(conn, itemsQuery, stockOutID, func(tx pgx.Tx) error {
+		cmd, err := tx.Exec(context.Background(), finalizeStockOutQuery, stockOutID, storeID)
+		if err == nil && cmd.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return err
+	})
+	if err != nil {
+		logger.Log.Errorf("Error previewing stock_out finalization: %v", err)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			return nil, pgErr
+		}
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// DeleteStockOut removes a StockOut of the store, its items, and associated
+// packagings. It returns pgx.ErrNoRows when the stock-out is not in the store.
+func DeleteStockOut(conn *pgxpool.Conn, stockOutID int, storeID uint) error {
 	logger.Log.Infof("DeleteStockOut id=%d", stockOutID)
 
 	tx, err := conn.Begin(context.Background())
@@ -404,6 +487,10 @@ func DeleteStockOut(conn *pgxpool.Conn, stockOutID int) error {
 	}
 	defer tx.Rollback(context.Background())
 
+	if err := lockStockOut(tx, stockOutID, storeID); err != nil {
+		return err
+	}
+
 	// Delete all packagings for this StockOut
 	_, err = tx.Exec(context.Background(),
 		`DELETE FROM tb_stock_out_packaging
@@ -443,3 +530,25 @@ func DeleteStockOut(conn *pgxpool.Conn, stockOutID int) error {
 	logger.Log.Infof("StockOut %d deleted successfully", stockOutID)
 	return nil
 }
+
+// lockStockOut locks the stock-out row for the rest of the transaction. It
+// returns pgx.ErrNoRows when the stock-out is not in the store.
+func lockStockOut(tx pgx.Tx, stockOutID int, storeID uint) error {
+	var id int
+	err := tx.QueryRow(context.Background(),
+		`SELECT stock_out_id FROM tb_stock_out WHERE stock_out_id = $1 AND store_id = $2 FOR UPDATE`,
+		stockOutID, storeID).Scan(&id)
+	if err != nil && err != pgx.ErrNoRows {
+		logger.Log.Errorf("Error locking stock_out: %v", err)
+	}
+	return err
+}
+
+// locationID returns the location id to persist for a document line, or nil
+// when the line is not bound to a location.
+func locationID(l *model.StorageLocation) *uint {
+	if l == nil {
+		return nil
+	}
+	return &l.ID
+}
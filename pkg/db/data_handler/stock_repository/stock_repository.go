@@ -3,33 +3,53 @@ package stock_repository
 import (
 	"context"
 
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/item_attribute_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func GetStock(conn *pgxpool.Conn, OwnerID, StoreID uint) ([]model.Stock, error) {
+// StockListSpec lists the fields GetStock can filter and sort by
+var StockListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"item_id":       {Column: "item_id", Type: list_query.FieldInt},
+		"category_id":   {Column: "category_id", Type: list_query.FieldInt},
+		"unit_id":       {Column: "unit_id", Type: list_query.FieldInt},
+		"ean13":         {Column: "ean13", Type: list_query.FieldText},
+		"current_stock": {Column: "current_stock", Type: list_query.FieldNumber},
+		"updated_at":    {Column: "stock_updated_at", Type: list_query.FieldTime},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":            {Column: "stock_id", Type: list_query.FieldInt},
+		"description":   {Column: "item_description", Type: list_query.FieldText},
+		"current_stock": {Column: "current_stock", Type: list_query.FieldNumber},
+	},
+	DefaultSort: "description",
+	Key:         list_query.Field{Column: "stock_id", Type: list_query.FieldInt},
+}
+
+// GetStock returns a page of the stock of the store's items, optionally
+// narrowed down by custom attribute filters.
+func GetStock(conn *pgxpool.Conn, OwnerID, StoreID uint, filters []model.ItemAttributeFilter, lq *list_query.Query) ([]model.Stock, *list_query.PageInfo, error) {
 	logger.Log.Info("GetStock")
 
 	query := `
-		SELECT stock_id, current_stock, item_id, item_description, 
+		SELECT stock_id, current_stock, item_id, item_description,
 		ean13, category_description, category_id, unit_id, unit_description,
 		stock_updated_at
-		FROM vw_stock_summary
-		WHERE created_by = $1 AND store_id = $2
-		ORDER BY item_description;
-	`
+		FROM vw_stock_summary s
+		WHERE created_by = $1 AND store_id = $2`
 
-	rows, err := conn.Query(context.Background(), query, OwnerID, StoreID)
-	if err != nil {
-		logger.Log.Errorf("Error querying items: %v", err)
-		return nil, err
-	}
-	defer rows.Close()
+	args := []any{OwnerID, StoreID}
+	filterSQL, filterArgs := item_attribute_repository.FilterSQL(filters, "s.item_id", len(args)+1)
+	query += filterSQL
+	args = append(args, filterArgs...)
 
 	var stockSlice []model.Stock
-
-	for rows.Next() {
+	page, err := list_query.Fetch(conn, lq, query, args, func(rows pgx.Rows, cursor *string) error {
 		var stock model.Stock
 		var item model.Item
 		var category model.Category
@@ -47,10 +67,10 @@ func GetStock(conn *pgxpool.Conn, OwnerID, StoreID uint) ([]model.Stock, error)
 			&unit.ID,
 			&unit.Description,
 			&stock.UpdatedAt,
+			cursor,
 		)
 		if err != nil {
-			logger.Log.Errorf("Error scanning item row: %v", err)
-			continue
+			return err
 		}
 
 		owner.ID = OwnerID
@@ -62,25 +82,33 @@ func GetStock(conn *pgxpool.Conn, OwnerID, StoreID uint) ([]model.Stock, error)
 		stock.Item = item
 
 		stockSlice = append(stockSlice, stock)
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Error querying stock: %v", err)
+		return nil, nil, err
 	}
 
 	logger.Log.Infof("Retrieved %d items from stock", len(stockSlice))
-	return stockSlice, nil
+	return stockSlice, page, nil
 }
 
-func GetStockByCategory(conn *pgxpool.Conn, OwnerID uint, CategoryID int) ([]model.Stock, error) {
+// GetStockByCategory returns the stock of every item in the given category
+// or any of its descendant categories.
+func GetStockByCategory(conn *pgxpool.Conn, OwnerID, StoreID, CategoryID uint) ([]model.Stock, error) {
 	logger.Log.Info("GetStockByCategory")
 
 	query := `
-		SELECT ist.item_stock_id, ist.current_stock,
-			it.item_id, it.item_description, it.ean13, cat.category_description, cat.category_id,
-		FROM tb_item_stock ist
-		JOIN tb_item it ON ist.item_id = it.item_id
-		JOIN tb_category cat ON it.category_id = cat.category_id
-		WHERE ist.created_by = $1 AND cat.category_id = $2
+		SELECT stock_id, current_stock, item_id, item_description,
+		ean13, category_description, category_id, unit_id, unit_description,
+		stock_updated_at
+		FROM vw_stock_summary
+		WHERE created_by = $1 AND store_id = $2
+		  AND category_id IN (SELECT category_id FROM fn_category_subtree($3))
+		ORDER BY item_description;
 	`
 
-	rows, err := conn.Query(context.Background(), query, OwnerID, CategoryID)
+	rows, err := conn.Query(context.Background(), query, OwnerID, StoreID, CategoryID)
 	if err != nil {
 		logger.Log.Errorf("Error querying items: %v", err)
 		return nil, err
@@ -93,6 +121,7 @@ func GetStockByCategory(conn *pgxpool.Conn, OwnerID uint, CategoryID int) ([]mod
 		var stock model.Stock
 		var item model.Item
 		var category model.Category
+		var unit model.UnitOfMeasure
 		var owner model.User
 
 		err := rows.Scan(
@@ -103,6 +132,9 @@ func GetStockByCategory(conn *pgxpool.Conn, OwnerID uint, CategoryID int) ([]mod
 			&item.EAN13,
 			&category.Description,
 			&category.ID,
+			&unit.ID,
+			&unit.Description,
+			&stock.UpdatedAt,
 		)
 		if err != nil {
 			logger.Log.Errorf("Error scanning item row: %v", err)
@@ -114,6 +146,7 @@ func GetStockByCategory(conn *pgxpool.Conn, OwnerID uint, CategoryID int) ([]mod
 		category.CreatedBy = owner
 
 		item.Category = category
+		item.UnitOfMeasure = unit
 		stock.Item = item
 
 		stockSlice = append(stockSlice, stock)
@@ -122,3 +155,231 @@ func GetStockByCategory(conn *pgxpool.Conn, OwnerID uint, CategoryID int) ([]mod
 	logger.Log.Infof("Retrieved %d items from stock", len(stockSlice))
 	return stockSlice, nil
 }
+
+// GetCategoryStockSummary aggregates stock quantity and value per category.
+// Each row carries the category's own figures and the totals rolled up from
+// its whole subtree. Stock is valued at the weighted average buy price of the
+// store's finalized stock-ins.
+func GetCategoryStockSummary(conn *pgxpool.Conn, StoreID uint) ([]model.CategoryStockSummary, error) {
+	logger.Log.Info("GetCategoryStockSummary")
+
+	query := `
+		WITH RECURSIVE closure AS (
+			SELECT category_id AS ancestor_id, category_id AS descendant_id
+			FROM tb_category
+			WHERE store_id = $1
+			UNION ALL
+			SELECT cl.ancestor_id, c.category_id
+			FROM closure cl
+			JOIN tb_category c ON c.parent_category_id = cl.descendant_id
+		),
+		avg_price AS (
+			SELECT sii.item_id,
+				SUM(sii.buy_price * sii.total_quantity) / NULLIF(SUM(sii.total_quantity), 0) AS price
+			FROM tb_stock_in_item sii
+			JOIN tb_stock_in si ON si.stock_in_id = sii.stock_in_id
+			WHERE si.store_id = $1 AND si.status = 'finalized'
+			GROUP BY sii.item_id
+		),
+		item_stock AS (
+			SELECT i.item_id, i.category_id, s.current_stock,
+				ROUND(s.current_stock * COALESCE(ap.price, 0), 2) AS stock_value
+			FROM tb_stock s
+			JOIN tb_item i ON i.item_id = s.item_id
+			LEFT JOIN avg_price ap ON ap.item_id = s.item_id
+			WHERE s.store_id = $1
+		)
+		SELECT c.category_id, c.category_description, c.parent_category_id, c.archived_at,
+			COUNT(ist.item_id) FILTER (WHERE ist.category_id = c.category_id),
+			COALESCE(SUM(ist.current_stock) FILTER (WHERE ist.category_id = c.category_id), 0),
+			COALESCE(SUM(ist.stock_value) FILTER (WHERE ist.category_id = c.category_id), 0),
+			COUNT(ist.item_id),
+			COALESCE(SUM(ist.current_stock), 0),
+			COALESCE(SUM(ist.stock_value), 0)
+		FROM tb_category c
+		JOIN closure cl ON cl.ancestor_id = c.category_id
+		LEFT JOIN item_stock ist ON ist.category_id = cl.descendant_id
+		WHERE c.store_id = $1
+		GROUP BY c.category_id, c.category_description, c.parent_category_id, c.archived_at
+		ORDER BY c.category_description;
+	`
+
+	rows, err := conn.Query(context.Background(), query, StoreID)
+	if err != nil {
+		logger.Log.Errorf("Error querying category stock summary: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []model.CategoryStockSummary
+	for rows.Next() {
+		var sum model.CategoryStockSummary
+		err := rows.Scan(
+			&sum.Category.ID,
+			&sum.Category.Description,
+			&sum.Category.ParentID,
+			&sum.Category.ArchivedAt,
+			&sum.ItemCount,
+			&sum.Quantity,
+			&sum.Value,
+			&sum.TotalItemCount,
+			&sum.TotalQuantity,
+			&sum.TotalValue,
+		)
+		if err != nil {
+			logger.Log.Errorf("Error scanning category stock summary row: %v", err)
+			return nil, err
+		}
+		summaries = append(summaries, sum)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Log.Errorf("Error iterating category stock summary: %v", err)
+		return nil, err
+	}
+
+	logger.Log.Infof("Retrieved stock summary for %d categories", len(summaries))
+	return summaries, nil
+}
+
+// querier is satisfied by both pool connections and transactions.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// GetStockLevels returns the current totals and per-location balances of the
+// given items. Items without stock are reported as zero.
+func GetStockLevels(conn *pgxpool.Conn, itemIDs []uint) (*model.StockLevels, error) {
+	logger.Log.Infof("GetStockLevels: %v", itemIDs)
+	return getStockLevels(conn, itemIDs)
+}
+
+func getStockLevels(q querier, itemIDs []uint) (*model.StockLevels, error) {
+	levels := &model.StockLevels{
+		Items:     make(map[uint]decimal.Decimal, len(itemIDs)),
+		Locations: make(map[model.StockLocationKey]decimal.Decimal),
+	}
+
+	rows, err := q.Query(context.Background(), `
+		SELECT i.item_id, COALESCE(s.current_stock, 0)
+		FROM tb_item i
+		LEFT JOIN tb_stock s ON s.item_id = i.item_id
+		WHERE i.item_id = ANY($1)`, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uint
+		var quantity decimal.Decimal
+		if err := rows.Scan(&id, &quantity); err != nil {
+			return nil, err
+		}
+		levels.Items[id] = quantity
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	locRows, err := q.Query(context.Background(), `
+		SELECT item_id, location_id, quantity
+		FROM tb_stock_location
+		WHERE item_id = ANY($1)`, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer locRows.Close()
+
+	for locRows.Next() {
+		var key model.StockLocationKey
+		var quantity decimal.Decimal
+		if err := locRows.Scan(&key.ItemID, &key.LocationID, &quantity); err != nil {
+			return nil, err
+		}
+		levels.Locations[key] = quantity
+	}
+
+	return levels, locRows.Err()
+}
+
+// PreviewFinalization runs finalize in a transaction that is always rolled
+// back, so the database triggers apply exactly as on a real finalization, and
+// returns the stock of the document's items before and after. itemsQuery
+// selects the item IDs of the document with id as $1.
+func PreviewFinalization(conn *pgxpool.Conn, itemsQuery string, id int, finalize func(tx pgx.Tx) error) ([]model.StockLevelChange, error) {
+	logger.Log.Infof("PreviewFinalization id=%d", id)
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, itemsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	var itemIDs []uint
+	for rows.Next() {
+		var itemID uint
+		if err := rows.Scan(&itemID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		itemIDs = append(itemIDs, itemID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	before, err := getStockLevels(tx, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := finalize(tx); err != nil {
+		return nil, err
+	}
+
+	after, err := getStockLevels(tx, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	itemRows, err := tx.Query(ctx, `
+		SELECT i.item_id, i.item_description, i.is_fractionable,
+		       uom.unit_id, uom.unit_description
+		FROM tb_item i
+		JOIN tb_unit_of_measure uom ON uom.unit_id = i.unit_id
+		WHERE i.item_id = ANY($1)
+		ORDER BY i.item_description`, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	changes := []model.StockLevelChange{}
+	for itemRows.Next() {
+		var item model.Item
+		err := itemRows.Scan(
+			&item.ID,
+			&item.Description,
+			&item.IsFractionable,
+			&item.UnitOfMeasure.ID,
+			&item.UnitOfMeasure.Description,
+		)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, model.StockLevelChange{
+			Item:      item,
+			Current:   before.Items[item.ID],
+			Resulting: after.Items[item.ID],
+		})
+	}
+
+	return changes, itemRows.Err()
+}
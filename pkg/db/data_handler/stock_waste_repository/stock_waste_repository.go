@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_approval_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/stock_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -16,9 +20,9 @@ func SaveStockWaste(conn *pgxpool.Conn, waste *model.StockWaste, storeId uint) e
 
 	query := `
 		INSERT INTO tb_stock_waste (
-			item_id, wasted_quantity, reason_text, reason_image_url, store_id, created_by
+			item_id, wasted_quantity, reason_text, reason_image_url, store_id, created_by, location_id
 		)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING stock_waste_id, created_at, status;
 	`
 
@@ -29,6 +33,7 @@ func SaveStockWaste(conn *pgxpool.Conn, waste *model.StockWaste, storeId uint) e
 		waste.ReasonImageURL,
 		storeId,
 		waste.CreatedBy.ID,
+		locationID(waste.Location),
 	).Scan(
 		&waste.StockWasteID,
 		&waste.CreatedAt,
@@ -44,7 +49,7 @@ func SaveStockWaste(conn *pgxpool.Conn, waste *model.StockWaste, storeId uint) e
 	return nil
 }
 
-func GetStockWasteByID(conn *pgxpool.Conn, stockWasteID int) (*model.StockWaste, error) {
+func GetStockWasteByID(conn *pgxpool.Conn, stockWasteID int, storeID uint) (*model.StockWaste, error) {
 	logger.Log.Infof("GetStockWasteByID: %d", stockWasteID)
 
 	query := `
@@ -52,6 +57,7 @@ func GetStockWasteByID(conn *pgxpool.Conn, stockWasteID int) (*model.StockWaste,
 			sw.stock_waste_id,
 			sw.wasted_quantity,
 			sw.status,
+			sw.approval_status,
 			sw.reason_text,
 			sw.reason_image_url,
 			sw.created_by,
@@ -62,20 +68,26 @@ func GetStockWasteByID(conn *pgxpool.Conn, stockWasteID int) (*model.StockWaste,
 			u.unit_description,
 			u.unit_id,
 			c.category_description,
-			c.category_id
+			c.category_id,
+			loc.location_id,
+			loc.location_name
 		FROM tb_stock_waste sw
 		JOIN tb_item i ON sw.item_id = i.item_id
 		JOIN tb_unit_of_measure u ON i.unit_id = u.unit_id
 		JOIN tb_category c ON i.category_id = c.category_id
-		WHERE sw.stock_waste_id = $1;
+		LEFT JOIN tb_storage_location loc ON loc.location_id = sw.location_id
+		WHERE sw.stock_waste_id = $1 AND sw.store_id = $2;
 	`
 
 	var waste model.StockWaste
+	var locID *uint
+	var locName *string
 
-	err := conn.QueryRow(context.Background(), query, stockWasteID).Scan(
+	err := conn.QueryRow(context.Background(), query, stockWasteID, storeID).Scan(
 		&waste.StockWasteID,
 		&waste.WastedQuantity,
 		&waste.Status,
+		&waste.ApprovalStatus,
 		&waste.ReasonText,
 		&waste.ReasonImageURL,
 		&waste.CreatedBy.ID,
@@ -87,24 +99,57 @@ func GetStockWasteByID(conn *pgxpool.Conn, stockWasteID int) (*model.StockWaste,
 		&waste.Item.UnitOfMeasure.ID,
 		&waste.Item.Category.Description,
 		&waste.Item.Category.ID,
+		&locID,
+		&locName,
 	)
 
 	if err != nil {
 		logger.Log.Errorf("Error fetching stock waste by ID: %v", err)
 		return nil, err
 	}
+	if locID != nil {
+		waste.Location = &model.StorageLocation{ID: *locID, Name: *locName}
+	}
+
+	waste.Approvals, err = stock_approval_repository.ListDecisions(conn, model.StockWasteDocument, waste.StockWasteID)
+	if err != nil {
+		return nil, err
+	}
 
 	return &waste, nil
 }
 
-func ListStockWaste(conn *pgxpool.Conn, offset, limit, storeId uint) ([]*model.StockWaste, error) {
-	logger.Log.Infof("ListStockWaste: offset=%d, limit=%d", offset, limit)
+// StockWasteListSpec lists the fields ListStockWaste can filter and sort by
+var StockWasteListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"status":          {Column: "status", Type: list_query.FieldText},
+		"approval_status": {Column: "approval_status", Type: list_query.FieldText},
+		"item_id":         {Column: "item_id", Type: list_query.FieldInt},
+		"wasted_quantity": {Column: "wasted_quantity", Type: list_query.FieldNumber},
+		"created_at":      {Column: "created_at", Type: list_query.FieldTime},
+		"finalized_at":    {Column: "finalized_at", Type: list_query.FieldTime},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":               {Column: "stock_waste_id", Type: list_query.FieldInt},
+		"created_at":       {Column: "created_at", Type: list_query.FieldTime},
+		"wasted_quantity":  {Column: "wasted_quantity", Type: list_query.FieldNumber},
+		"item_description": {Column: "item_description", Type: list_query.FieldText},
+	},
+	DefaultSort: "-created_at",
+	Key:         list_query.Field{Column: "stock_waste_id", Type: list_query.FieldInt},
+}
+
+// ListStockWaste lists a page of the store's waste entries. When categoryID
+// is set only items in that category or any of its subcategories are returned.
+func ListStockWaste(conn *pgxpool.Conn, storeId uint, categoryID *uint, lq *list_query.Query) ([]*model.StockWaste, *list_query.PageInfo, error) {
+	logger.Log.Infof("ListStockWaste: storeId=%d", storeId)
 
 	query := `
 		SELECT 
 			sw.stock_waste_id,
 			sw.wasted_quantity,
 			sw.status,
+			sw.approval_status,
 			sw.reason_text,
 			sw.reason_image_url,
 			sw.created_by,
@@ -115,31 +160,28 @@ func ListStockWaste(conn *pgxpool.Conn, offset, limit, storeId uint) ([]*model.S
 			u.unit_description,
 			u.unit_id,
 			c.category_description,
-			c.category_id
+			c.category_id,
+			loc.location_id,
+			loc.location_name
 		FROM tb_stock_waste sw
 		JOIN tb_item i ON sw.item_id = i.item_id
 		JOIN tb_unit_of_measure u ON i.unit_id = u.unit_id
 		JOIN tb_category c ON i.category_id = c.category_id
+		LEFT JOIN tb_storage_location loc ON loc.location_id = sw.location_id
 		WHERE sw.store_id = $1
-		ORDER BY sw.created_at DESC
-		OFFSET $2 LIMIT $3;
+		  AND ($2::int IS NULL OR i.category_id IN (SELECT category_id FROM fn_category_subtree($2)))
 	`
 
-	rows, err := conn.Query(context.Background(), query, storeId, offset, limit)
-	if err != nil {
-		logger.Log.Errorf("Error listing stock waste: %v", err)
-		return nil, err
-	}
-	defer rows.Close()
-
 	var results []*model.StockWaste
-
-	for rows.Next() {
+	page, err := list_query.Fetch(conn, lq, query, []any{storeId, categoryID}, func(rows pgx.Rows, cursor *string) error {
 		var waste model.StockWaste
+		var locID *uint
+		var locName *string
 		err := rows.Scan(
 			&waste.StockWasteID,
 			&waste.WastedQuantity,
 			&waste.Status,
+			&waste.ApprovalStatus,
 			&waste.ReasonText,
 			&waste.ReasonImageURL,
 			&waste.CreatedBy.ID,
@@ -151,19 +193,30 @@ func ListStockWaste(conn *pgxpool.Conn, offset, limit, storeId uint) ([]*model.S
 			&waste.Item.UnitOfMeasure.ID,
 			&waste.Item.Category.Description,
 			&waste.Item.Category.ID,
+			&locID,
+			&locName,
+			cursor,
 		)
 		if err != nil {
-			logger.Log.Errorf("Error scanning row in stock waste list: %v", err)
-			return nil, err
+			return err
+		}
+		if locID != nil {
+			waste.Location = &model.StorageLocation{ID: *locID, Name: *locName}
 		}
 		results = append(results, &waste)
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Error listing stock waste: %v", err)
+		return nil, nil, err
 	}
 
-	return results, nil
+	return results, page, nil
 }
 
-// UpdateStockWaste updates the fields of a stock waste entry
-func UpdateStockWaste(conn *pgxpool.Conn, waste *model.StockWaste) error {
+// UpdateStockWaste updates the fields of a stock waste entry of the store. It
+// returns pgx.ErrNoRows when the entry is not in the store.
+func UpdateStockWaste(conn *pgxpool.Conn, waste *model.StockWaste, storeID uint) error {
 	logger.Log.Infof("UpdateStockWaste id=%d", waste.StockWasteID)
 
 	query := `
@@ -172,8 +225,9 @@ func UpdateStockWaste(conn *pgxpool.Conn, waste *model.StockWaste) error {
 			item_id = $1,
 			wasted_quantity = $2,
 			reason_text = $3,
-			reason_image_url = $4
-			WHERE stock_waste_id = $5
+			reason_image_url = $4,
+			location_id = $5
+			WHERE stock_waste_id = $6 AND store_id = $7
 		RETURNING created_at;
 	`
 
@@ -182,7 +236,9 @@ func UpdateStockWaste(conn *pgxpool.Conn, waste *model.StockWaste) error {
 		waste.WastedQuantity,
 		waste.ReasonText,
 		waste.ReasonImageURL,
+		locationID(waste.Location),
 		waste.StockWasteID,
+		storeID,
 	).Scan(&waste.CreatedAt)
 
 	if err != nil {
@@ -194,16 +250,19 @@ func UpdateStockWaste(conn *pgxpool.Conn, waste *model.StockWaste) error {
 	return nil
 }
 
+const finalizeStockWasteQuery = `
+		UPDATE tb_stock_waste
+		SET status = 'finalized'
+		WHERE stock_waste_id = $1 AND store_id = $2
+	`
+
 // FinalizeStockWasteByID sets the status of the given stock-waste to 'finalized'
-// and sets finalized_at timestamp.
-func FinalizeStockWasteByID(conn *pgxpool.Conn, stockWasteID int) error {
+// and sets finalized_at timestamp. It returns pgx.ErrNoRows when the
+// stock-waste is not in the store.
+func FinalizeStockWasteByID(conn *pgxpool.Conn, stockWasteID int, storeID uint) error {
 	logger.Log.Infof("FinalizeStockWaste id=%d", stockWasteID)
 
-	_, err := conn.Exec(context.Background(), `
-		UPDATE tb_stock_waste
-		SET status = 'finalized'
-		WHERE stock_waste_id = $1
-	`, stockWasteID)
+	cmd, err := conn.Exec(context.Background(), finalizeStockWasteQuery, stockWasteID, storeID)
 
 	if err != nil {
 		logger.Log.Errorf("Error finalizing stock_waste: %v", err)
@@ -214,19 +273,48 @@ func FinalizeStockWasteByID(conn *pgxpool.Conn, stockWasteID int) error {
 		}
 		return err
 	}
+	if cmd.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
 
 	logger.Log.Info("StockWaste finalized successfully.")
 	return nil
 }
 
-// DeleteStockWasteByID deletes a stock waste record by its ID
-func DeleteStockWasteByID(conn *pgxpool.Conn, stockWasteID int) error {
+// PreviewFinalizeStockWasteByID finalizes the stock-waste in a transaction that
+// is rolled back and returns how the stock of its item would change.
+func PreviewFinalizeStockWasteByID(conn *pgxpool.Conn, stockWasteID int, storeID uint) ([]model.StockLevelChange, error) {
+	logger.Log.Infof("PreviewFinalizeStockWaste id=%d", stockWasteID)
+
+	itemsQuery := `SELECT item_id FROM tb_stock_waste WHERE stock_waste_id = $1`
+	changes, err := stock_repository.PreviewFinalization(conn, itemsQuery, stockWasteID, func(tx pgx.Tx) error {
+		cmd, err := tx.Exec(context.Background(), finalizeStockWasteQuery, stockWasteID, storeID)
+		if err == nil && cmd.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return err
+	})
+	if err != nil {
+		logger.Log.Errorf("Error previewing stock_waste finalization: %v", err)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			return nil, pgErr
+		}
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// DeleteStockWasteByID deletes a stock waste record of the store by its ID.
+// It returns pgx.ErrNoRows when the record is not in the store.
+func DeleteStockWasteByID(conn *pgxpool.Conn, stockWasteID int, storeID uint) error {
 	logger.Log.Infof("DeleteStockWaste id=%d", stockWasteID)
 
-	_, err := conn.Exec(context.Background(), `
+	cmd, err := conn.Exec(context.Background(), `
 		DELETE FROM tb_stock_waste
-		WHERE stock_waste_id = $1
-	`, stockWasteID)
+		WHERE stock_waste_id = $1 AND store_id = $2
+	`, stockWasteID, storeID)
 
 	if err != nil {
 		logger.Log.Errorf("Error deleting stock_waste: %v", err)
@@ -237,7 +325,19 @@ func DeleteStockWasteByID(conn *pgxpool.Conn, stockWasteID int) error {
 		}
 		return err
 	}
+	if cmd.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
 
 	logger.Log.Info("StockWaste deleted successfully.")
 	return nil
 }
+
+// locationID returns the location id to persist for a waste entry, or nil
+// when it is taken from unassigned stock.
+func locationID(l *model.StorageLocation) *uint {
+	if l == nil {
+		return nil
+	}
+	return &l.ID
+}
@@ -0,0 +1,309 @@
+package storage_location_repository
+
+import (
+	"context"
+	"fmt"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+// SaveStorageLocation inserts a new location into tb_storage_location
+func SaveStorageLocation(conn *pgxpool.Conn, location *model.StorageLocation) error {
+	logger.Log.Info("SaveStorageLocation")
+
+	query := `
+		INSERT INTO tb_storage_location (location_name, store_id, created_by)
+		VALUES ($1, $2, $3)
+		RETURNING location_id, created_at, updated_at`
+
+	err := conn.QueryRow(context.Background(), query, location.Name, location.StoreID, location.CreatedBy.ID).
+		Scan(&location.ID, &location.CreatedAt, &location.UpdatedAt)
+	if err != nil {
+		logger.Log.Errorf("Error saving storage location: %v", err)
+		return err
+	}
+
+	logger.Log.Info("Storage location successfully created")
+	return nil
+}
+
+// StorageLocationListSpec lists the fields ListStorageLocations can filter
+// and sort by
+var StorageLocationListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"name":       {Column: "location_name", Type: list_query.FieldText},
+		"created_at": {Column: "created_at", Type: list_query.FieldTime},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":         {Column: "location_id", Type: list_query.FieldInt},
+		"name":       {Column: "location_name", Type: list_query.FieldText},
+		"created_at": {Column: "created_at", Type: list_query.FieldTime},
+	},
+	DefaultSort: "name",
+	Key:         list_query.Field{Column: "location_id", Type: list_query.FieldInt},
+}
+
+// ListStorageLocations returns a page of the store's locations
+func ListStorageLocations(conn *pgxpool.Conn, storeID uint, lq *list_query.Query) ([]model.StorageLocation, *list_query.PageInfo, error) {
+	logger.Log.Infof("ListStorageLocations storeID=%d", storeID)
+
+	query := `
+		SELECT location_id, store_id, location_name, created_by, created_at, updated_at
+		FROM tb_storage_location
+		WHERE store_id = $1`
+
+	var locations []model.StorageLocation
+	page, err := list_query.Fetch(conn, lq, query, []any{storeID}, func(rows pgx.Rows, cursor *string) error {
+		var l model.StorageLocation
+		if err := rows.Scan(&l.ID, &l.StoreID, &l.Name, &l.CreatedBy.ID, &l.CreatedAt, &l.UpdatedAt, cursor); err != nil {
+			return err
+		}
+		locations = append(locations, l)
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Error querying storage locations: %v", err)
+		return nil, nil, err
+	}
+
+	return locations, page, nil
+}
+
+// GetStorageLocationByID retrieves a single location of the store by ID
+func GetStorageLocationByID(conn *pgxpool.Conn, id, storeID uint) (*model.StorageLocation, error) {
+	logger.Log.Infof("GetStorageLocationByID: %d", id)
+
+	query := `
+		SELECT location_id, store_id, location_name, created_by, created_at, updated_at
+		FROM tb_storage_location
+		WHERE location_id = $1 AND store_id = $2`
+
+	var l model.StorageLocation
+	err := conn.QueryRow(context.Background(), query, id, storeID).Scan(
+		&l.ID, &l.StoreID, &l.Name, &l.CreatedBy.ID, &l.CreatedAt, &l.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &l, nil
+}
+
+// UpdateStorageLocation renames a location and returns the updated record
+func UpdateStorageLocation(conn *pgxpool.Conn, l *model.StorageLocation) (*model.StorageLocation, error) {
+	logger.Log.Infof("UpdateStorageLocation: %d", l.ID)
+
+	query := `
+		UPDATE tb_storage_location
+		SET location_name = $1,
+		    updated_at = NOW()
+		WHERE location_id = $2 AND store_id = $3
+		RETURNING location_id, store_id, location_name, created_by, created_at, updated_at;
+	`
+
+	updated := &model.StorageLocation{}
+	err := conn.QueryRow(context.Background(), query, l.Name, l.ID, l.StoreID).Scan(
+		&updated.ID,
+		&updated.StoreID,
+		&updated.Name,
+		&updated.CreatedBy.ID,
+		&updated.CreatedAt,
+		&updated.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// DeleteStorageLocation removes a location. Empty balance rows are cleared
+// first; a location still holding stock or used by documents is left to the
+// foreign keys to reject. Nothing is deleted when the location is not in the
+// store.
+func DeleteStorageLocation(conn *pgxpool.Conn, id, storeID uint) error {
+	logger.Log.Infof("DeleteStorageLocation: %d", id)
+
+	tx, err := conn.Begin(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	_, err = tx.Exec(context.Background(),
+		`DELETE FROM tb_stock_location WHERE location_id = $1 AND quantity = 0`, id)
+	if err != nil {
+		return err
+	}
+
+	cmd, err := tx.Exec(context.Background(),
+		`DELETE FROM tb_storage_location WHERE location_id = $1 AND store_id = $2`, id, storeID)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return fmt.Errorf("no storage location deleted")
+	}
+
+	return tx.Commit(context.Background())
+}
+
+// GetReferencingItems lists the items still held at the given location
+func GetReferencingItems(conn *pgxpool.Conn, id uint) (any, error) {
+
+	rows, err := conn.Query(context.Background(), `
+		SELECT i.item_id, i.item_description
+		FROM tb_stock_location sl
+		JOIN tb_item i ON i.item_id = sl.item_id
+		WHERE sl.location_id = $1 AND sl.quantity <> 0`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []model.Item
+	for rows.Next() {
+		var i model.Item
+		if err := rows.Scan(&i.ID, &i.Description); err != nil {
+			return nil, err
+		}
+		result = append(result, i)
+	}
+
+	return result, nil
+}
+
+// GetStockLocations returns the per-location balances of a store keyed by item id
+func GetStockLocations(conn *pgxpool.Conn, storeID uint) (map[uint][]model.StockLocation, error) {
+	logger.Log.Infof("GetStockLocations storeID=%d", storeID)
+
+	query := `
+		SELECT sl.item_id, sl.quantity, l.location_id, l.store_id, l.location_name
+		FROM tb_stock_location sl
+		JOIN tb_storage_location l ON l.location_id = sl.location_id
+		WHERE l.store_id = $1 AND sl.quantity <> 0
+		ORDER BY l.location_name`
+
+	rows, err := conn.Query(context.Background(), query, storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byItem := map[uint][]model.StockLocation{}
+	for rows.Next() {
+		var itemID uint
+		var sl model.StockLocation
+		err := rows.Scan(&itemID, &sl.Quantity, &sl.Location.ID, &sl.Location.StoreID, &sl.Location.Name)
+		if err != nil {
+			return nil, err
+		}
+		byItem[itemID] = append(byItem[itemID], sl)
+	}
+
+	return byItem, nil
+}
+
+// SaveStockRelocation records a move between locations. The quantities are
+// moved by trg_apply_stock_relocation.
+func SaveStockRelocation(conn *pgxpool.Conn, r *model.StockRelocation) error {
+	logger.Log.Info("SaveStockRelocation")
+
+	var fromID *uint
+	if r.From != nil {
+		fromID = &r.From.ID
+	}
+
+	query := `
+		INSERT INTO tb_stock_relocation (store_id, item_id, from_location_id, to_location_id, quantity, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING relocation_id, created_at`
+
+	err := conn.QueryRow(context.Background(), query,
+		r.StoreID, r.Item.ID, fromID, r.To.ID, r.Quantity, r.CreatedBy.ID).
+		Scan(&r.ID, &r.CreatedAt)
+	if err != nil {
+		logger.Log.Errorf("Error saving stock relocation: %v", err)
+		return err
+	}
+
+	logger.Log.Info("Stock relocation successfully created")
+	return nil
+}
+
+// StockRelocationListSpec lists the fields ListStockRelocations can filter
+// and sort by
+var StockRelocationListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"item_id":          {Column: "item_id", Type: list_query.FieldInt},
+		"from_location_id": {Column: "from_location_id", Type: list_query.FieldInt},
+		"to_location_id":   {Column: "to_location_id", Type: list_query.FieldInt},
+		"quantity":         {Column: "quantity", Type: list_query.FieldNumber},
+		"created_by":       {Column: "created_by", Type: list_query.FieldInt},
+		"created_at":       {Column: "created_at", Type: list_query.FieldTime},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":               {Column: "relocation_id", Type: list_query.FieldInt},
+		"created_at":       {Column: "created_at", Type: list_query.FieldTime},
+		"quantity":         {Column: "quantity", Type: list_query.FieldNumber},
+		"item_description": {Column: "item_description", Type: list_query.FieldText},
+	},
+	DefaultSort: "-created_at",
+	Key:         list_query.Field{Column: "relocation_id", Type: list_query.FieldInt},
+}
+
+// ListStockRelocations returns a page of the relocation history of a store
+func ListStockRelocations(conn *pgxpool.Conn, storeID uint, lq *list_query.Query) ([]model.StockRelocation, *list_query.PageInfo, error) {
+	logger.Log.Infof("ListStockRelocations storeID=%d", storeID)
+
+	query := `
+		SELECT r.relocation_id, r.store_id, r.quantity, r.created_by, r.created_at,
+		       i.item_id, i.item_description,
+		       fl.location_id AS from_location_id, fl.location_name AS from_location_name,
+		       tl.location_id AS to_location_id, tl.location_name AS to_location_name
+		FROM tb_stock_relocation r
+		JOIN tb_item i ON i.item_id = r.item_id
+		LEFT JOIN tb_storage_location fl ON fl.location_id = r.from_location_id
+		JOIN tb_storage_location tl ON tl.location_id = r.to_location_id
+		WHERE r.store_id = $1`
+
+	var relocations []model.StockRelocation
+	page, err := list_query.Fetch(conn, lq, query, []any{storeID}, func(rows pgx.Rows, cursor *string) error {
+		var r model.StockRelocation
+		var fromID *uint
+		var fromName *string
+		err := rows.Scan(
+			&r.ID, &r.StoreID, &r.Quantity, &r.CreatedBy.ID, &r.CreatedAt,
+			&r.Item.ID, &r.Item.Description,
+			&fromID, &fromName,
+			&r.To.ID, &r.To.Name,
+			cursor,
+		)
+		if err != nil {
+			return err
+		}
+		if fromID != nil {
+			r.From = &model.StorageLocation{ID: *fromID, Name: *fromName}
+		}
+		relocations = append(relocations, r)
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Error querying stock relocations: %v", err)
+		return nil, nil, err
+	}
+
+	return relocations, page, nil
+}
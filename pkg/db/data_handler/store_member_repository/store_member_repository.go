@@ -0,0 +1,138 @@
+package store_member_repository
+
+import (
+	"context"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// HasStoreAccess reports whether the store exists and the user may use it,
+// either as a member or as an organization owner.
+func HasStoreAccess(conn *pgxpool.Conn, userID, storeID uint) (bool, error) {
+	logger.Log.Infof("HasStoreAccess user id: %d store id: %d", userID, storeID)
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM tb_store s
+			WHERE s.store_id = $2
+			  AND (
+			    EXISTS (SELECT 1 FROM tb_store_member m WHERE m.store_id = s.store_id AND m.user_id = $1)
+			    OR EXISTS (SELECT 1 FROM tb_user_role r WHERE r.user_id = $1 AND r.store_id IS NULL AND r.role = 'owner')
+			  )
+		)`
+
+	var ok bool
+	err := conn.QueryRow(context.Background(), query, userID, storeID).Scan(&ok)
+	if err != nil {
+		logger.Log.Errorf("Error checking store access: %v", err)
+		return false, err
+	}
+
+	return ok, nil
+}
+
+// ListStoreMembers returns the members of a store.
+func ListStoreMembers(conn *pgxpool.Conn, storeID uint) ([]model.StoreMember, error) {
+	logger.Log.Infof("ListStoreMembers store id: %d", storeID)
+
+	query := `
+		SELECT m.added_by, m.created_at,
+		       u.user_id, u.email, COALESCE(u.given_name, ''), COALESCE(u.family_name, '')
+		FROM tb_store_member m
+		JOIN public.tb_user u ON u.user_id = m.user_id
+		WHERE m.store_id = $1
+		ORDER BY u.email`
+
+	rows, err := conn.Query(context.Background(), query, storeID)
+	if err != nil {
+		logger.Log.Errorf("Error querying store members: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []model.StoreMember{}
+	for rows.Next() {
+		m := model.StoreMember{StoreID: storeID}
+		err := rows.Scan(
+			&m.AddedBy,
+			&m.CreatedAt,
+			&m.User.ID,
+			&m.User.Email,
+			&m.User.GivenName,
+			&m.User.FamilyName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// AddStoreMember makes the user a member of the store. Adding an existing
+// member is a no-op. It returns false when the user does not belong to the
+// organization.
+func AddStoreMember(conn *pgxpool.Conn, storeID, userID, addedBy, organizationID uint) (bool, error) {
+	logger.Log.Infof("AddStoreMember user id: %d store id: %d", userID, storeID)
+
+	query := `
+		WITH member AS (
+			SELECT user_id FROM public.tb_user WHERE user_id = $2 AND organization_id = $4
+		), inserted AS (
+			INSERT INTO tb_store_member (store_id, user_id, added_by)
+			SELECT $1, user_id, $3 FROM member
+			ON CONFLICT DO NOTHING
+		)
+		SELECT EXISTS (SELECT 1 FROM member)`
+
+	var found bool
+	err := conn.QueryRow(context.Background(), query, storeID, userID, addedBy, organizationID).Scan(&found)
+	if err != nil {
+		logger.Log.Errorf("Error adding store member: %v", err)
+		return false, err
+	}
+
+	return found, nil
+}
+
+// RemoveStoreMember revokes the user's access to the store, along with the
+// user's role there. It returns false when the user was not a member.
+func RemoveStoreMember(conn *pgxpool.Conn, storeID, userID uint) (bool, error) {
+	logger.Log.Infof("RemoveStoreMember user id: %d store id: %d", userID, storeID)
+
+	tx, err := conn.Begin(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Failed to begin transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback(context.Background())
+
+	_, err = tx.Exec(context.Background(),
+		`DELETE FROM tb_user_role WHERE store_id = $1 AND user_id = $2`, storeID, userID)
+	if err != nil {
+		logger.Log.Errorf("Error deleting store role: %v", err)
+		return false, err
+	}
+
+	cmdTag, err := tx.Exec(context.Background(),
+		`DELETE FROM tb_store_member WHERE store_id = $1 AND user_id = $2`, storeID, userID)
+	if err != nil {
+		logger.Log.Errorf("Error deleting store member: %v", err)
+		return false, err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		logger.Log.Errorf("Failed to commit transaction: %v", err)
+		return false, err
+	}
+
+	return true, nil
+}
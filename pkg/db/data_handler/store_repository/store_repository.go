@@ -6,6 +6,7 @@ import (
 
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
 
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
 	"github.com/jackc/pgx/v5"
@@ -33,34 +34,51 @@ func SaveStore(conn *pgxpool.Conn, store *model.Store, userID uint) error {
 	return nil
 }
 
-// ListStoresPaginated returns a paginated list of stores
-func ListStoresPaginated(conn *pgxpool.Conn, createdBy uint, offset, limit uint64) ([]model.Store, error) {
-	logger.Log.Infof("ListStoresPaginated offset=%d limit=%d", offset, limit)
+// StoreListSpec lists the fields ListStoresPaginated can filter and sort by
+var StoreListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"name":       {Column: "store_name", Type: list_query.FieldText},
+		"created_at": {Column: "created_at", Type: list_query.FieldTime},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":         {Column: "store_id", Type: list_query.FieldInt},
+		"name":       {Column: "store_name", Type: list_query.FieldText},
+		"created_at": {Column: "created_at", Type: list_query.FieldTime},
+	},
+	DefaultSort: "-created_at",
+	Key:         list_query.Field{Column: "store_id", Type: list_query.FieldInt},
+}
 
-	query := `
-		SELECT store_id, store_name, created_by, created_at, updated_at
-		FROM tb_store
-		WHERE created_by = $1
-		ORDER BY created_at DESC
-		OFFSET $2 LIMIT $3`
+// ListStoresPaginated returns a page of the stores the user may use: the
+// stores it is a member of, or every store for organization owners, leaving
+// out those where the user has no role. onlyStoreID narrows the list to one
+// store, for API keys restricted to it.
+func ListStoresPaginated(conn *pgxpool.Conn, userID uint, onlyStoreID *uint, lq *list_query.Query) ([]model.Store, *list_query.PageInfo, error) {
+	logger.Log.Infof("ListStoresPaginated limit=%d", lq.Limit)
 
-	rows, err := conn.Query(context.Background(), query, createdBy, offset, limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	query := `
+		SELECT s.store_id, s.store_name, s.created_by, s.created_at, s.updated_at
+		FROM tb_store s
+		WHERE (EXISTS (SELECT 1 FROM tb_store_member m WHERE m.store_id = s.store_id AND m.user_id = $1)
+		       OR EXISTS (SELECT 1 FROM tb_user_role r WHERE r.user_id = $1 AND r.store_id IS NULL AND r.role = 'owner'))
+		  AND EXISTS (SELECT 1 FROM tb_user_role r WHERE r.user_id = $1 AND (r.store_id IS NULL OR r.store_id = s.store_id))
+		  AND ($2::int IS NULL OR s.store_id = $2)`
 
 	var stores []model.Store
-	for rows.Next() {
+	page, err := list_query.Fetch(conn, lq, query, []any{userID, onlyStoreID}, func(rows pgx.Rows, cursor *string) error {
 		var s model.Store
-		err := rows.Scan(&s.ID, &s.Name, &s.CreatedBy.ID, &s.CreatedAt, &s.UpdatedAt)
+		err := rows.Scan(&s.ID, &s.Name, &s.CreatedBy.ID, &s.CreatedAt, &s.UpdatedAt, cursor)
 		if err != nil {
-			continue
+			return err
 		}
 		stores = append(stores, s)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return stores, nil
+	return stores, page, nil
 }
 
 // GetStoreByID retrieves a single store by ID
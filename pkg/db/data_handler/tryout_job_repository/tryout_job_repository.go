@@ -7,9 +7,12 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"time"
 
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/auth_session_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/migration_log_repository"
 	"github.com/jackc/pgx/v5"
 
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
@@ -154,6 +157,16 @@ func ProcessTryOutJob(job *model.TryOutJob) error {
 			logger.Log.Errorf("Migration failed on %s (%s): %v", schema, filename, err)
 			return err
 		}
+
+		// Logged like the per-tenant migrations, so they are not run again
+		// once the try-out is converted
+		if err = migration_log_repository.InsertTenantMigrationLog(ctx, tx, &model.TenantMigrationLog{
+			SchemaName: schema,
+			ScriptName: filename,
+		}); err != nil {
+			logger.Log.Errorf("Error logging migration %s on %s: %v", filename, schema, err)
+			return err
+		}
 	}
 
 	// 6) Set job status as created
@@ -251,14 +264,95 @@ func GetTryOutJobStatusByUuid(uuid string) (string, error) {
 	return status, nil
 }
 
-func DestroyTryOutEnvironment(organizationID uint) error {
+// DestroyTryOutEnvironment drops the schema of a try-out organization and
+// deactivates it and its users. ok is false when the organization is not a
+// try-out.
+func DestroyTryOutEnvironment(organizationID uint) (ok bool, err error) {
 	logger.Log.Infof("DestroyTryOutEnvironment <organizationID>:%d", organizationID)
+	return destroyTryOutEnvironment(organizationID, nil)
+}
+
+// ExpireTryOutEnvironment destroys a try-out organization like
+// DestroyTryOutEnvironment, if it expired before expiredBefore. ok is false
+// when it did not, e.g. it was extended or converted in the meantime.
+func ExpireTryOutEnvironment(organizationID uint, expiredBefore time.Time) (ok bool, err error) {
+	logger.Log.Infof("ExpireTryOutEnvironment <organizationID>:%d", organizationID)
+	return destroyTryOutEnvironment(organizationID, &expiredBefore)
+}
+
+// ListExpiredTryOutJobs returns the jobs of try-outs that expired before
+// expiredBefore and were not destroyed yet.
+func ListExpiredTryOutJobs(expiredBefore time.Time) ([]model.TryOutJob, error) {
+	logger.Log.Info("ListExpiredTryOutJobs")
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	query := `
+		SELECT
+		  tjb.job_id,
+		  tjb.tryout_uuid,
+		  tjb.created_by,
+		  tjb.status,
+		  tjb.created_at,
+		  org.organization_id,
+		  org.schema_name,
+		  org.is_try_out,
+		  org.expires_at,
+		  COALESCE(org.is_active, FALSE)
+		FROM public.tb_tryout_job tjb
+		JOIN public.tb_organization org ON org.organization_id = tjb.organization_id
+		WHERE tjb.status IN ('created', 'failed')
+		  AND org.is_try_out
+		  AND org.expires_at < $1
+		ORDER BY org.expires_at ASC;
+	`
+
+	rows, err := conn.Query(context.Background(), query, expiredBefore)
+	if err != nil {
+		logger.Log.Errorf("Error querying expired TryOutJobs: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []model.TryOutJob
+	for rows.Next() {
+		var job model.TryOutJob
+		if err := rows.Scan(
+			&job.JobID,
+			&job.TryoutUUID,
+			&job.CreatedBy.ID,
+			&job.Status,
+			&job.CreatedAt,
+			&job.Organization.ID,
+			&job.Organization.DBSchema,
+			&job.Organization.IsTryOut,
+			&job.Organization.ExpiresAt,
+			&job.Organization.IsActive,
+		); err != nil {
+			logger.Log.Errorf("Error scanning TryOutJob: %v", err)
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Log.Errorf("Row iteration error in ListExpiredTryOutJobs: %v", err)
+		return nil, err
+	}
+
+	return jobs, nil
+}
 
+func destroyTryOutEnvironment(organizationID uint, expiredBefore *time.Time) (ok bool, err error) {
 	ctx := context.Background()
 	conn, err := db.GetDB().Acquire(ctx)
 	if err != nil {
 		logger.Log.Errorf("Error acquiring connection: %v", err)
-		return err
+		return false, err
 	}
 	defer conn.Release()
 
@@ -266,7 +360,7 @@ func DestroyTryOutEnvironment(organizationID uint) error {
 	tx, err := conn.Begin(ctx)
 	if err != nil {
 		logger.Log.Errorf("Error starting transaction: %v", err)
-		return err
+		return false, err
 	}
 	defer func() {
 		if err != nil {
@@ -276,16 +370,24 @@ func DestroyTryOutEnvironment(organizationID uint) error {
 		}
 	}()
 
-	// Step 1: Get the schema name
+	// Step 1: Get the schema name, locking the organization so it cannot be
+	// converted or extended meanwhile
 	var schemaName string
 	err = tx.QueryRow(ctx, `
 		SELECT schema_name 
 		FROM public.tb_organization 
 		WHERE organization_id = $1
-	`, organizationID).Scan(&schemaName)
+		  AND is_try_out
+		  AND ($2::timestamptz IS NULL OR expires_at < $2)
+		FOR UPDATE
+	`, organizationID, expiredBefore).Scan(&schemaName)
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			err = tx.Rollback(ctx)
+			return false, err
+		}
 		logger.Log.Errorf("Error querying schema name: %v", err)
-		return err
+		return false, err
 	}
 
 	// Step 2: Drop schema cascade
@@ -293,7 +395,7 @@ func DestroyTryOutEnvironment(organizationID uint) error {
 	_, err = tx.Exec(ctx, dropSCHQuery)
 	if err != nil {
 		logger.Log.Errorf("Error dropping schema: %v", err)
-		return err
+		return false, err
 	}
 
 	// Step 3: Deactive the organization record
@@ -304,7 +406,7 @@ func DestroyTryOutEnvironment(organizationID uint) error {
 	`, false, organizationID)
 	if err != nil {
 		logger.Log.Errorf("Error deactivating organization record: %v", err)
-		return err
+		return false, err
 	}
 
 	// Step 4: Deactivating related organization users
@@ -315,15 +417,113 @@ func DestroyTryOutEnvironment(organizationID uint) error {
 	`, false, organizationID)
 	if err != nil {
 		logger.Log.Errorf("Error deleting user record: %v", err)
-		return err
+		return false, err
+	}
+
+	// Step 5: Mark the try-out job destroyed
+	_, err = tx.Exec(ctx, `
+		UPDATE public.tb_tryout_job
+		SET status = 'destroyed'
+		WHERE organization_id = $1
+	`, organizationID)
+	if err != nil {
+		logger.Log.Errorf("Error updating tryout job: %v", err)
+		return false, err
 	}
 
 	// Commit the transaction
 	if err = tx.Commit(ctx); err != nil {
 		logger.Log.Errorf("Error committing transaction: %v", err)
-		return err
+		return false, err
 	}
 
 	logger.Log.Infof("Schema %s dropped and organization %d deleted successfully", schemaName, organizationID)
-	return nil
+	return true, nil
+}
+
+// ConvertTryOutEnvironment turns an active try-out organization into a
+// permanent one, keeping its data. When newSchema is set the tenant schema
+// is renamed to it. The sessions of the organization's users are revoked,
+// as they end with the try-out and may name the old schema. ok is false
+// when the organization is not an active try-out.
+func ConvertTryOutEnvironment(organizationID uint, newSchema string) (ok bool, err error) {
+	logger.Log.Infof("ConvertTryOutEnvironment <organizationID>:%d <newSchema>:%s", organizationID, newSchema)
+
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return false, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error starting transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Step 1: Lock the organization and check it is still an active try-out
+	var schemaName string
+	err = tx.QueryRow(ctx, `
+		SELECT schema_name
+		FROM public.tb_organization
+		WHERE organization_id = $1 AND is_try_out AND is_active
+		FOR UPDATE
+	`, organizationID).Scan(&schemaName)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		logger.Log.Errorf("Error querying organization: %v", err)
+		return false, err
+	}
+
+	// Step 2: Rename the schema, keeping its migration history
+	if newSchema != "" && newSchema != schemaName {
+		renameQuery := fmt.Sprintf(`ALTER SCHEMA %s RENAME TO %s`,
+			pgx.Identifier{schemaName}.Sanitize(), pgx.Identifier{newSchema}.Sanitize())
+		if _, err = tx.Exec(ctx, renameQuery); err != nil {
+			logger.Log.Errorf("Error renaming schema %s to %s: %v", schemaName, newSchema, err)
+			return false, err
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE public.tb_tenant_migration_log
+			SET schema_name = $1
+			WHERE schema_name = $2
+		`, newSchema, schemaName)
+		if err != nil {
+			logger.Log.Errorf("Error updating tenant migration log: %v", err)
+			return false, err
+		}
+
+		schemaName = newSchema
+	}
+
+	// Step 3: Make the organization permanent
+	_, err = tx.Exec(ctx, `
+		UPDATE public.tb_organization
+		SET is_try_out = FALSE, expires_at = NULL, schema_name = $1
+		WHERE organization_id = $2
+	`, schemaName, organizationID)
+	if err != nil {
+		logger.Log.Errorf("Error converting organization record: %v", err)
+		return false, err
+	}
+
+	// Step 4: End the try-out sessions
+	err = auth_session_repository.RevokeOrganizationSessionsTx(ctx, tx, organizationID, auth_session_repository.RevokedTryOutConverted)
+	if err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		logger.Log.Errorf("Error committing transaction: %v", err)
+		return false, err
+	}
+
+	logger.Log.Infof("Organization %d converted from try-out, schema %s", organizationID, schemaName)
+	return true, nil
 }
@@ -0,0 +1,239 @@
+package two_factor_repository
+
+import (
+	"context"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetTwoFactor returns the TOTP enrollment of a user. It returns nil, nil
+// when the user has never started one.
+func GetTwoFactor(userID uint) (*model.TwoFactor, error) {
+	logger.Log.Infof("GetTwoFactor user id: %d", userID)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	tf := &model.TwoFactor{}
+	err = conn.QueryRow(context.Background(), `
+		SELECT totp_secret, totp_enabled_at, COALESCE(totp_last_counter, 0),
+		       (SELECT COUNT(*) FROM public.tb_user_backup_code bc
+		        WHERE bc.user_id = us.user_id AND bc.used_at IS NULL)
+		FROM public.tb_user us
+		WHERE us.user_id = $1 AND us.totp_secret IS NOT NULL`, userID).Scan(
+		&tf.Secret,
+		&tf.EnabledAt,
+		&tf.LastCounter,
+		&tf.BackupCodesLeft,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		logger.Log.Errorf("Error fetching two-factor enrollment: %v", err)
+		return nil, err
+	}
+
+	return tf, nil
+}
+
+// SavePendingSecret starts, or starts over, the TOTP enrollment of a user.
+// ok is false when the user already has 2FA enabled.
+func SavePendingSecret(userID uint, secret string) (ok bool, err error) {
+	logger.Log.Infof("SavePendingSecret user id: %d", userID)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return false, err
+	}
+	defer conn.Release()
+
+	tag, err := conn.Exec(context.Background(), `
+		UPDATE public.tb_user
+		SET totp_secret = $2, totp_last_counter = NULL, updated_at = NOW()
+		WHERE user_id = $1 AND totp_enabled_at IS NULL`, userID, secret)
+	if err != nil {
+		logger.Log.Errorf("Error saving TOTP secret: %v", err)
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// EnableTwoFactor turns on the pending enrollment of a user, remembering
+// the time step of the code that confirmed it, and replaces the user's
+// backup codes. ok is false when there was no pending enrollment.
+func EnableTwoFactor(userID uint, counter int64, backupCodeHashes []string) (ok bool, err error) {
+	logger.Log.Infof("EnableTwoFactor user id: %d", userID)
+
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return false, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Failed to begin transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE public.tb_user
+		SET totp_enabled_at = NOW(), totp_last_counter = $2, updated_at = NOW()
+		WHERE user_id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL`, userID, counter)
+	if err != nil {
+		logger.Log.Errorf("Error enabling two-factor: %v", err)
+		return false, err
+	}
+	if tag.RowsAffected() != 1 {
+		return false, nil
+	}
+
+	if err := replaceBackupCodesTx(ctx, tx, userID, backupCodeHashes); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// DisableTwoFactor drops the TOTP secret and backup codes of a user.
+func DisableTwoFactor(userID uint) error {
+	logger.Log.Infof("DisableTwoFactor user id: %d", userID)
+
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE public.tb_user
+		SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = NULL, updated_at = NOW()
+		WHERE user_id = $1`, userID)
+	if err != nil {
+		logger.Log.Errorf("Error disabling two-factor: %v", err)
+		return err
+	}
+
+	if err := replaceBackupCodesTx(ctx, tx, userID, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseTOTPCounter records the time step of an accepted code. ok is false
+// when a code of that step or a later one was already used.
+func UseTOTPCounter(userID uint, counter int64) (ok bool, err error) {
+	logger.Log.Infof("UseTOTPCounter user id: %d", userID)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return false, err
+	}
+	defer conn.Release()
+
+	tag, err := conn.Exec(context.Background(), `
+		UPDATE public.tb_user
+		SET totp_last_counter = $2
+		WHERE user_id = $1 AND (totp_last_counter IS NULL OR totp_last_counter < $2)`, userID, counter)
+	if err != nil {
+		logger.Log.Errorf("Error updating TOTP counter: %v", err)
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReplaceBackupCodes swaps all backup codes of a user for new ones.
+func ReplaceBackupCodes(userID uint, codeHashes []string) error {
+	logger.Log.Infof("ReplaceBackupCodes user id: %d", userID)
+
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceBackupCodesTx(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ConsumeBackupCode marks an unused backup code of a user as used. ok is
+// false when no such code exists, so every code works once.
+func ConsumeBackupCode(userID uint, codeHash string) (ok bool, err error) {
+	logger.Log.Infof("ConsumeBackupCode user id: %d", userID)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return false, err
+	}
+	defer conn.Release()
+
+	tag, err := conn.Exec(context.Background(), `
+		UPDATE public.tb_user_backup_code
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, codeHash)
+	if err != nil {
+		logger.Log.Errorf("Error consuming backup code: %v", err)
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func replaceBackupCodesTx(ctx context.Context, tx pgx.Tx, userID uint, codeHashes []string) error {
+	_, err := tx.Exec(ctx, `DELETE FROM public.tb_user_backup_code WHERE user_id = $1`, userID)
+	if err != nil {
+		logger.Log.Errorf("Error deleting backup codes: %v", err)
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO public.tb_user_backup_code (user_id, code_hash)
+			VALUES ($1, $2)`, userID, hash)
+		if err != nil {
+			logger.Log.Errorf("Error inserting backup code: %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
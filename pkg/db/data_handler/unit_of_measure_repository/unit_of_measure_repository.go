@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/list_query"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
@@ -33,53 +34,61 @@ func SaveUnitOfMeasure(conn *pgxpool.Conn, unit *model.UnitOfMeasure) error {
 	return nil
 }
 
-// ListUnitsPaginated returns paginated unit list
-func ListUnitsPaginated(conn *pgxpool.Conn, ownerID, storeID, offset, limit uint) ([]model.UnitOfMeasure, error) {
-	logger.Log.Infof("ListUnitsPaginated offset=%d limit=%d", offset, limit)
+// UnitListSpec lists the fields ListUnits can filter and sort by
+var UnitListSpec = &list_query.Spec{
+	Filters: map[string]list_query.Field{
+		"description": {Column: "unit_description", Type: list_query.FieldText},
+		"created_at":  {Column: "created_at", Type: list_query.FieldTime},
+	},
+	Sorts: map[string]list_query.Field{
+		"id":          {Column: "unit_id", Type: list_query.FieldInt},
+		"description": {Column: "unit_description", Type: list_query.FieldText},
+		"created_at":  {Column: "created_at", Type: list_query.FieldTime},
+	},
+	DefaultSort: "-created_at",
+	Key:         list_query.Field{Column: "unit_id", Type: list_query.FieldInt},
+}
+
+// ListUnits returns a page of the store's units, archived units only when
+// includeArchived is set
+func ListUnits(conn *pgxpool.Conn, ownerID, storeID uint, includeArchived bool, lq *list_query.Query) ([]model.UnitOfMeasure, *list_query.PageInfo, error) {
+	logger.Log.Info("ListUnits")
 
 	query := `
-		SELECT unit_id, unit_description, created_by, created_at, updated_at
+		SELECT unit_id, unit_description, created_by, created_at, updated_at, archived_at
 		FROM tb_unit_of_measure
 		WHERE created_by = $1 AND store_id = $2
-		ORDER BY created_at DESC
-		OFFSET $3 LIMIT $4`
-
-	rows, err := conn.Query(context.Background(), query,
-		ownerID,
-		storeID,
-		offset,
-		limit,
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+		  AND ($3 OR archived_at IS NULL)`
 
 	var units []model.UnitOfMeasure
-	for rows.Next() {
+	page, err := list_query.Fetch(conn, lq, query, []any{ownerID, storeID, includeArchived}, func(rows pgx.Rows, cursor *string) error {
 		var u model.UnitOfMeasure
-		err := rows.Scan(&u.ID, &u.Description, &u.CreatedBy.ID, &u.CreatedAt, &u.UpdatedAt)
-		if err != nil {
-			continue
+		if err := rows.Scan(&u.ID, &u.Description, &u.CreatedBy.ID, &u.CreatedAt, &u.UpdatedAt, &u.ArchivedAt, cursor); err != nil {
+			return err
 		}
 		units = append(units, u)
+		return nil
+	})
+	if err != nil {
+		logger.Log.Errorf("Error querying units: %v", err)
+		return nil, nil, err
 	}
 
-	return units, nil
+	return units, page, nil
 }
 
-// GetUnitOfMeasureByID retrieves a single unit by ID
-func GetUnitOfMeasureByID(conn *pgxpool.Conn, id uint) (*model.UnitOfMeasure, error) {
+// GetUnitOfMeasureByID retrieves a single unit of the store by ID
+func GetUnitOfMeasureByID(conn *pgxpool.Conn, id, storeID uint) (*model.UnitOfMeasure, error) {
 	logger.Log.Infof("GetUnitOfMeasureByID: %d", id)
 
 	query := `
-		SELECT unit_id, unit_description, created_by, created_at, updated_at
+		SELECT unit_id, unit_description, created_by, created_at, updated_at, archived_at
 		FROM tb_unit_of_measure
-		WHERE unit_id = $1`
+		WHERE unit_id = $1 AND store_id = $2`
 
 	var u model.UnitOfMeasure
-	err := conn.QueryRow(context.Background(), query, id).Scan(
-		&u.ID, &u.Description, &u.CreatedBy.ID, &u.CreatedAt, &u.UpdatedAt,
+	err := conn.QueryRow(context.Background(), query, id, storeID).Scan(
+		&u.ID, &u.Description, &u.CreatedBy.ID, &u.CreatedAt, &u.UpdatedAt, &u.ArchivedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -98,18 +107,19 @@ func UpdateUnitOfMeasure(conn *pgxpool.Conn, u *model.UnitOfMeasure) (*model.Uni
 		UPDATE tb_unit_of_measure
 		SET unit_description = $1,
 		    updated_at = NOW()
-		WHERE unit_id = $2
-		RETURNING unit_id, unit_description, created_at, updated_at;
+		WHERE unit_id = $2 AND store_id = $3
+		RETURNING unit_id, unit_description, created_at, updated_at, archived_at;
 	`
 
 	updated := &model.UnitOfMeasure{}
-	row := conn.QueryRow(context.Background(), query, u.Description, u.ID)
+	row := conn.QueryRow(context.Background(), query, u.Description, u.ID, u.Store.ID)
 
 	err := row.Scan(
 		&updated.ID,
 		&updated.Description,
 		&updated.CreatedAt,
 		&updated.UpdatedAt,
+		&updated.ArchivedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -118,12 +128,12 @@ func UpdateUnitOfMeasure(conn *pgxpool.Conn, u *model.UnitOfMeasure) (*model.Uni
 	return updated, nil
 }
 
-// DeleteUnitOfMeasure removes a unit record
-func DeleteUnitOfMeasure(conn *pgxpool.Conn, id uint) error {
+// DeleteUnitOfMeasure removes a unit record of the store
+func DeleteUnitOfMeasure(conn *pgxpool.Conn, id, storeID uint) error {
 	logger.Log.Infof("DeleteUnitOfMeasure: %d", id)
 
 	cmd, err := conn.Exec(context.Background(),
-		`DELETE FROM tb_unit_of_measure WHERE unit_id = $1`, id)
+		`DELETE FROM tb_unit_of_measure WHERE unit_id = $1 AND store_id = $2`, id, storeID)
 	if err != nil {
 		return err
 	}
@@ -133,6 +143,39 @@ func DeleteUnitOfMeasure(conn *pgxpool.Conn, id uint) error {
 	return nil
 }
 
+// ArchiveUnitOfMeasure stamps archived_at on a unit, keeping the first timestamp
+// if it is already archived. Returns nil, nil when the unit does not exist in
+// the store.
+func ArchiveUnitOfMeasure(conn *pgxpool.Conn, id, storeID uint) (*model.UnitOfMeasure, error) {
+	logger.Log.Infof("ArchiveUnitOfMeasure: %d", id)
+
+	cmd, err := conn.Exec(context.Background(),
+		`UPDATE tb_unit_of_measure SET archived_at = COALESCE(archived_at, NOW()) WHERE unit_id = $1 AND store_id = $2`, id, storeID)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.RowsAffected() == 0 {
+		return nil, nil
+	}
+	return GetUnitOfMeasureByID(conn, id, storeID)
+}
+
+// UnarchiveUnitOfMeasure clears archived_at on a unit.
+// Returns nil, nil when the unit does not exist in the store.
+func UnarchiveUnitOfMeasure(conn *pgxpool.Conn, id, storeID uint) (*model.UnitOfMeasure, error) {
+	logger.Log.Infof("UnarchiveUnitOfMeasure: %d", id)
+
+	cmd, err := conn.Exec(context.Background(),
+		`UPDATE tb_unit_of_measure SET archived_at = NULL WHERE unit_id = $1 AND store_id = $2`, id, storeID)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.RowsAffected() == 0 {
+		return nil, nil
+	}
+	return GetUnitOfMeasureByID(conn, id, storeID)
+}
+
 func GetReferencingItems(conn *pgxpool.Conn, id uint) (any, error) {
 
 	rows, err := conn.Query(context.Background(), `
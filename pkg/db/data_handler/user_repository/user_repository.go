@@ -8,7 +8,6 @@ import (
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
 
-	data_errors "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
 	"github.com/jackc/pgx/v5"
@@ -188,29 +187,116 @@ func DeleteUser(conn *pgxpool.Conn, id uint) error {
 	return nil
 }
 
-// GetUserByGoogleID retrieves a user from the tb_user table by google_id.
-func GetUserByGoogleID(googleID string) (*model.User, error) {
-	logger.Log.Info("GetUserByGoogleID")
+// GetUserByIdentity retrieves the user linked to an external identity, with
+// its organization. It returns nil, nil when no user has that identity.
+func GetUserByIdentity(provider, subject string) (*model.User, error) {
+	logger.Log.Infof("GetUserByIdentity provider: %s", provider)
+	return getUserWithOrganization(`us.user_id = (
+		SELECT ui.user_id FROM public.tb_user_identity ui
+		WHERE ui.provider = $1 AND ui.subject = $2)`, provider, subject)
+}
+
+// InsertUserIdentityTx links an external identity to the user.
+func InsertUserIdentityTx(ctx context.Context, tx pgx.Tx, userID uint, identity model.Identity) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO public.tb_user_identity (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, NULLIF($4, ''))`,
+		userID, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		logger.Log.Errorf("Error linking user identity: %v", err)
+	}
+	return err
+}
+
+// UpdateLastLogin updates the last_login field of a user to the current timestamp,
+// based on the provided user id.
+func StampNowLastLogin(userID uint) error {
+	logger.Log.Infof("Updating last login for user id: %d", userID)
 
 	conn, err := db.GetDB().Acquire(context.Background())
 	if err != nil {
 		logger.Log.Errorf("Error acquiring connection: %v", err)
-		return nil, err
+		return err
 	}
 	logger.Log.Info("DB connection successfully acquired.")
 	defer conn.Release()
 
 	query := `
-		SELECT us.user_id, us.username, us.email, us.password_hash, us.salt, us.google_id,
-		       us.given_name, us.family_name, us.picture_url, us.auth_provider,
-		       us.created_at, us.updated_at, us.last_login, us.is_active,
-			   org.organization_id, org.organization_name, org.organization_key, org.domain, org.schema_name,
-			   org.is_try_out, org.expires_at, org.is_active
+		UPDATE public.tb_user 
+		SET last_login = NOW()
+		WHERE user_id = $1
+	`
+
+	cmdTag, err := conn.Exec(context.Background(), query, userID)
+	if err != nil {
+		logger.Log.Errorf("Error updating last_login: %v", err)
+		return err
+	}
+
+	// Check if the update affected any row. If not, it means the user was not found.
+	if cmdTag.RowsAffected() == 0 {
+		err = fmt.Errorf("no user found with id %d", userID)
+		logger.Log.Error(err)
+		return err
+	}
+
+	logger.Log.Infof("Successfully updated last login for user id: %d", userID)
+	return nil
+}
+
+func InsertUserTx(ctx context.Context, tx pgx.Tx, user *model.User) error {
+	query := `INSERT INTO public.tb_user (username, email, password_hash, salt, google_id, organization_id, given_name, family_name, picture_url, auth_provider, is_active, email_verified_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) RETURNING user_id, created_at, updated_at`
+	return tx.QueryRow(ctx, query,
+		user.Username,
+		user.Email,
+		user.PasswordHash,
+		user.Salt,
+		user.GoogleID,
+		user.Organization.ID,
+		user.GivenName,
+		user.FamilyName,
+		user.PictureURL,
+		user.AuthProvider,
+		user.IsActive,
+		user.EmailVerifiedAt,
+	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+}
+
+// GetUserWithOrganizationByEmail retrieves a user and its organization by
+// email, ignoring case. It returns nil, nil when no user has that email.
+func GetUserWithOrganizationByEmail(email string) (*model.User, error) {
+	logger.Log.Info("GetUserWithOrganizationByEmail")
+	return getUserWithOrganization("lower(us.email) = lower($1)", email)
+}
+
+// GetUserWithOrganizationByID retrieves a user and its organization by id.
+// It returns nil, nil when the user does not exist.
+func GetUserWithOrganizationByID(userID uint) (*model.User, error) {
+	logger.Log.Infof("GetUserWithOrganizationByID user id: %d", userID)
+	return getUserWithOrganization("us.user_id = $1", userID)
+}
+
+func getUserWithOrganization(where string, args ...any) (*model.User, error) {
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	query := `
+		SELECT us.user_id, COALESCE(us.username, ''), us.email,
+		       COALESCE(us.password_hash, ''), COALESCE(us.salt, ''), COALESCE(us.google_id, ''),
+		       COALESCE(us.given_name, ''), COALESCE(us.family_name, ''), COALESCE(us.picture_url, ''),
+		       us.auth_provider, us.created_at, us.updated_at, us.is_active, us.email_verified_at,
+		       us.totp_enabled_at,
+		       org.organization_id, org.organization_name, org.organization_key, org.domain, org.schema_name,
+		       org.is_try_out, org.expires_at, org.is_active, org.require_two_factor
 		FROM public.tb_user us
-		JOIN tb_organization org ON us.organization_id = org.organization_id
-		WHERE google_id = $1`
+		JOIN public.tb_organization org ON us.organization_id = org.organization_id
+		WHERE ` + where
 	user := &model.User{}
-	err = conn.QueryRow(context.Background(), query, googleID).Scan(
+	err = conn.QueryRow(context.Background(), query, args...).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
@@ -223,8 +309,9 @@ func GetUserByGoogleID(googleID string) (*model.User, error) {
 		&user.AuthProvider,
 		&user.CreatedAt,
 		&user.UpdatedAt,
-		&user.LastLogin,
 		&user.IsActive,
+		&user.EmailVerifiedAt,
+		&user.TwoFactorEnabledAt,
 		&user.Organization.ID,
 		&user.Organization.Name,
 		&user.Organization.Key,
@@ -233,69 +320,166 @@ func GetUserByGoogleID(googleID string) (*model.User, error) {
 		&user.Organization.IsTryOut,
 		&user.Organization.ExpiresAt,
 		&user.Organization.IsActive,
+		&user.Organization.RequireTwoFactor,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			logger.Log.Info("No user found for google_id: " + googleID)
-			return nil, &data_errors.GoogleIDUserNotFound{GoogleID: googleID}
+			return nil, nil
 		}
-		logger.Log.Errorf("Error fetching user by google_id: %v", err)
+		logger.Log.Errorf("Error fetching user with organization: %v", err)
 		return nil, err
 	}
 
-	logger.Log.Info("User successfully retrieved.")
 	return user, nil
 }
 
-// UpdateLastLogin updates the last_login field of a user to the current timestamp,
-// based on the provided user id.
-func StampNowLastLogin(userID uint) error {
-	logger.Log.Infof("Updating last login for user id: %d", userID)
+// UpdateUserPassword replaces the password hash and salt of a user.
+func UpdateUserPassword(userID uint, passwordHash, salt string) error {
+	logger.Log.Infof("UpdateUserPassword user id: %d", userID)
 
 	conn, err := db.GetDB().Acquire(context.Background())
 	if err != nil {
 		logger.Log.Errorf("Error acquiring connection: %v", err)
 		return err
 	}
-	logger.Log.Info("DB connection successfully acquired.")
 	defer conn.Release()
 
-	query := `
-		UPDATE public.tb_user 
-		SET last_login = NOW()
-		WHERE user_id = $1
-	`
+	cmdTag, err := conn.Exec(context.Background(), `
+		UPDATE public.tb_user
+		SET password_hash = $1, salt = $2, updated_at = NOW()
+		WHERE user_id = $3`, passwordHash, salt, userID)
+	if err != nil {
+		logger.Log.Errorf("Error updating password: %v", err)
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("no user found with id %d", userID)
+	}
 
-	cmdTag, err := conn.Exec(context.Background(), query, userID)
+	return nil
+}
+
+// StampNowEmailVerified marks the user's email as verified, keeping the
+// first verification time.
+func StampNowEmailVerified(userID uint) error {
+	logger.Log.Infof("StampNowEmailVerified user id: %d", userID)
+
+	conn, err := db.GetDB().Acquire(context.Background())
 	if err != nil {
-		logger.Log.Errorf("Error updating last_login: %v", err)
+		logger.Log.Errorf("Error acquiring connection: %v", err)
 		return err
 	}
+	defer conn.Release()
 
-	// Check if the update affected any row. If not, it means the user was not found.
-	if cmdTag.RowsAffected() == 0 {
-		err = fmt.Errorf("no user found with id %d", userID)
-		logger.Log.Error(err)
+	_, err = conn.Exec(context.Background(), `
+		UPDATE public.tb_user
+		SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+		WHERE user_id = $1`, userID)
+	if err != nil {
+		logger.Log.Errorf("Error verifying email: %v", err)
 		return err
 	}
 
-	logger.Log.Infof("Successfully updated last login for user id: %d", userID)
 	return nil
 }
 
-func InsertUserTx(ctx context.Context, tx pgx.Tx, user *model.User) error {
-	query := `INSERT INTO public.tb_user (username, email, password_hash, salt, google_id, organization_id, given_name, family_name, picture_url, auth_provider, is_active) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING user_id, created_at, updated_at`
-	return tx.QueryRow(ctx, query,
-		user.Username,
-		user.Email,
-		user.PasswordHash,
-		user.Salt,
-		user.GoogleID,
-		user.Organization.ID,
-		user.GivenName,
-		user.FamilyName,
-		user.PictureURL,
-		user.AuthProvider,
-		user.IsActive,
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+// MoveUserToOrganizationTx attaches the user to another organization. It is
+// used when the user accepts a mailed invitation, which also proves the email
+// address, so the email is marked verified.
+func MoveUserToOrganizationTx(ctx context.Context, tx pgx.Tx, userID, organizationID uint) error {
+	cmdTag, err := tx.Exec(ctx, `
+		UPDATE public.tb_user
+		SET organization_id = $2,
+		    email_verified_at = COALESCE(email_verified_at, NOW()),
+		    updated_at = NOW()
+		WHERE user_id = $1`, userID, organizationID)
+	if err != nil {
+		logger.Log.Errorf("Error moving user to organization: %v", err)
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("no user found with id %d", userID)
+	}
+
+	return nil
+}
+
+// ListOrganizationUsers returns the users of an organization with their
+// organization-wide roles. Roles are left nil when the organization's schema
+// cannot be read.
+func ListOrganizationUsers(org *model.Organization) ([]model.OrganizationUser, error) {
+	logger.Log.Infof("ListOrganizationUsers organization id: %d", org.ID)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(context.Background(), `
+		SELECT user_id, COALESCE(username, ''), email, auth_provider, COALESCE(is_active, FALSE),
+		       created_at, last_login, email_verified_at, totp_enabled_at IS NOT NULL
+		FROM public.tb_user
+		WHERE organization_id = $1
+		ORDER BY email`, org.ID)
+	if err != nil {
+		logger.Log.Errorf("Error querying organization users: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []model.OrganizationUser{}
+	byID := map[uint]int{}
+	for rows.Next() {
+		var u model.OrganizationUser
+		err := rows.Scan(
+			&u.ID,
+			&u.Username,
+			&u.Email,
+			&u.AuthProvider,
+			&u.IsActive,
+			&u.CreatedAt,
+			&u.LastLogin,
+			&u.EmailVerifiedAt,
+			&u.TwoFactor,
+		)
+		if err != nil {
+			logger.Log.Errorf("Error scanning organization user: %v", err)
+			return nil, err
+		}
+		byID[u.ID] = len(users)
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Log.Errorf("Error iterating organization users: %v", err)
+		return nil, err
+	}
+
+	roleRows, err := conn.Query(context.Background(), `
+		SELECT user_id, role
+		FROM `+pgx.Identifier{org.DBSchema, "tb_user_role"}.Sanitize()+`
+		WHERE store_id IS NULL`)
+	if err != nil {
+		logger.Log.Warnf("Error querying roles of schema %s: %v", org.DBSchema, err)
+		return users, nil
+	}
+	defer roleRows.Close()
+
+	for roleRows.Next() {
+		var userID uint
+		var role model.Role
+		if err := roleRows.Scan(&userID, &role); err != nil {
+			logger.Log.Errorf("Error scanning user role: %v", err)
+			return nil, err
+		}
+		if i, ok := byID[userID]; ok {
+			users[i].Role = &role
+		}
+	}
+	if err := roleRows.Err(); err != nil {
+		logger.Log.Warnf("Error iterating roles of schema %s: %v", org.DBSchema, err)
+	}
+
+	return users, nil
 }
@@ -0,0 +1,114 @@
+package user_token_repository
+
+import (
+	"context"
+	"time"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/jackc/pgx/v5"
+)
+
+// Purposes a one-time token can be issued for.
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposePasswordReset     = "password_reset"
+	PurposeTwoFactorLogin    = "two_factor_login"
+)
+
+// SaveUserToken stores the hash of a new token and revokes the user's other
+// unused tokens for the same purpose, so only the latest mailed link works.
+func SaveUserToken(userID uint, purpose, tokenHash string, expiresAt time.Time) error {
+	logger.Log.Infof("SaveUserToken user id: %d purpose: %s", userID, purpose)
+
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE public.tb_user_token
+		SET used_at = NOW()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`, userID, purpose)
+	if err != nil {
+		logger.Log.Errorf("Error revoking previous tokens: %v", err)
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO public.tb_user_token (user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)`, userID, purpose, tokenHash, expiresAt)
+	if err != nil {
+		logger.Log.Errorf("Error inserting user token: %v", err)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ConsumeUserToken marks an unused, unexpired token as used and returns its
+// user. ok is false when no such token exists, so every token works once.
+func ConsumeUserToken(purpose, tokenHash string) (userID uint, ok bool, err error) {
+	logger.Log.Infof("ConsumeUserToken purpose: %s", purpose)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return 0, false, err
+	}
+	defer conn.Release()
+
+	err = conn.QueryRow(context.Background(), `
+		UPDATE public.tb_user_token
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2
+		  AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`, tokenHash, purpose).Scan(&userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, false, nil
+		}
+		logger.Log.Errorf("Error consuming user token: %v", err)
+		return 0, false, err
+	}
+
+	return userID, true, nil
+}
+
+// LookupUserToken returns the user of an unused, unexpired token without
+// using it up, for tokens that may be presented again after a failed step.
+func LookupUserToken(purpose, tokenHash string) (userID uint, ok bool, err error) {
+	logger.Log.Infof("LookupUserToken purpose: %s", purpose)
+
+	conn, err := db.GetDB().Acquire(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return 0, false, err
+	}
+	defer conn.Release()
+
+	err = conn.QueryRow(context.Background(), `
+		SELECT user_id FROM public.tb_user_token
+		WHERE token_hash = $1 AND purpose = $2
+		  AND used_at IS NULL AND expires_at > NOW()`, tokenHash, purpose).Scan(&userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, false, nil
+		}
+		logger.Log.Errorf("Error looking up user token: %v", err)
+		return 0, false, err
+	}
+
+	return userID, true, nil
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
 	_ "github.com/lib/pq"
 
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -65,3 +67,12 @@ func CloseDB() {
 		logger.Log.Info("Database connection pool closed")
 	}
 }
+
+// SetAuditActor records userID as the user making the changes of tx, for the
+// audit log triggers. It lasts until tx ends.
+func SetAuditActor(ctx context.Context, tx pgx.Tx, userID uint) error {
+	_, err := tx.Exec(ctx,
+		"SELECT set_config('app.user_id', $1, true), set_config('app.api_key_id', '', true)",
+		strconv.FormatUint(uint64(userID), 10))
+	return err
+}
@@ -39,6 +39,64 @@ func IsStockOutTotalQuantityNotMatching(pgErr *pgconn.PgError) bool {
 	return pgErr.Code == "P0005"
 }
 
+// Raised by the fn_prevent_archived_*_reference triggers when a new row
+// points at an archived catalog entity
+func IsArchivedReferenceError(pgErr *pgconn.PgError) bool {
+	return pgErr.Code == "P0006"
+}
+
+// Raised by trg_validate_category_parent on self-parenting, cross-store
+// parents or cycles
+func IsCategoryHierarchyError(pgErr *pgconn.PgError) bool {
+	return pgErr.Code == "P0007"
+}
+
+// Raised when a document line or relocation points at a location of another store
+func IsInvalidStorageLocationError(pgErr *pgconn.PgError) bool {
+	return pgErr.Code == "P0008"
+}
+
+// Raised when picking or relocating more than a location holds
+func IsInsufficientLocationStockError(pgErr *pgconn.PgError) bool {
+	return pgErr.Code == "P0009"
+}
+
+// Raised by trg_validate_item_attribute_value when a value does not match its definition
+func IsItemAttributeError(pgErr *pgconn.PgError) bool {
+	return pgErr.Code == "P0010"
+}
+
+// Raised by trg_validate_item_packaging_inner on cycles or cross-item nesting
+func IsPackagingHierarchyError(pgErr *pgconn.PgError) bool {
+	return pgErr.Code == "P0011"
+}
+
+// Raised by trg_require_stock_*_approval when finalizing an unapproved document
+func IsApprovalRequiredError(pgErr *pgconn.PgError) bool {
+	return pgErr.Code == "P0012"
+}
+
+// Raised by trg_lock_stock_*_under_approval when editing a submitted or approved document
+func IsDocumentLockedForApprovalError(pgErr *pgconn.PgError) bool {
+	return pgErr.Code == "P0013"
+}
+
+// Raised by trg_protect_stock_*_delete when deleting a finalized document
+func IsDocumentNotDraftError(pgErr *pgconn.PgError) bool {
+	return pgErr.Code == "P0015"
+}
+
+// Raised by the trg_validate_*_store triggers when a row references an item,
+// packaging, category or unit of another store
+func IsStoreMismatchError(pgErr *pgconn.PgError) bool {
+	return pgErr.Code == "P0016"
+}
+
+// Raised by trg_keep_organization_owner when the last organization owner is removed or demoted
+func IsLastOrganizationOwnerError(pgErr *pgconn.PgError) bool {
+	return pgErr.Code == "P0014"
+}
+
 // Extracts the referenced table name from pgErr.Detail (if present)
 func GetReferencedTableName(pgErr *pgconn.PgError) string {
 	if pgErr == nil || pgErr.Detail == "" {
@@ -143,6 +201,237 @@ func HandleDBErrorWithReferencingFetcher(c *gin.Context, err error, id uint, fet
 	c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Internal server error"})
 }
 
+// HandleArchivedReferenceError writes a 422 response and returns true when err
+// comes from referencing an archived entity. Otherwise it writes nothing so the
+// caller can fall back to its own error response.
+func HandleArchivedReferenceError(c *gin.Context, err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || !IsArchivedReferenceError(pgErr) {
+		return false
+	}
+
+	logger.Log.Info("HandleArchivedReferenceError")
+	c.JSON(http.StatusUnprocessableEntity,
+		dto.ArchivedEntityReferencedErrorResponse{
+			Error:           "Cannot reference an archived record.",
+			Code:            pgErr.Code,
+			InternalCode:    errorCodes.CodeArchivedEntityReferenced,
+			Details:         pgErr.Message,
+			ReferencedTable: GetReferencedTableName(pgErr),
+		})
+	return true
+}
+
+// HandleCategoryHierarchyError writes a 422 response and returns true when err
+// comes from an invalid category parent. Otherwise it writes nothing.
+func HandleCategoryHierarchyError(c *gin.Context, err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || !IsCategoryHierarchyError(pgErr) {
+		return false
+	}
+
+	logger.Log.Info("HandleCategoryHierarchyError")
+	c.JSON(http.StatusUnprocessableEntity,
+		dto.InvalidCategoryHierarchyErrorResponse{
+			Error:        "Invalid category parent.",
+			Code:         pgErr.Code,
+			InternalCode: errorCodes.CodeInvalidCategoryHierarchy,
+			Details:      pgErr.Message,
+		})
+	return true
+}
+
+// HandleStorageLocationError writes a 422 response and returns true when err
+// comes from an invalid location or a location without enough stock.
+// Otherwise it writes nothing.
+func HandleStorageLocationError(c *gin.Context, err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	var resp dto.StorageLocationErrorResponse
+	switch {
+	case IsInvalidStorageLocationError(pgErr):
+		resp = dto.StorageLocationErrorResponse{
+			Error:        "Invalid storage location.",
+			InternalCode: errorCodes.CodeInvalidStorageLocation,
+		}
+	case IsInsufficientLocationStockError(pgErr):
+		resp = dto.StorageLocationErrorResponse{
+			Error:        "Not enough stock at the storage location.",
+			InternalCode: errorCodes.CodeInsufficientLocationStock,
+		}
+	default:
+		return false
+	}
+
+	logger.Log.Info("HandleStorageLocationError")
+	resp.Code = pgErr.Code
+	resp.Details = pgErr.Message
+	c.JSON(http.StatusUnprocessableEntity, resp)
+	return true
+}
+
+// HandleStoreMismatchError writes a 422 response and returns true when err
+// comes from referencing a catalog entity of another store. Otherwise it
+// writes nothing.
+func HandleStoreMismatchError(c *gin.Context, err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || !IsStoreMismatchError(pgErr) {
+		return false
+	}
+
+	logger.Log.Info("HandleStoreMismatchError")
+	c.JSON(http.StatusUnprocessableEntity,
+		dto.StoreMismatchErrorResponse{
+			Error:        "Cannot reference a record of another store.",
+			Code:         pgErr.Code,
+			InternalCode: errorCodes.CodeStoreMismatch,
+			Details:      pgErr.Message,
+		})
+	return true
+}
+
+// HandleItemAttributeError writes a 422 response and returns true when err is
+// an invalid custom attribute value, either caught in Go or by the database.
+// Otherwise it writes nothing.
+func HandleItemAttributeError(c *gin.Context, err error) bool {
+	var attrErr *errorCodes.InvalidItemAttribute
+	if errors.As(err, &attrErr) {
+		logger.Log.Info("HandleItemAttributeError")
+		c.JSON(http.StatusUnprocessableEntity,
+			dto.InvalidItemAttributeErrorResponse{
+				Error:        "Invalid item attribute.",
+				InternalCode: errorCodes.CodeInvalidItemAttribute,
+				Attribute:    attrErr.Key,
+				Details:      attrErr.Error(),
+			})
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || !IsItemAttributeError(pgErr) {
+		return false
+	}
+
+	logger.Log.Info("HandleItemAttributeError")
+	c.JSON(http.StatusUnprocessableEntity,
+		dto.InvalidItemAttributeErrorResponse{
+			Error:        "Invalid item attribute.",
+			Code:         pgErr.Code,
+			InternalCode: errorCodes.CodeInvalidItemAttribute,
+			Details:      pgErr.Message,
+		})
+	return true
+}
+
+// HandlePackagingHierarchyError writes a 422 response and returns true when err
+// comes from an invalid inner packaging. Otherwise it writes nothing.
+func HandlePackagingHierarchyError(c *gin.Context, err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || !IsPackagingHierarchyError(pgErr) {
+		return false
+	}
+
+	logger.Log.Info("HandlePackagingHierarchyError")
+	c.JSON(http.StatusUnprocessableEntity,
+		dto.InvalidPackagingHierarchyErrorResponse{
+			Error:        "Invalid inner packaging.",
+			Code:         pgErr.Code,
+			InternalCode: errorCodes.CodeInvalidPackagingHierarchy,
+			Details:      pgErr.Message,
+		})
+	return true
+}
+
+// HandleApprovalError writes a 422 response and returns true when err is an
+// approval action the document does not allow, or a finalize or edit blocked
+// by the approval workflow. Otherwise it writes nothing.
+func HandleApprovalError(c *gin.Context, err error) bool {
+	var transitionErr *errorCodes.InvalidApprovalTransition
+	if errors.As(err, &transitionErr) {
+		logger.Log.Info("HandleApprovalError")
+		c.JSON(http.StatusUnprocessableEntity,
+			dto.ApprovalErrorResponse{
+				Error:        "Invalid approval action.",
+				InternalCode: errorCodes.CodeInvalidApprovalTransition,
+				Details:      transitionErr.Error(),
+			})
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	var resp dto.ApprovalErrorResponse
+	switch {
+	case IsApprovalRequiredError(pgErr):
+		resp = dto.ApprovalErrorResponse{
+			Error:        "Document needs an approval before it can be finalized.",
+			InternalCode: errorCodes.CodeApprovalRequired,
+		}
+	case IsDocumentLockedForApprovalError(pgErr):
+		resp = dto.ApprovalErrorResponse{
+			Error:        "Document is under approval and cannot be edited.",
+			InternalCode: errorCodes.CodeDocumentLockedForApproval,
+		}
+	case IsDocumentNotDraftError(pgErr):
+		resp = dto.ApprovalErrorResponse{
+			Error:        "Only draft documents can be deleted.",
+			InternalCode: errorCodes.CodeDocumentNotDraft,
+		}
+	default:
+		return false
+	}
+
+	logger.Log.Info("HandleApprovalError")
+	resp.Code = pgErr.Code
+	resp.Details = pgErr.Message
+	c.JSON(http.StatusUnprocessableEntity, resp)
+	return true
+}
+
+// HandleUnknownDocumentLineError writes a 422 response and returns true when
+// an update names an item or packaging line of another document. Otherwise it
+// writes nothing.
+func HandleUnknownDocumentLineError(c *gin.Context, err error) bool {
+	var lineErr *errorCodes.UnknownDocumentLine
+	if !errors.As(err, &lineErr) {
+		return false
+	}
+
+	logger.Log.Info("HandleUnknownDocumentLineError")
+	c.JSON(http.StatusUnprocessableEntity,
+		dto.UnknownDocumentLineErrorResponse{
+			Error:        "Unknown document line.",
+			InternalCode: errorCodes.CodeUnknownDocumentLine,
+			Details:      lineErr.Error(),
+		})
+	return true
+}
+
+// HandleLastOwnerError writes a 422 response and returns true when err
+// would leave the organization without an owner. Otherwise it writes nothing.
+func HandleLastOwnerError(c *gin.Context, err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || !IsLastOrganizationOwnerError(pgErr) {
+		return false
+	}
+
+	logger.Log.Info("HandleLastOwnerError")
+	c.JSON(http.StatusUnprocessableEntity,
+		dto.LastOwnerErrorResponse{
+			Error:        "The organization must keep at least one owner.",
+			Code:         pgErr.Code,
+			InternalCode: errorCodes.CodeLastOrganizationOwner,
+			Details:      pgErr.Message,
+		})
+	return true
+}
+
 func HandleDBError(c *gin.Context, err error, id int) {
 	logger.Log.Info("HandleDBError")
 
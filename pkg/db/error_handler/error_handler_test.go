@@ -0,0 +1,73 @@
+package error_handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dto "github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestHandleStoreMismatchErrorRejectsCrossStoreReferences(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"stock-in line", "Item 7 does not belong to the stock-in store"},
+		{"stock-out packaging", "Packaging 3 does not belong to the stock-out store"},
+		{"item category", "Category 2 does not belong to the item store"},
+		{"packaging item", "Item 7 does not belong to the packaging store"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			// Repositories wrap the database error
+			err := fmt.Errorf("insert stock in item: %w", &pgconn.PgError{Code: "P0016", Message: tt.message})
+			if !HandleStoreMismatchError(c, err) {
+				t.Fatal("store mismatch not handled")
+			}
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+			}
+
+			var resp dto.StoreMismatchErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.InternalCode != errorCodes.CodeStoreMismatch || resp.Code != "P0016" || resp.Details != tt.message {
+				t.Errorf("response = %+v", resp)
+			}
+		})
+	}
+}
+
+func TestHandleStoreMismatchErrorIgnoresOtherErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, err := range []error{
+		&pgconn.PgError{Code: "P0008", Message: "Location 4 does not belong to the stock-in store"},
+		&pgconn.PgError{Code: "23503", Detail: `Key (item_id)=(9) is not present in table "tb_item".`},
+		errors.New("connection reset"),
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		if HandleStoreMismatchError(c, err) {
+			t.Errorf("%v handled as a store mismatch", err)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("%v wrote %q", err, w.Body.String())
+		}
+	}
+}
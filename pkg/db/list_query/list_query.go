@@ -0,0 +1,449 @@
+package list_query
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	dataErrors "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+)
+
+// Query parameters shared by every list endpoint.
+const (
+	ParamLimit  = "limit"
+	ParamOffset = "offset"
+	ParamCursor = "cursor"
+	ParamSort   = "sort"
+
+	// FilterPrefix marks whitelisted field filters, e.g. filter.status=draft,
+	// filter.created_at.min=2025-01-01. A comma-separated value matches any
+	// of the listed values.
+	FilterPrefix = "filter."
+
+	DefaultLimit = 20
+	MaxLimit     = 200
+)
+
+// FieldType is the Postgres type a field's query values are cast to.
+type FieldType string
+
+const (
+	FieldInt    FieldType = "bigint"
+	FieldNumber FieldType = "numeric"
+	FieldText   FieldType = "text"
+	FieldTime   FieldType = "timestamptz"
+	FieldBool   FieldType = "boolean"
+)
+
+// Field maps a public field name to an output column of the resource's base
+// query.
+type Field struct {
+	Column string
+	Type   FieldType
+}
+
+// Spec declares what a resource accepts. Base queries are wrapped as a
+// subquery, so Column must name an output column of the base SELECT.
+// Sortable columns must be NOT NULL so cursors can compare them.
+type Spec struct {
+	Filters     map[string]Field
+	Sorts       map[string]Field
+	DefaultSort string
+	// Key is a unique column appended to every ordering as a tie-breaker
+	Key Field
+}
+
+// Sort is one ORDER BY term.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Filter is one whitelisted condition. Op is "eq", "min" or "max".
+type Filter struct {
+	Field  string
+	Op     string
+	Values []string
+}
+
+// Query is a parsed list request for a given Spec.
+type Query struct {
+	spec    *Spec
+	Filters []Filter
+	Sort    []Sort
+	Limit   uint64
+	Offset  uint64
+	after   []string
+}
+
+// PageInfo describes the page returned by Fetch. NextCursor is empty on the
+// last page.
+type PageInfo struct {
+	Total      int64
+	NextCursor string
+}
+
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+// Parse reads limit, offset, cursor, sort and filter.* parameters against
+// spec. Parameters outside the shared set are ignored so endpoints can keep
+// their own flags.
+func Parse(spec *Spec, params url.Values) (*Query, error) {
+	q := &Query{spec: spec, Limit: DefaultLimit}
+
+	if raw := params.Get(ParamLimit); raw != "" {
+		limit, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil || limit == 0 || limit > MaxLimit {
+			return nil, &dataErrors.InvalidListQuery{
+				Param:  ParamLimit,
+				Reason: fmt.Sprintf("must be an integer between 1 and %d", MaxLimit),
+			}
+		}
+		q.Limit = limit
+	}
+
+	if raw := params.Get(ParamOffset); raw != "" {
+		offset, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			return nil, &dataErrors.InvalidListQuery{Param: ParamOffset, Reason: "must be a non-negative integer"}
+		}
+		q.Offset = offset
+	}
+
+	sortParam := spec.DefaultSort
+	if raw := params.Get(ParamSort); raw != "" {
+		sortParam = raw
+	}
+	seen := map[string]bool{}
+	for _, term := range strings.Split(sortParam, ",") {
+		name, desc := strings.CutPrefix(strings.TrimSpace(term), "-")
+		if _, ok := spec.Sorts[name]; !ok {
+			return nil, &dataErrors.InvalidListQuery{
+				Param:  ParamSort,
+				Reason: fmt.Sprintf("cannot sort by %q; allowed: %s", name, allowed(spec.Sorts)),
+			}
+		}
+		if seen[name] {
+			return nil, &dataErrors.InvalidListQuery{Param: ParamSort, Reason: fmt.Sprintf("%q is listed twice", name)}
+		}
+		seen[name] = true
+		q.Sort = append(q.Sort, Sort{Field: name, Desc: desc})
+	}
+
+	if raw := params.Get(ParamCursor); raw != "" {
+		if q.Offset > 0 {
+			return nil, &dataErrors.InvalidListQuery{Param: ParamCursor, Reason: "cannot be combined with offset"}
+		}
+		after, err := q.decodeCursor(raw)
+		if err != nil {
+			return nil, err
+		}
+		q.after = after
+	}
+
+	for param, values := range params {
+		name, ok := strings.CutPrefix(param, FilterPrefix)
+		if !ok || len(values) == 0 {
+			continue
+		}
+
+		op := "eq"
+		if n, ok := strings.CutSuffix(name, ".min"); ok {
+			name, op = n, "min"
+		} else if n, ok := strings.CutSuffix(name, ".max"); ok {
+			name, op = n, "max"
+		}
+
+		field, ok := spec.Filters[name]
+		if !ok {
+			return nil, &dataErrors.InvalidListQuery{
+				Param:  param,
+				Reason: fmt.Sprintf("cannot filter by %q; allowed: %s", name, allowed(spec.Filters)),
+			}
+		}
+		if op != "eq" && (field.Type == FieldText || field.Type == FieldBool) {
+			return nil, &dataErrors.InvalidListQuery{Param: param, Reason: "min/max only apply to numeric and date fields"}
+		}
+
+		parts := []string{values[0]}
+		if op == "eq" {
+			parts = strings.Split(values[0], ",")
+		}
+		for i, v := range parts {
+			normalized, err := normalize(field.Type, strings.TrimSpace(v))
+			if err != nil {
+				return nil, &dataErrors.InvalidListQuery{Param: param, Reason: err.Error()}
+			}
+			parts[i] = normalized
+		}
+
+		q.Filters = append(q.Filters, Filter{Field: name, Op: op, Values: parts})
+	}
+
+	return q, nil
+}
+
+// Unpaged returns a query with the spec's default sort and no limit, for
+// callers that need the whole collection (e.g. building a tree).
+func Unpaged(spec *Spec) *Query {
+	q, err := Parse(spec, url.Values{})
+	if err != nil {
+		panic(fmt.Sprintf("invalid default sort %q: %v", spec.DefaultSort, err))
+	}
+	q.Limit = 0
+	return q
+}
+
+// Fetch counts the filtered rows of base and runs the requested page. base
+// must be a complete SELECT; args are its placeholders. scan is called for
+// each row of the page and must scan every base column followed by one
+// string destination for the row's cursor.
+func Fetch(conn *pgxpool.Conn, q *Query, base string, args []any, scan func(rows pgx.Rows, cursor *string) error) (*PageInfo, error) {
+	where, whereArgs := q.where(len(args) + 1)
+	args = append(args, whereArgs...)
+
+	page := &PageInfo{}
+	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s) q WHERE TRUE%s", base, where)
+	if err := conn.QueryRow(context.Background(), countSQL, args...).Scan(&page.Total); err != nil {
+		logger.Log.Errorf("Error counting list rows: %v", err)
+		return nil, err
+	}
+
+	keys := q.keys()
+	cols := make([]string, len(keys))
+	for i, k := range keys {
+		cols[i] = "q." + k.field.Column
+	}
+
+	pageSQL := fmt.Sprintf(
+		"SELECT q.*, json_build_array(%s)::text FROM (%s) q WHERE TRUE%s",
+		strings.Join(cols, ", "), base, where,
+	)
+
+	if len(q.after) > 0 {
+		cond, condArgs := q.afterCondition(len(args) + 1)
+		pageSQL += " AND " + cond
+		args = append(args, condArgs...)
+	}
+
+	terms := make([]string, len(keys))
+	for i, k := range keys {
+		terms[i] = "q." + k.field.Column
+		if k.desc {
+			terms[i] += " DESC"
+		}
+	}
+	pageSQL += " ORDER BY " + strings.Join(terms, ", ")
+
+	if q.Limit > 0 {
+		// One extra row tells whether there is a next page
+		pageSQL += fmt.Sprintf(" LIMIT %d", q.Limit+1)
+	}
+	if q.Offset > 0 {
+		pageSQL += fmt.Sprintf(" OFFSET %d", q.Offset)
+	}
+
+	logger.Log.DebugSQL(pageSQL, args...)
+
+	rows, err := conn.Query(context.Background(), pageSQL, args...)
+	if err != nil {
+		logger.Log.Errorf("Error querying list page: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var last string
+	var n uint64
+	for rows.Next() {
+		if q.Limit > 0 && n == q.Limit {
+			page.NextCursor = q.encodeCursor(last)
+			break
+		}
+		if err := scan(rows, &last); err != nil {
+			logger.Log.Errorf("Error scanning list row: %v", err)
+			return nil, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+type key struct {
+	field Field
+	desc  bool
+}
+
+// keys is the effective ordering: requested sorts plus the tie-breaker,
+// which follows the direction of the last sort term.
+func (q *Query) keys() []key {
+	keys := make([]key, 0, len(q.Sort)+1)
+	desc := false
+	for _, s := range q.Sort {
+		f := q.spec.Sorts[s.Field]
+		keys = append(keys, key{field: f, desc: s.Desc})
+		desc = s.Desc
+		if f.Column == q.spec.Key.Column {
+			return keys
+		}
+	}
+	return append(keys, key{field: q.spec.Key, desc: desc})
+}
+
+func (q *Query) where(firstArg int) (string, []any) {
+	var sb strings.Builder
+	var args []any
+
+	for _, f := range q.Filters {
+		field := q.spec.Filters[f.Field]
+		n := firstArg + len(args)
+		switch f.Op {
+		case "min":
+			fmt.Fprintf(&sb, " AND q.%s >= $%d::%s", field.Column, n, field.Type)
+			args = append(args, f.Values[0])
+		case "max":
+			fmt.Fprintf(&sb, " AND q.%s <= $%d::%s", field.Column, n, field.Type)
+			args = append(args, f.Values[0])
+		default:
+			fmt.Fprintf(&sb, " AND q.%s = ANY($%d::%s[])", field.Column, n, field.Type)
+			args = append(args, f.Values)
+		}
+	}
+
+	return sb.String(), args
+}
+
+// afterCondition renders the keyset condition for rows strictly after the
+// cursor under a possibly mixed-direction ordering:
+// (a > $1) OR (a = $1 AND b < $2) OR ...
+func (q *Query) afterCondition(firstArg int) (string, []any) {
+	keys := q.keys()
+	args := make([]any, len(keys))
+	for i := range keys {
+		args[i] = q.after[i]
+	}
+
+	var ors []string
+	for i, k := range keys {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, fmt.Sprintf("q.%s = $%d::%s", keys[j].field.Column, firstArg+j, keys[j].field.Type))
+		}
+		cmp := ">"
+		if k.desc {
+			cmp = "<"
+		}
+		ands = append(ands, fmt.Sprintf("q.%s %s $%d::%s", k.field.Column, cmp, firstArg+i, k.field.Type))
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+func (q *Query) sortString() string {
+	terms := make([]string, len(q.Sort))
+	for i, s := range q.Sort {
+		terms[i] = s.Field
+		if s.Desc {
+			terms[i] = "-" + s.Field
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+// encodeCursor wraps the JSON array of key values built by Postgres.
+func (q *Query) encodeCursor(values string) string {
+	dec := json.NewDecoder(strings.NewReader(values))
+	dec.UseNumber()
+	var raw []any
+	if err := dec.Decode(&raw); err != nil {
+		logger.Log.Errorf("Error decoding cursor values: %v", err)
+		return ""
+	}
+
+	c := cursor{Sort: q.sortString(), Values: make([]string, len(raw))}
+	for i, v := range raw {
+		c.Values[i] = fmt.Sprint(v)
+	}
+
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (q *Query) decodeCursor(raw string) ([]string, error) {
+	invalid := &dataErrors.InvalidListQuery{Param: ParamCursor, Reason: "malformed cursor"}
+
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var c cursor
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, invalid
+	}
+	if c.Sort != q.sortString() {
+		return nil, &dataErrors.InvalidListQuery{Param: ParamCursor, Reason: "cursor was issued for a different sort"}
+	}
+	if len(c.Values) != len(q.keys()) {
+		return nil, invalid
+	}
+
+	return c.Values, nil
+}
+
+func normalize(t FieldType, v string) (string, error) {
+	switch t {
+	case FieldInt:
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			return "", fmt.Errorf("expects an integer")
+		}
+	case FieldNumber:
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return "", fmt.Errorf("expects a number")
+		}
+	case FieldBool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return "", fmt.Errorf("expects true or false")
+		}
+		return strconv.FormatBool(b), nil
+	case FieldTime:
+		if _, err := time.Parse(time.RFC3339, v); err == nil {
+			return v, nil
+		}
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			return "", fmt.Errorf("expects a YYYY-MM-DD date or an RFC 3339 timestamp")
+		}
+	}
+	return v, nil
+}
+
+func allowed(fields map[string]Field) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,282 @@
+// Package decimal provides an exact base-10 number for quantities and prices.
+// Values round-trip with Postgres NUMERIC columns as text and are written to
+// JSON as plain numbers, so no binary floating point is involved between the
+// request body and the database.
+package decimal
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is coef * 10^-scale. The zero value is 0. Decimals are immutable:
+// every operation returns a new value.
+type Decimal struct {
+	coef  *big.Int
+	scale int32
+}
+
+// Zero is the decimal 0.
+var Zero = Decimal{}
+
+var ten = big.NewInt(10)
+
+// NewFromInt returns the decimal for an integer.
+func NewFromInt(v int64) Decimal {
+	return Decimal{coef: big.NewInt(v)}
+}
+
+// Limits on parsed literals. The columns behind these values are at most
+// NUMERIC(14,2), so anything past them is a typo or an attempt to make the
+// big.Int arithmetic blow up.
+const (
+	maxDigits   = 40
+	maxExponent = 20
+	maxScale    = 30
+)
+
+// NewFromString parses a plain or exponent decimal literal, e.g. "12.50",
+// "-3" or "1.5e2". Literals with more than 40 digits, an exponent beyond
+// ±20 or more than 30 decimal places are rejected.
+func NewFromString(s string) (Decimal, error) {
+	return parse(s, true)
+}
+
+func parse(s string, limited bool) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Zero, fmt.Errorf("decimal: empty string")
+	}
+
+	var exp int64
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return Zero, fmt.Errorf("decimal: invalid exponent in %q", s)
+		}
+		if limited && (e > maxExponent || e < -maxExponent) {
+			return Zero, fmt.Errorf("decimal: exponent out of range in %q", s)
+		}
+		exp = e
+		s = s[:i]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	digits := intPart + fracPart
+	if digits == "" || digits == "-" || digits == "+" {
+		return Zero, fmt.Errorf("decimal: invalid number %q", s)
+	}
+	if limited && len(strings.TrimLeft(digits, "+-")) > maxDigits {
+		return Zero, fmt.Errorf("decimal: too many digits in %q", s)
+	}
+
+	coef, ok := new(big.Int).SetString(digits, 10)
+	if !ok || strings.ContainsAny(fracPart, "+-") {
+		return Zero, fmt.Errorf("decimal: invalid number %q", s)
+	}
+
+	scale := int64(len(fracPart)) - exp
+	if limited && scale > maxScale {
+		return Zero, fmt.Errorf("decimal: too many decimal places in %q", s)
+	}
+	if scale < 0 {
+		coef.Mul(coef, pow10(int32(-scale)))
+		scale = 0
+	}
+
+	return Decimal{coef: coef, scale: int32(scale)}, nil
+}
+
+// RequireFromString is NewFromString for literals known to be valid.
+func RequireFromString(s string) Decimal {
+	d, err := NewFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// NewFromFloat converts using the shortest representation that reads back
+// as f, so 0.1 becomes exactly 0.1.
+func NewFromFloat(f float64) Decimal {
+	d, err := parse(strconv.FormatFloat(f, 'f', -1, 64), false)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// NewFromUnits returns units * 10^-scale, the inverse of Units.
+func NewFromUnits(units int64, scale int32) Decimal {
+	return Decimal{coef: big.NewInt(units), scale: scale}
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(ten, big.NewInt(int64(n)), nil)
+}
+
+func (d Decimal) int() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// rescale returns the coefficient of d expressed with the given larger scale.
+func (d Decimal) rescale(scale int32) *big.Int {
+	c := new(big.Int).Set(d.int())
+	if scale > d.scale {
+		c.Mul(c, pow10(scale-d.scale))
+	}
+	return c
+}
+
+func align(a, b Decimal) (*big.Int, *big.Int, int32) {
+	scale := max(a.scale, b.scale)
+	return a.rescale(scale), b.rescale(scale), scale
+}
+
+// Add returns d + o.
+func (d Decimal) Add(o Decimal) Decimal {
+	x, y, scale := align(d, o)
+	return Decimal{coef: x.Add(x, y), scale: scale}
+}
+
+// Sub returns d - o.
+func (d Decimal) Sub(o Decimal) Decimal {
+	x, y, scale := align(d, o)
+	return Decimal{coef: x.Sub(x, y), scale: scale}
+}
+
+// Mul returns d * o.
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.int(), o.int()), scale: d.scale + o.scale}
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+// Cmp returns -1, 0 or +1 as d is less than, equal to or greater than o.
+func (d Decimal) Cmp(o Decimal) int {
+	x, y, _ := align(d, o)
+	return x.Cmp(y)
+}
+
+// Equal reports whether d and o are the same number, regardless of scale.
+func (d Decimal) Equal(o Decimal) bool { return d.Cmp(o) == 0 }
+
+// Sign returns -1, 0 or +1.
+func (d Decimal) Sign() int { return d.int().Sign() }
+
+// IsZero reports whether d is 0.
+func (d Decimal) IsZero() bool { return d.Sign() == 0 }
+
+// IsInteger reports whether d has no fractional part.
+func (d Decimal) IsInteger() bool {
+	if d.scale <= 0 {
+		return true
+	}
+	return new(big.Int).Rem(d.int(), pow10(d.scale)).Sign() == 0
+}
+
+// Scale returns the number of digits kept after the decimal point.
+func (d Decimal) Scale() int32 { return d.scale }
+
+// Units returns d as a whole number of 10^-scale steps, e.g. 12.5 with scale
+// 2 is 1250. It reports false when d has more decimal places than scale.
+func (d Decimal) Units(scale int32) (*big.Int, bool) {
+	if scale >= d.scale {
+		return d.rescale(scale), true
+	}
+	r := d.Round(scale)
+	return r.int(), r.Equal(d)
+}
+
+// Round rounds half away from zero to the given number of decimal places.
+func (d Decimal) Round(places int32) Decimal {
+	if places >= d.scale {
+		return d
+	}
+	div := pow10(d.scale - places)
+	q, r := new(big.Int).QuoRem(d.int(), div, new(big.Int))
+	// |r| * 2 >= div rounds away from zero
+	if r.Abs(r).Lsh(r, 1).Cmp(div) >= 0 {
+		if d.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return Decimal{coef: q, scale: places}
+}
+
+// Float64 returns the nearest float64, for display or non-monetary maths only.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String renders d in plain notation keeping its scale, e.g. "12.50".
+func (d Decimal) String() string {
+	s := new(big.Int).Abs(d.int()).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) - len(s) + 1; pad > 0 {
+			s = strings.Repeat("0", pad) + s
+		}
+		s = s[:len(s)-int(d.scale)] + "." + s[len(s)-int(d.scale):]
+	}
+	if d.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// MarshalJSON writes d as a JSON number.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a quoted decimal string.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		*d = Zero
+		return nil
+	}
+	parsed, err := NewFromString(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner. pgx hands NUMERIC values over as text.
+func (d *Decimal) Scan(src any) error {
+	var err error
+	switch v := src.(type) {
+	case nil:
+		*d = Zero
+	case string:
+		*d, err = NewFromString(v)
+	case []byte:
+		*d, err = NewFromString(string(v))
+	case int64:
+		*d = NewFromInt(v)
+	case float64:
+		*d = NewFromFloat(v)
+	default:
+		err = fmt.Errorf("decimal: cannot scan %T", src)
+	}
+	return err
+}
+
+// Value implements driver.Valuer so NUMERIC parameters are sent as text.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
@@ -0,0 +1,149 @@
+package decimal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewFromString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"0", "0"},
+		{"12.50", "12.50"},
+		{"-3", "-3"},
+		{"+7.1", "7.1"},
+		{" 4.25 ", "4.25"},
+		{".5", "0.5"},
+		{"-0.05", "-0.05"},
+		{"1.5e2", "150"},
+		{"1.5E-2", "0.015"},
+		{"25e-1", "2.5"},
+		{"1e20", "100000000000000000000"},
+	}
+	for _, tt := range tests {
+		d, err := NewFromString(tt.in)
+		if err != nil {
+			t.Errorf("NewFromString(%q) returned error: %v", tt.in, err)
+			continue
+		}
+		if got := d.String(); got != tt.want {
+			t.Errorf("NewFromString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewFromStringRejects(t *testing.T) {
+	tests := []string{
+		"",
+		"-",
+		".",
+		"abc",
+		"1.2.3",
+		"1.-2",
+		"1e",
+		"1e1.5",
+		"1e21",
+		"1e-21",
+		"1e999999999",
+		"1e-999999999",
+		"0." + strings.Repeat("1", 31),
+		strings.Repeat("9", 41),
+	}
+	for _, in := range tests {
+		if d, err := NewFromString(in); err == nil {
+			t.Errorf("NewFromString(%q) = %s, want error", in, d)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		in     string
+		places int32
+		want   string
+	}{
+		{"1.234", 2, "1.23"},
+		{"1.235", 2, "1.24"},
+		{"-1.235", 2, "-1.24"},
+		{"-1.234", 2, "-1.23"},
+		{"0.5", 0, "1"},
+		{"-0.5", 0, "-1"},
+		{"0.49", 0, "0"},
+		{"2.5", 3, "2.5"},
+	}
+	for _, tt := range tests {
+		if got := RequireFromString(tt.in).Round(tt.places).String(); got != tt.want {
+			t.Errorf("%s.Round(%d) = %s, want %s", tt.in, tt.places, got, tt.want)
+		}
+	}
+}
+
+func TestCmp(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1", "1.00", 0},
+		{"1.01", "1.1", -1},
+		{"-2", "-10", 1},
+		{"0", "-0.00", 0},
+		{"150", "1.5e2", 0},
+	}
+	for _, tt := range tests {
+		if got := RequireFromString(tt.a).Cmp(RequireFromString(tt.b)); got != tt.want {
+			t.Errorf("Cmp(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if !Zero.Equal(RequireFromString("0.000")) {
+		t.Errorf("Zero is not equal to 0.000")
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a := RequireFromString("12.50")
+	b := RequireFromString("0.125")
+
+	if got := a.Add(b).String(); got != "12.625" {
+		t.Errorf("Add = %s, want 12.625", got)
+	}
+	if got := b.Sub(a).String(); got != "-12.375" {
+		t.Errorf("Sub = %s, want -12.375", got)
+	}
+	if got := a.Mul(b).String(); got != "1.56250" {
+		t.Errorf("Mul = %s, want 1.56250", got)
+	}
+}
+
+func TestUnits(t *testing.T) {
+	u, ok := RequireFromString("12.5").Units(2)
+	if !ok || u.String() != "1250" {
+		t.Errorf("Units(2) = %s, %v, want 1250, true", u, ok)
+	}
+	if _, ok := RequireFromString("0.125").Units(2); ok {
+		t.Errorf("Units(2) of 0.125 reported exact")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var d Decimal
+	for _, in := range []string{`12.50`, `"12.50"`} {
+		if err := d.UnmarshalJSON([]byte(in)); err != nil {
+			t.Fatalf("UnmarshalJSON(%s) returned error: %v", in, err)
+		}
+		b, _ := d.MarshalJSON()
+		if string(b) != "12.50" {
+			t.Errorf("round trip of %s = %s, want 12.50", in, b)
+		}
+	}
+	if err := d.UnmarshalJSON([]byte(`1e1000000000`)); err == nil {
+		t.Errorf("UnmarshalJSON accepted an out of range exponent")
+	}
+}
+
+func TestNewFromFloat(t *testing.T) {
+	if got := NewFromFloat(0.1).String(); got != "0.1" {
+		t.Errorf("NewFromFloat(0.1) = %s, want 0.1", got)
+	}
+}
@@ -0,0 +1,50 @@
+package mapper
+
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+func CreateAPIKeyToModel(req *request.CreateAPIKeyRequest) *model.APIKey {
+	scopes := make([]model.Permission, len(req.Scopes))
+	for i, s := range req.Scopes {
+		scopes[i] = model.Permission(s)
+	}
+
+	return &model.APIKey{
+		Name:      req.Name,
+		Scopes:    scopes,
+		StoreID:   req.StoreID,
+		ExpiresAt: req.ExpiresAt,
+	}
+}
+
+func ToAPIKeyResponse(m *model.APIKey) response.APIKeyResponse {
+	scopes := make([]string, len(m.Scopes))
+	for i, s := range m.Scopes {
+		scopes[i] = string(s)
+	}
+
+	return response.APIKeyResponse{
+		ID:         m.ID,
+		Name:       m.Name,
+		Prefix:     m.Prefix,
+		Scopes:     scopes,
+		StoreID:    m.StoreID,
+		CreatedBy:  m.CreatedBy,
+		ExpiresAt:  m.ExpiresAt,
+		LastUsedAt: m.LastUsedAt,
+		LastUsedIP: m.LastUsedIP,
+		RevokedAt:  m.RevokedAt,
+		CreatedAt:  m.CreatedAt,
+	}
+}
+
+func ToAPIKeyResponseList(ms []model.APIKey) []response.APIKeyResponse {
+	out := make([]response.APIKeyResponse, len(ms))
+	for i := range ms {
+		out[i] = ToAPIKeyResponse(&ms[i])
+	}
+	return out
+}
@@ -0,0 +1,20 @@
+package mapper
+
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+func ToAuditEntryResponse(m *model.AuditEntry) response.AuditEntryResponse {
+	return response.AuditEntryResponse{
+		ID:         m.ID,
+		OccurredAt: m.OccurredAt,
+		UserID:     m.UserID,
+		APIKeyID:   m.APIKeyID,
+		EntityType: m.EntityType,
+		EntityID:   m.EntityID,
+		Action:     m.Action,
+		Before:     m.Before,
+		After:      m.After,
+	}
+}
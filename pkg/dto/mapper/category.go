@@ -9,6 +9,7 @@ import (
 func CreateCategoryToModel(req *request.CreateCategoryRequest, ownerID, storeID uint) *model.Category {
 	return &model.Category{
 		Description: req.Description,
+		ParentID:    req.ParentID,
 		CreatedBy:   model.User{ID: ownerID},
 		Store:       model.Store{ID: storeID},
 	}
@@ -26,7 +27,33 @@ func ToCategoryResponse(m *model.Category) *response.CategoryResponse {
 	return &response.CategoryResponse{
 		ID:          m.ID,
 		Description: m.Description,
+		ParentID:    m.ParentID,
 		CreatedAt:   m.CreatedAt,
 		UpdatedAt:   m.UpdatedAt,
+		ArchivedAt:  m.ArchivedAt,
 	}
 }
+
+// ToCategoryTreeResponse nests a flat category list under each parent.
+// Categories whose parent is not in the list (e.g. an archived parent that
+// was filtered out) are returned as roots so nothing gets dropped.
+func ToCategoryTreeResponse(cats []*model.Category) []*response.CategoryResponse {
+	nodes := make(map[uint]*response.CategoryResponse, len(cats))
+	for _, cat := range cats {
+		nodes[cat.ID] = ToCategoryResponse(cat)
+	}
+
+	roots := []*response.CategoryResponse{}
+	for _, cat := range cats {
+		node := nodes[cat.ID]
+		if cat.ParentID != nil {
+			if parent, ok := nodes[*cat.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	return roots
+}
@@ -0,0 +1,41 @@
+package mapper
+
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+func ToInvitationResponse(m *model.Invitation) response.InvitationResponse {
+	return response.InvitationResponse{
+		ID:         m.ID,
+		Email:      m.Email,
+		Role:       string(m.Role),
+		StoreID:    m.StoreID,
+		Status:     m.Status(),
+		InvitedBy:  m.InvitedBy,
+		ExpiresAt:  m.ExpiresAt,
+		LastSentAt: m.LastSentAt,
+		AcceptedAt: m.AcceptedAt,
+		AcceptedBy: m.AcceptedBy,
+		RevokedAt:  m.RevokedAt,
+		CreatedAt:  m.CreatedAt,
+	}
+}
+
+func ToInvitationResponseList(ms []model.Invitation) []response.InvitationResponse {
+	out := make([]response.InvitationResponse, len(ms))
+	for i := range ms {
+		out[i] = ToInvitationResponse(&ms[i])
+	}
+	return out
+}
+
+func ToInvitationPreviewResponse(m *model.Invitation) response.InvitationPreviewResponse {
+	return response.InvitationPreviewResponse{
+		OrganizationName: m.Organization.Name,
+		Email:            m.Email,
+		Role:             string(m.Role),
+		StoreID:          m.StoreID,
+		ExpiresAt:        m.ExpiresAt,
+	}
+}
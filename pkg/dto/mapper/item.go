@@ -14,6 +14,7 @@ func CreateItemToModel(req *request.CreateItemRequest, ownerID, storeID uint) *m
 		Category:       model.Category{ID: req.CategoryID},
 		UnitOfMeasure:  model.UnitOfMeasure{ID: req.UnitOfMeasureID},
 		IsFractionable: req.IsFractionable,
+		Attributes:     []model.ItemAttributeValue{},
 		CreatedBy:      model.User{ID: ownerID},
 		Store:          model.Store{ID: storeID},
 	}
@@ -40,13 +41,17 @@ func ToItemResponse(m *model.Item) response.ItemResponse {
 		Category: response.CategoryResponse{
 			ID:          m.Category.ID,
 			Description: m.Category.Description,
+			ArchivedAt:  m.Category.ArchivedAt,
 		},
 		UnitOfMeasure: response.UnitOfMeasureResponse{
 			ID:          m.UnitOfMeasure.ID,
 			Description: m.UnitOfMeasure.Description,
+			ArchivedAt:  m.UnitOfMeasure.ArchivedAt,
 		},
 		IsFractionable: m.IsFractionable,
+		Attributes:     ToItemAttributeValuesResponse(m.Attributes),
 		CreatedAt:      m.CreatedAt,
 		UpdatedAt:      m.UpdatedAt,
+		ArchivedAt:     m.ArchivedAt,
 	}
 }
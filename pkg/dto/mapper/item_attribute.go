@@ -0,0 +1,68 @@
+package mapper
+
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+func CreateItemAttributeDefinitionToModel(req *request.CreateItemAttributeDefinitionRequest, userID, storeID uint) *model.ItemAttributeDefinition {
+	return &model.ItemAttributeDefinition{
+		StoreID:   storeID,
+		Key:       req.Key,
+		Label:     req.Label,
+		Type:      req.Type,
+		Options:   req.Options,
+		Required:  req.Required,
+		Min:       req.Min,
+		Max:       req.Max,
+		CreatedBy: model.User{ID: userID},
+	}
+}
+
+func UpdateItemAttributeDefinitionToModel(req *request.UpdateItemAttributeDefinitionRequest) *model.ItemAttributeDefinition {
+	return &model.ItemAttributeDefinition{
+		ID:       req.ID,
+		Label:    req.Label,
+		Options:  req.Options,
+		Required: req.Required,
+		Min:      req.Min,
+		Max:      req.Max,
+	}
+}
+
+func ToItemAttributeDefinitionResponse(m *model.ItemAttributeDefinition) response.ItemAttributeDefinitionResponse {
+	return response.ItemAttributeDefinitionResponse{
+		ID:        m.ID,
+		Key:       m.Key,
+		Label:     m.Label,
+		Type:      m.Type,
+		Options:   m.Options,
+		Required:  m.Required,
+		Min:       m.Min,
+		Max:       m.Max,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+}
+
+// ToItemAttributeValuesResponse flattens attribute values into a key → value
+// map; dates are rendered as YYYY-MM-DD.
+func ToItemAttributeValuesResponse(values []model.ItemAttributeValue) map[string]any {
+	if len(values) == 0 {
+		return nil
+	}
+
+	attrs := make(map[string]any, len(values))
+	for _, v := range values {
+		switch {
+		case v.Number != nil:
+			attrs[v.Definition.Key] = *v.Number
+		case v.Date != nil:
+			attrs[v.Definition.Key] = v.Date.Format(model.AttributeDateLayout)
+		case v.Text != nil:
+			attrs[v.Definition.Key] = *v.Text
+		}
+	}
+	return attrs
+}
@@ -8,28 +8,34 @@ import (
 
 func CreateItemPackagingToModel(r *request.CreateItemPackagingRequest, OwnerID, StoreID uint) *model.ItemPackaging {
 	return &model.ItemPackaging{
-		Description: r.Description,
-		Quantity:    r.Quantity,
-		Item:        model.Item{ID: r.ItemID},
-		CreatedBy:   model.User{ID: OwnerID},
-		Store:       model.Store{ID: StoreID},
+		Description:      r.Description,
+		Quantity:         r.Quantity,
+		InnerPackagingID: r.InnerPackagingID,
+		InnerQuantity:    r.InnerQuantity,
+		Item:             model.Item{ID: r.ItemID},
+		CreatedBy:        model.User{ID: OwnerID},
+		Store:            model.Store{ID: StoreID},
 	}
 }
 
 func UpdateItemPackagingToModel(r *request.UpdateItemPackagingRequest) *model.ItemPackaging {
 	return &model.ItemPackaging{
-		ID:          r.ID,
-		Description: r.Description,
-		Quantity:    r.Quantity,
-		Item:        model.Item{ID: r.ItemID},
+		ID:               r.ID,
+		Description:      r.Description,
+		Quantity:         r.Quantity,
+		InnerPackagingID: r.InnerPackagingID,
+		InnerQuantity:    r.InnerQuantity,
+		Item:             model.Item{ID: r.ItemID},
 	}
 }
 
 func ToItemPackagingResponse(m *model.ItemPackaging) response.ItemPackagingResponse {
 	return response.ItemPackagingResponse{
-		ID:          m.ID,
-		Description: m.Description,
-		Quantity:    m.Quantity,
+		ID:               m.ID,
+		Description:      m.Description,
+		Quantity:         m.Quantity,
+		InnerPackagingID: m.InnerPackagingID,
+		InnerQuantity:    m.InnerQuantity,
 		Item: response.ItemResponse{ID: m.Item.ID,
 			Description: m.Item.Description,
 			UnitOfMeasure: response.UnitOfMeasureResponse{
@@ -40,6 +46,29 @@ func ToItemPackagingResponse(m *model.ItemPackaging) response.ItemPackagingRespo
 				ID:          m.Item.Category.ID,
 				Description: m.Item.Category.Description,
 			},
+			ArchivedAt: m.Item.ArchivedAt,
 		},
+		ArchivedAt: m.ArchivedAt,
+	}
+}
+
+func ToPackagingBreakdownResponse(m *model.PackagingBreakdown) response.PackagingBreakdownResponse {
+	lines := make([]response.PackagingBreakdownLineResponse, 0, len(m.Lines))
+	for _, l := range m.Lines {
+		lines = append(lines, response.PackagingBreakdownLineResponse{
+			ItemPackaging: ToItemPackagingResponse(&l.ItemPackaging),
+			Quantity:      l.Quantity,
+			Held:          l.Held,
+		})
+	}
+
+	return response.PackagingBreakdownResponse{
+		ItemID:            m.ItemID,
+		RequestedQuantity: m.Requested,
+		TotalQuantity:     m.Total,
+		Remainder:         m.Remainder,
+		Exact:             m.Exact,
+		PackageCount:      m.PackageCount,
+		Packagings:        lines,
 	}
 }
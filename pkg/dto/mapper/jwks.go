@@ -0,0 +1,32 @@
+package mapper
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/util"
+)
+
+func ToJWKSResponse(keys []util.JWTKey) response.JWKSResponse {
+	resp := response.JWKSResponse{Keys: []response.JSONWebKey{}}
+	for _, key := range keys {
+		jwk := response.JSONWebKey{Kid: key.ID, Use: "sig", Alg: key.Alg}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		resp.Keys = append(resp.Keys, jwk)
+	}
+	return resp
+}
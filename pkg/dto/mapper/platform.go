@@ -0,0 +1,58 @@
+package mapper
+
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+func ToPlatformOrganizationResponse(m *model.OrganizationUsage) response.PlatformOrganizationResponse {
+	return response.PlatformOrganizationResponse{
+		ID:                 m.ID,
+		Name:               m.Name,
+		Key:                m.Key,
+		Domain:             m.Domain,
+		Schema:             m.DBSchema,
+		IsActive:           m.IsActive,
+		IsTryOut:           m.IsTryOut,
+		ExpiresAt:          m.ExpiresAt,
+		RequireTwoFactor:   m.RequireTwoFactor,
+		UserCount:          m.UserCount,
+		ActiveUserCount:    m.ActiveUserCount,
+		ActiveSessionCount: m.ActiveSessionCount,
+		APIKeyCount:        m.APIKeyCount,
+		LastLoginAt:        m.LastLoginAt,
+		StoreCount:         m.StoreCount,
+		ItemCount:          m.ItemCount,
+		StockInCount:       m.StockInCount,
+		StockOutCount:      m.StockOutCount,
+	}
+}
+
+func ToPlatformUserResponse(m *model.OrganizationUser) response.PlatformUserResponse {
+	var role *string
+	if m.Role != nil {
+		r := string(*m.Role)
+		role = &r
+	}
+
+	return response.PlatformUserResponse{
+		ID:               m.ID,
+		Username:         m.Username,
+		Email:            m.Email,
+		AuthProvider:     m.AuthProvider,
+		IsActive:         m.IsActive,
+		CreatedAt:        m.CreatedAt,
+		LastLogin:        m.LastLogin,
+		EmailVerifiedAt:  m.EmailVerifiedAt,
+		TwoFactorEnabled: m.TwoFactor,
+		Role:             role,
+	}
+}
+
+func ToPlatformUserResponseList(ms []model.OrganizationUser) []response.PlatformUserResponse {
+	resp := make([]response.PlatformUserResponse, len(ms))
+	for i := range ms {
+		resp[i] = ToPlatformUserResponse(&ms[i])
+	}
+	return resp
+}
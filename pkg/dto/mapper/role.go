@@ -0,0 +1,65 @@
+package mapper
+
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+func ToRoleAssignmentResponse(m *model.RoleAssignment) response.RoleAssignmentResponse {
+	return response.RoleAssignmentResponse{
+		ID: m.ID,
+		User: response.UserResponse{
+			ID:    m.User.ID,
+			Name:  m.User.GivenName,
+			Email: m.User.Email,
+		},
+		StoreID:    m.StoreID,
+		Role:       string(m.Role),
+		AssignedBy: m.AssignedBy,
+		CreatedAt:  m.CreatedAt,
+		UpdatedAt:  m.UpdatedAt,
+	}
+}
+
+func ToRoleAssignmentResponseList(ms []model.RoleAssignment) []response.RoleAssignmentResponse {
+	out := make([]response.RoleAssignmentResponse, len(ms))
+	for i := range ms {
+		out[i] = ToRoleAssignmentResponse(&ms[i])
+	}
+	return out
+}
+
+func ToPermissionList(role model.Role) []string {
+	perms := role.Permissions()
+	out := make([]string, len(perms))
+	for i, p := range perms {
+		out[i] = string(p)
+	}
+	return out
+}
+
+// ToMyRolesResponse splits a user's role assignments into the organization
+// role and the per-store overrides.
+func ToMyRolesResponse(assignments []model.RoleAssignment) response.MyRolesResponse {
+	resp := response.MyRolesResponse{
+		Permissions: []string{},
+		Stores:      []response.StoreRoleResponse{},
+	}
+
+	for _, a := range assignments {
+		if a.StoreID == nil {
+			role := string(a.Role)
+			resp.OrganizationRole = &role
+			resp.Permissions = ToPermissionList(a.Role)
+			continue
+		}
+
+		resp.Stores = append(resp.Stores, response.StoreRoleResponse{
+			StoreID:     *a.StoreID,
+			Role:        string(a.Role),
+			Permissions: ToPermissionList(a.Role),
+		})
+	}
+
+	return resp
+}
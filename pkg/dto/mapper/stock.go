@@ -10,11 +10,60 @@ func ToStockResponse(m *model.Stock) *response.StockResponse {
 	if m == nil {
 		return nil
 	}
-	return &response.StockResponse{
+	resp := &response.StockResponse{
 		ID:           m.ID,
 		Item:         ToItemResponse(&m.Item),
 		CurrentStock: m.CurrentStock,
 		CreatedAt:    m.CreatedAt,
 		UpdatedAt:    m.UpdatedAt,
 	}
+
+	// Locations is only loaded when the breakdown was requested
+	if m.Locations != nil {
+		unassigned := m.Unassigned
+		resp.Unassigned = &unassigned
+		resp.Locations = make([]response.StockLocationResponse, 0, len(m.Locations))
+		for _, l := range m.Locations {
+			resp.Locations = append(resp.Locations, response.StockLocationResponse{
+				Location: *ToStorageLocationResponse(&l.Location),
+				Quantity: l.Quantity,
+			})
+		}
+	}
+
+	return resp
+}
+
+// ToCategoryStockSummaryTreeResponse nests the per-category summaries under
+// their parents, returning the root nodes.
+func ToCategoryStockSummaryTreeResponse(sums []model.CategoryStockSummary) []*response.CategoryStockSummaryResponse {
+	nodes := make(map[uint]*response.CategoryStockSummaryResponse, len(sums))
+	for _, m := range sums {
+		nodes[m.Category.ID] = &response.CategoryStockSummaryResponse{
+			CategoryID:     m.Category.ID,
+			Description:    m.Category.Description,
+			ParentID:       m.Category.ParentID,
+			ArchivedAt:     m.Category.ArchivedAt,
+			ItemCount:      m.ItemCount,
+			Quantity:       m.Quantity,
+			Value:          m.Value,
+			TotalItemCount: m.TotalItemCount,
+			TotalQuantity:  m.TotalQuantity,
+			TotalValue:     m.TotalValue,
+		}
+	}
+
+	roots := []*response.CategoryStockSummaryResponse{}
+	for _, m := range sums {
+		node := nodes[m.Category.ID]
+		if m.Category.ParentID != nil {
+			if parent, ok := nodes[*m.Category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	return roots
 }
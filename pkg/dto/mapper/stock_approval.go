@@ -0,0 +1,56 @@
+package mapper
+
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+func ToApprovalDecisionResponse(m *model.ApprovalDecision) response.ApprovalDecisionResponse {
+	return response.ApprovalDecisionResponse{
+		ID:           m.ID,
+		DocumentType: string(m.DocumentType),
+		DocumentID:   m.DocumentID,
+		Action:       m.Action,
+		Comment:      m.Comment,
+		DecidedBy: response.UserResponse{
+			ID:    m.DecidedBy.ID,
+			Name:  m.DecidedBy.Username,
+			Email: m.DecidedBy.Email,
+		},
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+// ToApprovalDecisionResponses keeps the history order and returns an empty
+// list for documents without decisions.
+func ToApprovalDecisionResponses(decisions []model.ApprovalDecision) []response.ApprovalDecisionResponse {
+	out := make([]response.ApprovalDecisionResponse, 0, len(decisions))
+	for i := range decisions {
+		out = append(out, ToApprovalDecisionResponse(&decisions[i]))
+	}
+	return out
+}
+
+func UpdateApprovalPolicyToModel(req *request.UpdateApprovalPolicyRequest, storeID uint) *model.ApprovalPolicy {
+	return &model.ApprovalPolicy{
+		StoreID:               storeID,
+		Enabled:               req.Enabled,
+		RequireForWaste:       req.RequireForWaste,
+		RequireForStockOut:    req.RequireForStockOut,
+		StockInValueThreshold: req.StockInValueThreshold,
+		AllowSelfApproval:     req.AllowSelfApproval,
+	}
+}
+
+func ToApprovalPolicyResponse(m *model.ApprovalPolicy) response.ApprovalPolicyResponse {
+	return response.ApprovalPolicyResponse{
+		StoreID:               m.StoreID,
+		Enabled:               m.Enabled,
+		RequireForWaste:       m.RequireForWaste,
+		RequireForStockOut:    m.RequireForStockOut,
+		StockInValueThreshold: m.StockInValueThreshold,
+		AllowSelfApproval:     m.AllowSelfApproval,
+		UpdatedAt:             m.UpdatedAt,
+	}
+}
@@ -23,6 +23,7 @@ func CreateStockInToModel(r *request.CreateStockInRequest) *model.StockIn {
 			Item:          model.Item{ID: itr.ItemID},
 			BuyPrice:      itr.BuyPrice,
 			TotalQuantity: itr.TotalQuantity,
+			Location:      toStorageLocationRef(itr.LocationID),
 			Packagings:    packagings,
 		}
 
@@ -53,6 +54,7 @@ func UpdateStockInToModel(r *request.UpdateStockInRequest) *model.StockIn {
 			Item:          model.Item{ID: itr.ItemID},
 			BuyPrice:      itr.BuyPrice,
 			TotalQuantity: itr.TotalQuantity,
+			Location:      toStorageLocationRef(itr.LocationID),
 			Packagings:    packagings,
 		}
 
@@ -83,17 +85,20 @@ func ToStockInResponse(m *model.StockIn) *response.StockInResponse {
 			Item:          ToItemResponse(&i.Item),
 			BuyPrice:      i.BuyPrice,
 			TotalQuantity: i.TotalQuantity,
+			Location:      ToStorageLocationResponse(i.Location),
 			Packagings:    packagings,
 		})
 	}
 
 	return &response.StockInResponse{
-		ID:          m.ID,
-		Status:      m.Status,
-		Items:       items,
-		CreatedAt:   m.CreatedAt,
-		UpdatedAt:   m.UpdatedAt,
-		FinalizedAt: util.SafeTime(m.FinalizedAt),
+		ID:             m.ID,
+		Status:         m.Status,
+		ApprovalStatus: m.ApprovalStatus,
+		Approvals:      ToApprovalDecisionResponses(m.Approvals),
+		Items:          items,
+		CreatedAt:      m.CreatedAt,
+		UpdatedAt:      m.UpdatedAt,
+		FinalizedAt:    util.SafeTime(m.FinalizedAt),
 	}
 }
 
@@ -22,6 +22,7 @@ func CreateStockOutToModel(r *request.CreateStockOutRequest) *model.StockOut {
 		stockOutItem := model.StockOutItem{
 			Item:          model.Item{ID: itr.ItemID},
 			TotalQuantity: itr.TotalQuantity,
+			Location:      toStorageLocationRef(itr.LocationID),
 			Packagings:    packagings,
 		}
 
@@ -51,6 +52,7 @@ func UpdateStockOutToModel(r *request.UpdateStockOutRequest) *model.StockOut {
 			StockOutID:    r.ID,
 			Item:          model.Item{ID: itr.ItemID},
 			TotalQuantity: itr.TotalQuantity,
+			Location:      toStorageLocationRef(itr.LocationID),
 			Packagings:    packagings,
 		}
 
@@ -80,16 +82,19 @@ func ToStockOutResponse(m *model.StockOut) *response.StockOutResponse {
 			ID:            i.ID,
 			Item:          ToItemResponse(&i.Item),
 			TotalQuantity: i.TotalQuantity,
+			Location:      ToStorageLocationResponse(i.Location),
 			Packagings:    packagings,
 		})
 	}
 
 	return &response.StockOutResponse{
-		ID:          m.ID,
-		Status:      m.Status,
-		Items:       items,
-		CreatedAt:   m.CreatedAt,
-		UpdatedAt:   m.UpdatedAt,
-		FinalizedAt: util.SafeTime(m.FinalizedAt),
+		ID:             m.ID,
+		Status:         m.Status,
+		ApprovalStatus: m.ApprovalStatus,
+		Approvals:      ToApprovalDecisionResponses(m.Approvals),
+		Items:          items,
+		CreatedAt:      m.CreatedAt,
+		UpdatedAt:      m.UpdatedAt,
+		FinalizedAt:    util.SafeTime(m.FinalizedAt),
 	}
 }
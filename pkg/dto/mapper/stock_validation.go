@@ -0,0 +1,43 @@
+package mapper
+
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+func ToStockDocumentValidationResponse(m *model.StockDocumentValidation) response.StockDocumentValidationResponse {
+	problems := make([]response.StockDocumentProblemResponse, 0, len(m.Problems))
+	for _, p := range m.Problems {
+		problems = append(problems, response.StockDocumentProblemResponse{
+			LineID:       p.LineID,
+			ItemID:       p.ItemID,
+			Severity:     string(p.Severity),
+			Code:         p.PgCode,
+			InternalCode: p.InternalCode,
+			Message:      p.Message,
+		})
+	}
+
+	return response.StockDocumentValidationResponse{
+		DocumentID: m.DocumentID,
+		Status:     m.Status,
+		Valid:      m.Valid(),
+		Problems:   problems,
+	}
+}
+
+func ToFinalizePreviewResponse(documentID uint, changes []model.StockLevelChange) response.FinalizePreviewResponse {
+	resp := response.FinalizePreviewResponse{
+		DocumentID: documentID,
+		Changes:    make([]response.StockLevelChangeResponse, 0, len(changes)),
+	}
+	for _, ch := range changes {
+		resp.Changes = append(resp.Changes, response.StockLevelChangeResponse{
+			Item:           ToItemResponse(&ch.Item),
+			CurrentStock:   ch.Current,
+			ResultingStock: ch.Resulting,
+			Change:         ch.Resulting.Sub(ch.Current),
+		})
+	}
+	return resp
+}
@@ -16,6 +16,7 @@ func CreateStockWasteToModel(req *request.CreateStockWasteRequest, userID uint)
 			ID: req.ItemID,
 		},
 		WastedQuantity: req.WastedQuantity,
+		Location:       toStorageLocationRef(req.LocationID),
 		ReasonText:     req.ReasonText,
 		CreatedBy: model.User{
 			ID: userID,
@@ -30,6 +31,7 @@ func UpdateStockWasteToModel(req *request.UpdateStockWasteRequest, userID uint)
 		StockWasteID:   req.StockWasteID,
 		Item:           model.Item{ID: req.ItemID},
 		WastedQuantity: req.WastedQuantity,
+		Location:       toStorageLocationRef(req.LocationID),
 		ReasonText:     req.ReasonText,
 		CreatedBy:      model.User{ID: userID},
 	}
@@ -41,10 +43,13 @@ func ToStockWasteResponse(m *model.StockWaste) response.StockWasteResponse {
 		StockWasteID:   m.StockWasteID,
 		Item:           ToItemResponse(&m.Item),
 		WastedQuantity: m.WastedQuantity,
+		Location:       ToStorageLocationResponse(m.Location),
 		ReasonText:     m.ReasonText,
 		ReasonImageURL: m.ReasonImageURL,
 		CreatedAt:      m.CreatedAt,
 		FinalizedAt:    util.SafeTime(m.FinalizedAt),
 		Status:         m.Status,
+		ApprovalStatus: m.ApprovalStatus,
+		Approvals:      ToApprovalDecisionResponses(m.Approvals),
 	}
 }
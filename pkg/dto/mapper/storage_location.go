@@ -0,0 +1,67 @@
+package mapper
+
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/request"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/dto/response"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+func CreateStorageLocationToModel(req *request.CreateStorageLocationRequest, userID, storeID uint) *model.StorageLocation {
+	return &model.StorageLocation{
+		Name:      req.Name,
+		StoreID:   storeID,
+		CreatedBy: model.User{ID: userID},
+	}
+}
+
+func UpdateStorageLocationToModel(req *request.UpdateStorageLocationRequest) *model.StorageLocation {
+	return &model.StorageLocation{
+		ID:   req.ID,
+		Name: req.Name,
+	}
+}
+
+// ToStorageLocationResponse maps a location model to its DTO. A nil location
+// (e.g. a document line without put-away location) maps to nil.
+func ToStorageLocationResponse(m *model.StorageLocation) *response.StorageLocationResponse {
+	if m == nil {
+		return nil
+	}
+	return &response.StorageLocationResponse{
+		ID:        m.ID,
+		Name:      m.Name,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+}
+
+func CreateStockRelocationToModel(req *request.CreateStockRelocationRequest, userID, storeID uint) *model.StockRelocation {
+	return &model.StockRelocation{
+		StoreID:   storeID,
+		Item:      model.Item{ID: req.ItemID},
+		From:      toStorageLocationRef(req.FromLocationID),
+		To:        model.StorageLocation{ID: req.ToLocationID},
+		Quantity:  req.Quantity,
+		CreatedBy: model.User{ID: userID},
+	}
+}
+
+func ToStockRelocationResponse(m *model.StockRelocation) *response.StockRelocationResponse {
+	return &response.StockRelocationResponse{
+		ID:        m.ID,
+		Item:      ToItemResponse(&m.Item),
+		From:      ToStorageLocationResponse(m.From),
+		To:        *ToStorageLocationResponse(&m.To),
+		Quantity:  m.Quantity,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+// toStorageLocationRef turns an optional location id from a request into a
+// model reference.
+func toStorageLocationRef(id *uint) *model.StorageLocation {
+	if id == nil {
+		return nil
+	}
+	return &model.StorageLocation{ID: *id}
+}
@@ -29,3 +29,24 @@ func ToStoreResponse(m *model.Store) response.StoreResponse {
 		UpdatedAt: m.UpdatedAt,
 	}
 }
+
+func ToStoreMemberResponse(m *model.StoreMember) response.StoreMemberResponse {
+	return response.StoreMemberResponse{
+		StoreID: m.StoreID,
+		User: response.UserResponse{
+			ID:    m.User.ID,
+			Name:  m.User.GivenName,
+			Email: m.User.Email,
+		},
+		AddedBy:   m.AddedBy,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+func ToStoreMemberResponseList(ms []model.StoreMember) []response.StoreMemberResponse {
+	out := make([]response.StoreMemberResponse, len(ms))
+	for i := range ms {
+		out[i] = ToStoreMemberResponse(&ms[i])
+	}
+	return out
+}
@@ -32,5 +32,6 @@ func ToUnitOfMeasureResponse(unitOfMeasure *model.UnitOfMeasure) response.UnitOf
 	return response.UnitOfMeasureResponse{
 		ID:          unitOfMeasure.ID,
 		Description: unitOfMeasure.Description,
+		ArchivedAt:  unitOfMeasure.ArchivedAt,
 	}
 }
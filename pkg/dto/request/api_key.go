@@ -0,0 +1,20 @@
+package request
+
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+)
+
+// CreateAPIKeyRequest creates an API key. Scopes are permission names; keys
+// cannot be granted store:manage.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"       validate:"required,max=100"`
+	Scopes    []string   `json:"scopes"     validate:"required,min=1,dive,oneof=store:read store:write catalog:read catalog:write catalog:delete stock:read stock:write stock:finalize stock:approve audit:read"`
+	StoreID   *uint      `json:"store_id"   validate:"omitempty,gt=0"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (r *CreateAPIKeyRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
@@ -0,0 +1,119 @@
+package request
+
+import "github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+
+// RegisterRequest signs up a local user. The given name also names the
+// try-out organization; its schema takes an ASCII slug of it, so any name is
+// accepted. Passwords are capped at 64 bytes, not characters, so that with
+// the salt they fit in bcrypt's 72 byte input.
+type RegisterRequest struct {
+	GivenName  string `json:"given_name"  validate:"required,max=50"`
+	FamilyName string `json:"family_name" validate:"max=100"`
+	Email      string `json:"email"       validate:"required,email,max=255"`
+	Password   string `json:"password"    validate:"required,min=8,password_bytes"`
+}
+
+func (r *RegisterRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+type LoginRequest struct {
+	Email    string `json:"email"    validate:"required,email"`
+	Password string `json:"password" validate:"required,password_bytes"`
+}
+
+func (r *LoginRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (r *VerifyEmailRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+// EmailRequest asks for a new verification or password reset email.
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+func (r *EmailRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"    validate:"required"`
+	Password string `json:"password" validate:"required,min=8,password_bytes"`
+}
+
+func (r *ResetPasswordRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func (r *RefreshTokenRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+// ExchangeLoginCodeRequest redeems the code an OAuth callback redirected with.
+type ExchangeLoginCodeRequest struct {
+	Code string `json:"code" validate:"required,max=128"`
+}
+
+func (r *ExchangeLoginCodeRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+// TwoFactorCodeRequest carries a code from the user's authenticator app.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+func (r *TwoFactorCodeRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+// TwoFactorReauthRequest confirms a change to the user's 2FA with the
+// password and an authenticator or backup code.
+type TwoFactorReauthRequest struct {
+	Password string `json:"password" validate:"required,password_bytes"`
+	Code     string `json:"code"     validate:"required,max=32"`
+}
+
+func (r *TwoFactorReauthRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+// TwoFactorTokenRequest carries the token of a password login waiting for
+// its second factor.
+type TwoFactorTokenRequest struct {
+	TwoFactorToken string `json:"two_factor_token" validate:"required,max=128"`
+}
+
+func (r *TwoFactorTokenRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+// TwoFactorLoginRequest completes a password login with an authenticator
+// or backup code.
+type TwoFactorLoginRequest struct {
+	TwoFactorToken string `json:"two_factor_token" validate:"required,max=128"`
+	Code           string `json:"code"             validate:"required,max=32"`
+}
+
+func (r *TwoFactorLoginRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+type RequireTwoFactorRequest struct {
+	Required *bool `json:"required" validate:"required"`
+}
+
+func (r *RequireTwoFactorRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
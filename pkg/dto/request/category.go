@@ -4,6 +4,7 @@ import "github.com/IlfGauhnith/GraoAGrao/pkg/validator"
 
 type CreateCategoryRequest struct {
 	Description string `json:"description" validate:"required"`
+	ParentID    *uint  `json:"parent_id" validate:"omitempty,gt=0"`
 }
 
 // Validate runs Go-Playground on the struct tags.
@@ -20,3 +21,13 @@ type UpdateCategoryRequest struct {
 func (r *UpdateCategoryRequest) Validate() error {
 	return validator.Validate.Struct(r)
 }
+
+// MoveCategoryRequest re-parents a category. A null parent_id moves it to the root.
+type MoveCategoryRequest struct {
+	ParentID *uint `json:"parent_id" validate:"omitempty,gt=0"`
+}
+
+// Validate runs Go-Playground on the struct tags.
+func (r *MoveCategoryRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
@@ -0,0 +1,36 @@
+package request
+
+import "github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+
+// CreateInvitationRequest invites an email into the organization. With a
+// store id the role applies only in that store.
+type CreateInvitationRequest struct {
+	Email   string `json:"email"    validate:"required,email,max=255"`
+	Role    string `json:"role"     validate:"required,oneof=owner manager clerk viewer"`
+	StoreID *uint  `json:"store_id" validate:"omitempty,gt=0"`
+}
+
+func (r *CreateInvitationRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (r *AcceptInvitationRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+// RegisterWithInvitationRequest signs up a local user from an invitation.
+// The email is the one the invitation was sent to.
+type RegisterWithInvitationRequest struct {
+	Token      string `json:"token"       validate:"required"`
+	GivenName  string `json:"given_name"  validate:"required,max=255"`
+	FamilyName string `json:"family_name" validate:"max=255"`
+	Password   string `json:"password"    validate:"required,min=8,password_bytes"`
+}
+
+func (r *RegisterWithInvitationRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
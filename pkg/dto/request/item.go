@@ -7,11 +7,12 @@ import (
 
 // CreateItemRequest is used when POSTing a new Item.
 type CreateItemRequest struct {
-	Description     string `json:"description"      validate:"required"`
-	EAN13           string `json:"ean13"            validate:"required,len=13,numeric"`
-	CategoryID      uint   `json:"category_id"      validate:"required"`
-	UnitOfMeasureID uint   `json:"unit_of_measure_id" validate:"required"`
-	IsFractionable  bool   `json:"is_fractionable"`
+	Description     string         `json:"description"      validate:"required"`
+	EAN13           string         `json:"ean13"            validate:"required,len=13,numeric"`
+	CategoryID      uint           `json:"category_id"      validate:"required"`
+	UnitOfMeasureID uint           `json:"unit_of_measure_id" validate:"required"`
+	IsFractionable  bool           `json:"is_fractionable"`
+	Attributes      map[string]any `json:"attributes,omitempty"`
 }
 
 // Validate runs Go-Playground on the struct tags.
@@ -21,12 +22,13 @@ func (r *CreateItemRequest) Validate() error {
 
 // UpdateItemRequest is used when PUT/PATCHing an existing Item.
 type UpdateItemRequest struct {
-	ID              uint   `json:"id" validate:"required"`
-	Description     string `json:"description"      validate:"required"`
-	EAN13           string `json:"ean13"            validate:"required,len=13,numeric"`
-	CategoryID      uint   `json:"category_id"      validate:"required"`
-	UnitOfMeasureID uint   `json:"unit_of_measure_id" validate:"required"`
-	IsFractionable  bool   `json:"is_fractionable"`
+	ID              uint           `json:"id" validate:"required"`
+	Description     string         `json:"description"      validate:"required"`
+	EAN13           string         `json:"ean13"            validate:"required,len=13,numeric"`
+	CategoryID      uint           `json:"category_id"      validate:"required"`
+	UnitOfMeasureID uint           `json:"unit_of_measure_id" validate:"required"`
+	IsFractionable  bool           `json:"is_fractionable"`
+	Attributes      map[string]any `json:"attributes,omitempty"`
 }
 
 // Validate runs Go-Playground on the struct tags.
@@ -0,0 +1,34 @@
+package request
+
+import "github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+
+type CreateItemAttributeDefinitionRequest struct {
+	Key      string   `json:"key"      validate:"required,max=64,attribute_key"`
+	Label    string   `json:"label"    validate:"required"`
+	Type     string   `json:"type"     validate:"required,oneof=text number enum date"`
+	Options  []string `json:"options"  validate:"required_if=Type enum,omitempty,dive,required"`
+	Required bool     `json:"required"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+}
+
+// Validate runs Go-Playground on the struct tags.
+func (r *CreateItemAttributeDefinitionRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+// UpdateItemAttributeDefinitionRequest cannot change key or type, since
+// existing item values depend on both.
+type UpdateItemAttributeDefinitionRequest struct {
+	ID       uint     `json:"id"       validate:"required"`
+	Label    string   `json:"label"    validate:"required"`
+	Options  []string `json:"options"  validate:"omitempty,dive,required"`
+	Required bool     `json:"required"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+}
+
+// Validate runs Go-Playground on the struct tags.
+func (r *UpdateItemAttributeDefinitionRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
@@ -1,11 +1,16 @@
 package request
 
-import "github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+)
 
 type CreateItemPackagingRequest struct {
-	ItemID      uint    `json:"item_id" validate:"required"`
-	Description string  `json:"description" validate:"required"`
-	Quantity    float32 `json:"quantity" validate:"required,gt=0"`
+	ItemID           uint             `json:"item_id" validate:"required"`
+	Description      string           `json:"description" validate:"required"`
+	Quantity         decimal.Decimal  `json:"quantity" swaggertype:"number" validate:"required_without=InnerPackagingID,omitempty,gt=0"` // derived when nested
+	InnerPackagingID *uint            `json:"inner_packaging_id,omitempty" validate:"omitempty,gt=0"`
+	InnerQuantity    *decimal.Decimal `json:"inner_quantity,omitempty" swaggertype:"number" validate:"required_with=InnerPackagingID,omitempty,gt=0"`
 }
 
 // Validate runs Go-Playground on the struct tags.
@@ -14,10 +19,12 @@ func (r *CreateItemPackagingRequest) Validate() error {
 }
 
 type UpdateItemPackagingRequest struct {
-	ID          uint    `json:"id" validate:"required"`
-	ItemID      uint    `json:"item_id" validate:"required"`
-	Description string  `json:"description" validate:"required"`
-	Quantity    float32 `json:"quantity" validate:"required,gt=0"`
+	ID               uint             `json:"id" validate:"required"`
+	ItemID           uint             `json:"item_id" validate:"required"`
+	Description      string           `json:"description" validate:"required"`
+	Quantity         decimal.Decimal  `json:"quantity" swaggertype:"number" validate:"required_without=InnerPackagingID,omitempty,gt=0"` // derived when nested
+	InnerPackagingID *uint            `json:"inner_packaging_id,omitempty" validate:"omitempty,gt=0"`
+	InnerQuantity    *decimal.Decimal `json:"inner_quantity,omitempty" swaggertype:"number" validate:"required_with=InnerPackagingID,omitempty,gt=0"`
 }
 
 // Validate runs Go-Playground on the struct tags.
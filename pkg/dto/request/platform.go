@@ -0,0 +1,16 @@
+package request
+
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+)
+
+// ExtendTryOutRequest moves the expiration of a try-out organization.
+type ExtendTryOutRequest struct {
+	ExpiresAt time.Time `json:"expires_at" validate:"required"`
+}
+
+func (r *ExtendTryOutRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
@@ -0,0 +1,11 @@
+package request
+
+import "github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner manager clerk viewer"`
+}
+
+func (r *AssignRoleRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
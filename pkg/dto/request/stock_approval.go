@@ -0,0 +1,37 @@
+package request
+
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+)
+
+// StockDocumentDecisionRequest carries the optional comment of a submission
+// or approval. Send {} when there is nothing to say.
+type StockDocumentDecisionRequest struct {
+	Comment *string `json:"comment"`
+}
+
+func (r *StockDocumentDecisionRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+// RejectStockDocumentRequest requires a comment telling what to change.
+type RejectStockDocumentRequest struct {
+	Comment string `json:"comment" validate:"required"`
+}
+
+func (r *RejectStockDocumentRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+type UpdateApprovalPolicyRequest struct {
+	Enabled               bool             `json:"enabled"`
+	RequireForWaste       bool             `json:"require_for_waste"`
+	RequireForStockOut    bool             `json:"require_for_stock_out"`
+	StockInValueThreshold *decimal.Decimal `json:"stock_in_value_threshold" swaggertype:"number" validate:"omitempty,gte=0"`
+	AllowSelfApproval     bool             `json:"allow_self_approval"`
+}
+
+func (r *UpdateApprovalPolicyRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
@@ -1,6 +1,9 @@
 package request
 
-import "github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+)
 
 type CreateStockInRequest struct {
 	Items []CreateStockInItemRequest `json:"items" validate:"required,dive"`
@@ -8,8 +11,9 @@ type CreateStockInRequest struct {
 
 type CreateStockInItemRequest struct {
 	ItemID        uint                            `json:"item_id" validate:"required"`
-	BuyPrice      float64                         `json:"buy_price" validate:"required,gt=0"`
-	TotalQuantity float64                         `json:"total_quantity" validate:"required,gt=0"`
+	BuyPrice      decimal.Decimal                 `json:"buy_price" swaggertype:"number" validate:"required,gt=0"`
+	TotalQuantity decimal.Decimal                 `json:"total_quantity" swaggertype:"number" validate:"required,gt=0"`
+	LocationID    *uint                           `json:"location_id,omitempty"`
 	Packagings    []CreateStockInPackagingRequest `json:"packagings" validate:"required,dive"`
 }
 
@@ -31,8 +35,9 @@ type UpdateStockInRequest struct {
 type UpdateStockInItemRequest struct {
 	ID            *uint                           `json:"id,omitempty"`
 	ItemID        uint                            `json:"item_id" validate:"required"`
-	BuyPrice      float64                         `json:"buy_price" validate:"required,gt=0"`
-	TotalQuantity float64                         `json:"total_quantity" validate:"required,gt=0"`
+	BuyPrice      decimal.Decimal                 `json:"buy_price" swaggertype:"number" validate:"required,gt=0"`
+	TotalQuantity decimal.Decimal                 `json:"total_quantity" swaggertype:"number" validate:"required,gt=0"`
+	LocationID    *uint                           `json:"location_id,omitempty"`
 	Packagings    []UpdateStockInPackagingRequest `json:"packagings" validate:"required,dive"`
 }
 
@@ -1,6 +1,9 @@
 package request
 
-import "github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+)
 
 type CreateStockOutRequest struct {
 	Items []CreateStockOutItemRequest `json:"items" validate:"required,dive"`
@@ -8,7 +11,8 @@ type CreateStockOutRequest struct {
 
 type CreateStockOutItemRequest struct {
 	ItemID        uint                             `json:"item_id" validate:"required"`
-	TotalQuantity float64                          `json:"total_quantity" validate:"required,gt=0"`
+	TotalQuantity decimal.Decimal                  `json:"total_quantity" swaggertype:"number" validate:"required,gt=0"`
+	LocationID    *uint                            `json:"location_id,omitempty"`
 	Packagings    []CreateStockOutPackagingRequest `json:"packagings" validate:"required,dive"`
 }
 
@@ -29,7 +33,8 @@ type UpdateStockOutRequest struct {
 type UpdateStockOutItemRequest struct {
 	ID            *uint                            `json:"id,omitempty"`
 	ItemID        uint                             `json:"item_id" validate:"required"`
-	TotalQuantity float64                          `json:"total_quantity" validate:"required,gt=0"`
+	TotalQuantity decimal.Decimal                  `json:"total_quantity" swaggertype:"number" validate:"required,gt=0"`
+	LocationID    *uint                            `json:"location_id,omitempty"`
 	Packagings    []UpdateStockOutPackagingRequest `json:"packagings" validate:"required,dive"`
 }
 
@@ -1,11 +1,15 @@
 package request
 
-import "github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+)
 
 type CreateStockWasteRequest struct {
-	ItemID         uint    `json:"item_id" binding:"required"`
-	WastedQuantity float64 `json:"wasted_quantity" binding:"required,gt=0"`
-	ReasonText     string  `json:"reason_text" binding:"required"`
+	ItemID         uint            `json:"item_id" validate:"required"`
+	WastedQuantity decimal.Decimal `json:"wasted_quantity" swaggertype:"number" validate:"required,gt=0"`
+	LocationID     *uint           `json:"location_id,omitempty"`
+	ReasonText     string          `json:"reason_text" validate:"required"`
 }
 
 // Validate runs Go-Playground on the struct tags.
@@ -14,10 +18,11 @@ func (r *CreateStockWasteRequest) Validate() error {
 }
 
 type UpdateStockWasteRequest struct {
-	StockWasteID   uint    `json:"stock_waste_id" binding:"required"`
-	ItemID         uint    `json:"item_id" binding:"required"`
-	WastedQuantity float64 `json:"wasted_quantity" binding:"required,gt=0"`
-	ReasonText     string  `json:"reason_text" binding:"required"`
+	StockWasteID   uint            `json:"stock_waste_id" validate:"required"`
+	ItemID         uint            `json:"item_id" validate:"required"`
+	WastedQuantity decimal.Decimal `json:"wasted_quantity" swaggertype:"number" validate:"required,gt=0"`
+	LocationID     *uint           `json:"location_id,omitempty"`
+	ReasonText     string          `json:"reason_text" validate:"required"`
 }
 
 // Validate runs Go-Playground on the struct tags.
@@ -0,0 +1,39 @@
+package request
+
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/validator"
+)
+
+type CreateStorageLocationRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// Validate runs Go-Playground on the struct tags.
+func (r *CreateStorageLocationRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+type UpdateStorageLocationRequest struct {
+	ID   uint   `json:"id" validate:"required"`
+	Name string `json:"name" validate:"required"`
+}
+
+// Validate runs Go-Playground on the struct tags.
+func (r *UpdateStorageLocationRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
+
+// CreateStockRelocationRequest moves stock between two locations. Omitting
+// from_location_id moves stock that is not yet assigned to any location.
+type CreateStockRelocationRequest struct {
+	ItemID         uint            `json:"item_id" validate:"required"`
+	FromLocationID *uint           `json:"from_location_id,omitempty" validate:"omitempty,gt=0"`
+	ToLocationID   uint            `json:"to_location_id" validate:"required"`
+	Quantity       decimal.Decimal `json:"quantity" swaggertype:"number" validate:"required,gt=0"`
+}
+
+// Validate runs Go-Playground on the struct tags.
+func (r *CreateStockRelocationRequest) Validate() error {
+	return validator.Validate.Struct(r)
+}
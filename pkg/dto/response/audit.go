@@ -0,0 +1,18 @@
+package response
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditEntryResponse struct {
+	ID         uint64          `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	UserID     *uint           `json:"user_id"`    // null for system changes
+	APIKeyID   *uint           `json:"api_key_id"` // set when the user acted through an API key
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Action     string          `json:"action"` // create, update, delete or finalize
+	Before     json.RawMessage `json:"before" swaggertype:"object"`
+	After      json.RawMessage `json:"after" swaggertype:"object"`
+}
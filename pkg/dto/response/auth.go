@@ -1,5 +1,73 @@
 package response
 
+import errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+
 type GoogleInitOAuthResponse struct {
 	GoogleUrl string `json:"googleUrl"`
 }
+
+type OAuthInitResponse struct {
+	URL string `json:"url"`
+}
+
+type AuthProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+type LoginResponse struct {
+	Token          string `json:"token"`
+	RefreshToken   string `json:"refresh_token"`
+	ExpiresAt      int64  `json:"expires_at"` // Unix time the access token expires
+	Name           string `json:"name"`
+	Email          string `json:"email"`
+	UserPictureURL string `json:"user_picture_url"`
+	IsTryOut       bool   `json:"is_try_out"`
+	Uuid           string `json:"uuid,omitempty"` // Try-out job started by an OAuth login
+	// BackupCodes are set once, by the login that enrolled the user in 2FA
+	BackupCodes []string `json:"backup_codes,omitempty"`
+}
+
+// TwoFactorChallengeResponse answers a password login that needs a second
+// factor. SetupRequired is set when the organization requires 2FA and the
+// user has to enroll first.
+type TwoFactorChallengeResponse struct {
+	TwoFactorToken string `json:"two_factor_token"`
+	ExpiresAt      int64  `json:"expires_at"` // Unix time the token expires
+	SetupRequired  bool   `json:"setup_required"`
+}
+
+// TwoFactorSetupResponse is what the user adds to an authenticator app.
+// Apps read the otpauth URI from a QR code.
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled         bool `json:"enabled"`
+	Pending         bool `json:"pending"` // set up but not confirmed yet
+	BackupCodesLeft int  `json:"backup_codes_left"`
+	Required        bool `json:"required"` // by the organization
+}
+
+// BackupCodesResponse is the only response that carries backup codes.
+type BackupCodesResponse struct {
+	BackupCodes []string `json:"backup_codes"`
+}
+
+// RegisterResponse carries the try-out job to poll at /tryOut/status.
+type RegisterResponse struct {
+	Uuid  string `json:"uuid"`
+	Email string `json:"email"`
+}
+
+type AuthErrorResponse struct {
+	Error        string               `json:"error"`
+	InternalCode errorCodes.ErrorCode `json:"internal_code"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"` // Unix time the access token expires
+}
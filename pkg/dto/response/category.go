@@ -4,8 +4,11 @@ import "time"
 
 // CategoryResponse hides internal fields and shows only what clients need.
 type CategoryResponse struct {
-	ID          uint      `json:"id"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint                `json:"id"`
+	Description string              `json:"description"`
+	ParentID    *uint               `json:"parent_id"`
+	Children    []*CategoryResponse `json:"children,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	ArchivedAt  *time.Time          `json:"archived_at"`
 }
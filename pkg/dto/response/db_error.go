@@ -41,3 +41,74 @@ type StockOutTotalQuantityNotMatchingResponse struct {
 	InternalCode errorCodes.ErrorCode `json:"internal_code"`
 	Details      string               `json:"details"`
 }
+
+type ArchivedEntityReferencedErrorResponse struct {
+	Error           string               `json:"error"`
+	Code            string               `json:"code"`
+	InternalCode    errorCodes.ErrorCode `json:"internal_code"`
+	Details         string               `json:"details"`
+	ReferencedTable string               `json:"referencedTable"`
+}
+
+type InvalidCategoryHierarchyErrorResponse struct {
+	Error        string               `json:"error"`
+	Code         string               `json:"code"`
+	InternalCode errorCodes.ErrorCode `json:"internal_code"`
+	Details      string               `json:"details"`
+}
+
+type StorageLocationErrorResponse struct {
+	Error        string               `json:"error"`
+	Code         string               `json:"code"`
+	InternalCode errorCodes.ErrorCode `json:"internal_code"`
+	Details      string               `json:"details"`
+}
+
+type StoreMismatchErrorResponse struct {
+	Error        string               `json:"error"`
+	Code         string               `json:"code"`
+	InternalCode errorCodes.ErrorCode `json:"internal_code"`
+	Details      string               `json:"details"`
+}
+
+type InvalidItemAttributeErrorResponse struct {
+	Error        string               `json:"error"`
+	Code         string               `json:"code,omitempty"`
+	InternalCode errorCodes.ErrorCode `json:"internal_code"`
+	Attribute    string               `json:"attribute,omitempty"`
+	Details      string               `json:"details"`
+}
+
+type InvalidListQueryErrorResponse struct {
+	Error        string               `json:"error"`
+	InternalCode errorCodes.ErrorCode `json:"internal_code"`
+	Parameter    string               `json:"parameter,omitempty"`
+	Details      string               `json:"details"`
+}
+
+type InvalidPackagingHierarchyErrorResponse struct {
+	Error        string               `json:"error"`
+	Code         string               `json:"code"`
+	InternalCode errorCodes.ErrorCode `json:"internal_code"`
+	Details      string               `json:"details"`
+}
+
+type ApprovalErrorResponse struct {
+	Error        string               `json:"error"`
+	Code         string               `json:"code,omitempty"`
+	InternalCode errorCodes.ErrorCode `json:"internal_code"`
+	Details      string               `json:"details"`
+}
+
+type LastOwnerErrorResponse struct {
+	Error        string               `json:"error"`
+	Code         string               `json:"code"`
+	InternalCode errorCodes.ErrorCode `json:"internal_code"`
+	Details      string               `json:"details"`
+}
+
+type UnknownDocumentLineErrorResponse struct {
+	Error        string               `json:"error"`
+	InternalCode errorCodes.ErrorCode `json:"internal_code"`
+	Details      string               `json:"details"`
+}
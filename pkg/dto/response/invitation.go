@@ -0,0 +1,27 @@
+package response
+
+import "time"
+
+type InvitationResponse struct {
+	ID         uint       `json:"id"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	StoreID    *uint      `json:"store_id"`
+	Status     string     `json:"status"` // pending, accepted, revoked or expired
+	InvitedBy  *uint      `json:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastSentAt time.Time  `json:"last_sent_at"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	AcceptedBy *uint      `json:"accepted_by"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// InvitationPreviewResponse is what the invitee sees before accepting.
+type InvitationPreviewResponse struct {
+	OrganizationName string    `json:"organization_name"`
+	Email            string    `json:"email"`
+	Role             string    `json:"role"`
+	StoreID          *uint     `json:"store_id"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
@@ -11,6 +11,8 @@ type ItemResponse struct {
 	Category       CategoryResponse      `json:"category"`
 	UnitOfMeasure  UnitOfMeasureResponse `json:"unit_of_measure"`
 	IsFractionable bool                  `json:"is_fractionable"`
+	Attributes     map[string]any        `json:"attributes,omitempty"`
 	CreatedAt      time.Time             `json:"created_at"`
 	UpdatedAt      time.Time             `json:"updated_at"`
+	ArchivedAt     *time.Time            `json:"archived_at"`
 }
@@ -0,0 +1,16 @@
+package response
+
+import "time"
+
+type ItemAttributeDefinitionResponse struct {
+	ID        uint      `json:"id"`
+	Key       string    `json:"key"`
+	Label     string    `json:"label"`
+	Type      string    `json:"type"`
+	Options   []string  `json:"options,omitempty"`
+	Required  bool      `json:"required"`
+	Min       *float64  `json:"min,omitempty"`
+	Max       *float64  `json:"max,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
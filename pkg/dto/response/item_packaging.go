@@ -1,8 +1,36 @@
 package response
 
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
+
 type ItemPackagingResponse struct {
-	ID          uint         `json:"id"`
-	Description string       `json:"description"`
-	Quantity    float32      `json:"quantity"`
-	Item        ItemResponse `json:"item"`
+	ID               uint             `json:"id"`
+	Description      string           `json:"description"`
+	Quantity         decimal.Decimal  `json:"quantity" swaggertype:"number"` // base units, also for nested packagings
+	InnerPackagingID *uint            `json:"inner_packaging_id"`
+	InnerQuantity    *decimal.Decimal `json:"inner_quantity" swaggertype:"number"`
+	Item             ItemResponse     `json:"item"`
+	ArchivedAt       *time.Time       `json:"archived_at"`
+}
+
+type PackagingBreakdownLineResponse struct {
+	ItemPackaging ItemPackagingResponse `json:"item_packaging"`
+	Quantity      int64                 `json:"quantity"`
+	Held          *int64                `json:"held,omitempty"`
+}
+
+// PackagingBreakdownResponse suggests packagings for a stock document line.
+// Its packagings can be copied as-is; when exact is false they fall short of
+// requested_quantity by remainder.
+type PackagingBreakdownResponse struct {
+	ItemID            uint                             `json:"item_id"`
+	RequestedQuantity decimal.Decimal                  `json:"requested_quantity" swaggertype:"number"`
+	TotalQuantity     decimal.Decimal                  `json:"total_quantity" swaggertype:"number"`
+	Remainder         decimal.Decimal                  `json:"remainder" swaggertype:"number"`
+	Exact             bool                             `json:"exact"`
+	PackageCount      int64                            `json:"package_count"`
+	Packagings        []PackagingBreakdownLineResponse `json:"packagings"`
 }
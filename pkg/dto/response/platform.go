@@ -0,0 +1,45 @@
+package response
+
+import "time"
+
+type PlatformOrganizationResponse struct {
+	ID                 uint       `json:"id"`
+	Name               string     `json:"name"`
+	Key                string     `json:"key"`
+	Domain             string     `json:"domain"`
+	Schema             string     `json:"schema"`
+	IsActive           bool       `json:"is_active"`
+	IsTryOut           bool       `json:"is_try_out"`
+	ExpiresAt          *time.Time `json:"expires_at"`
+	RequireTwoFactor   bool       `json:"require_two_factor"`
+	UserCount          int        `json:"user_count"`
+	ActiveUserCount    int        `json:"active_user_count"`
+	ActiveSessionCount int        `json:"active_session_count"`
+	APIKeyCount        int        `json:"api_key_count"`
+	LastLoginAt        *time.Time `json:"last_login_at"`
+	// Tenant data counts, null when the tenant schema could not be read
+	StoreCount    *int `json:"store_count"`
+	ItemCount     *int `json:"item_count"`
+	StockInCount  *int `json:"stock_in_count"`
+	StockOutCount *int `json:"stock_out_count"`
+}
+
+type PlatformUserResponse struct {
+	ID               uint       `json:"id"`
+	Username         string     `json:"username"`
+	Email            string     `json:"email"`
+	AuthProvider     string     `json:"auth_provider"`
+	IsActive         bool       `json:"is_active"`
+	CreatedAt        time.Time  `json:"created_at"`
+	LastLogin        *time.Time `json:"last_login"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	Role             *string    `json:"role"` // organization-wide role
+}
+
+type TenantMigrationResponse struct {
+	OrganizationID uint     `json:"organization_id"`
+	Schema         string   `json:"schema"`
+	Applied        []string `json:"applied"` // scripts run now, in order
+	Error          *string  `json:"error,omitempty"`
+}
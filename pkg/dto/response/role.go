@@ -0,0 +1,40 @@
+package response
+
+import (
+	"time"
+
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+)
+
+type RoleAssignmentResponse struct {
+	ID         uint         `json:"id"`
+	User       UserResponse `json:"user"`
+	StoreID    *uint        `json:"store_id"`
+	Role       string       `json:"role"`
+	AssignedBy *uint        `json:"assigned_by"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+type StoreRoleResponse struct {
+	StoreID     uint     `json:"store_id"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+}
+
+// MyRolesResponse tells the client what the authenticated user may do.
+// Stores without an entry in Stores use the organization role.
+type MyRolesResponse struct {
+	OrganizationRole *string             `json:"organization_role"`
+	Permissions      []string            `json:"permissions"`
+	Stores           []StoreRoleResponse `json:"stores"`
+}
+
+// PermissionDeniedResponse is returned with 403 whenever the user's role
+// does not grant what the route needs.
+type PermissionDeniedResponse struct {
+	Error        string               `json:"error"`
+	InternalCode errorCodes.ErrorCode `json:"internal_code"`
+	Permission   string               `json:"permission"`
+	Role         *string              `json:"role"`
+}
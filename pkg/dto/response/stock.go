@@ -1,12 +1,34 @@
 package response
 
-import "time"
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
 
 // StockResponse represents the current stock position for an item.
 type StockResponse struct {
-	ID           uint         `json:"id"`
-	Item         ItemResponse `json:"item"`
-	CurrentStock int          `json:"current_stock"`
-	CreatedAt    time.Time    `json:"created_at"`
-	UpdatedAt    time.Time    `json:"updated_at"`
+	ID           uint                    `json:"id"`
+	Item         ItemResponse            `json:"item"`
+	CurrentStock decimal.Decimal         `json:"current_stock" swaggertype:"number"`
+	Locations    []StockLocationResponse `json:"locations,omitempty"`
+	Unassigned   *decimal.Decimal        `json:"unassigned,omitempty" swaggertype:"number"`
+	CreatedAt    time.Time               `json:"created_at"`
+	UpdatedAt    time.Time               `json:"updated_at"`
+}
+
+// CategoryStockSummaryResponse is a node of the category stock report.
+// total_* fields roll up the node and all of its children.
+type CategoryStockSummaryResponse struct {
+	CategoryID     uint                            `json:"category_id"`
+	Description    string                          `json:"description"`
+	ParentID       *uint                           `json:"parent_id"`
+	ArchivedAt     *time.Time                      `json:"archived_at"`
+	ItemCount      int                             `json:"item_count"`
+	Quantity       decimal.Decimal                 `json:"quantity" swaggertype:"number"`
+	Value          decimal.Decimal                 `json:"value" swaggertype:"number"`
+	TotalItemCount int                             `json:"total_item_count"`
+	TotalQuantity  decimal.Decimal                 `json:"total_quantity" swaggertype:"number"`
+	TotalValue     decimal.Decimal                 `json:"total_value" swaggertype:"number"`
+	Children       []*CategoryStockSummaryResponse `json:"children,omitempty"`
 }
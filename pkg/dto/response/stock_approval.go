@@ -0,0 +1,27 @@
+package response
+
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
+
+type ApprovalDecisionResponse struct {
+	ID           uint         `json:"id"`
+	DocumentType string       `json:"document_type"`
+	DocumentID   uint         `json:"document_id"`
+	Action       string       `json:"action"`
+	Comment      *string      `json:"comment,omitempty"`
+	DecidedBy    UserResponse `json:"decided_by"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+type ApprovalPolicyResponse struct {
+	StoreID               uint             `json:"store_id"`
+	Enabled               bool             `json:"enabled"`
+	RequireForWaste       bool             `json:"require_for_waste"`
+	RequireForStockOut    bool             `json:"require_for_stock_out"`
+	StockInValueThreshold *decimal.Decimal `json:"stock_in_value_threshold" swaggertype:"number"`
+	AllowSelfApproval     bool             `json:"allow_self_approval"`
+	UpdatedAt             *time.Time       `json:"updated_at"`
+}
@@ -1,21 +1,28 @@
 package response
 
-import "time"
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
 
 type StockInResponse struct {
-	ID          uint                  `json:"id"`
-	Items       []StockInItemResponse `json:"items"`
-	Status      string                `json:"status"`
-	CreatedAt   time.Time             `json:"created_at"`
-	UpdatedAt   time.Time             `json:"updated_at"`
-	FinalizedAt time.Time             `json:"finalized_at"`
+	ID             uint                       `json:"id"`
+	Items          []StockInItemResponse      `json:"items"`
+	Status         string                     `json:"status"`
+	ApprovalStatus *string                    `json:"approval_status"`
+	Approvals      []ApprovalDecisionResponse `json:"approvals"`
+	CreatedAt      time.Time                  `json:"created_at"`
+	UpdatedAt      time.Time                  `json:"updated_at"`
+	FinalizedAt    time.Time                  `json:"finalized_at"`
 }
 
 type StockInItemResponse struct {
 	ID            uint                       `json:"id"`
 	Item          ItemResponse               `json:"item"`
-	BuyPrice      float64                    `json:"buy_price"`
-	TotalQuantity float64                    `json:"total_quantity"`
+	BuyPrice      decimal.Decimal            `json:"buy_price" swaggertype:"number"`
+	TotalQuantity decimal.Decimal            `json:"total_quantity" swaggertype:"number"`
+	Location      *StorageLocationResponse   `json:"location"`
 	Packagings    []StockInPackagingResponse `json:"packagings"`
 }
 
@@ -1,20 +1,27 @@
 package response
 
-import "time"
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
 
 type StockOutResponse struct {
-	ID          uint                   `json:"id"`
-	Items       []StockOutItemResponse `json:"items"`
-	Status      string                 `json:"status"`
-	CreatedAt   time.Time              `json:"created_at"`
-	UpdatedAt   time.Time              `json:"updated_at"`
-	FinalizedAt time.Time              `json:"finalized_at"`
+	ID             uint                       `json:"id"`
+	Items          []StockOutItemResponse     `json:"items"`
+	Status         string                     `json:"status"`
+	ApprovalStatus *string                    `json:"approval_status"`
+	Approvals      []ApprovalDecisionResponse `json:"approvals"`
+	CreatedAt      time.Time                  `json:"created_at"`
+	UpdatedAt      time.Time                  `json:"updated_at"`
+	FinalizedAt    time.Time                  `json:"finalized_at"`
 }
 
 type StockOutItemResponse struct {
 	ID            uint                        `json:"id"`
 	Item          ItemResponse                `json:"item"`
-	TotalQuantity float64                     `json:"total_quantity"`
+	TotalQuantity decimal.Decimal             `json:"total_quantity" swaggertype:"number"`
+	Location      *StorageLocationResponse    `json:"location"`
 	Packagings    []StockOutPackagingResponse `json:"packagings"`
 }
 
@@ -0,0 +1,38 @@
+package response
+
+import (
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+)
+
+type StockDocumentProblemResponse struct {
+	LineID       *uint                `json:"line_id,omitempty"`
+	ItemID       *uint                `json:"item_id,omitempty"`
+	Severity     string               `json:"severity" enums:"error,warning"`
+	Code         string               `json:"code,omitempty"`
+	InternalCode errorCodes.ErrorCode `json:"internal_code"`
+	Message      string               `json:"message"`
+}
+
+// StockDocumentValidationResponse lists every problem of a stock document.
+// valid is false as soon as one problem has severity error.
+type StockDocumentValidationResponse struct {
+	DocumentID uint                           `json:"document_id"`
+	Status     string                         `json:"status"`
+	Valid      bool                           `json:"valid"`
+	Problems   []StockDocumentProblemResponse `json:"problems"`
+}
+
+type StockLevelChangeResponse struct {
+	Item           ItemResponse    `json:"item"`
+	CurrentStock   decimal.Decimal `json:"current_stock" swaggertype:"number"`
+	ResultingStock decimal.Decimal `json:"resulting_stock" swaggertype:"number"`
+	Change         decimal.Decimal `json:"change" swaggertype:"number"`
+}
+
+// FinalizePreviewResponse is returned by a dry-run finalize. Nothing has been
+// committed.
+type FinalizePreviewResponse struct {
+	DocumentID uint                       `json:"document_id"`
+	Changes    []StockLevelChangeResponse `json:"changes"`
+}
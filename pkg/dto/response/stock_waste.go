@@ -1,14 +1,21 @@
 package response
 
-import "time"
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
 
 type StockWasteResponse struct {
-	StockWasteID   uint         `json:"stock_waste_id"`
-	Item           ItemResponse `json:"item"`
-	WastedQuantity float64      `json:"wasted_quantity"`
-	Status         string       `json:"status"`
-	ReasonText     string       `json:"reason_text"`
-	ReasonImageURL *string      `json:"reason_image_url,omitempty"`
-	CreatedAt      time.Time    `json:"created_at"`
-	FinalizedAt    time.Time    `json:"finalized_at"`
+	StockWasteID   uint                       `json:"stock_waste_id"`
+	Item           ItemResponse               `json:"item"`
+	WastedQuantity decimal.Decimal            `json:"wasted_quantity" swaggertype:"number"`
+	Location       *StorageLocationResponse   `json:"location"`
+	Status         string                     `json:"status"`
+	ApprovalStatus *string                    `json:"approval_status"`
+	Approvals      []ApprovalDecisionResponse `json:"approvals"`
+	ReasonText     string                     `json:"reason_text"`
+	ReasonImageURL *string                    `json:"reason_image_url,omitempty"`
+	CreatedAt      time.Time                  `json:"created_at"`
+	FinalizedAt    time.Time                  `json:"finalized_at"`
 }
@@ -0,0 +1,29 @@
+package response
+
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
+
+type StorageLocationResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StockLocationResponse is the quantity of an item held at one location.
+type StockLocationResponse struct {
+	Location StorageLocationResponse `json:"location"`
+	Quantity decimal.Decimal         `json:"quantity" swaggertype:"number"`
+}
+
+type StockRelocationResponse struct {
+	ID        uint                     `json:"id"`
+	Item      ItemResponse             `json:"item"`
+	From      *StorageLocationResponse `json:"from_location"`
+	To        StorageLocationResponse  `json:"to_location"`
+	Quantity  decimal.Decimal          `json:"quantity" swaggertype:"number"`
+	CreatedAt time.Time                `json:"created_at"`
+}
@@ -1,6 +1,10 @@
 package response
 
-import "time"
+import (
+	"time"
+
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+)
 
 type StoreResponse struct {
 	ID        uint      `json:"id"`
@@ -8,3 +12,18 @@ type StoreResponse struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+type StoreMemberResponse struct {
+	StoreID   uint         `json:"store_id"`
+	User      UserResponse `json:"user"`
+	AddedBy   *uint        `json:"added_by"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// StoreAccessDeniedResponse is returned with 403 when the user is not a
+// member of the requested store, or the store does not exist.
+type StoreAccessDeniedResponse struct {
+	Error        string               `json:"error"`
+	InternalCode errorCodes.ErrorCode `json:"internal_code"`
+	StoreID      uint                 `json:"store_id"`
+}
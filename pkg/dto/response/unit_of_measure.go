@@ -1,7 +1,10 @@
 package response
 
+import "time"
+
 // UnitOfMeasureResponse likewise for unit of measure.
 type UnitOfMeasureResponse struct {
-	ID          uint   `json:"id"`
-	Description string `json:"description"`
+	ID          uint       `json:"id"`
+	Description string     `json:"description"`
+	ArchivedAt  *time.Time `json:"archived_at"`
 }
@@ -2,13 +2,50 @@ package errors
 
 import "fmt"
 
-// GoogleIDUserNotFound represents an error
-// when a user is not found by Google ID.
-type GoogleIDUserNotFound struct {
-	GoogleID string
+// InvalidItemAttribute represents a custom attribute value or filter
+// that does not match the tenant's attribute definitions.
+type InvalidItemAttribute struct {
+	Key    string
+	Reason string
 }
 
 // Error returns the error message.
-func (e *GoogleIDUserNotFound) Error() string {
-	return fmt.Sprintf("user with Google ID %s not found", e.GoogleID)
+func (e *InvalidItemAttribute) Error() string {
+	return fmt.Sprintf("attribute %q: %s", e.Key, e.Reason)
+}
+
+// InvalidListQuery represents a pagination, filter or sort query
+// parameter that a list endpoint does not accept.
+type InvalidListQuery struct {
+	Param  string
+	Reason string
+}
+
+// Error returns the error message.
+func (e *InvalidListQuery) Error() string {
+	return fmt.Sprintf("query parameter %q: %s", e.Param, e.Reason)
+}
+
+// InvalidApprovalTransition represents a submit, approve or reject action
+// that the document's current approval state does not allow.
+type InvalidApprovalTransition struct {
+	Action string
+	Reason string
+}
+
+// Error returns the error message.
+func (e *InvalidApprovalTransition) Error() string {
+	return fmt.Sprintf("cannot %s document: %s", e.Action, e.Reason)
+}
+
+// UnknownDocumentLine represents an item or packaging line ID that does not
+// belong to the document being updated.
+type UnknownDocumentLine struct {
+	Line string
+	ID   uint
+}
+
+// Error returns the error message.
+func (e *UnknownDocumentLine) Error() string {
+	return fmt.Sprintf("%s %d does not belong to this document", e.Line, e.ID)
 }
@@ -10,4 +10,50 @@ const (
 	CodeStockOutTotalQuantityNotMatching ErrorCode = "STOCK_OUT_TOTAL_QUANTITY_WRONG"
 	CodeGoogleUserNotFound               ErrorCode = "GOOGLE_USER_NOT_FOUND"
 	CodeStartTryOutEnvironment           ErrorCode = "START_TRYOUT_ENVIRONMENT"
+	CodeArchivedEntityReferenced         ErrorCode = "ARCHIVED_ENTITY_REFERENCED"
+	CodeInvalidCategoryHierarchy         ErrorCode = "INVALID_CATEGORY_HIERARCHY"
+	CodeInvalidStorageLocation           ErrorCode = "INVALID_STORAGE_LOCATION"
+	CodeInsufficientLocationStock        ErrorCode = "INSUFFICIENT_LOCATION_STOCK"
+	CodeStoreMismatch                    ErrorCode = "STORE_MISMATCH"
+	CodeInvalidItemAttribute             ErrorCode = "INVALID_ITEM_ATTRIBUTE"
+	CodeInvalidListQuery                 ErrorCode = "INVALID_LIST_QUERY"
+	CodeInvalidPackagingHierarchy        ErrorCode = "INVALID_PACKAGING_HIERARCHY"
+	CodeDocumentNotDraft                 ErrorCode = "DOCUMENT_NOT_DRAFT"
+	CodeEmptyDocument                    ErrorCode = "EMPTY_DOCUMENT"
+	CodeMissingPackagings                ErrorCode = "MISSING_PACKAGINGS"
+	CodePackagingItemMismatch            ErrorCode = "PACKAGING_ITEM_MISMATCH"
+	CodeInsufficientStock                ErrorCode = "INSUFFICIENT_STOCK"
+	CodeApprovalRequired                 ErrorCode = "APPROVAL_REQUIRED"
+	CodeDocumentLockedForApproval        ErrorCode = "DOCUMENT_LOCKED_FOR_APPROVAL"
+	CodeInvalidApprovalTransition        ErrorCode = "INVALID_APPROVAL_TRANSITION"
+	CodeInvalidCredentials               ErrorCode = "INVALID_CREDENTIALS"
+	CodeEmailNotVerified                 ErrorCode = "EMAIL_NOT_VERIFIED"
+	CodeTooManyLoginAttempts             ErrorCode = "TOO_MANY_LOGIN_ATTEMPTS"
+	CodeInvalidAuthToken                 ErrorCode = "INVALID_AUTH_TOKEN"
+	CodeEmailAlreadyRegistered           ErrorCode = "EMAIL_ALREADY_REGISTERED"
+	CodeInvalidRefreshToken              ErrorCode = "INVALID_REFRESH_TOKEN"
+	CodePermissionDenied                 ErrorCode = "PERMISSION_DENIED"
+	CodeLastOrganizationOwner            ErrorCode = "LAST_ORGANIZATION_OWNER"
+	CodeStoreAccessDenied                ErrorCode = "STORE_ACCESS_DENIED"
+	CodeInvalidInvitation                ErrorCode = "INVALID_INVITATION"
+	CodeInvitationEmailMismatch          ErrorCode = "INVITATION_EMAIL_MISMATCH"
+	CodeInvitationAlreadyOpen            ErrorCode = "INVITATION_ALREADY_OPEN"
+	CodeAlreadyOrganizationMember        ErrorCode = "ALREADY_ORGANIZATION_MEMBER"
+	CodeUserInAnotherOrganization        ErrorCode = "USER_IN_ANOTHER_ORGANIZATION"
+	CodeAPIKeyScopeDenied                ErrorCode = "API_KEY_SCOPE_DENIED"
+	CodeAPIKeyNotAllowed                 ErrorCode = "API_KEY_NOT_ALLOWED"
+	CodeAPIKeyStoreRestricted            ErrorCode = "API_KEY_STORE_RESTRICTED"
+	CodeIdentityUserNotFound             ErrorCode = "IDENTITY_USER_NOT_FOUND"
+	CodeInvalidLoginCode                 ErrorCode = "INVALID_LOGIN_CODE"
+	CodeTwoFactorUnavailable             ErrorCode = "TWO_FACTOR_UNAVAILABLE"
+	CodeTwoFactorAlreadyEnabled          ErrorCode = "TWO_FACTOR_ALREADY_ENABLED"
+	CodeTwoFactorNotEnabled              ErrorCode = "TWO_FACTOR_NOT_ENABLED"
+	CodeTwoFactorNotPending              ErrorCode = "TWO_FACTOR_NOT_PENDING"
+	CodeTwoFactorRequired                ErrorCode = "TWO_FACTOR_REQUIRED"
+	CodeTwoFactorSetupRequired           ErrorCode = "TWO_FACTOR_SETUP_REQUIRED"
+	CodeInvalidTwoFactorCode             ErrorCode = "INVALID_TWO_FACTOR_CODE"
+	CodePlatformAdminRequired            ErrorCode = "PLATFORM_ADMIN_REQUIRED"
+	CodeOrganizationNotTryOut            ErrorCode = "ORGANIZATION_NOT_TRY_OUT"
+	CodeUnknownDocumentLine              ErrorCode = "UNKNOWN_DOCUMENT_LINE"
+	CodePackagingNotFound                ErrorCode = "PACKAGING_NOT_FOUND"
 )
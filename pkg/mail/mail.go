@@ -0,0 +1,118 @@
+// Package mail sends transactional emails through a pluggable Sender. The
+// default sender is picked from the environment: SMTP when SMTP_HOST is set,
+// otherwise a sender that only logs, for local development.
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(msg Message) error
+}
+
+var (
+	mu     sync.RWMutex
+	sender Sender = newSenderFromEnv()
+)
+
+// SetSender replaces the sender used by Send, e.g. with a fake in tests.
+func SetSender(s Sender) {
+	mu.Lock()
+	defer mu.Unlock()
+	sender = s
+}
+
+// Send delivers msg with the current sender.
+func Send(msg Message) error {
+	mu.RLock()
+	s := sender
+	mu.RUnlock()
+	return s.Send(msg)
+}
+
+func newSenderFromEnv() Sender {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return LogSender{}
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@graoagrao.local"
+	}
+
+	return &SMTPSender{
+		Addr:     net.JoinHostPort(host, port),
+		Host:     host,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}
+}
+
+// SMTPSender delivers through an SMTP server. Without a username no
+// authentication is attempted, which suits local catchers such as Mailpit.
+type SMTPSender struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send implements Sender.
+func (s *SMTPSender) Send(msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	if err := smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, s.format(msg)); err != nil {
+		logger.Log.Errorf("Error sending mail to %s: %v", msg.To, err)
+		return err
+	}
+	return nil
+}
+
+func (s *SMTPSender) format(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// LogSender writes messages to the log instead of delivering them.
+type LogSender struct{}
+
+// Send implements Sender.
+func (LogSender) Send(msg Message) error {
+	logger.Log.Warnf("SMTP_HOST not set, mail to %s not delivered. Subject: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
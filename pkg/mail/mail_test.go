@@ -0,0 +1,101 @@
+package mail
+
+import (
+	"testing"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/mail/smtptest"
+)
+
+func TestSMTPSender(t *testing.T) {
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	sender := &SMTPSender{Addr: server.Addr, Host: server.Host, From: "no-reply@example.com"}
+	msg := Message{
+		To:      "ana@example.com",
+		Subject: "Olá",
+		Body:    "first line\n.dotted line\nlast line\n",
+	}
+	if err := sender.Send(msg); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	got := server.Messages()
+	if len(got) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(got))
+	}
+	m := got[0]
+	if m.From != "no-reply@example.com" || len(m.To) != 1 || m.To[0] != "ana@example.com" {
+		t.Errorf("envelope = %s -> %v", m.From, m.To)
+	}
+	if m.Username != "" {
+		t.Errorf("authenticated as %q without a username", m.Username)
+	}
+	if m.Header.Get("Subject") != "Olá" || m.Header.Get("To") != "ana@example.com" {
+		t.Errorf("header = %v", m.Header)
+	}
+	if m.Header.Get("Content-Type") != "text/plain; charset=UTF-8" {
+		t.Errorf("Content-Type = %q", m.Header.Get("Content-Type"))
+	}
+	if m.Body != msg.Body {
+		t.Errorf("body = %q, want %q", m.Body, msg.Body)
+	}
+}
+
+func TestSMTPSenderAuth(t *testing.T) {
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	sender := &SMTPSender{
+		Addr:     server.Addr,
+		Host:     server.Host,
+		Username: "mailer",
+		Password: "secret",
+		From:     "no-reply@example.com",
+	}
+	if err := sender.Send(Message{To: "ana@example.com", Subject: "s", Body: "b"}); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if got := server.Messages(); len(got) != 1 || got[0].Username != "mailer" {
+		t.Errorf("messages = %+v, want one sent as mailer", got)
+	}
+}
+
+func TestSMTPSenderUnreachable(t *testing.T) {
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	sender := &SMTPSender{Addr: server.Addr, Host: server.Host, From: "no-reply@example.com"}
+	if err := sender.Send(Message{To: "ana@example.com"}); err == nil {
+		t.Errorf("Send to a closed server returned no error")
+	}
+}
+
+type recordingSender struct{ sent []Message }
+
+func (r *recordingSender) Send(msg Message) error {
+	r.sent = append(r.sent, msg)
+	return nil
+}
+
+func TestSetSender(t *testing.T) {
+	rec := &recordingSender{}
+	SetSender(rec)
+	defer SetSender(LogSender{})
+
+	if err := Send(Message{To: "ana@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.sent) != 1 || rec.sent[0].To != "ana@example.com" {
+		t.Errorf("sent = %+v", rec.sent)
+	}
+}
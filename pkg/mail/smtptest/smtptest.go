@@ -0,0 +1,197 @@
+// Package smtptest provides an in-process SMTP server that keeps the
+// messages it receives, so email flows can be tested end to end through
+// mail.SMTPSender without a real mail server.
+package smtptest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+)
+
+// Message is a message the server accepted.
+type Message struct {
+	From string
+	To   []string
+	// Username is the one given with AUTH PLAIN, empty without auth.
+	Username string
+	// Header and Body are parsed from the DATA section; Body has CRLF line
+	// endings turned back into LF.
+	Header mail.Header
+	Body   string
+}
+
+// Server is a minimal SMTP server on a loopback port. It speaks enough of
+// the protocol for net/smtp: EHLO, AUTH PLAIN, MAIL, RCPT, DATA, RSET, NOOP
+// and QUIT. It never offers STARTTLS.
+type Server struct {
+	// Addr is the host:port to send to.
+	Addr string
+	// Host is the host part of Addr, for smtp.PlainAuth.
+	Host string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewServer starts a server on 127.0.0.1. Close it when done.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	host, _, _ := net.SplitHostPort(l.Addr().String())
+	s := &Server{Addr: l.Addr().String(), Host: host, listener: l}
+
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server and waits for open sessions to end.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// Messages returns the messages accepted so far, oldest first. A message is
+// stored before its DATA is acknowledged, so it is listed once the sending
+// call has returned.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.session(conn)
+		}()
+	}
+}
+
+func (s *Server) session(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) bool {
+		_, err := conn.Write([]byte(line + "\r\n"))
+		return err == nil
+	}
+
+	var msg Message
+	if !reply("220 smtptest ready") {
+		return
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+
+		var ok bool
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			ok = reply("250-smtptest") && reply("250-8BITMIME") && reply("250 AUTH PLAIN")
+		case "HELO":
+			ok = reply("250 smtptest")
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			if !strings.EqualFold(mech, "PLAIN") {
+				ok = reply("504 unsupported mechanism")
+				break
+			}
+			// identity NUL username NUL password
+			creds, err := base64.StdEncoding.DecodeString(initial)
+			parts := strings.Split(string(creds), "\x00")
+			if err != nil || len(parts) != 3 {
+				ok = reply("501 malformed credentials")
+				break
+			}
+			msg.Username = parts[1]
+			ok = reply("235 authenticated")
+		case "MAIL":
+			msg.From = address(arg)
+			msg.To = nil
+			ok = reply("250 ok")
+		case "RCPT":
+			msg.To = append(msg.To, address(arg))
+			ok = reply("250 ok")
+		case "DATA":
+			if !reply("354 end with <CRLF>.<CRLF>") {
+				return
+			}
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			parsed, err := mail.ReadMessage(strings.NewReader(data))
+			if err != nil {
+				ok = reply("554 malformed message")
+				break
+			}
+			body, _ := io.ReadAll(parsed.Body)
+			msg.Header = parsed.Header
+			msg.Body = strings.ReplaceAll(string(body), "\r\n", "\n")
+
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+
+			msg = Message{Username: msg.Username}
+			ok = reply("250 queued")
+		case "RSET":
+			msg = Message{Username: msg.Username}
+			ok = reply("250 ok")
+		case "NOOP":
+			ok = reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			ok = reply("502 command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// address extracts the path of a "FROM:<a@b>" or "TO:<a@b>" argument.
+func address(arg string) string {
+	_, path, _ := strings.Cut(arg, ":")
+	path, _, _ = strings.Cut(strings.TrimSpace(path), " ")
+	return strings.Trim(path, "<>")
+}
+
+// readData reads a DATA section up to the lone dot, undoing dot-stuffing.
+func readData(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if line == ".\r\n" {
+			return b.String(), nil
+		}
+		b.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
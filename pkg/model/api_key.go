@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// APIKey lets an integration call the API on behalf of the user who created
+// it. The key can do no more than its scopes and the creator's role allow,
+// and only in StoreID when it is set. Managing stores, roles, invitations and
+// keys always needs a logged in user, so keys are never granted store:manage.
+type APIKey struct {
+	ID             uint
+	OrganizationID uint
+	Name           string
+	Prefix         string
+	Scopes         []Permission
+	StoreID        *uint
+	CreatedBy      *uint
+	ExpiresAt      *time.Time // nil for keys that never expire
+	LastUsedAt     *time.Time
+	LastUsedIP     *string
+	RevokedAt      *time.Time
+	CreatedAt      time.Time
+}
+
+// Allows reports whether the key was granted the permission.
+func (k *APIKey) Allows(p Permission) bool {
+	for _, scope := range k.Scopes {
+		if scope == p {
+			return true
+		}
+	}
+	return false
+}
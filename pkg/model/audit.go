@@ -0,0 +1,30 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit log actions. An update that finalizes a stock document is logged as
+// AuditFinalize.
+const (
+	AuditCreate   = "create"
+	AuditUpdate   = "update"
+	AuditDelete   = "delete"
+	AuditFinalize = "finalize"
+)
+
+// AuditEntry is one change to a row of the tenant's data, written by the
+// audit triggers. Before is nil for creations and After for deletions.
+// UserID is nil for changes made outside a request.
+type AuditEntry struct {
+	ID         uint64
+	OccurredAt time.Time
+	UserID     *uint
+	APIKeyID   *uint
+	EntityType string
+	EntityID   string
+	Action     string
+	Before     json.RawMessage
+	After      json.RawMessage
+}
@@ -5,10 +5,13 @@ import "time"
 type Category struct {
 	ID          uint
 	Description string
+	ParentID    *uint
+	Children    []*Category
 
 	CreatedBy User
 	Store     Store
 
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ArchivedAt *time.Time
 }
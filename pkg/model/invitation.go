@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// Invitation statuses, derived from the invitation's timestamps.
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// Invitation asks the owner of Email to join the organization with Role, in
+// the whole organization or only in one store when StoreID is set.
+type Invitation struct {
+	ID           uint
+	Organization Organization
+	Email        string
+	Role         Role
+	StoreID      *uint
+	InvitedBy    *uint
+	ExpiresAt    time.Time
+	LastSentAt   time.Time
+	AcceptedAt   *time.Time
+	AcceptedBy   *uint
+	RevokedAt    *time.Time
+	CreatedAt    time.Time
+}
+
+// Status reports whether the invitation can still be accepted, or why not.
+func (i *Invitation) Status() string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationAccepted
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case time.Now().After(i.ExpiresAt):
+		return InvitationExpired
+	default:
+		return InvitationPending
+	}
+}
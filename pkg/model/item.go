@@ -9,10 +9,12 @@ type Item struct {
 	Category       Category
 	UnitOfMeasure  UnitOfMeasure
 	IsFractionable bool
+	Attributes     []ItemAttributeValue
 
 	CreatedBy User
 	Store     Store
 
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ArchivedAt *time.Time
 }
@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// Supported types for tenant-defined item attributes.
+const (
+	AttributeTypeText   = "text"
+	AttributeTypeNumber = "number"
+	AttributeTypeEnum   = "enum"
+	AttributeTypeDate   = "date"
+)
+
+// AttributeDateLayout is the wire format of date attributes.
+const AttributeDateLayout = "2006-01-02"
+
+// ItemAttributeDefinition describes a custom field tenants can set on items.
+type ItemAttributeDefinition struct {
+	ID        uint
+	StoreID   uint
+	Key       string
+	Label     string
+	Type      string
+	Options   []string
+	Required  bool
+	Min       *float64
+	Max       *float64
+	CreatedBy User
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ItemAttributeValue holds the value of one attribute on an item. Only the
+// field matching Definition.Type is set.
+type ItemAttributeValue struct {
+	Definition ItemAttributeDefinition
+	Text       *string
+	Number     *float64
+	Date       *time.Time
+}
+
+// Comparison operators accepted by attribute filters.
+const (
+	AttributeFilterEq  = "eq"
+	AttributeFilterMin = "min"
+	AttributeFilterMax = "max"
+)
+
+// ItemAttributeFilter restricts a listing to items whose attribute matches.
+// Value is already parsed into the type of Definition.
+type ItemAttributeFilter struct {
+	Definition ItemAttributeDefinition
+	Op         string
+	Value      any
+}
@@ -1,16 +1,52 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
 
 type ItemPackaging struct {
 	ID          uint
 	Item        Item
 	Description string
-	Quantity    float32
+	Quantity    decimal.Decimal // base units per package
+
+	// Exact base-unit equivalent, multiplied down the whole chain without
+	// rounding to the column scale. Only set where loaded for reconciling
+	// document totals.
+	BaseQuantity decimal.Decimal
+
+	// Set when the packaging holds InnerQuantity units of another packaging
+	// of the same item instead of base units directly.
+	InnerPackagingID *uint
+	InnerQuantity    *decimal.Decimal
 
 	CreatedBy User
 	Store     Store
 
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ArchivedAt *time.Time
+}
+
+// PackagingBreakdownLine is one packaging of a suggested breakdown and how
+// many packages of it to use.
+type PackagingBreakdownLine struct {
+	ItemPackaging ItemPackaging
+	Quantity      int64
+	Held          *int64 // packages currently in stock, set when stock was considered
+}
+
+// PackagingBreakdown is a suggested set of packagings for a total quantity.
+// When no combination matches exactly, Lines sum to the closest total below
+// the requested one and Remainder holds the difference.
+type PackagingBreakdown struct {
+	ItemID       uint
+	Requested    decimal.Decimal
+	Total        decimal.Decimal
+	Remainder    decimal.Decimal
+	Exact        bool
+	PackageCount int64
+	Lines        []PackagingBreakdownLine
 }
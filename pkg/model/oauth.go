@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// OAuthState is the server-side record of a login started with an identity
+// provider, found again by the state the provider sends back.
+type OAuthState struct {
+	Provider        string
+	Nonce           string
+	CodeVerifier    string // PKCE verifier, the provider only saw its challenge
+	IsTryOut        bool
+	InvitationToken string
+	ExpiresAt       time.Time
+}
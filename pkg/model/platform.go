@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// OrganizationUsage is an organization with the figures platform admins
+// look at to operate it.
+type OrganizationUsage struct {
+	Organization
+	UserCount          int
+	ActiveUserCount    int
+	ActiveSessionCount int
+	APIKeyCount        int        // keys not revoked
+	LastLoginAt        *time.Time // nil when no user ever logged in
+	// Counts of the tenant's data. They are nil when the tenant schema
+	// cannot be read, e.g. it is missing or behind on migrations.
+	StoreCount    *int
+	ItemCount     *int
+	StockInCount  *int
+	StockOutCount *int
+}
+
+// OrganizationUser is a user of an organization as platform admins see it.
+type OrganizationUser struct {
+	ID              uint
+	Username        string
+	Email           string
+	AuthProvider    string
+	IsActive        bool
+	CreatedAt       time.Time
+	LastLogin       *time.Time
+	EmailVerifiedAt *time.Time
+	TwoFactor       bool
+	Role            *Role // organization-wide role, nil without one
+}
@@ -0,0 +1,85 @@
+package model
+
+import "time"
+
+// Role is what a user may do in the organization or in one of its stores.
+type Role string
+
+const (
+	RoleOwner   Role = "owner"
+	RoleManager Role = "manager"
+	RoleClerk   Role = "clerk"
+	RoleViewer  Role = "viewer"
+)
+
+// Permission is an action over one of the API's route groups.
+type Permission string
+
+const (
+	PermStoreRead   Permission = "store:read"
+	PermStoreWrite  Permission = "store:write"
+	PermStoreManage Permission = "store:manage" // create and delete stores, approval policies and roles
+
+	PermCatalogRead   Permission = "catalog:read" // items, categories, units, packagings and attributes
+	PermCatalogWrite  Permission = "catalog:write"
+	PermCatalogDelete Permission = "catalog:delete"
+
+	PermStockRead     Permission = "stock:read"
+	PermStockWrite    Permission = "stock:write" // drafts, locations and relocations
+	PermStockFinalize Permission = "stock:finalize"
+	PermStockApprove  Permission = "stock:approve"
+
+	PermAuditRead Permission = "audit:read"
+)
+
+// rolePermissions is the permission matrix. Each role has the permissions
+// of the roles below it.
+var rolePermissions = map[Role][]Permission{
+	RoleViewer: {
+		PermStoreRead, PermCatalogRead, PermStockRead,
+	},
+	RoleClerk: {
+		PermStoreRead, PermCatalogRead, PermStockRead,
+		PermStockWrite, PermStockFinalize,
+	},
+	RoleManager: {
+		PermStoreRead, PermCatalogRead, PermStockRead,
+		PermStockWrite, PermStockFinalize,
+		PermStoreWrite, PermCatalogWrite, PermCatalogDelete, PermStockApprove,
+		PermAuditRead,
+	},
+	RoleOwner: {
+		PermStoreRead, PermCatalogRead, PermStockRead,
+		PermStockWrite, PermStockFinalize,
+		PermStoreWrite, PermCatalogWrite, PermCatalogDelete, PermStockApprove,
+		PermAuditRead, PermStoreManage,
+	},
+}
+
+// Can reports whether the role grants the permission.
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions lists what the role grants.
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
+// RoleAssignment gives a user a role in the whole organization, or only in
+// one store when StoreID is set.
+type RoleAssignment struct {
+	ID         uint
+	User       User
+	StoreID    *uint
+	Role       Role
+	AssignedBy *uint
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -1,12 +1,50 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
 
 type Stock struct {
 	ID           uint
 	Item         Item
 	CreatedBy    User
-	CurrentStock int
+	CurrentStock decimal.Decimal
+	Locations    []StockLocation
+	Unassigned   decimal.Decimal
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
+
+// CategoryStockSummary holds stock figures for a single category. The Total*
+// fields include every descendant category.
+type CategoryStockSummary struct {
+	Category       Category
+	ItemCount      int
+	Quantity       decimal.Decimal
+	Value          decimal.Decimal
+	TotalItemCount int
+	TotalQuantity  decimal.Decimal
+	TotalValue     decimal.Decimal
+}
+
+// StockLocationKey identifies an item's balance at one storage location.
+type StockLocationKey struct {
+	ItemID     uint
+	LocationID uint
+}
+
+// StockLevels holds current item totals and per-location balances, keyed for
+// lookups while checking documents against them.
+type StockLevels struct {
+	Items     map[uint]decimal.Decimal
+	Locations map[StockLocationKey]decimal.Decimal
+}
+
+// StockLevelChange is an item's stock before and after a document is finalized.
+type StockLevelChange struct {
+	Item      Item
+	Current   decimal.Decimal
+	Resulting decimal.Decimal
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
+
+// StockDocumentType names a kind of stock document in the approval history.
+type StockDocumentType string
+
+const (
+	StockInDocument    StockDocumentType = "stock_in"
+	StockOutDocument   StockDocumentType = "stock_out"
+	StockWasteDocument StockDocumentType = "stock_waste"
+)
+
+// Approval states of a draft document, stored next to its draft/finalized
+// status. A nil ApprovalStatus means the document was never submitted.
+const (
+	ApprovalSubmitted = "submitted"
+	ApprovalApproved  = "approved"
+	ApprovalRejected  = "rejected"
+)
+
+// ApprovalDecision is one entry of a document's approval history. Action is
+// one of the approval states.
+type ApprovalDecision struct {
+	ID           uint
+	DocumentType StockDocumentType
+	DocumentID   uint
+	Action       string
+	Comment      *string
+	DecidedBy    User
+	CreatedAt    time.Time
+}
+
+// ApprovalPolicy is a store's approval configuration. Documents only need an
+// approval when Enabled and one of the thresholds matches.
+type ApprovalPolicy struct {
+	StoreID               uint
+	Enabled               bool
+	RequireForWaste       bool
+	RequireForStockOut    bool
+	StockInValueThreshold *decimal.Decimal // nil never requires approval for stock-ins
+	AllowSelfApproval     bool
+	UpdatedAt             *time.Time // nil until the policy is first saved
+}
@@ -1,23 +1,30 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
 
 type StockIn struct {
-	ID          uint
-	CreatedBy   User
-	Items       []StockInItem
-	Status      string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	FinalizedAt *time.Time
+	ID             uint
+	CreatedBy      User
+	Items          []StockInItem
+	Status         string
+	ApprovalStatus *string
+	Approvals      []ApprovalDecision
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	FinalizedAt    *time.Time
 }
 
 type StockInItem struct {
 	ID            uint
 	StockInID     uint
 	Item          Item
-	BuyPrice      float64
-	TotalQuantity float64
+	BuyPrice      decimal.Decimal
+	TotalQuantity decimal.Decimal
+	Location      *StorageLocation
 	Packagings    []StockInPackaging
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
@@ -1,22 +1,29 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
 
 type StockOut struct {
-	ID          uint
-	CreatedBy   User
-	Items       []StockOutItem
-	Status      string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	FinalizedAt *time.Time
+	ID             uint
+	CreatedBy      User
+	Items          []StockOutItem
+	Status         string
+	ApprovalStatus *string
+	Approvals      []ApprovalDecision
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	FinalizedAt    *time.Time
 }
 
 type StockOutItem struct {
 	ID            uint
 	StockOutID    uint
 	Item          Item
-	TotalQuantity float64
+	TotalQuantity decimal.Decimal
+	Location      *StorageLocation
 	Packagings    []StockOutPackaging
 }
 
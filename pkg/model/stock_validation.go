@@ -0,0 +1,43 @@
+package model
+
+import (
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+)
+
+type ValidationSeverity string
+
+const (
+	// ValidationError problems make finalization fail or leave wrong data.
+	ValidationError ValidationSeverity = "error"
+	// ValidationWarning problems are accepted by finalization but likely mistakes.
+	ValidationWarning ValidationSeverity = "warning"
+)
+
+// StockDocumentProblem is one finding of a draft check. LineID and ItemID are
+// nil for document-level problems. PgCode is the code the finalize triggers
+// raise for the same problem, when they check it.
+type StockDocumentProblem struct {
+	LineID       *uint
+	ItemID       *uint
+	Severity     ValidationSeverity
+	PgCode       string
+	InternalCode errorCodes.ErrorCode
+	Message      string
+}
+
+// StockDocumentValidation lists every problem found on a stock document.
+type StockDocumentValidation struct {
+	DocumentID uint
+	Status     string
+	Problems   []StockDocumentProblem
+}
+
+// Valid reports whether the document has no error-level problems.
+func (v *StockDocumentValidation) Valid() bool {
+	for _, p := range v.Problems {
+		if p.Severity == ValidationError {
+			return false
+		}
+	}
+	return true
+}
@@ -1,12 +1,19 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
 
 type StockWaste struct {
 	StockWasteID   uint
 	Item           Item
-	WastedQuantity float64
+	WastedQuantity decimal.Decimal
+	Location       *StorageLocation // nil takes the waste from unassigned stock
 	Status         string
+	ApprovalStatus *string // nil when never submitted
+	Approvals      []ApprovalDecision
 	ReasonText     string
 	ReasonImageURL *string // nullable
 	CreatedBy      User
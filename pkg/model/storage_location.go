@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+)
+
+// StorageLocation is a place inside a store where stock is kept
+// (stockroom, shelf area, cold room...).
+type StorageLocation struct {
+	ID        uint
+	StoreID   uint
+	Name      string
+	CreatedBy User
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// StockLocation is the quantity of an item held at a single location.
+type StockLocation struct {
+	Location StorageLocation
+	Quantity decimal.Decimal
+}
+
+// StockRelocation moves stock of an item between two locations of the same
+// store. A nil From moves unassigned stock into To.
+type StockRelocation struct {
+	ID        uint
+	StoreID   uint
+	Item      Item
+	From      *StorageLocation
+	To        StorageLocation
+	Quantity  decimal.Decimal
+	CreatedBy User
+	CreatedAt time.Time
+}
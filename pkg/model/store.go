@@ -10,3 +10,12 @@ type Store struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// StoreMember gives a user access to a store. Organization owners can use
+// every store without being members.
+type StoreMember struct {
+	StoreID   uint
+	User      User
+	AddedBy   *uint
+	CreatedAt time.Time
+}
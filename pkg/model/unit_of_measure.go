@@ -9,6 +9,7 @@ type UnitOfMeasure struct {
 	CreatedBy User
 	Store     Store
 
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ArchivedAt *time.Time
 }
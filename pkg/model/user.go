@@ -12,22 +12,39 @@ type GoogleUserInfo struct {
 	VerifiedEmail bool   `json:"verified_email"`
 }
 
+// Identity is a user's account at an external identity provider, as the
+// provider reported it on login. Users are linked to identities by
+// provider and subject, the email may change.
+type Identity struct {
+	Provider      string `json:"provider"`
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Picture       string `json:"picture"`
+}
+
 type User struct {
-	ID           uint         `json:"id"`
-	Username     string       `json:"username"`
-	Email        string       `json:"email"`
-	PasswordHash string       `json:"password_hash"`
-	Salt         string       `json:"salt"`
-	GoogleID     string       `json:"google_id"`
-	GivenName    string       `json:"given_name"`
-	FamilyName   string       `json:"family_name"`
-	PictureURL   string       `json:"picture_url"`
-	AuthProvider string       `json:"auth_provider"`
-	CreatedAt    time.Time    `json:"created_at"`
-	UpdatedAt    time.Time    `json:"updated_at"`
-	LastLogin    time.Time    `json:"last_login"`
-	IsActive     bool         `json:"is_active"`
-	Organization Organization `json:"organization"`
+	ID              uint       `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	PasswordHash    string     `json:"password_hash"`
+	Salt            string     `json:"salt"`
+	GoogleID        string     `json:"google_id"`
+	GivenName       string     `json:"given_name"`
+	FamilyName      string     `json:"family_name"`
+	PictureURL      string     `json:"picture_url"`
+	AuthProvider    string     `json:"auth_provider"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	LastLogin       time.Time  `json:"last_login"`
+	IsActive        bool       `json:"is_active"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"` // nil until the address is confirmed
+	// TwoFactorEnabledAt is nil unless the user confirmed a TOTP enrollment
+	TwoFactorEnabledAt *time.Time   `json:"two_factor_enabled_at"`
+	Organization       Organization `json:"organization"`
 }
 
 type Organization struct {
@@ -41,4 +58,16 @@ type Organization struct {
 	ExpiresAt *time.Time `json:"expires_at"` // Its a pointer, so it can be null
 	IsTryOut  bool       `json:"is_try_out"`
 	IsActive  bool       `json:"is_active"`
+	// RequireTwoFactor makes the password logins of every user need a
+	// second factor
+	RequireTwoFactor bool `json:"require_two_factor"`
+}
+
+// TwoFactor is a user's TOTP enrollment. It is pending until EnabledAt is
+// set by the first valid code.
+type TwoFactor struct {
+	Secret          string
+	EnabledAt       *time.Time
+	LastCounter     int64 // time step of the last accepted code
+	BackupCodesLeft int
 }
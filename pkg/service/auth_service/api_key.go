@@ -0,0 +1,57 @@
+package auth_service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/api_key_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+const (
+	// apiKeyPrefix marks API keys so they are easy to recognize, e.g. by
+	// secret scanners.
+	apiKeyPrefix = "gag_"
+	// apiKeyDisplayLength is how much of a key is kept in clear to tell keys
+	// apart.
+	apiKeyDisplayLength = 12
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("invalid, revoked or expired API key")
+)
+
+// CreateAPIKey stores a new key and returns its secret. Only a hash is kept,
+// so the secret cannot be shown again.
+func CreateAPIKey(key *model.APIKey) (string, error) {
+	token, _, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	secret := apiKeyPrefix + token
+	key.Prefix = secret[:apiKeyDisplayLength]
+
+	if err := api_key_repository.CreateAPIKey(key, hashToken(secret)); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// AuthenticateAPIKey returns a usable key and the user it acts for, and
+// records its use from ipAddress.
+func AuthenticateAPIKey(secret, ipAddress string) (*model.APIKey, *model.User, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	key, user, err := api_key_repository.AuthenticateAPIKey(hashToken(secret), ipAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key == nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	return key, user, nil
+}
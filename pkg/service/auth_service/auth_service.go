@@ -0,0 +1,307 @@
+package auth_service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/login_attempt_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/user_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/user_token_repository"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/mail"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/service/tryout_service"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/util"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// LoginThrottleWindow is how far back failed logins are counted.
+	LoginThrottleWindow = login_attempt_repository.ThrottleWindow
+	// MaxFailedLoginsPerEmail locks an account for the rest of the window.
+	MaxFailedLoginsPerEmail = 5
+	// MaxFailedLoginsPerIP stops a single client from trying many accounts.
+	MaxFailedLoginsPerIP = 20
+
+	EmailVerificationTTL = 24 * time.Hour
+	PasswordResetTTL     = time.Hour
+
+	// saltBytes keeps password+salt within bcrypt's 72 byte input limit for
+	// passwords of up to 64 bytes.
+	saltBytes = 4
+
+	// userEmailConstraint is the unique constraint on tb_user.email.
+	userEmailConstraint = "tb_user_email_key"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrEmailNotVerified   = errors.New("email address not verified")
+	ErrTooManyAttempts    = errors.New("too many failed login attempts")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrEmailTaken         = errors.New("email already registered")
+)
+
+// dummyHash is compared against when the email is unknown, so a login takes
+// about as long whether or not the account exists.
+var dummyHash, _ = util.HashPassword("dummy-password", "00000000")
+
+// Register signs a new local user up into a try-out environment and mails an
+// email verification link. The user cannot log in until the email is verified.
+func Register(user *model.User, password string) (model.TryOutJob, error) {
+	user.Email = normalizeEmail(user.Email)
+
+	existing, err := user_repository.GetUserWithOrganizationByEmail(user.Email)
+	if err != nil {
+		return model.TryOutJob{}, err
+	}
+	if existing != nil {
+		return model.TryOutJob{}, ErrEmailTaken
+	}
+
+	if err := setPassword(user, password); err != nil {
+		return model.TryOutJob{}, err
+	}
+	user.AuthProvider = "local"
+	user.IsActive = true
+
+	job, err := tryout_service.PublishTryOutEnvironmentJob(user, nil)
+	if err != nil {
+		// Lost a race with another sign up for the same email
+		if isEmailTaken(err) {
+			return job, ErrEmailTaken
+		}
+		return job, err
+	}
+
+	if err := SendEmailVerification(user.Email); err != nil {
+		// The account exists, the user can ask for another link
+		logger.Log.Errorf("Failed to send verification email: %v", err)
+	}
+
+	return job, nil
+}
+
+// Login checks a password and returns the user with its organization.
+// Repeated failures for an email or from an IP address are throttled. When
+// the user needs a second factor, the login only counts as successful once
+// CompleteTwoFactorLogin accepts it.
+func Login(email, password, ipAddress string) (*model.User, error) {
+	email = normalizeEmail(email)
+
+	if err := checkLoginThrottle(email, ipAddress); err != nil {
+		return nil, err
+	}
+
+	user, err := user_repository.GetUserWithOrganizationByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || user.PasswordHash == "" {
+		util.CheckPassword(dummyHash, password, "00000000")
+		recordAttempt(email, ipAddress, false)
+		return nil, ErrInvalidCredentials
+	}
+
+	if !util.CheckPassword(user.PasswordHash, password, user.Salt) || !user.IsActive || !user.Organization.IsActive {
+		recordAttempt(email, ipAddress, false)
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+
+	// A login waiting for its second factor has not succeeded yet: it must
+	// not reset the failure count that also throttles the 2FA codes.
+	if !TwoFactorRequired(user) {
+		recordAttempt(email, ipAddress, true)
+	}
+
+	if util.PasswordNeedsRehash(user.PasswordHash) {
+		if err := setPassword(user, password); err == nil {
+			user_repository.UpdateUserPassword(user.ID, user.PasswordHash, user.Salt)
+		}
+	}
+
+	return user, nil
+}
+
+// SendEmailVerification mails a new verification link to an unverified user.
+// Unknown and already verified emails are ignored so callers cannot tell
+// which addresses have accounts.
+func SendEmailVerification(email string) error {
+	user, err := user_repository.GetUserWithOrganizationByEmail(normalizeEmail(email))
+	if err != nil || user == nil || user.EmailVerifiedAt != nil {
+		return err
+	}
+
+	token, err := issueToken(user.ID, user_token_repository.PurposeEmailVerification, EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return sendEmailVerification(user.Email, token)
+}
+
+func sendEmailVerification(email, token string) error {
+	return mail.Send(mail.Message{
+		To:      email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf(
+			"Confirm your email address by opening the link below. It expires in %s.\n\n%s/verify-email?token=%s\n",
+			EmailVerificationTTL, frontendURL(), token,
+		),
+	})
+}
+
+// VerifyEmail consumes a verification token and marks the email verified.
+func VerifyEmail(token string) error {
+	userID, ok, err := user_token_repository.ConsumeUserToken(user_token_repository.PurposeEmailVerification, hashToken(token))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidToken
+	}
+
+	return user_repository.StampNowEmailVerified(userID)
+}
+
+// RequestPasswordReset mails a password reset link. Unknown emails are
+// ignored so callers cannot tell which addresses have accounts.
+func RequestPasswordReset(email string) error {
+	user, err := user_repository.GetUserWithOrganizationByEmail(normalizeEmail(email))
+	if err != nil || user == nil || !user.IsActive {
+		return err
+	}
+
+	token, err := issueToken(user.ID, user_token_repository.PurposePasswordReset, PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return sendPasswordReset(user.Email, token)
+}
+
+func sendPasswordReset(email, token string) error {
+	return mail.Send(mail.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Choose a new password by opening the link below. It expires in %s and works once.\n"+
+				"If you did not ask for it, ignore this email.\n\n%s/reset-password?token=%s\n",
+			PasswordResetTTL, frontendURL(), token,
+		),
+	})
+}
+
+// ResetPassword consumes a reset token and sets a new password. Following
+// the mailed link also proves the email address, so it is marked verified.
+// The password is hashed first so a password bcrypt refuses leaves the token
+// usable.
+func ResetPassword(token, password string) error {
+	user := &model.User{}
+	if err := setPassword(user, password); err != nil {
+		return err
+	}
+
+	userID, ok, err := user_token_repository.ConsumeUserToken(user_token_repository.PurposePasswordReset, hashToken(token))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidToken
+	}
+
+	if err := user_repository.UpdateUserPassword(userID, user.PasswordHash, user.Salt); err != nil {
+		return err
+	}
+
+	return user_repository.StampNowEmailVerified(userID)
+}
+
+func setPassword(user *model.User, password string) error {
+	salt, err := util.GenerateSalt(saltBytes)
+	if err != nil {
+		return err
+	}
+	hash, err := util.HashPassword(password, salt)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	user.Salt = salt
+	return nil
+}
+
+// issueToken stores the hash of a new random token and returns the token.
+func issueToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := user_token_repository.SaveUserToken(userID, purpose, hash, time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// newOpaqueToken returns a random URL-safe token and the hash to store.
+func newOpaqueToken() (token, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(raw)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkLoginThrottle returns ErrTooManyAttempts while an email or IP
+// address has too many recent failed logins.
+func checkLoginThrottle(email, ipAddress string) error {
+	byEmail, byIP, err := login_attempt_repository.CountFailedLoginAttempts(email, ipAddress, time.Now().Add(-LoginThrottleWindow))
+	if err != nil {
+		return err
+	}
+	if byEmail >= MaxFailedLoginsPerEmail || byIP >= MaxFailedLoginsPerIP {
+		logger.Log.Warnf("Login throttled for %s from %s", email, ipAddress)
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+func recordAttempt(email, ipAddress string, succeeded bool) {
+	if err := login_attempt_repository.SaveLoginAttempt(email, ipAddress, succeeded); err != nil {
+		logger.Log.Errorf("Failed to record login attempt: %v", err)
+	}
+}
+
+// isEmailTaken reports whether err is the unique violation of tb_user.email.
+func isEmailTaken(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == userEmailConstraint
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func frontendURL() string {
+	return strings.TrimRight(os.Getenv("FRONTEND_URL"), "/")
+}
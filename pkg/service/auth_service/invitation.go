@@ -0,0 +1,300 @@
+package auth_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/invitation_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/role_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/user_repository"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/mail"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// InvitationTTL is how long a mailed invitation link works. Resending an
+// invitation starts it over.
+const InvitationTTL = 7 * 24 * time.Hour
+
+// openInvitationConstraint allows one open invitation per organization and email.
+const openInvitationConstraint = "uq_organization_invitation_open"
+
+var (
+	ErrInvalidInvitation         = errors.New("invalid or expired invitation")
+	ErrInvitationEmailMismatch   = errors.New("invitation was sent to another email")
+	ErrAlreadyMember             = errors.New("user already belongs to the organization")
+	ErrInvitationPending         = errors.New("email already has an open invitation")
+	ErrUserInAnotherOrganization = errors.New("user belongs to another organization")
+)
+
+// CreateInvitation stores an invitation into inv.Organization and mails its
+// link. An email can have only one pending invitation per organization; an
+// expired one is replaced.
+func CreateInvitation(inv *model.Invitation) error {
+	inv.Email = normalizeEmail(inv.Email)
+
+	existing, err := user_repository.GetUserWithOrganizationByEmail(inv.Email)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Organization.ID == inv.Organization.ID {
+		return ErrAlreadyMember
+	}
+
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+	inv.ExpiresAt = time.Now().Add(InvitationTTL)
+
+	if err := invitation_repository.CreateInvitation(inv, hash); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == openInvitationConstraint {
+			return ErrInvitationPending
+		}
+		return err
+	}
+
+	if err := sendInvitation(inv, token); err != nil {
+		// The invitation exists, the owner can resend it
+		logger.Log.Errorf("Failed to send invitation email: %v", err)
+	}
+
+	return nil
+}
+
+// ResendInvitation mails a new link for an open invitation on behalf of
+// actorID and invalidates the earlier one. It returns nil, nil when the
+// invitation was accepted, revoked or is not in the organization.
+func ResendInvitation(id, organizationID, actorID uint) (*model.Invitation, error) {
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	inv, err := invitation_repository.RenewInvitation(id, organizationID, hash, time.Now().Add(InvitationTTL), actorID)
+	if err != nil || inv == nil {
+		return inv, err
+	}
+
+	return inv, sendInvitation(inv, token)
+}
+
+// GetInvitation returns the invitation of a token while it can be accepted.
+func GetInvitation(token string) (*model.Invitation, error) {
+	inv, err := invitation_repository.GetInvitationByTokenHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil || inv.Status() != model.InvitationPending || !inv.Organization.IsActive {
+		return nil, ErrInvalidInvitation
+	}
+
+	return inv, nil
+}
+
+// AcceptInvitation attaches an existing user to the inviting organization.
+// Only users still in a try-out may switch organizations. The user's
+// sessions carry the old organization, so they are all revoked and the
+// caller must start a new one.
+func AcceptInvitation(token string, userID uint) (*model.User, error) {
+	user, err := user_repository.GetUserWithOrganizationByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidInvitation
+	}
+
+	err = acceptInvitation(token, func(ctx context.Context, tx pgx.Tx, inv *model.Invitation) (uint, error) {
+		if inv.Email != normalizeEmail(user.Email) {
+			return 0, ErrInvitationEmailMismatch
+		}
+		if user.Organization.ID == inv.Organization.ID {
+			return 0, ErrAlreadyMember
+		}
+		if !user.Organization.IsTryOut {
+			return 0, ErrUserInAnotherOrganization
+		}
+		return user.ID, user_repository.MoveUserToOrganizationTx(ctx, tx, user.ID, inv.Organization.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := LogoutAll(user.ID); err != nil {
+		return nil, err
+	}
+
+	return user_repository.GetUserWithOrganizationByID(user.ID)
+}
+
+// RegisterWithInvitation signs a new local user up straight into the
+// inviting organization. The invitation was mailed, so the email counts as
+// verified and the user can log in right away.
+func RegisterWithInvitation(token string, user *model.User, password string) (*model.User, error) {
+	if err := setPassword(user, password); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user.AuthProvider = "local"
+	user.IsActive = true
+	user.EmailVerifiedAt = &now
+
+	err := acceptInvitation(token, func(ctx context.Context, tx pgx.Tx, inv *model.Invitation) (uint, error) {
+		user.Email = inv.Email
+		user.Organization = inv.Organization
+		if err := insertInvitedUser(ctx, tx, user); err != nil {
+			return 0, err
+		}
+		return user.ID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user_repository.GetUserWithOrganizationByID(user.ID)
+}
+
+// AcceptInvitationWithIdentity accepts an invitation for an external
+// identity, creating its user in the inviting organization when it has none
+// yet. The provider must have verified the invited email.
+func AcceptInvitationWithIdentity(token string, identity model.Identity) (*model.User, error) {
+	linked, err := user_repository.GetUserByIdentity(identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if linked != nil {
+		return AcceptInvitation(token, linked.ID)
+	}
+
+	if !identity.EmailVerified {
+		return nil, ErrInvitationEmailMismatch
+	}
+
+	existing, err := user_repository.GetUserWithOrganizationByEmail(normalizeEmail(identity.Email))
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		// An account with this email must accept after logging in
+		return nil, ErrEmailTaken
+	}
+
+	user := util.NewUserFromIdentity(identity)
+	err = acceptInvitation(token, func(ctx context.Context, tx pgx.Tx, inv *model.Invitation) (uint, error) {
+		if inv.Email != normalizeEmail(user.Email) {
+			return 0, ErrInvitationEmailMismatch
+		}
+		user.Email = inv.Email
+		user.Organization = inv.Organization
+		if err := insertInvitedUser(ctx, tx, user); err != nil {
+			return 0, err
+		}
+		if err := user_repository.InsertUserIdentityTx(ctx, tx, user.ID, identity); err != nil {
+			return 0, err
+		}
+		return user.ID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user_repository.GetUserWithOrganizationByID(user.ID)
+}
+
+// acceptInvitation locks the open invitation of token, lets join put the
+// accepting user in the organization and gives the user the invited role,
+// all in one transaction.
+func acceptInvitation(token string, join func(ctx context.Context, tx pgx.Tx, inv *model.Invitation) (uint, error)) error {
+	ctx := context.Background()
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	inv, err := invitation_repository.LockOpenInvitationTx(ctx, tx, hashToken(token))
+	if err != nil {
+		return err
+	}
+	if inv == nil {
+		return ErrInvalidInvitation
+	}
+
+	userID, err := join(ctx, tx, inv)
+	if err != nil {
+		return err
+	}
+
+	// Roles live in the organization's schema
+	schema := pgx.Identifier{inv.Organization.DBSchema}.Sanitize()
+	if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL search_path TO %s, public", schema)); err != nil {
+		logger.Log.Errorf("Failed to set organization schema: %v", err)
+		return err
+	}
+
+	// The audit log records the accepting user as the actor
+	if err := db.SetAuditActor(ctx, tx, userID); err != nil {
+		logger.Log.Errorf("Failed to set audit actor: %v", err)
+		return err
+	}
+
+	assignment := &model.RoleAssignment{
+		User:       model.User{ID: userID},
+		StoreID:    inv.StoreID,
+		Role:       inv.Role,
+		AssignedBy: inv.InvitedBy,
+	}
+	if err := role_repository.SaveRoleAssignmentTx(ctx, tx, assignment); err != nil {
+		// The invited store was deleted in the meantime
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrInvalidInvitation
+		}
+		return err
+	}
+
+	if err := invitation_repository.MarkInvitationAcceptedTx(ctx, tx, inv.ID, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func insertInvitedUser(ctx context.Context, tx pgx.Tx, user *model.User) error {
+	err := user_repository.InsertUserTx(ctx, tx, user)
+	if err != nil {
+		if isEmailTaken(err) {
+			return ErrEmailTaken
+		}
+		logger.Log.Errorf("Error creating invited user: %v", err)
+	}
+	return err
+}
+
+func sendInvitation(inv *model.Invitation, token string) error {
+	return mail.Send(mail.Message{
+		To:      inv.Email,
+		Subject: fmt.Sprintf("You are invited to %s", inv.Organization.Name),
+		Body: fmt.Sprintf(
+			"You have been invited to join %s as %s. Accept the invitation by opening the link below. It expires in %s.\n\n%s/invitation?token=%s\n",
+			inv.Organization.Name, inv.Role, InvitationTTL, frontendURL(), token,
+		),
+	})
+}
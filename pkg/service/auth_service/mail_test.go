@@ -0,0 +1,90 @@
+package auth_service
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/mail"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/mail/smtptest"
+)
+
+// withSMTP routes mail.Send through an in-process SMTP server.
+func withSMTP(t *testing.T) *smtptest.Server {
+	t.Helper()
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mail.SetSender(&mail.SMTPSender{Addr: server.Addr, Host: server.Host, From: "no-reply@example.com"})
+	t.Cleanup(func() {
+		mail.SetSender(mail.LogSender{})
+		server.Close()
+	})
+	return server
+}
+
+// mailedToken returns the token of the single link to path in body.
+func mailedToken(t *testing.T, body, path string) string {
+	t.Helper()
+	re := regexp.MustCompile(regexp.QuoteMeta("https://app.example.com"+path) + `\?token=([A-Za-z0-9_-]+)\n`)
+	m := re.FindAllStringSubmatch(body, -1)
+	if len(m) != 1 {
+		t.Fatalf("body has %d links to %s, want 1:\n%s", len(m), path, body)
+	}
+	return m[0][1]
+}
+
+func TestSendEmailVerification(t *testing.T) {
+	t.Setenv("FRONTEND_URL", "https://app.example.com/")
+	server := withSMTP(t)
+
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sendEmailVerification("ana@example.com", token); err != nil {
+		t.Fatalf("sendEmailVerification returned error: %v", err)
+	}
+
+	got := server.Messages()
+	if len(got) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(got))
+	}
+	if got[0].To[0] != "ana@example.com" || got[0].Header.Get("Subject") != "Confirm your email" {
+		t.Errorf("message to %v with subject %q", got[0].To, got[0].Header.Get("Subject"))
+	}
+	if !strings.Contains(got[0].Body, EmailVerificationTTL.String()) {
+		t.Errorf("body does not state the expiry:\n%s", got[0].Body)
+	}
+	if mailed := mailedToken(t, got[0].Body, "/verify-email"); hashToken(mailed) != hash {
+		t.Errorf("mailed token %q does not match the stored hash", mailed)
+	}
+}
+
+func TestSendPasswordReset(t *testing.T) {
+	t.Setenv("FRONTEND_URL", "https://app.example.com")
+	server := withSMTP(t)
+
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sendPasswordReset("ana@example.com", token); err != nil {
+		t.Fatalf("sendPasswordReset returned error: %v", err)
+	}
+
+	got := server.Messages()
+	if len(got) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(got))
+	}
+	if got[0].To[0] != "ana@example.com" || got[0].Header.Get("Subject") != "Reset your password" {
+		t.Errorf("message to %v with subject %q", got[0].To, got[0].Header.Get("Subject"))
+	}
+	if !strings.Contains(got[0].Body, PasswordResetTTL.String()) {
+		t.Errorf("body does not state the expiry:\n%s", got[0].Body)
+	}
+	if mailed := mailedToken(t, got[0].Body, "/reset-password"); hashToken(mailed) != hash {
+		t.Errorf("mailed token %q does not match the stored hash", mailed)
+	}
+}
@@ -0,0 +1,116 @@
+package auth_service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/auth"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/oauth_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/user_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+const (
+	// OAuthStateTTL is how long a user has to log in at the provider.
+	OAuthStateTTL = 10 * time.Minute
+	// LoginCodeTTL is how long the frontend has to exchange the code of an
+	// OAuth callback redirect.
+	LoginCodeTTL = time.Minute
+)
+
+var (
+	ErrInvalidOAuthState = errors.New("invalid or expired oauth state")
+	ErrInvalidLoginCode  = errors.New("invalid or expired login code")
+)
+
+// BeginOAuth records a login with the provider and returns the URL of the
+// provider's consent page and the state it will send back. The nonce and
+// PKCE verifier stay on the server.
+func BeginOAuth(provider auth.Provider, isTryOut bool, invitationToken string) (authURL, state string, err error) {
+	state, stateHash, err := newOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, _, err := newOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	record := &model.OAuthState{
+		Provider:        provider.Name(),
+		Nonce:           nonce,
+		CodeVerifier:    auth.NewCodeVerifier(),
+		IsTryOut:        isTryOut,
+		InvitationToken: invitationToken,
+		ExpiresAt:       time.Now().Add(OAuthStateTTL),
+	}
+	if err := oauth_repository.SaveOAuthState(stateHash, record); err != nil {
+		return "", "", err
+	}
+
+	return provider.AuthURL(state, record.Nonce, record.CodeVerifier), state, nil
+}
+
+// CompleteOAuth looks up the login of the state, once, and has its provider
+// exchange the code for the user's identity.
+func CompleteOAuth(ctx context.Context, provider auth.Provider, state, code string) (model.Identity, *model.OAuthState, error) {
+	record, err := oauth_repository.ConsumeOAuthState(hashToken(state))
+	if err != nil {
+		return model.Identity{}, nil, err
+	}
+	if record == nil || record.Provider != provider.Name() {
+		return model.Identity{}, nil, ErrInvalidOAuthState
+	}
+
+	identity, err := provider.Identity(ctx, code, record.Nonce, record.CodeVerifier)
+	if err != nil {
+		return model.Identity{}, nil, err
+	}
+
+	return identity, record, nil
+}
+
+// IssueLoginCode returns a single-use code the frontend exchanges for the
+// user's session tokens. tryoutUUID is the try-out job the login started,
+// if any.
+func IssueLoginCode(userID uint, tryoutUUID string) (string, error) {
+	code, codeHash, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := oauth_repository.SaveLoginCode(codeHash, userID, tryoutUUID, time.Now().Add(LoginCodeTTL)); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// ExchangeLoginCode redeems a login code and starts the user's session. It
+// also returns the try-out job the login started, if any.
+func ExchangeLoginCode(code, userAgent, ipAddress string) (*model.User, *TokenPair, string, error) {
+	userID, tryoutUUID, ok, err := oauth_repository.ConsumeLoginCode(hashToken(code))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if !ok {
+		return nil, nil, "", ErrInvalidLoginCode
+	}
+
+	user, err := user_repository.GetUserWithOrganizationByID(userID)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if user == nil || !user.IsActive {
+		return nil, nil, "", ErrInvalidLoginCode
+	}
+
+	tokens, err := StartSession(user, userAgent, ipAddress)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	user_repository.StampNowLastLogin(user.ID)
+	return user, tokens, tryoutUUID, nil
+}
@@ -0,0 +1,116 @@
+package auth_service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/auth_session_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/user_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/util"
+	"github.com/google/uuid"
+)
+
+const (
+	// SessionTTL caps how long a session can be kept alive by refreshing.
+	SessionTTL = 30 * 24 * time.Hour
+	// RefreshTokenTTL is how long an unused refresh token stays valid.
+	RefreshTokenTTL = 14 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+)
+
+// TokenPair is what a client gets when a session starts or is refreshed.
+type TokenPair struct {
+	AccessToken          string
+	AccessTokenExpiresAt time.Time
+	RefreshToken         string
+}
+
+// StartSession opens a server-side session for the user and issues its
+// first access and refresh tokens. Try-out sessions end with the try-out.
+func StartSession(user *model.User, userAgent, ipAddress string) (*TokenPair, error) {
+	sessionID := uuid.New().String()
+
+	expiresAt := time.Now().Add(SessionTTL)
+	if user.Organization.IsTryOut && user.Organization.ExpiresAt != nil && user.Organization.ExpiresAt.Before(expiresAt) {
+		expiresAt = *user.Organization.ExpiresAt
+	}
+
+	refreshToken, refreshHash, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshExpiresAt := time.Now().Add(RefreshTokenTTL)
+	if refreshExpiresAt.After(expiresAt) {
+		refreshExpiresAt = expiresAt
+	}
+
+	err = auth_session_repository.CreateSession(sessionID, user.ID, userAgent, ipAddress, expiresAt, refreshHash, refreshExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return issueAccessToken(user, sessionID, refreshToken)
+}
+
+// RefreshSession rotates a refresh token and issues a new access token for
+// its session. Reusing a rotated refresh token revokes the session.
+func RefreshSession(refreshToken string) (*TokenPair, error) {
+	newToken, newHash, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, userID, err := auth_session_repository.RotateRefreshToken(hashToken(refreshToken), newHash, time.Now().Add(RefreshTokenTTL))
+	if err != nil {
+		if errors.Is(err, auth_session_repository.ErrInvalidRefreshToken) || errors.Is(err, auth_session_repository.ErrRefreshTokenReused) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	user, err := user_repository.GetUserWithOrganizationByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive || !user.Organization.IsActive {
+		auth_session_repository.RevokeSession(sessionID, userID, auth_session_repository.RevokedUserInactive)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return issueAccessToken(user, sessionID, newToken)
+}
+
+// Logout revokes the given session of the user.
+func Logout(userID uint, sessionID string) error {
+	return auth_session_repository.RevokeSession(sessionID, userID, auth_session_repository.RevokedLogout)
+}
+
+// LogoutAll revokes every session of the user, on every device.
+func LogoutAll(userID uint) error {
+	return auth_session_repository.RevokeUserSessions(userID, auth_session_repository.RevokedLogoutAll)
+}
+
+func issueAccessToken(user *model.User, sessionID, refreshToken string) (*TokenPair, error) {
+	var jwt string
+	var err error
+
+	if user.Organization.IsTryOut {
+		jwt, err = util.GenerateTryOutJWT(*user, sessionID, *user.Organization.ExpiresAt)
+	} else {
+		jwt, err = util.GenerateJWT(*user, sessionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:          jwt,
+		AccessTokenExpiresAt: time.Now().Add(util.AccessTokenTTL),
+		RefreshToken:         refreshToken,
+	}, nil
+}
@@ -0,0 +1,341 @@
+package auth_service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/organization_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/two_factor_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/user_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/user_token_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/util"
+)
+
+const (
+	// TwoFactorLoginTTL is how long a password login waits for its second
+	// factor.
+	TwoFactorLoginTTL = 5 * time.Minute
+	// BackupCodeCount is how many backup codes a user gets at a time.
+	BackupCodeCount = 10
+
+	defaultTOTPIssuer = "GraoAGrao"
+)
+
+var (
+	ErrTwoFactorUnavailable   = errors.New("two-factor authentication is only for password logins")
+	ErrTwoFactorEnabled       = errors.New("two-factor authentication already enabled")
+	ErrTwoFactorNotEnabled    = errors.New("two-factor authentication not enabled")
+	ErrTwoFactorNotPending    = errors.New("no two-factor enrollment to confirm")
+	ErrTwoFactorRequired      = errors.New("organization requires two-factor authentication")
+	ErrInvalidTwoFactorCode   = errors.New("invalid two-factor code")
+	ErrTwoFactorSetupRequired = errors.New("two-factor enrollment required")
+)
+
+var backupCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TwoFactorSetup is what a user adds to an authenticator app.
+type TwoFactorSetup struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+// TwoFactorRequired reports whether a password login of the user needs a
+// second factor: the user enabled 2FA or the organization requires it.
+// Users without a password log in at an identity provider, which is in
+// charge of their second factor.
+func TwoFactorRequired(user *model.User) bool {
+	if user.PasswordHash == "" {
+		return false
+	}
+	return user.TwoFactorEnabledAt != nil || user.Organization.RequireTwoFactor
+}
+
+// BeginTwoFactorLogin returns the token of a password login waiting for its
+// second factor. The token is good for nothing but CompleteTwoFactorLogin
+// and, while the user has no 2FA yet, SetupTwoFactorForLogin.
+func BeginTwoFactorLogin(user *model.User) (string, error) {
+	return issueToken(user.ID, user_token_repository.PurposeTwoFactorLogin, TwoFactorLoginTTL)
+}
+
+// SetupTwoFactorForLogin starts the enrollment of a user who must have 2FA
+// to log in and does not yet.
+func SetupTwoFactorForLogin(token string) (*TwoFactorSetup, error) {
+	user, err := twoFactorLoginUser(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return SetupTwoFactor(user.ID)
+}
+
+// CompleteTwoFactorLogin checks the second factor of a password login and
+// returns its user, for the caller to start the session. A user enrolling
+// because the organization requires it confirms the enrollment with the
+// code and gets backup codes. Failed codes count as failed logins.
+func CompleteTwoFactorLogin(token, code, ipAddress string) (*model.User, []string, error) {
+	user, err := twoFactorLoginUser(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkLoginThrottle(user.Email, ipAddress); err != nil {
+		return nil, nil, err
+	}
+
+	var backupCodes []string
+	if user.TwoFactorEnabledAt != nil {
+		err = verifySecondFactor(user.ID, code)
+	} else {
+		backupCodes, err = ConfirmTwoFactor(user.ID, code)
+		if errors.Is(err, ErrTwoFactorNotPending) {
+			return nil, nil, ErrTwoFactorSetupRequired
+		}
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			recordAttempt(user.Email, ipAddress, false)
+		}
+		return nil, nil, err
+	}
+
+	_, ok, err := user_token_repository.ConsumeUserToken(user_token_repository.PurposeTwoFactorLogin, hashToken(token))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrInvalidToken
+	}
+
+	recordAttempt(user.Email, ipAddress, true)
+	return user, backupCodes, nil
+}
+
+// GetTwoFactor returns the enrollment of a user, nil when there is none.
+func GetTwoFactor(userID uint) (*model.TwoFactor, error) {
+	return two_factor_repository.GetTwoFactor(userID)
+}
+
+// SetupTwoFactor starts, or starts over, the TOTP enrollment of a password
+// user. It takes effect once ConfirmTwoFactor gets a valid code.
+func SetupTwoFactor(userID uint) (*TwoFactorSetup, error) {
+	user, err := user_repository.GetUserWithOrganizationByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.PasswordHash == "" {
+		return nil, ErrTwoFactorUnavailable
+	}
+	if user.TwoFactorEnabledAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := two_factor_repository.SavePendingSecret(user.ID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	return &TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: util.TOTPProvisioningURI(totpIssuer(), user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables the pending enrollment of a user with its first
+// code and returns the user's backup codes, which are only shown now.
+func ConfirmTwoFactor(userID uint, code string) ([]string, error) {
+	tf, err := two_factor_repository.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil || tf.EnabledAt != nil {
+		return nil, ErrTwoFactorNotPending
+	}
+
+	counter, ok := util.ValidateTOTP(tf.Secret, code, time.Now(), 0)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err = two_factor_repository.EnableTwoFactor(userID, counter, hashes)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTwoFactorNotPending
+	}
+
+	return codes, nil
+}
+
+// DisableTwoFactor turns 2FA off after checking the user's password and a
+// code. Users of an organization that requires 2FA cannot turn it off.
+func DisableTwoFactor(userID uint, password, code string) error {
+	user, err := reauthenticate(userID, password, code)
+	if err != nil {
+		return err
+	}
+	if user.Organization.RequireTwoFactor {
+		return ErrTwoFactorRequired
+	}
+
+	return two_factor_repository.DisableTwoFactor(userID)
+}
+
+// RegenerateBackupCodes replaces the backup codes of a user, after checking
+// the user's password and a code, and returns the new ones.
+func RegenerateBackupCodes(userID uint, password, code string) ([]string, error) {
+	if _, err := reauthenticate(userID, password, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := two_factor_repository.ReplaceBackupCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// SetOrganizationRequireTwoFactor sets whether the password logins of the
+// organization's users need a second factor, on behalf of actorID. Users
+// without 2FA enroll on their next login.
+func SetOrganizationRequireTwoFactor(organizationID uint, required bool, actorID uint) error {
+	return organization_repository.SetRequireTwoFactor(organizationID, required, actorID)
+}
+
+// twoFactorLoginUser returns the active user of a two-factor login token.
+func twoFactorLoginUser(token string) (*model.User, error) {
+	userID, ok, err := user_token_repository.LookupUserToken(user_token_repository.PurposeTwoFactorLogin, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := user_repository.GetUserWithOrganizationByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive || !user.Organization.IsActive {
+		return nil, ErrInvalidToken
+	}
+
+	return user, nil
+}
+
+// reauthenticate checks the password and second factor of a user with 2FA
+// enabled, before changes to the user's 2FA.
+func reauthenticate(userID uint, password, code string) (*model.User, error) {
+	user, err := user_repository.GetUserWithOrganizationByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.PasswordHash == "" {
+		return nil, ErrTwoFactorUnavailable
+	}
+	if user.TwoFactorEnabledAt == nil {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	if !util.CheckPassword(user.PasswordHash, password, user.Salt) {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := verifySecondFactor(user.ID, code); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// verifySecondFactor accepts a TOTP code the user has not used yet, or one
+// of the user's unused backup codes, which is then used up.
+func verifySecondFactor(userID uint, code string) error {
+	tf, err := two_factor_repository.GetTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+	if tf == nil || tf.EnabledAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == util.TOTPDigits {
+		counter, ok := util.ValidateTOTP(tf.Secret, code, time.Now(), tf.LastCounter)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		ok, err := two_factor_repository.UseTOTPCounter(userID, counter)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	ok, err := two_factor_repository.ConsumeBackupCode(userID, hashToken(normalizeBackupCode(code)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// newBackupCodes returns BackupCodeCount random codes, formatted as
+// xxxxx-xxxxx, and the hashes to store.
+func newBackupCodes() (codes, hashes []string, err error) {
+	codes = make([]string, BackupCodeCount)
+	hashes = make([]string, BackupCodeCount)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(backupCodeEncoding.EncodeToString(raw))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeBackupCode accepts backup codes typed in any case, with or
+// without the dash.
+func normalizeBackupCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return defaultTOTPIssuer
+}
@@ -0,0 +1,170 @@
+package item_attribute_service
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	dataErrors "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+// FilterPrefix marks query parameters that filter on custom attributes,
+// e.g. attr.roast_level=dark or attr.moisture.max=12.
+const FilterPrefix = "attr."
+
+// ResolveValues checks raw JSON attribute values against the store's
+// definitions and converts them to typed values. A null value leaves the
+// attribute unset.
+func ResolveValues(defs []model.ItemAttributeDefinition, raw map[string]any) ([]model.ItemAttributeValue, error) {
+	byKey := make(map[string]model.ItemAttributeDefinition, len(defs))
+	for _, d := range defs {
+		byKey[d.Key] = d
+	}
+
+	values := []model.ItemAttributeValue{}
+	for key, rv := range raw {
+		def, ok := byKey[key]
+		if !ok {
+			return nil, &dataErrors.InvalidItemAttribute{Key: key, Reason: "unknown attribute"}
+		}
+		if rv == nil {
+			continue
+		}
+
+		v := model.ItemAttributeValue{Definition: def}
+		switch def.Type {
+		case model.AttributeTypeText, model.AttributeTypeEnum:
+			s, ok := rv.(string)
+			if !ok {
+				return nil, &dataErrors.InvalidItemAttribute{Key: key, Reason: "expects a string"}
+			}
+			if def.Type == model.AttributeTypeEnum && !slices.Contains(def.Options, s) {
+				return nil, &dataErrors.InvalidItemAttribute{
+					Key:    key,
+					Reason: "must be one of " + strings.Join(def.Options, ", "),
+				}
+			}
+			v.Text = &s
+
+		case model.AttributeTypeNumber:
+			n, ok := rv.(float64)
+			if !ok {
+				return nil, &dataErrors.InvalidItemAttribute{Key: key, Reason: "expects a number"}
+			}
+			if err := checkBounds(def, n); err != nil {
+				return nil, err
+			}
+			v.Number = &n
+
+		case model.AttributeTypeDate:
+			s, ok := rv.(string)
+			if !ok {
+				return nil, &dataErrors.InvalidItemAttribute{Key: key, Reason: "expects a YYYY-MM-DD date"}
+			}
+			t, err := time.Parse(model.AttributeDateLayout, s)
+			if err != nil {
+				return nil, &dataErrors.InvalidItemAttribute{Key: key, Reason: "expects a YYYY-MM-DD date"}
+			}
+			v.Date = &t
+		}
+
+		values = append(values, v)
+	}
+
+	for _, d := range defs {
+		if !d.Required {
+			continue
+		}
+		if !slices.ContainsFunc(values, func(v model.ItemAttributeValue) bool { return v.Definition.ID == d.ID }) {
+			return nil, &dataErrors.InvalidItemAttribute{Key: d.Key, Reason: "is required"}
+		}
+	}
+
+	return values, nil
+}
+
+// HasFilters reports whether the query string carries any attribute filter,
+// so callers can skip loading definitions otherwise.
+func HasFilters(query url.Values) bool {
+	for param := range query {
+		if strings.HasPrefix(param, FilterPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseFilters reads attr.<key>, attr.<key>.min and attr.<key>.max query
+// parameters. min/max are only accepted on number and date attributes.
+func ParseFilters(defs []model.ItemAttributeDefinition, query url.Values) ([]model.ItemAttributeFilter, error) {
+	byKey := make(map[string]model.ItemAttributeDefinition, len(defs))
+	for _, d := range defs {
+		byKey[d.Key] = d
+	}
+
+	var filters []model.ItemAttributeFilter
+	for param, raw := range query {
+		if !strings.HasPrefix(param, FilterPrefix) || len(raw) == 0 {
+			continue
+		}
+
+		key := strings.TrimPrefix(param, FilterPrefix)
+		op := model.AttributeFilterEq
+		if k, ok := strings.CutSuffix(key, ".min"); ok {
+			key, op = k, model.AttributeFilterMin
+		} else if k, ok := strings.CutSuffix(key, ".max"); ok {
+			key, op = k, model.AttributeFilterMax
+		}
+
+		def, ok := byKey[key]
+		if !ok {
+			return nil, &dataErrors.InvalidItemAttribute{Key: key, Reason: "unknown attribute"}
+		}
+
+		rangeable := def.Type == model.AttributeTypeNumber || def.Type == model.AttributeTypeDate
+		if op != model.AttributeFilterEq && !rangeable {
+			return nil, &dataErrors.InvalidItemAttribute{Key: key, Reason: "only number and date attributes accept min/max"}
+		}
+
+		var value any
+		switch def.Type {
+		case model.AttributeTypeNumber:
+			n, err := strconv.ParseFloat(raw[0], 64)
+			if err != nil {
+				return nil, &dataErrors.InvalidItemAttribute{Key: key, Reason: "expects a number"}
+			}
+			value = n
+		case model.AttributeTypeDate:
+			t, err := time.Parse(model.AttributeDateLayout, raw[0])
+			if err != nil {
+				return nil, &dataErrors.InvalidItemAttribute{Key: key, Reason: "expects a YYYY-MM-DD date"}
+			}
+			value = t
+		default:
+			value = raw[0]
+		}
+
+		filters = append(filters, model.ItemAttributeFilter{Definition: def, Op: op, Value: value})
+	}
+
+	return filters, nil
+}
+
+func checkBounds(def model.ItemAttributeDefinition, n float64) error {
+	if def.Min != nil && n < *def.Min {
+		return &dataErrors.InvalidItemAttribute{
+			Key:    def.Key,
+			Reason: "must be at least " + strconv.FormatFloat(*def.Min, 'f', -1, 64),
+		}
+	}
+	if def.Max != nil && n > *def.Max {
+		return &dataErrors.InvalidItemAttribute{
+			Key:    def.Key,
+			Reason: "must be at most " + strconv.FormatFloat(*def.Max, 'f', -1, 64),
+		}
+	}
+	return nil
+}
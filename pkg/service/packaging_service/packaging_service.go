@@ -0,0 +1,188 @@
+package packaging_service
+
+import (
+	"math"
+	"sort"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+// MaxSearchSteps bounds the exact search. Larger totals are first brought
+// under it by taking whole packages of the largest packagings.
+const MaxSearchSteps = 200_000
+
+type option struct {
+	packaging model.ItemPackaging
+	size      int64 // search steps per package
+	limit     int64 // packages available, -1 when unlimited
+	count     int64 // packages picked so far
+}
+
+func (o *option) available() int64 {
+	if o.limit < 0 {
+		return math.MaxInt64
+	}
+	return o.limit - o.count
+}
+
+// SuggestBreakdown proposes how many packages of each packaging add up to
+// total. An exact match is preferred over any other, then the fewest packages.
+// held caps the packages usable per packaging ID; nil means unlimited.
+func SuggestBreakdown(itemID uint, packagings []model.ItemPackaging, held map[uint]int64, total decimal.Decimal) *model.PackagingBreakdown {
+	result := &model.PackagingBreakdown{
+		ItemID:    itemID,
+		Requested: total,
+		Total:     decimal.Zero,
+		Remainder: total,
+		Lines:     []model.PackagingBreakdownLine{},
+	}
+
+	scale := total.Scale()
+	for _, p := range packagings {
+		scale = max(scale, p.Quantity.Scale())
+	}
+
+	target, ok := toUnits(total, scale)
+	if !ok || target <= 0 {
+		return result
+	}
+
+	var opts []*option
+	var step int64
+	for _, p := range packagings {
+		size, ok := toUnits(p.Quantity, scale)
+		if !ok || size <= 0 {
+			continue
+		}
+		limit := int64(-1)
+		if held != nil {
+			if limit = held[p.ID]; limit <= 0 {
+				continue
+			}
+		}
+		opts = append(opts, &option{packaging: p, size: size, limit: limit})
+		step = gcd(step, size)
+	}
+	if len(opts) == 0 {
+		return result
+	}
+
+	// Only multiples of the common step are reachable, so search in steps
+	for _, o := range opts {
+		o.size /= step
+	}
+	sort.SliceStable(opts, func(i, j int) bool { return opts[i].size > opts[j].size })
+
+	remaining := takeLargest(opts, target/step)
+	search(opts, remaining)
+
+	var used int64
+	for _, o := range opts {
+		if o.count == 0 {
+			continue
+		}
+		line := model.PackagingBreakdownLine{ItemPackaging: o.packaging, Quantity: o.count}
+		if held != nil {
+			h := held[o.packaging.ID]
+			line.Held = &h
+		}
+		result.Lines = append(result.Lines, line)
+		result.PackageCount += o.count
+		used += o.count * o.size
+	}
+
+	result.Total = decimal.NewFromUnits(used*step, scale)
+	result.Remainder = total.Sub(result.Total)
+	result.Exact = result.Remainder.IsZero()
+	return result
+}
+
+// takeLargest fills whole packages, largest first, until the remaining steps
+// fit the exact search, and returns what is left to fill.
+func takeLargest(opts []*option, steps int64) int64 {
+	for _, o := range opts {
+		if steps <= MaxSearchSteps {
+			break
+		}
+		n := min((steps-MaxSearchSteps+o.size-1)/o.size, o.available(), steps/o.size)
+		o.count += n
+		steps -= n * o.size
+	}
+	return steps
+}
+
+// search adds to opts the fewest packages reaching the largest sum not above
+// steps. Counts are bounded, so each packaging is split into 1, 2, 4...
+// package chunks and solved as a 0/1 knapsack.
+func search(opts []*option, steps int64) {
+	type chunk struct {
+		opt    *option
+		n      int64
+		weight int64
+	}
+
+	var chunks []chunk
+	var capacity int64
+	for _, o := range opts {
+		avail := min(o.available(), steps/o.size)
+		capacity += avail * o.size
+		for k := int64(1); avail > 0; k *= 2 {
+			n := min(k, avail)
+			chunks = append(chunks, chunk{opt: o, n: n, weight: n * o.size})
+			avail -= n
+		}
+	}
+	bound := min(steps, capacity)
+	if bound <= 0 {
+		return
+	}
+
+	const unreachable = math.MaxInt64
+	cost := make([]int64, bound+1)
+	for s := range cost {
+		cost[s] = unreachable
+	}
+	cost[0] = 0
+
+	words := bound/64 + 1
+	taken := make([][]uint64, len(chunks))
+	for i, ch := range chunks {
+		taken[i] = make([]uint64, words)
+		for s := bound; s >= ch.weight; s-- {
+			prev := cost[s-ch.weight]
+			if prev != unreachable && prev+ch.n < cost[s] {
+				cost[s] = prev + ch.n
+				taken[i][s/64] |= 1 << (s % 64)
+			}
+		}
+	}
+
+	best := bound
+	for best > 0 && cost[best] == unreachable {
+		best--
+	}
+
+	for i := len(chunks) - 1; i >= 0 && best > 0; i-- {
+		if taken[i][best/64]&(1<<(best%64)) != 0 {
+			chunks[i].opt.count += chunks[i].n
+			best -= chunks[i].weight
+		}
+	}
+}
+
+// toUnits expresses d as an integer number of 10^-scale units.
+func toUnits(d decimal.Decimal, scale int32) (int64, bool) {
+	u, ok := d.Units(scale)
+	if !ok || !u.IsInt64() {
+		return 0, false
+	}
+	return u.Int64(), true
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,247 @@
+package stock_validation_service
+
+import (
+	"fmt"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+	errorCodes "github.com/IlfGauhnith/GraoAGrao/pkg/errors"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+)
+
+// Codes raised by the finalize triggers for the same checks
+const (
+	pgStockInMissingPackagings  = "P0002"
+	pgStockInTotalMismatch      = "P0003"
+	pgStockOutMissingPackagings = "P0004"
+	pgStockOutTotalMismatch     = "P0005"
+	pgInsufficientLocationStock = "P0009"
+)
+
+// line is the part of a stock-in or stock-out line the packaging checks need.
+type line struct {
+	id           uint
+	itemID       uint
+	fractionable bool
+	total        decimal.Decimal
+	packagings   []packagingLine
+}
+
+type packagingLine struct {
+	packagingID uint
+	quantity    int
+}
+
+// ValidateStockIn checks a stock-in the way finalization would, but reports
+// every problem instead of stopping at the first. packagings maps the
+// packaging IDs used by the document to the packagings, with BaseQuantity set.
+func ValidateStockIn(doc *model.StockIn, packagings map[uint]model.ItemPackaging) *model.StockDocumentValidation {
+	v := newValidation(doc.ID, doc.Status, len(doc.Items))
+
+	for _, it := range doc.Items {
+		l := line{id: it.ID, itemID: it.Item.ID, fractionable: it.Item.IsFractionable, total: it.TotalQuantity}
+		for _, p := range it.Packagings {
+			l.packagings = append(l.packagings, packagingLine{packagingID: p.ItemPackaging.ID, quantity: p.Quantity})
+		}
+		v.Problems = append(v.Problems, checkPackagings(l, packagings,
+			pgStockInMissingPackagings, pgStockInTotalMismatch, errorCodes.CodeStockInTotalQuantityNotMatching)...)
+	}
+
+	return v
+}
+
+// ValidateStockOut checks a stock-out the way finalization would, and also
+// warns when it takes more than the store holds. levels must cover the items
+// of the document and all of their locations.
+func ValidateStockOut(doc *model.StockOut, packagings map[uint]model.ItemPackaging, levels *model.StockLevels) *model.StockDocumentValidation {
+	v := newValidation(doc.ID, doc.Status, len(doc.Items))
+
+	picked := make(map[model.StockLocationKey]decimal.Decimal)
+	pickedUnassigned := make(map[uint]decimal.Decimal)
+	taken := make(map[uint]decimal.Decimal)
+	for _, it := range doc.Items {
+		l := line{id: it.ID, itemID: it.Item.ID, fractionable: it.Item.IsFractionable, total: it.TotalQuantity}
+		for _, p := range it.Packagings {
+			l.packagings = append(l.packagings, packagingLine{packagingID: p.ItemPackaging.ID, quantity: p.Quantity})
+		}
+		v.Problems = append(v.Problems, checkPackagings(l, packagings,
+			pgStockOutMissingPackagings, pgStockOutTotalMismatch, errorCodes.CodeStockOutTotalQuantityNotMatching)...)
+
+		taken[it.Item.ID] = taken[it.Item.ID].Add(it.TotalQuantity)
+		if it.Location != nil {
+			key := model.StockLocationKey{ItemID: it.Item.ID, LocationID: it.Location.ID}
+			picked[key] = picked[key].Add(it.TotalQuantity)
+		} else {
+			pickedUnassigned[it.Item.ID] = pickedUnassigned[it.Item.ID].Add(it.TotalQuantity)
+		}
+	}
+
+	// Picks are summed per item and location, as the finalize trigger does,
+	// and reported on every line that shares a short location
+	for _, it := range doc.Items {
+		if it.Location == nil {
+			located, available := unassignedStock(levels, it.Item.ID)
+			if located.Sign() > 0 && pickedUnassigned[it.Item.ID].Cmp(available) > 0 {
+				v.Problems = append(v.Problems, lineProblem(it.ID, it.Item.ID, model.ValidationError,
+					pgInsufficientLocationStock, errorCodes.CodeInsufficientLocationStock,
+					fmt.Sprintf("picking %s from unassigned stock but only %s available", pickedUnassigned[it.Item.ID], available)))
+			}
+			continue
+		}
+		key := model.StockLocationKey{ItemID: it.Item.ID, LocationID: it.Location.ID}
+		available := levels.Locations[key]
+		if picked[key].Cmp(available) > 0 {
+			v.Problems = append(v.Problems, lineProblem(it.ID, it.Item.ID, model.ValidationError,
+				pgInsufficientLocationStock, errorCodes.CodeInsufficientLocationStock,
+				fmt.Sprintf("picking %s from location %s but only %s available", picked[key], it.Location.Name, available)))
+		}
+	}
+
+	reported := make(map[uint]bool)
+	for _, it := range doc.Items {
+		if reported[it.Item.ID] {
+			continue
+		}
+		reported[it.Item.ID] = true
+		if current := levels.Items[it.Item.ID]; taken[it.Item.ID].Cmp(current) > 0 {
+			v.Problems = append(v.Problems, lineProblem(it.ID, it.Item.ID, model.ValidationWarning,
+				"", errorCodes.CodeInsufficientStock,
+				fmt.Sprintf("taking %s but only %s in stock", taken[it.Item.ID], current)))
+		}
+	}
+
+	return v
+}
+
+// ValidateStockWaste checks a waste entry before finalization. levels must
+// cover the wasted item and all of its locations.
+func ValidateStockWaste(waste *model.StockWaste, levels *model.StockLevels) *model.StockDocumentValidation {
+	v := newValidation(waste.StockWasteID, waste.Status, 1)
+	itemID := waste.Item.ID
+
+	if waste.Location != nil {
+		key := model.StockLocationKey{ItemID: itemID, LocationID: waste.Location.ID}
+		if available := levels.Locations[key]; waste.WastedQuantity.Cmp(available) > 0 {
+			v.Problems = append(v.Problems, model.StockDocumentProblem{
+				ItemID:       &itemID,
+				Severity:     model.ValidationError,
+				PgCode:       pgInsufficientLocationStock,
+				InternalCode: errorCodes.CodeInsufficientLocationStock,
+				Message:      fmt.Sprintf("wasting %s from location %s but only %s available", waste.WastedQuantity, waste.Location.Name, available),
+			})
+		}
+	} else if located, available := unassignedStock(levels, itemID); located.Sign() > 0 && waste.WastedQuantity.Cmp(available) > 0 {
+		v.Problems = append(v.Problems, model.StockDocumentProblem{
+			ItemID:       &itemID,
+			Severity:     model.ValidationError,
+			PgCode:       pgInsufficientLocationStock,
+			InternalCode: errorCodes.CodeInsufficientLocationStock,
+			Message:      fmt.Sprintf("wasting %s from unassigned stock but only %s available", waste.WastedQuantity, available),
+		})
+	}
+
+	if current := levels.Items[itemID]; waste.WastedQuantity.Cmp(current) > 0 {
+		v.Problems = append(v.Problems, model.StockDocumentProblem{
+			ItemID:       &itemID,
+			Severity:     model.ValidationWarning,
+			InternalCode: errorCodes.CodeInsufficientStock,
+			Message:      fmt.Sprintf("wasting %s but only %s in stock", waste.WastedQuantity, current),
+		})
+	}
+
+	return v
+}
+
+// unassignedStock returns how much of the item is held at locations and how
+// much is left unassigned. Lines without a location take unassigned stock,
+// which the finalize triggers only limit once the item has located stock.
+func unassignedStock(levels *model.StockLevels, itemID uint) (located, available decimal.Decimal) {
+	for key, quantity := range levels.Locations {
+		if key.ItemID == itemID {
+			located = located.Add(quantity)
+		}
+	}
+	available = levels.Items[itemID].Sub(located)
+	if available.Sign() < 0 {
+		available = decimal.Zero
+	}
+	return located, available
+}
+
+// newValidation starts a report with the document-level checks.
+func newValidation(id uint, status string, lines int) *model.StockDocumentValidation {
+	v := &model.StockDocumentValidation{
+		DocumentID: id,
+		Status:     status,
+		Problems:   []model.StockDocumentProblem{},
+	}
+
+	if status != "draft" {
+		v.Problems = append(v.Problems, model.StockDocumentProblem{
+			Severity:     model.ValidationError,
+			InternalCode: errorCodes.CodeDocumentNotDraft,
+			Message:      fmt.Sprintf("document is %s, only drafts can be finalized", status),
+		})
+	}
+	if lines == 0 {
+		v.Problems = append(v.Problems, model.StockDocumentProblem{
+			Severity:     model.ValidationWarning,
+			InternalCode: errorCodes.CodeEmptyDocument,
+			Message:      "document has no lines, finalizing it changes no stock",
+		})
+	}
+
+	return v
+}
+
+// checkPackagings mirrors the packaging totals finalize triggers for one
+// line, summing the exact base-unit equivalents as fn_item_packaging_base_quantity
+// does. A line with an unknown packaging has no total to compare.
+func checkPackagings(l line, packagings map[uint]model.ItemPackaging, missingPg, mismatchPg string, mismatchCode errorCodes.ErrorCode) []model.StockDocumentProblem {
+	var problems []model.StockDocumentProblem
+
+	sum := decimal.Zero
+	complete := true
+	for _, p := range l.packagings {
+		ip, ok := packagings[p.packagingID]
+		if !ok {
+			complete = false
+			problems = append(problems, lineProblem(l.id, l.itemID, model.ValidationError,
+				"", errorCodes.CodePackagingNotFound,
+				fmt.Sprintf("packaging %d does not exist", p.packagingID)))
+			continue
+		}
+		if ip.Item.ID != l.itemID {
+			problems = append(problems, lineProblem(l.id, l.itemID, model.ValidationError,
+				"", errorCodes.CodePackagingItemMismatch,
+				fmt.Sprintf("packaging %q belongs to another item", ip.Description)))
+		}
+		sum = sum.Add(ip.BaseQuantity.Mul(decimal.NewFromInt(int64(p.quantity))))
+	}
+
+	if !l.fractionable {
+		return problems
+	}
+
+	if len(l.packagings) == 0 {
+		problems = append(problems, lineProblem(l.id, l.itemID, model.ValidationError,
+			missingPg, errorCodes.CodeMissingPackagings,
+			"fractionable item has no packaging rows"))
+	} else if complete && !sum.Equal(l.total) {
+		problems = append(problems, lineProblem(l.id, l.itemID, model.ValidationError,
+			mismatchPg, mismatchCode,
+			fmt.Sprintf("packagings add up to %s but the line declares %s", sum, l.total)))
+	}
+
+	return problems
+}
+
+func lineProblem(lineID, itemID uint, severity model.ValidationSeverity, pgCode string, code errorCodes.ErrorCode, message string) model.StockDocumentProblem {
+	return model.StockDocumentProblem{
+		LineID:       &lineID,
+		ItemID:       &itemID,
+		Severity:     severity,
+		PgCode:       pgCode,
+		InternalCode: code,
+		Message:      message,
+	}
+}
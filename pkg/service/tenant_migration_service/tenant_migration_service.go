@@ -0,0 +1,172 @@
+package tenant_migration_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/migration_log_repository"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/organization_repository"
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrSchemaNotFound is returned when the organization's schema does not
+// exist, e.g. a destroyed try-out environment.
+var ErrSchemaNotFound = errors.New("tenant schema does not exist")
+
+// Scripts returns the per-tenant migration scripts in
+// PER_TENANT_MIGRATION_PATH, in the order they apply.
+func Scripts() ([]string, error) {
+	migrationPath := os.Getenv("PER_TENANT_MIGRATION_PATH")
+	if migrationPath == "" {
+		return nil, fmt.Errorf("PER_TENANT_MIGRATION_PATH is not set")
+	}
+
+	entries, err := os.ReadDir(migrationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var sqlFiles []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".sql" {
+			sqlFiles = append(sqlFiles, filepath.Join(migrationPath, entry.Name()))
+		}
+	}
+
+	// Sort ascending
+	sort.Strings(sqlFiles)
+
+	return sqlFiles, nil
+}
+
+// ActiveOrganizations returns the organizations a bulk migration run goes
+// through. Deactivated organizations, destroyed try-outs among them, are
+// left out; an organization is migrated on its own before it is
+// reactivated.
+func ActiveOrganizations() ([]model.Organization, error) {
+	orgs, err := organization_repository.ListOrganizations()
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]model.Organization, 0, len(orgs))
+	for _, org := range orgs {
+		if !org.IsActive {
+			logger.Log.Infof("[MIGRATION] Skipping inactive schema: %s", org.DBSchema)
+			continue
+		}
+		active = append(active, org)
+	}
+	return active, nil
+}
+
+// ApplyPending applies the scripts the organization's schema has not run
+// yet, in one transaction, and returns the names of those it applied.
+// Concurrent runs for the same schema wait for each other. It returns
+// ErrSchemaNotFound when the schema is missing.
+func ApplyPending(ctx context.Context, org model.Organization, scripts []string) (applied []string, err error) {
+	conn, err := db.GetDB().Acquire(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error acquiring connection: %v", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	// Start a transaction for that organization
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		logger.Log.Errorf("Error starting transaction for schema %s: %v", org.DBSchema, err)
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(ctx); rbErr != nil {
+				logger.Log.Errorf("Error rolling back transaction for schema %s: %v", org.DBSchema, rbErr)
+			}
+		}
+	}()
+
+	if _, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('tenant_migration:' || $1))", org.DBSchema); err != nil {
+		logger.Log.Errorf("Error locking schema %s for migration: %v", org.DBSchema, err)
+		return nil, err
+	}
+
+	var exists bool
+	if err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)", org.DBSchema).Scan(&exists); err != nil {
+		logger.Log.Errorf("Error looking up schema %s: %v", org.DBSchema, err)
+		return nil, err
+	}
+	if !exists {
+		err = ErrSchemaNotFound
+		logger.Log.Errorf("Schema %s of organization %d does not exist", org.DBSchema, org.ID)
+		return nil, err
+	}
+
+	// Querying organization schema migration logs
+	migrationLogs, err := migration_log_repository.ListTenantMigrationLogBySchemaName(org.DBSchema)
+	if err != nil {
+		logger.Log.Errorf("Error querying tenant migration logs: %v", err)
+		return nil, err
+	}
+
+	// Build a set of already run script names
+	runScripts := make(map[string]bool)
+	for _, log := range migrationLogs {
+		runScripts[log.ScriptName] = true
+	}
+
+	// For each script
+	for _, scriptPath := range scripts {
+		scriptFile := filepath.Base(scriptPath)
+
+		// Apply only scripts not yet run
+		if runScripts[scriptFile] {
+			logger.Log.Infof("Skipping already run script: %s", scriptFile)
+			continue
+		}
+
+		// Reading script content
+		content, readErr := os.ReadFile(scriptPath)
+		if readErr != nil {
+			logger.Log.Errorf("failed to read migration file %s: %v", scriptFile, readErr)
+			return nil, readErr
+		}
+
+		// Appending query to set schema
+		fullSQL := fmt.Sprintf("SET LOCAL search_path TO %s, public;\n%s", pgx.Identifier{org.DBSchema}.Sanitize(), string(content))
+
+		logger.Log.Infof("[MIGRATION] ▶ Applying %s to schema %s", scriptFile, org.DBSchema)
+
+		// Applying migration
+		if _, err = tx.Exec(ctx, fullSQL); err != nil {
+			logger.Log.Errorf("Error applying migration: %v", err)
+			return nil, fmt.Errorf("%s: %w", scriptFile, err)
+		}
+
+		// Logging
+		if err = migration_log_repository.InsertTenantMigrationLog(ctx, tx, &model.TenantMigrationLog{
+			SchemaName: org.DBSchema,
+			ScriptName: scriptFile,
+		}); err != nil {
+			logger.Log.Errorf("Error logging migration: %v", err)
+			return nil, err
+		}
+
+		applied = append(applied, scriptFile)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		logger.Log.Errorf("Error committing transaction for %s: %v", org.DBSchema, err)
+		return nil, err
+	}
+
+	return applied, nil
+}
@@ -2,7 +2,11 @@ package tryout_service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"regexp"
+	"strings"
 	"time"
 
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
@@ -16,7 +20,36 @@ import (
 	"github.com/IlfGauhnith/GraoAGrao/pkg/model"
 )
 
-func PublishTryOutEnvironmentJob(user *model.User) (model.TryOutJob, error) {
+var ErrNotTryOut = errors.New("organization is not an active try-out")
+
+// cleanSchemaName matches schema names that need no quoting.
+var cleanSchemaName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// tryOutSchemaSuffix matches the try-out UUID that ends the schema name of
+// a try-out, see tryOutSchemaName. Older try-outs used the raw given name
+// and a hyphenated UUID, which cleanSchemaName rejects.
+var tryOutSchemaSuffix = regexp.MustCompile(`_[0-9a-f]{32}$`)
+
+// unaccent folds the accented letters of Portuguese names for schemaSlug.
+var unaccent = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n",
+)
+
+// maxSchemaSlug leaves room within Postgres' 63 byte identifiers for the
+// suffix of a taken name.
+const maxSchemaSlug = 40
+
+const defaultTryOutGracePeriod = 72 * time.Hour
+
+// PublishTryOutEnvironmentJob creates the try-out organization, its first
+// user and the job that sets the environment up. identity is the external
+// identity to link to the user, nil for local sign ups.
+func PublishTryOutEnvironmentJob(user *model.User, identity *model.Identity) (model.TryOutJob, error) {
 	ctx := context.Background()
 	conn, err := db.GetDB().Acquire(ctx)
 
@@ -47,8 +80,8 @@ func PublishTryOutEnvironmentJob(user *model.User) (model.TryOutJob, error) {
 
 		Organization: model.Organization{
 			Name:      user.GivenName,
-			Domain:    user.GivenName,
-			DBSchema:  fmt.Sprintf("%s-%s", user.GivenName, uuid),
+			Domain:    uuid,
+			DBSchema:  tryOutSchemaName(user.GivenName, uuid),
 			Key:       fmt.Sprintf("%s-%s", user.GivenName, uuid),
 			ExpiresAt: &expirationT,
 			IsTryOut:  true,
@@ -68,6 +101,12 @@ func PublishTryOutEnvironmentJob(user *model.User) (model.TryOutJob, error) {
 		return job, err
 	}
 
+	if identity != nil {
+		if err := user_repository.InsertUserIdentityTx(ctx, tx, user.ID, *identity); err != nil {
+			return job, err
+		}
+	}
+
 	job.CreatedBy = *user
 
 	// 3. Insert tryout job
@@ -103,6 +142,149 @@ func ProcessTryOutEnvironmentJob(job *model.TryOutJob) error {
 	return nil
 }
 
-func ExpireTryOutJob(job *model.TryOutJob) error {
-	return nil
+// ExpireTryOutJob destroys the environment of a try-out job whose try-out
+// expired more than the grace period ago. It returns false when the
+// try-out is no longer due, e.g. it was extended or converted since it was
+// listed.
+func ExpireTryOutJob(job *model.TryOutJob) (bool, error) {
+	logger.Log.Infof("ExpireTryOutJob job.uuid:%s", job.TryoutUUID)
+
+	expired, err := tryout_job_repository.ExpireTryOutEnvironment(job.Organization.ID, time.Now().Add(-TryOutGracePeriod()))
+	if err != nil {
+		return false, err
+	}
+	if expired {
+		job.Status = "destroyed"
+	}
+
+	return expired, nil
+}
+
+// ExpireTryOutEnvironments destroys every try-out that expired more than
+// the grace period ago and logs the outcome of the run.
+func ExpireTryOutEnvironments() {
+	grace := TryOutGracePeriod()
+	jobs, err := tryout_job_repository.ListExpiredTryOutJobs(time.Now().Add(-grace))
+	if err != nil {
+		logger.Log.Error("Failed to list expired try-out jobs:", err)
+		return
+	}
+
+	var destroyed, skipped, failed int
+	for _, job := range jobs {
+		expired, err := ExpireTryOutJob(&job)
+		switch {
+		case err != nil:
+			failed++
+			logger.Log.Errorf("Failed to expire try-out job %s (organization %d, schema %s): %v",
+				job.TryoutUUID, job.Organization.ID, job.Organization.DBSchema, err)
+		case !expired:
+			skipped++
+			logger.Log.Infof("Try-out job %s no longer due for expiry", job.TryoutUUID)
+		default:
+			destroyed++
+			logger.Log.Infof("Expired try-out job %s: schema %s dropped, organization %d deactivated",
+				job.TryoutUUID, job.Organization.DBSchema, job.Organization.ID)
+		}
+	}
+
+	logger.Log.Infof("Try-out expiry run (grace %s): %d due, %d destroyed, %d skipped, %d failed",
+		grace, len(jobs), destroyed, skipped, failed)
+}
+
+// TryOutGracePeriod is how long an expired try-out is kept before its
+// environment is destroyed, so its users can still subscribe. It is read
+// from TRYOUT_GRACE_PERIOD as a Go duration, e.g. 72h.
+func TryOutGracePeriod() time.Duration {
+	grace, err := time.ParseDuration(os.Getenv("TRYOUT_GRACE_PERIOD"))
+	if err != nil || grace < 0 {
+		return defaultTryOutGracePeriod
+	}
+	return grace
+}
+
+// ConvertTryOutEnvironment makes a try-out organization permanent and keeps
+// everything entered during the try-out. A schema with a try-out name is
+// renamed after the organization. The users' sessions are revoked, so they
+// log in again with regular tokens. It returns the organization's schema.
+func ConvertTryOutEnvironment(org model.Organization) (string, error) {
+	logger.Log.Infof("ConvertTryOutEnvironment organization id: %d", org.ID)
+
+	if !org.IsTryOut {
+		return "", ErrNotTryOut
+	}
+
+	schema := org.DBSchema
+	if !cleanSchemaName.MatchString(schema) || tryOutSchemaSuffix.MatchString(schema) {
+		var err error
+		schema, err = freeSchemaName(schemaSlug(org.Name))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	ok, err := tryout_job_repository.ConvertTryOutEnvironment(org.ID, schema)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrNotTryOut
+	}
+
+	return schema, nil
+}
+
+// schemaSlug turns an organization name into a schema name: lower case
+// letters, digits and underscores, starting with a letter.
+func schemaSlug(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range unaccent.Replace(strings.ToLower(name)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			underscore = false
+		case !underscore && b.Len() > 0:
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+
+	slug := strings.TrimRight(b.String(), "_")
+	if len(slug) > maxSchemaSlug {
+		slug = strings.TrimRight(slug[:maxSchemaSlug], "_")
+	}
+	// pg_ names are reserved for system schemas
+	if slug == "" || slug[0] < 'a' || slug[0] > 'z' || strings.HasPrefix(slug, "pg_") {
+		slug = "org_" + slug
+	}
+	return strings.TrimRight(slug, "_")
+}
+
+// tryOutSchemaName names the schema of a try-out after the user's slug and
+// the try-out UUID, e.g. "jose_9b2f...", within Postgres' 63 byte limit.
+// Given names may hold any letter, the schema only ASCII ones.
+func tryOutSchemaName(givenName, tryoutUUID string) string {
+	id := strings.ReplaceAll(tryoutUUID, "-", "")
+	slug := schemaSlug(givenName)
+	if max := 63 - len(id) - 1; len(slug) > max {
+		slug = strings.TrimRight(slug[:max], "_")
+	}
+	return slug + "_" + id
+}
+
+// freeSchemaName returns base, or base with a numeric suffix when a schema
+// or organization already has that name.
+func freeSchemaName(base string) (string, error) {
+	name := base
+	for i := 2; ; i++ {
+		taken, err := organization_repository.IsSchemaNameTaken(name)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return name, nil
+		}
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
 }
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
@@ -14,7 +15,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -28,12 +29,36 @@ func GenerateSalt(length int) (string, error) {
 	return hex.EncodeToString(salt), nil
 }
 
-// HashPassword hashes a password with a given salt.
+// bcryptCost is read from BCRYPT_COST, falling back to bcrypt.DefaultCost
+// when unset or out of bcrypt's range.
+var bcryptCost = loadBcryptCost()
+
+func loadBcryptCost() int {
+	cost, err := strconv.Atoi(os.Getenv("BCRYPT_COST"))
+	if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+// HashPassword hashes a password with a given salt using the configured bcrypt cost.
 func HashPassword(password, salt string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password+salt), bcrypt.DefaultCost)
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password+salt), bcryptCost)
 	return string(hashed), err
 }
 
+// CheckPassword reports whether password and salt match a hash from HashPassword.
+func CheckPassword(hash, password, salt string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password+salt)) == nil
+}
+
+// PasswordNeedsRehash reports whether hash was made with another cost than
+// the configured one, so it can be upgraded on the next successful login.
+func PasswordNeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != bcryptCost
+}
+
 // GenerateOAuthState generates a random string to use as the OAuth state.
 // The 'length' parameter specifies how many random bytes to generate.
 func GenerateOAuthState(length int) (string, error) {
@@ -46,13 +71,14 @@ func GenerateOAuthState(length int) (string, error) {
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+// AccessTokenTTL is kept short because access tokens are only checked
+// against their session, clients renew them with a refresh token.
+const AccessTokenTTL = 15 * time.Minute
 
-func GenerateJWT(user model.User) (string, error) {
-	claims := buildCommonClaims(user)
+func GenerateJWT(user model.User, sessionID string) (string, error) {
+	claims := buildCommonClaims(user, sessionID)
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
+	tokenString, err := signJWT(claims)
 	if err != nil {
 		logger.Log.Errorf("Error signing regular JWT token: %v", err)
 		return "", err
@@ -62,12 +88,11 @@ func GenerateJWT(user model.User) (string, error) {
 	return tokenString, nil
 }
 
-func GenerateTryOutJWT(user model.User, tryOutExpiresAt time.Time) (string, error) {
-	claims := buildCommonClaims(user)
+func GenerateTryOutJWT(user model.User, sessionID string, tryOutExpiresAt time.Time) (string, error) {
+	claims := buildCommonClaims(user, sessionID)
 	claims["tryout_expires_at"] = tryOutExpiresAt.Unix()
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
+	tokenString, err := signJWT(claims)
 	if err != nil {
 		logger.Log.Errorf("Error signing tryout JWT token: %v", err)
 		return "", err
@@ -77,15 +102,34 @@ func GenerateTryOutJWT(user model.User, tryOutExpiresAt time.Time) (string, erro
 	return tokenString, nil
 }
 
-// ValidateJWT verifies and parses the given JWT token
+// signJWT signs the claims with the current signing key and names the key
+// in the kid header, so the token can be verified after a rotation.
+func signJWT(claims jwt.MapClaims) (string, error) {
+	key, err := signingJWTKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Signer)
+}
+
+// ValidateJWT verifies and parses the given JWT token against the key its
+// kid header names.
 func ValidateJWT(tokenString string) (*jwt.Token, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Ensure the signing method is correct
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := verificationJWTKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		// Ensure the signing method is the key's
+		if token.Method.Alg() != key.Alg {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return jwtSecret, nil
-	})
+		return key.Public, nil
+	}, jwt.WithValidMethods([]string{"RS256", "EdDSA"}), jwt.WithExpirationRequired())
 
 	if err != nil {
 		logger.Log.Errorf("Error validating JWT token: %v", err)
@@ -170,6 +214,30 @@ func GetUserFromContext(c *gin.Context) (model.User, error) {
 	return *user, nil
 }
 
+// ErrNoSession indicates no session id was found in context
+var ErrNoSession = errors.New("no session id in context")
+
+// GetSessionIDFromContext returns the session of the authenticated request.
+func GetSessionIDFromContext(c *gin.Context) (string, error) {
+	sessionID := c.GetString("session_id")
+	if sessionID == "" {
+		return "", ErrNoSession
+	}
+	return sessionID, nil
+}
+
+// GetAPIKeyFromContext returns the API key the request authenticated with.
+// ok is false when the request was made by a logged in user.
+func GetAPIKeyFromContext(c *gin.Context) (key *model.APIKey, ok bool) {
+	raw, exists := c.Get("api_key")
+	if !exists {
+		return nil, false
+	}
+
+	key, ok = raw.(*model.APIKey)
+	return key, ok
+}
+
 var (
 	// ErrNoStoreID indicates no storeID found in context
 	ErrNoStoreID = errors.New("no store id in context")
@@ -194,7 +262,7 @@ func GetStoreIDFromContext(c *gin.Context) (uint, error) {
 	return id, nil
 }
 
-func buildCommonClaims(user model.User) jwt.MapClaims {
+func buildCommonClaims(user model.User, sessionID string) jwt.MapClaims {
 	return jwt.MapClaims{
 		"user_id":                  user.ID,
 		"user_email":               user.Email,
@@ -203,11 +271,27 @@ func buildCommonClaims(user model.User) jwt.MapClaims {
 		"user_organization_schema": user.Organization.DBSchema,
 		"user_given_name":          user.GivenName,
 		"user_family_name":         user.FamilyName,
-		"exp":                      time.Now().Add(8 * time.Hour).Unix(), // Token lifespan
-		"iat":                      time.Now().Unix(),                    // Issued at
+		"sid":                      sessionID,                             // Server-side session
+		"exp":                      time.Now().Add(AccessTokenTTL).Unix(), // Token lifespan
+		"iat":                      time.Now().Unix(),                     // Issued at
 	}
 }
 
+// GetSessionIDFromJWT returns the session the token was issued for.
+func GetSessionIDFromJWT(token jwt.Token) (string, error) {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", errors.New("invalid jwt")
+	}
+
+	sessionID, ok := claims["sid"].(string)
+	if !ok || sessionID == "" {
+		return "", errors.New("sid not found in token")
+	}
+
+	return sessionID, nil
+}
+
 // GetTryOutExpirationFromJWT parses a JWT and returns the tryout expiration time.
 // Returns ErrInvalidToken if the token is invalid, and ErrNoTryOutClaim if the claim is missing or malformed.
 func GetTryOutExpirationFromJWT(token jwt.Token) (time.Time, error) {
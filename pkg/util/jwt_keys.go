@@ -0,0 +1,195 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTKey is a key access tokens are signed or verified with. Signer is nil
+// for keys that are kept only to verify tokens issued before a rotation.
+type JWTKey struct {
+	ID     string
+	Alg    string
+	Public crypto.PublicKey
+	Signer crypto.Signer
+}
+
+func (k *JWTKey) method() jwt.SigningMethod {
+	if k.Alg == "EdDSA" {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+var jwtKeys struct {
+	sync.RWMutex
+	signing *JWTKey
+	byID    map[string]*JWTKey
+}
+
+// LoadJWTKeys reads the access token keys from the PEM files in
+// JWT_KEYS_DIR, each named after its key id: <kid>.pem. A file holding a
+// private key (RSA for RS256, Ed25519 for EdDSA) can sign, a file holding
+// only a public key verifies tokens signed before a rotation. JWT_SIGNING_KID
+// names the key to sign with, and can be left out when there is only one
+// private key.
+//
+// To rotate, add the new key, let the JWKS caches of other services pick it
+// up, switch JWT_SIGNING_KID to it and replace the old key's file with its
+// public key. Remove the old key once the last token signed with it has
+// expired, AccessTokenTTL later.
+//
+// Without JWT_KEYS_DIR outside production, a throwaway Ed25519 key is
+// generated, so tokens do not survive a restart.
+func LoadJWTKeys() error {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		if GetStage() == "PROD" {
+			return errors.New("JWT_KEYS_DIR is not set")
+		}
+		logger.Log.Warn("JWT_KEYS_DIR is not set, signing access tokens with a temporary key")
+		return setJWTKeys(nil, "")
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := make([]*JWTKey, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := parseJWTKey(kid, data)
+		if err != nil {
+			return fmt.Errorf("jwt key %s: %w", kid, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return setJWTKeys(keys, os.Getenv("JWT_SIGNING_KID"))
+}
+
+func setJWTKeys(keys []*JWTKey, signingKID string) error {
+	if len(keys) == 0 {
+		_, private, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return err
+		}
+		keys = []*JWTKey{{ID: "dev", Alg: "EdDSA", Public: private.Public(), Signer: private}}
+	}
+
+	byID := make(map[string]*JWTKey, len(keys))
+	var signing *JWTKey
+	for _, key := range keys {
+		byID[key.ID] = key
+		if key.Signer == nil {
+			continue
+		}
+		if key.ID == signingKID || (signingKID == "" && signing == nil) {
+			signing = key
+		} else if signingKID == "" {
+			return errors.New("JWT_SIGNING_KID is required with more than one private key")
+		}
+	}
+	if signing == nil {
+		return fmt.Errorf("no private key for signing key id %q", signingKID)
+	}
+
+	jwtKeys.Lock()
+	defer jwtKeys.Unlock()
+	jwtKeys.signing = signing
+	jwtKeys.byID = byID
+
+	logger.Log.Infof("Signing access tokens with key %s (%s), %d key(s) loaded", signing.ID, signing.Alg, len(byID))
+	return nil
+}
+
+func parseJWTKey(kid string, data []byte) (*JWTKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &JWTKey{ID: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Alg, key.Public, key.Signer = "RS256", &k.PublicKey, k
+	case *rsa.PublicKey:
+		key.Alg, key.Public = "RS256", k
+	case ed25519.PrivateKey:
+		key.Alg, key.Public, key.Signer = "EdDSA", k.Public(), k
+	case ed25519.PublicKey:
+		key.Alg, key.Public = "EdDSA", k
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+
+	if rsaKey, ok := key.Public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, errors.New("RSA keys must have at least 2048 bits")
+	}
+
+	return key, nil
+}
+
+func signingJWTKey() (*JWTKey, error) {
+	jwtKeys.RLock()
+	defer jwtKeys.RUnlock()
+	if jwtKeys.signing == nil {
+		return nil, errors.New("jwt keys not loaded")
+	}
+	return jwtKeys.signing, nil
+}
+
+func verificationJWTKey(kid string) (*JWTKey, bool) {
+	jwtKeys.RLock()
+	defer jwtKeys.RUnlock()
+	key, ok := jwtKeys.byID[kid]
+	return key, ok
+}
+
+// JWTVerificationKeys returns every loaded key, sorted by id, for
+// publishing in the JWKS.
+func JWTVerificationKeys() []JWTKey {
+	jwtKeys.RLock()
+	defer jwtKeys.RUnlock()
+
+	keys := make([]JWTKey, 0, len(jwtKeys.byID))
+	for _, key := range jwtKeys.byID {
+		keys = append(keys, JWTKey{ID: key.ID, Alg: key.Alg, Public: key.Public})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
@@ -9,6 +9,7 @@ import (
 
 	_ "github.com/IlfGauhnith/GraoAGrao/pkg/config"
 	db "github.com/IlfGauhnith/GraoAGrao/pkg/db"
+	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/login_attempt_repository"
 	"github.com/IlfGauhnith/GraoAGrao/pkg/db/data_handler/tryout_job_repository"
 	logger "github.com/IlfGauhnith/GraoAGrao/pkg/logger"
 	model "github.com/IlfGauhnith/GraoAGrao/pkg/model"
@@ -43,25 +44,40 @@ func WaitForShutdown() {
 	os.Exit(0)
 }
 
-// NewUserFromGoogleUserInfo transforms a GoogleUserInfo into a User model.
-// It sets the AuthProvider to "google", uses the current time for timestamps,
-// and derives a username if one is not provided by using the email's local part.
-func NewUserFromGoogleUserInfo(googleUser model.GoogleUserInfo) *model.User {
+// NewUserFromIdentity transforms an external identity into a User model.
+// It sets the AuthProvider to the identity's provider, uses the current time
+// for timestamps, and leaves the username empty.
+func NewUserFromIdentity(identity model.Identity) *model.User {
 	now := time.Now()
 
-	return &model.User{
-		Username:     "",
-		Email:        googleUser.Email,
-		GoogleID:     googleUser.ID,
-		GivenName:    googleUser.GivenName,
-		FamilyName:   googleUser.FamilyName,
-		PictureURL:   googleUser.Picture,
-		AuthProvider: "google",
-		UpdatedAt:    now,
-		LastLogin:    now,
-		IsActive:     true,
-		// PasswordHash and Salt remain empty because this user signed in with Google.
+	var verifiedAt *time.Time
+	if identity.EmailVerified {
+		verifiedAt = &now
 	}
+
+	givenName := identity.GivenName
+	if givenName == "" {
+		givenName = identity.Name
+	}
+
+	user := &model.User{
+		Username:        "",
+		Email:           identity.Email,
+		GivenName:       givenName,
+		FamilyName:      identity.FamilyName,
+		PictureURL:      identity.Picture,
+		AuthProvider:    identity.Provider,
+		UpdatedAt:       now,
+		LastLogin:       now,
+		IsActive:        true,
+		EmailVerifiedAt: verifiedAt,
+		// PasswordHash and Salt remain empty because this user signed in with a provider.
+	}
+	if identity.Provider == "google" {
+		user.GoogleID = identity.Subject
+	}
+
+	return user
 }
 
 // GetDBConnFromContext safely retrieves *pgxpool.Conn from gin.Context
@@ -103,5 +119,19 @@ func StartTryOutCronWorker() {
 			}
 		}
 	})
+
+	// Destroy the environments of try-outs expired past the grace period
+	c.AddFunc("@every 1h", tryout_service.ExpireTryOutEnvironments)
+
+	// Delete login attempts too old to count towards the login throttle
+	c.AddFunc("@every 1h", func() {
+		deleted, err := login_attempt_repository.DeleteLoginAttemptsBefore(time.Now().Add(-login_attempt_repository.ThrottleWindow))
+		if err != nil {
+			logger.Log.Error("Failed to delete old login attempts:", err)
+			return
+		}
+		logger.Log.Infof("Deleted %d old login attempts", deleted)
+	})
+
 	c.Start()
 }
@@ -1,6 +1,44 @@
 package validator
 
-import v10 "github.com/go-playground/validator/v10"
+import (
+	"reflect"
+	"regexp"
+
+	"github.com/IlfGauhnith/GraoAGrao/pkg/decimal"
+	v10 "github.com/go-playground/validator/v10"
+)
 
 // Validate is a singleton thread safe instance you can use everywhere.
 var Validate = v10.New()
+
+// attributeKeyPattern mirrors chk_item_attribute_key on tb_item_attribute_definition.
+var attributeKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// maxPasswordBytes leaves room for the 8 character salt within bcrypt's 72
+// byte input. Lengths are in bytes, so multibyte passwords are measured the
+// way bcrypt sees them.
+const maxPasswordBytes = 72 - 8
+
+func init() {
+	Validate.RegisterValidation("attribute_key", func(fl v10.FieldLevel) bool {
+		return attributeKeyPattern.MatchString(fl.Field().String())
+	})
+
+	Validate.RegisterValidation("password_bytes", func(fl v10.FieldLevel) bool {
+		return passwordFits(fl.Field().String())
+	})
+
+	// Lets numeric tags such as gt=0 apply to decimal fields
+	Validate.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		if d, ok := field.Interface().(decimal.Decimal); ok {
+			return d.Float64()
+		}
+		return nil
+	}, decimal.Decimal{})
+}
+
+// passwordFits reports whether password is short enough to be hashed with
+// its salt.
+func passwordFits(password string) bool {
+	return len(password) <= maxPasswordBytes
+}
@@ -0,0 +1,25 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		ok       bool
+	}{
+		{"64 ASCII bytes", strings.Repeat("a", 64), true},
+		{"65 ASCII bytes", strings.Repeat("a", 65), false},
+		{"32 two-byte runes", strings.Repeat("é", 32), true},
+		{"33 two-byte runes", strings.Repeat("é", 33), false},
+		{"22 three-byte runes", strings.Repeat("€", 22), false},
+	}
+	for _, tt := range tests {
+		if got := passwordFits(tt.password); got != tt.ok {
+			t.Errorf("%s: passwordFits = %v, want %v", tt.name, got, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+-- +goose Up
+-- Step 1: Add archived_at lifecycle column to catalog entities
+ALTER TABLE tb_item ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ NULL;
+ALTER TABLE tb_category ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ NULL;
+ALTER TABLE tb_unit_of_measure ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ NULL;
+ALTER TABLE tb_item_packaging ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ NULL;
+
+COMMENT ON COLUMN tb_item.archived_at IS
+  'When set, the item is archived: hidden from listings and not referenceable by new documents.';
+COMMENT ON COLUMN tb_category.archived_at IS
+  'When set, the category is archived: hidden from listings and not referenceable by new items.';
+COMMENT ON COLUMN tb_unit_of_measure.archived_at IS
+  'When set, the unit is archived: hidden from listings and not referenceable by new items.';
+COMMENT ON COLUMN tb_item_packaging.archived_at IS
+  'When set, the packaging is archived: hidden from listings and not referenceable by new documents.';
+
+-- Step 2: Reject new references to archived items
+CREATE OR REPLACE FUNCTION fn_prevent_archived_item_reference()
+RETURNS TRIGGER AS $$
+BEGIN
+  -- Existing rows keep pointing at an item archived after they were written
+  IF TG_OP = 'UPDATE' AND NEW.item_id IS NOT DISTINCT FROM OLD.item_id THEN
+    RETURN NEW;
+  END IF;
+
+  IF EXISTS (
+    SELECT 1 FROM tb_item
+    WHERE item_id = NEW.item_id
+      AND archived_at IS NOT NULL
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0006',
+      MESSAGE = FORMAT('Item %s is archived and cannot be referenced', NEW.item_id),
+      DETAIL = FORMAT('Key (item_id)=(%s) is archived in table "tb_item".', NEW.item_id);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_prevent_archived_item_stock_in_item ON tb_stock_in_item;
+CREATE TRIGGER trg_prevent_archived_item_stock_in_item
+BEFORE INSERT OR UPDATE OF item_id ON tb_stock_in_item
+FOR EACH ROW EXECUTE FUNCTION fn_prevent_archived_item_reference();
+
+DROP TRIGGER IF EXISTS trg_prevent_archived_item_stock_out_item ON tb_stock_out_item;
+CREATE TRIGGER trg_prevent_archived_item_stock_out_item
+BEFORE INSERT OR UPDATE OF item_id ON tb_stock_out_item
+FOR EACH ROW EXECUTE FUNCTION fn_prevent_archived_item_reference();
+
+DROP TRIGGER IF EXISTS trg_prevent_archived_item_stock_waste ON tb_stock_waste;
+CREATE TRIGGER trg_prevent_archived_item_stock_waste
+BEFORE INSERT OR UPDATE OF item_id ON tb_stock_waste
+FOR EACH ROW EXECUTE FUNCTION fn_prevent_archived_item_reference();
+
+DROP TRIGGER IF EXISTS trg_prevent_archived_item_item_packaging ON tb_item_packaging;
+CREATE TRIGGER trg_prevent_archived_item_item_packaging
+BEFORE INSERT OR UPDATE OF item_id ON tb_item_packaging
+FOR EACH ROW EXECUTE FUNCTION fn_prevent_archived_item_reference();
+
+-- Step 3: Reject new references to archived packagings
+CREATE OR REPLACE FUNCTION fn_prevent_archived_packaging_reference()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_OP = 'UPDATE' AND NEW.item_packaging_id IS NOT DISTINCT FROM OLD.item_packaging_id THEN
+    RETURN NEW;
+  END IF;
+
+  IF EXISTS (
+    SELECT 1 FROM tb_item_packaging
+    WHERE item_packaging_id = NEW.item_packaging_id
+      AND archived_at IS NOT NULL
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0006',
+      MESSAGE = FORMAT('Item packaging %s is archived and cannot be referenced', NEW.item_packaging_id),
+      DETAIL = FORMAT('Key (item_packaging_id)=(%s) is archived in table "tb_item_packaging".', NEW.item_packaging_id);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_prevent_archived_packaging_stock_in ON tb_stock_in_packaging;
+CREATE TRIGGER trg_prevent_archived_packaging_stock_in
+BEFORE INSERT OR UPDATE OF item_packaging_id ON tb_stock_in_packaging
+FOR EACH ROW EXECUTE FUNCTION fn_prevent_archived_packaging_reference();
+
+DROP TRIGGER IF EXISTS trg_prevent_archived_packaging_stock_out ON tb_stock_out_packaging;
+CREATE TRIGGER trg_prevent_archived_packaging_stock_out
+BEFORE INSERT OR UPDATE OF item_packaging_id ON tb_stock_out_packaging
+FOR EACH ROW EXECUTE FUNCTION fn_prevent_archived_packaging_reference();
+
+-- Step 4: Reject new items pointing at archived categories or units
+CREATE OR REPLACE FUNCTION fn_prevent_archived_item_classification_reference()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF (TG_OP = 'INSERT' OR NEW.category_id IS DISTINCT FROM OLD.category_id)
+    AND EXISTS (
+      SELECT 1 FROM tb_category
+      WHERE category_id = NEW.category_id
+        AND archived_at IS NOT NULL
+    )
+  THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0006',
+      MESSAGE = FORMAT('Category %s is archived and cannot be referenced', NEW.category_id),
+      DETAIL = FORMAT('Key (category_id)=(%s) is archived in table "tb_category".', NEW.category_id);
+  END IF;
+
+  IF (TG_OP = 'INSERT' OR NEW.unit_id IS DISTINCT FROM OLD.unit_id)
+    AND EXISTS (
+      SELECT 1 FROM tb_unit_of_measure
+      WHERE unit_id = NEW.unit_id
+        AND archived_at IS NOT NULL
+    )
+  THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0006',
+      MESSAGE = FORMAT('Unit of measure %s is archived and cannot be referenced', NEW.unit_id),
+      DETAIL = FORMAT('Key (unit_id)=(%s) is archived in table "tb_unit_of_measure".', NEW.unit_id);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_prevent_archived_classification_item ON tb_item;
+CREATE TRIGGER trg_prevent_archived_classification_item
+BEFORE INSERT OR UPDATE OF category_id, unit_id ON tb_item
+FOR EACH ROW EXECUTE FUNCTION fn_prevent_archived_item_classification_reference();
+
+-- Step 5: Partial indexes backing the default (non-archived) listings
+CREATE INDEX IF NOT EXISTS idx_item_store_active ON tb_item (store_id) WHERE archived_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_category_store_active ON tb_category (store_id) WHERE archived_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_unit_store_active ON tb_unit_of_measure (store_id) WHERE archived_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_item_packaging_store_active ON tb_item_packaging (store_id) WHERE archived_at IS NULL;
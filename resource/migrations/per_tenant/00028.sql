@@ -0,0 +1,87 @@
+-- +goose Up
+-- Step 1: Self-reference so categories can be nested (e.g. Grains > Coffee > Arabica)
+ALTER TABLE tb_category
+ADD COLUMN IF NOT EXISTS parent_category_id INTEGER NULL REFERENCES tb_category(category_id);
+
+COMMENT ON COLUMN tb_category.parent_category_id IS
+  'Parent category; NULL for root categories. Cycles are rejected by trg_validate_category_parent.';
+
+CREATE INDEX IF NOT EXISTS idx_category_parent ON tb_category (parent_category_id);
+
+-- Step 2: Reject self-parenting, cross-store parents and cycles
+CREATE OR REPLACE FUNCTION fn_validate_category_parent()
+RETURNS TRIGGER AS $$
+DECLARE
+  parent_store_id INTEGER;
+BEGIN
+  IF NEW.parent_category_id IS NULL THEN
+    RETURN NEW;
+  END IF;
+
+  IF NEW.parent_category_id = NEW.category_id THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0007',
+      MESSAGE = FORMAT('Category %s cannot be its own parent', NEW.category_id);
+  END IF;
+
+  SELECT store_id INTO parent_store_id
+  FROM tb_category
+  WHERE category_id = NEW.parent_category_id;
+
+  IF FOUND AND parent_store_id IS DISTINCT FROM NEW.store_id THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0007',
+      MESSAGE = FORMAT(
+        'Parent category %s belongs to another store',
+        NEW.parent_category_id
+      );
+  END IF;
+
+  -- Walking up from the new parent must never reach the row being saved
+  IF TG_OP = 'UPDATE' AND EXISTS (
+    WITH RECURSIVE ancestors AS (
+      SELECT category_id, parent_category_id
+      FROM tb_category
+      WHERE category_id = NEW.parent_category_id
+      UNION ALL
+      SELECT c.category_id, c.parent_category_id
+      FROM tb_category c
+      JOIN ancestors a ON c.category_id = a.parent_category_id
+    )
+    SELECT 1 FROM ancestors WHERE category_id = NEW.category_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0007',
+      MESSAGE = FORMAT(
+        'Moving category %s under %s would create a cycle',
+        NEW.category_id,
+        NEW.parent_category_id
+      );
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_validate_category_parent ON tb_category;
+CREATE TRIGGER trg_validate_category_parent
+BEFORE INSERT OR UPDATE OF parent_category_id ON tb_category
+FOR EACH ROW EXECUTE FUNCTION fn_validate_category_parent();
+
+-- Step 3: Helper returning a category and all of its descendants
+CREATE OR REPLACE FUNCTION fn_category_subtree(p_category_id INTEGER)
+RETURNS TABLE (category_id INTEGER)
+LANGUAGE sql
+STABLE
+AS $$
+  WITH RECURSIVE subtree AS (
+    SELECT c.category_id
+    FROM tb_category c
+    WHERE c.category_id = p_category_id
+    UNION ALL
+    SELECT c.category_id
+    FROM tb_category c
+    JOIN subtree s ON c.parent_category_id = s.category_id
+  )
+  SELECT subtree.category_id FROM subtree;
+$$;
@@ -0,0 +1,262 @@
+-- +goose Up
+-- Step 1: Storage locations (stockroom, shelf area, cold room...) per store
+CREATE TABLE IF NOT EXISTS tb_storage_location (
+    location_id SERIAL PRIMARY KEY,
+    store_id INTEGER NOT NULL REFERENCES tb_store(store_id),
+    location_name VARCHAR(255) NOT NULL,
+    created_by INTEGER NOT NULL REFERENCES public.tb_user(user_id),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CONSTRAINT uq_storage_location_store_name UNIQUE (store_id, location_name)
+);
+
+DROP TRIGGER IF EXISTS trg_set_updated_at_storage_location ON tb_storage_location;
+CREATE TRIGGER trg_set_updated_at_storage_location
+BEFORE UPDATE ON tb_storage_location
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Step 2: Per-location balance. tb_stock keeps the item total; whatever is not
+-- allocated to a location here is reported as unassigned.
+CREATE TABLE IF NOT EXISTS tb_stock_location (
+    item_id INTEGER NOT NULL REFERENCES tb_item(item_id),
+    location_id INTEGER NOT NULL REFERENCES tb_storage_location(location_id),
+    quantity NUMERIC(10,2) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+    PRIMARY KEY (item_id, location_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_location_location ON tb_stock_location (location_id);
+
+-- Step 3: Put-away / pick location on document lines
+ALTER TABLE tb_stock_in_item
+ADD COLUMN IF NOT EXISTS location_id INTEGER NULL REFERENCES tb_storage_location(location_id);
+
+ALTER TABLE tb_stock_out_item
+ADD COLUMN IF NOT EXISTS location_id INTEGER NULL REFERENCES tb_storage_location(location_id);
+
+COMMENT ON COLUMN tb_stock_in_item.location_id IS
+  'Location the stock is put away into on finalization. NULL leaves it unassigned.';
+COMMENT ON COLUMN tb_stock_out_item.location_id IS
+  'Location the stock is picked from on finalization. NULL picks from unassigned stock.';
+
+-- Step 4: A document line can only use locations of the document's store
+CREATE OR REPLACE FUNCTION fn_validate_stock_in_item_location()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF NEW.location_id IS NOT NULL AND NOT EXISTS (
+    SELECT 1
+    FROM tb_stock_in si
+    JOIN tb_storage_location l ON l.store_id = si.store_id
+    WHERE si.stock_in_id = NEW.stock_in_id
+      AND l.location_id = NEW.location_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0008',
+      MESSAGE = FORMAT('Location %s does not belong to the stock-in store', NEW.location_id);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_validate_stock_in_item_location ON tb_stock_in_item;
+CREATE TRIGGER trg_validate_stock_in_item_location
+BEFORE INSERT OR UPDATE OF location_id ON tb_stock_in_item
+FOR EACH ROW EXECUTE FUNCTION fn_validate_stock_in_item_location();
+
+CREATE OR REPLACE FUNCTION fn_validate_stock_out_item_location()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF NEW.location_id IS NOT NULL AND NOT EXISTS (
+    SELECT 1
+    FROM tb_stock_out so
+    JOIN tb_storage_location l ON l.store_id = so.store_id
+    WHERE so.stock_out_id = NEW.stock_out_id
+      AND l.location_id = NEW.location_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0008',
+      MESSAGE = FORMAT('Location %s does not belong to the stock-out store', NEW.location_id);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_validate_stock_out_item_location ON tb_stock_out_item;
+CREATE TRIGGER trg_validate_stock_out_item_location
+BEFORE INSERT OR UPDATE OF location_id ON tb_stock_out_item
+FOR EACH ROW EXECUTE FUNCTION fn_validate_stock_out_item_location();
+
+-- Step 5: Move located quantities on finalization, next to the tb_stock totals
+CREATE OR REPLACE FUNCTION fn_update_stock_location_on_stock_in_finalization()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF (
+    OLD.finalized_at IS NULL AND NEW.finalized_at IS NOT NULL AND
+    OLD.status = 'draft' AND NEW.status = 'finalized'
+  ) THEN
+    INSERT INTO tb_stock_location (item_id, location_id, quantity)
+    SELECT sii.item_id, sii.location_id, SUM(sii.total_quantity)
+    FROM tb_stock_in_item sii
+    WHERE sii.stock_in_id = NEW.stock_in_id
+      AND sii.location_id IS NOT NULL
+    GROUP BY sii.item_id, sii.location_id
+    ON CONFLICT (item_id, location_id)
+    DO UPDATE SET quantity = tb_stock_location.quantity + EXCLUDED.quantity,
+                  updated_at = NOW();
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_update_stock_location_on_in_finalization ON tb_stock_in;
+CREATE TRIGGER trg_update_stock_location_on_in_finalization
+AFTER UPDATE ON tb_stock_in
+FOR EACH ROW
+WHEN (
+  OLD.finalized_at IS DISTINCT FROM NEW.finalized_at OR
+  OLD.status IS DISTINCT FROM NEW.status
+)
+EXECUTE FUNCTION fn_update_stock_location_on_stock_in_finalization();
+
+CREATE OR REPLACE FUNCTION fn_update_stock_location_on_stock_out_finalization()
+RETURNS TRIGGER AS $$
+DECLARE
+  shortage RECORD;
+BEGIN
+  IF (
+    OLD.finalized_at IS NULL AND NEW.finalized_at IS NOT NULL AND
+    OLD.status = 'draft' AND NEW.status = 'finalized'
+  ) THEN
+    SELECT picked.item_id, picked.location_id, picked.quantity, COALESCE(sl.quantity, 0) AS available
+    INTO shortage
+    FROM (
+      SELECT soi.item_id, soi.location_id, SUM(soi.total_quantity) AS quantity
+      FROM tb_stock_out_item soi
+      WHERE soi.stock_out_id = NEW.stock_out_id
+        AND soi.location_id IS NOT NULL
+      GROUP BY soi.item_id, soi.location_id
+    ) picked
+    LEFT JOIN tb_stock_location sl
+      ON sl.item_id = picked.item_id AND sl.location_id = picked.location_id
+    WHERE picked.quantity > COALESCE(sl.quantity, 0)
+    LIMIT 1;
+
+    IF FOUND THEN
+      RAISE EXCEPTION USING
+        ERRCODE = 'P0009',
+        MESSAGE = FORMAT(
+          'Item %s: picking %s from location %s but only %s available',
+          shortage.item_id,
+          shortage.quantity::text,
+          shortage.location_id,
+          shortage.available::text
+        );
+    END IF;
+
+    INSERT INTO tb_stock_location (item_id, location_id, quantity)
+    SELECT soi.item_id, soi.location_id, -1 * SUM(soi.total_quantity)
+    FROM tb_stock_out_item soi
+    WHERE soi.stock_out_id = NEW.stock_out_id
+      AND soi.location_id IS NOT NULL
+    GROUP BY soi.item_id, soi.location_id
+    ON CONFLICT (item_id, location_id)
+    DO UPDATE SET quantity = tb_stock_location.quantity + EXCLUDED.quantity,
+                  updated_at = NOW();
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_update_stock_location_on_out_finalization ON tb_stock_out;
+CREATE TRIGGER trg_update_stock_location_on_out_finalization
+AFTER UPDATE ON tb_stock_out
+FOR EACH ROW
+WHEN (
+  OLD.finalized_at IS DISTINCT FROM NEW.finalized_at OR
+  OLD.status IS DISTINCT FROM NEW.status
+)
+EXECUTE FUNCTION fn_update_stock_location_on_stock_out_finalization();
+
+-- Step 6: Internal relocation moves. from_location_id NULL moves unassigned
+-- stock into a location (initial put-away of existing stock).
+CREATE TABLE IF NOT EXISTS tb_stock_relocation (
+    relocation_id SERIAL PRIMARY KEY,
+    store_id INTEGER NOT NULL REFERENCES tb_store(store_id),
+    item_id INTEGER NOT NULL REFERENCES tb_item(item_id),
+    from_location_id INTEGER NULL REFERENCES tb_storage_location(location_id),
+    to_location_id INTEGER NOT NULL REFERENCES tb_storage_location(location_id),
+    quantity NUMERIC(10,2) NOT NULL CHECK (quantity > 0),
+    created_by INTEGER NOT NULL REFERENCES public.tb_user(user_id),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CONSTRAINT chk_relocation_distinct_locations CHECK (from_location_id IS DISTINCT FROM to_location_id)
+);
+
+CREATE OR REPLACE FUNCTION fn_apply_stock_relocation()
+RETURNS TRIGGER AS $$
+DECLARE
+  available NUMERIC(10,2);
+BEGIN
+  IF EXISTS (
+    SELECT 1 FROM tb_storage_location
+    WHERE location_id IN (NEW.from_location_id, NEW.to_location_id)
+      AND store_id IS DISTINCT FROM NEW.store_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0008',
+      MESSAGE = 'Relocation locations must belong to the relocation store';
+  END IF;
+
+  IF NEW.from_location_id IS NULL THEN
+    SELECT COALESCE(MAX(s.current_stock), 0) - COALESCE(SUM(sl.quantity), 0)
+    INTO available
+    FROM tb_stock s
+    LEFT JOIN tb_stock_location sl ON sl.item_id = s.item_id
+    WHERE s.item_id = NEW.item_id;
+  ELSE
+    SELECT COALESCE(SUM(quantity), 0)
+    INTO available
+    FROM tb_stock_location
+    WHERE item_id = NEW.item_id
+      AND location_id = NEW.from_location_id;
+  END IF;
+
+  IF COALESCE(available, 0) < NEW.quantity THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0009',
+      MESSAGE = FORMAT(
+        'Item %s: relocating %s but only %s available at the source',
+        NEW.item_id,
+        NEW.quantity::text,
+        COALESCE(available, 0)::text
+      );
+  END IF;
+
+  IF NEW.from_location_id IS NOT NULL THEN
+    UPDATE tb_stock_location
+    SET quantity = quantity - NEW.quantity,
+        updated_at = NOW()
+    WHERE item_id = NEW.item_id
+      AND location_id = NEW.from_location_id;
+  END IF;
+
+  INSERT INTO tb_stock_location (item_id, location_id, quantity)
+  VALUES (NEW.item_id, NEW.to_location_id, NEW.quantity)
+  ON CONFLICT (item_id, location_id)
+  DO UPDATE SET quantity = tb_stock_location.quantity + EXCLUDED.quantity,
+                updated_at = NOW();
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_apply_stock_relocation ON tb_stock_relocation;
+CREATE TRIGGER trg_apply_stock_relocation
+BEFORE INSERT ON tb_stock_relocation
+FOR EACH ROW EXECUTE FUNCTION fn_apply_stock_relocation();
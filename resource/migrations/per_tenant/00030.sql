@@ -0,0 +1,103 @@
+-- +goose Up
+-- Step 1: Tenant-defined attribute fields for items (origin farm, roast level...)
+CREATE TABLE IF NOT EXISTS tb_item_attribute_definition (
+    attribute_id SERIAL PRIMARY KEY,
+    store_id INTEGER NOT NULL REFERENCES tb_store(store_id),
+    attribute_key VARCHAR(64) NOT NULL,
+    attribute_label VARCHAR(255) NOT NULL,
+    attribute_type VARCHAR(16) NOT NULL,
+    enum_options TEXT[] NULL,
+    is_required BOOLEAN NOT NULL DEFAULT FALSE,
+    min_value NUMERIC NULL,
+    max_value NUMERIC NULL,
+    created_by INTEGER NOT NULL REFERENCES public.tb_user(user_id),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CONSTRAINT uq_item_attribute_store_key UNIQUE (store_id, attribute_key),
+    CONSTRAINT chk_item_attribute_key CHECK (attribute_key ~ '^[a-z][a-z0-9_]*$'),
+    CONSTRAINT chk_item_attribute_type CHECK (attribute_type IN ('text', 'number', 'enum', 'date')),
+    CONSTRAINT chk_item_attribute_enum_options CHECK (
+        (attribute_type = 'enum') = (enum_options IS NOT NULL AND cardinality(enum_options) > 0)
+    ),
+    CONSTRAINT chk_item_attribute_bounds CHECK (
+        attribute_type = 'number' OR (min_value IS NULL AND max_value IS NULL)
+    )
+);
+
+DROP TRIGGER IF EXISTS trg_set_updated_at_item_attribute_definition ON tb_item_attribute_definition;
+CREATE TRIGGER trg_set_updated_at_item_attribute_definition
+BEFORE UPDATE ON tb_item_attribute_definition
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Step 2: Values per item, one typed column per attribute type so filters
+-- compare numbers and dates natively
+CREATE TABLE IF NOT EXISTS tb_item_attribute_value (
+    item_id INTEGER NOT NULL REFERENCES tb_item(item_id) ON DELETE CASCADE,
+    attribute_id INTEGER NOT NULL REFERENCES tb_item_attribute_definition(attribute_id),
+    value_text TEXT NULL,
+    value_number NUMERIC NULL,
+    value_date DATE NULL,
+
+    PRIMARY KEY (item_id, attribute_id),
+    CONSTRAINT chk_item_attribute_single_value CHECK (
+        num_nonnulls(value_text, value_number, value_date) = 1
+    )
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_attribute_value_text ON tb_item_attribute_value (attribute_id, lower(value_text));
+CREATE INDEX IF NOT EXISTS idx_item_attribute_value_number ON tb_item_attribute_value (attribute_id, value_number);
+CREATE INDEX IF NOT EXISTS idx_item_attribute_value_date ON tb_item_attribute_value (attribute_id, value_date);
+
+-- Step 3: Values must match their definition (type, enum options, bounds, store)
+CREATE OR REPLACE FUNCTION fn_validate_item_attribute_value()
+RETURNS TRIGGER AS $$
+DECLARE
+  def tb_item_attribute_definition%ROWTYPE;
+BEGIN
+  SELECT * INTO def
+  FROM tb_item_attribute_definition
+  WHERE attribute_id = NEW.attribute_id;
+
+  IF NOT EXISTS (
+    SELECT 1 FROM tb_item
+    WHERE item_id = NEW.item_id
+      AND store_id = def.store_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0010',
+      MESSAGE = FORMAT('Attribute "%s" belongs to another store', def.attribute_key);
+  END IF;
+
+  IF (def.attribute_type IN ('text', 'enum') AND NEW.value_text IS NULL)
+    OR (def.attribute_type = 'number' AND NEW.value_number IS NULL)
+    OR (def.attribute_type = 'date' AND NEW.value_date IS NULL)
+  THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0010',
+      MESSAGE = FORMAT('Attribute "%s" expects a %s value', def.attribute_key, def.attribute_type);
+  END IF;
+
+  IF def.attribute_type = 'enum' AND NOT (NEW.value_text = ANY (def.enum_options)) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0010',
+      MESSAGE = FORMAT('Attribute "%s" does not accept "%s"', def.attribute_key, NEW.value_text);
+  END IF;
+
+  IF def.attribute_type = 'number' AND (
+    (def.min_value IS NOT NULL AND NEW.value_number < def.min_value) OR
+    (def.max_value IS NOT NULL AND NEW.value_number > def.max_value)
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0010',
+      MESSAGE = FORMAT('Attribute "%s" is out of range', def.attribute_key);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_validate_item_attribute_value ON tb_item_attribute_value;
+CREATE TRIGGER trg_validate_item_attribute_value
+BEFORE INSERT OR UPDATE ON tb_item_attribute_value
+FOR EACH ROW EXECUTE FUNCTION fn_validate_item_attribute_value();
@@ -0,0 +1,24 @@
+-- +goose Up
+-- Step 1: Extensions for accent stripping and trigram matching. They are
+-- database-wide, so every tenant schema shares the copy living in public.
+CREATE EXTENSION IF NOT EXISTS unaccent WITH SCHEMA public;
+CREATE EXTENSION IF NOT EXISTS pg_trgm WITH SCHEMA public;
+
+-- Step 2: Immutable normalizer so it can back expression indexes.
+-- public.unaccent(text) is only STABLE because it depends on search_path;
+-- pinning the dictionary makes the result deterministic.
+CREATE OR REPLACE FUNCTION fn_search_normalize(input TEXT)
+RETURNS TEXT AS $$
+  SELECT lower(public.unaccent('public.unaccent'::regdictionary, COALESCE(input, '')));
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;
+
+-- Step 3: Trigram indexes for the searched columns ("Café Açúcar" ~ "cafe acucar")
+CREATE INDEX IF NOT EXISTS idx_item_description_search
+  ON tb_item USING gin (fn_search_normalize(item_description) public.gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_category_description_search
+  ON tb_category USING gin (fn_search_normalize(category_description) public.gin_trgm_ops);
+
+-- Step 4: EAN lookups are exact or prefix matches on digits
+CREATE INDEX IF NOT EXISTS idx_item_ean13_prefix
+  ON tb_item (ean13 bpchar_pattern_ops);
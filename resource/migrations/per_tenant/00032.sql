@@ -0,0 +1,6 @@
+-- +goose Up
+-- Step 1: Packaging contents use the same exact scale as stock quantities, so
+-- fractionable items can be packed in amounts like 0.5 kg and the finalize
+-- triggers compare NUMERIC against NUMERIC only.
+ALTER TABLE tb_item_packaging
+  ALTER COLUMN quantity TYPE NUMERIC(10,2) USING quantity::NUMERIC(10,2);
@@ -0,0 +1,235 @@
+-- +goose Up
+-- Step 1: A packaging may hold N units of another packaging of the same item
+-- (pallet > box > bag). quantity stays the base-unit equivalent and is
+-- derived for nested packagings.
+ALTER TABLE tb_item_packaging
+  ADD COLUMN IF NOT EXISTS inner_packaging_id INTEGER NULL REFERENCES tb_item_packaging(item_packaging_id),
+  ADD COLUMN IF NOT EXISTS inner_quantity NUMERIC(10,2) NULL;
+
+ALTER TABLE tb_item_packaging
+  ADD CONSTRAINT chk_item_packaging_inner
+    CHECK ((inner_packaging_id IS NULL) = (inner_quantity IS NULL)),
+  ADD CONSTRAINT chk_item_packaging_inner_quantity
+    CHECK (inner_quantity IS NULL OR inner_quantity > 0);
+
+CREATE INDEX IF NOT EXISTS idx_item_packaging_inner ON tb_item_packaging(inner_packaging_id);
+
+COMMENT ON COLUMN tb_item_packaging.quantity IS
+  'Base units per package. Derived from inner_quantity for nested packagings.';
+COMMENT ON COLUMN tb_item_packaging.inner_packaging_id IS
+  'Packaging contained by this one, NULL when it holds base units directly.';
+
+-- Step 2: Exact base-unit equivalent, multiplied down the whole chain so
+-- intermediate levels are never rounded to the column scale
+CREATE OR REPLACE FUNCTION fn_item_packaging_base_quantity(p_item_packaging_id INTEGER)
+RETURNS NUMERIC AS $$
+  WITH RECURSIVE chain AS (
+    SELECT item_packaging_id, inner_packaging_id, quantity,
+           COALESCE(inner_quantity, 1)::NUMERIC AS factor
+    FROM tb_item_packaging
+    WHERE item_packaging_id = p_item_packaging_id
+    UNION ALL
+    SELECT p.item_packaging_id, p.inner_packaging_id, p.quantity,
+           c.factor * COALESCE(p.inner_quantity, 1)
+    FROM tb_item_packaging p
+    JOIN chain c ON p.item_packaging_id = c.inner_packaging_id
+  )
+  SELECT factor * quantity
+  FROM chain
+  WHERE inner_packaging_id IS NULL;
+$$ LANGUAGE sql STABLE STRICT;
+
+-- Step 3: Same item only, no cycles, and derive quantity from the contents
+CREATE OR REPLACE FUNCTION fn_validate_item_packaging_inner()
+RETURNS TRIGGER AS $$
+DECLARE
+  inner_item_id INTEGER;
+  inner_base NUMERIC;
+BEGIN
+  -- Containers must keep holding packagings of their own item
+  IF TG_OP = 'UPDATE' AND NEW.item_id IS DISTINCT FROM OLD.item_id AND EXISTS (
+    SELECT 1 FROM tb_item_packaging WHERE inner_packaging_id = NEW.item_packaging_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0011',
+      MESSAGE = FORMAT(
+        'Packaging %s is contained by other packagings and cannot move to another item',
+        NEW.item_packaging_id
+      );
+  END IF;
+
+  IF NEW.inner_packaging_id IS NULL THEN
+    RETURN NEW;
+  END IF;
+
+  IF NEW.inner_packaging_id = NEW.item_packaging_id THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0011',
+      MESSAGE = FORMAT('Packaging %s cannot contain itself', NEW.item_packaging_id);
+  END IF;
+
+  SELECT item_id INTO inner_item_id
+  FROM tb_item_packaging
+  WHERE item_packaging_id = NEW.inner_packaging_id;
+
+  IF FOUND AND inner_item_id IS DISTINCT FROM NEW.item_id THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0011',
+      MESSAGE = FORMAT(
+        'Inner packaging %s belongs to another item',
+        NEW.inner_packaging_id
+      );
+  END IF;
+
+  -- Walking down from the inner packaging must never reach the row being saved
+  IF TG_OP = 'UPDATE' AND EXISTS (
+    WITH RECURSIVE contents AS (
+      SELECT item_packaging_id, inner_packaging_id
+      FROM tb_item_packaging
+      WHERE item_packaging_id = NEW.inner_packaging_id
+      UNION ALL
+      SELECT p.item_packaging_id, p.inner_packaging_id
+      FROM tb_item_packaging p
+      JOIN contents c ON p.item_packaging_id = c.inner_packaging_id
+    )
+    SELECT 1 FROM contents WHERE item_packaging_id = NEW.item_packaging_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0011',
+      MESSAGE = FORMAT(
+        'Packaging %s cannot contain %s: it would contain itself',
+        NEW.item_packaging_id,
+        NEW.inner_packaging_id
+      );
+  END IF;
+
+  inner_base := fn_item_packaging_base_quantity(NEW.inner_packaging_id);
+  NEW.quantity := NEW.inner_quantity * inner_base;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_validate_item_packaging_inner ON tb_item_packaging;
+CREATE TRIGGER trg_validate_item_packaging_inner
+BEFORE INSERT OR UPDATE ON tb_item_packaging
+FOR EACH ROW EXECUTE FUNCTION fn_validate_item_packaging_inner();
+
+-- Step 4: Re-derive containers when the contents change size
+CREATE OR REPLACE FUNCTION fn_propagate_item_packaging_quantity()
+RETURNS TRIGGER AS $$
+BEGIN
+  -- Touching inner_quantity re-runs the derivation above, which fires this
+  -- trigger again one level up
+  UPDATE tb_item_packaging
+  SET inner_quantity = inner_quantity
+  WHERE inner_packaging_id = NEW.item_packaging_id;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_propagate_item_packaging_quantity ON tb_item_packaging;
+CREATE TRIGGER trg_propagate_item_packaging_quantity
+AFTER UPDATE ON tb_item_packaging
+FOR EACH ROW
+WHEN (OLD.quantity IS DISTINCT FROM NEW.quantity)
+EXECUTE FUNCTION fn_propagate_item_packaging_quantity();
+
+-- Step 5: Finalize validation reconciles against the exact base-unit
+-- equivalent, whichever level the document lines were entered at
+CREATE OR REPLACE FUNCTION validate_stock_in_packaging_totals()
+RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    rec RECORD;
+BEGIN
+    IF (
+      NEW.status = 'finalized'
+      AND OLD.status IS DISTINCT FROM 'finalized'
+    )
+    THEN
+        FOR rec IN
+            SELECT
+              i.is_fractionable,
+              sii.stock_in_item_id,
+              sii.total_quantity,
+              SUM(sip.quantity * fn_item_packaging_base_quantity(sip.item_packaging_id)) AS calculated_total
+            FROM tb_stock_in_item    AS sii
+            JOIN tb_item AS i
+              ON i.item_id = sii.item_id
+            LEFT JOIN tb_stock_in_packaging AS sip
+              ON sip.stock_in_item_id = sii.stock_in_item_id
+            WHERE sii.stock_in_id = NEW.stock_in_id
+            GROUP BY i.is_fractionable, sii.stock_in_item_id, sii.total_quantity
+        LOOP
+            IF rec.is_fractionable THEN
+              IF rec.calculated_total IS NULL THEN
+                  RAISE EXCEPTION USING
+                    ERRCODE = 'P0002',
+                    MESSAGE = FORMAT(
+                      'StockInItem %s has no packaging rows',
+                      rec.stock_in_item_id
+                    );
+              ELSIF rec.total_quantity IS DISTINCT FROM rec.calculated_total THEN
+                  RAISE EXCEPTION USING
+                    ERRCODE = 'P0003',
+                    MESSAGE = FORMAT(
+                      'StockInItem %s: packaging total (%s) does not match declared total_quantity (%s)',
+                      rec.stock_in_item_id,
+                      rec.calculated_total::text,
+                      rec.total_quantity::text
+                    );
+              END IF;
+            END IF;
+        END LOOP;
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION validate_stock_out_packaging_totals()
+RETURNS TRIGGER AS $$
+DECLARE
+  rec RECORD;
+BEGIN
+  IF (
+    NEW.status = 'finalized'
+    AND OLD.status IS DISTINCT FROM 'finalized'
+  ) THEN
+    FOR rec IN
+      SELECT
+        i.is_fractionable,
+        soi.stock_out_item_id,
+        soi.total_quantity,
+        SUM(sop.quantity * fn_item_packaging_base_quantity(sop.item_packaging_id)) AS calculated_total
+      FROM tb_stock_out_item AS soi
+      JOIN tb_item AS i ON i.item_id = soi.item_id
+      LEFT JOIN tb_stock_out_packaging AS sop ON sop.stock_out_item_id = soi.stock_out_item_id
+      WHERE soi.stock_out_id = NEW.stock_out_id
+      GROUP BY i.is_fractionable, soi.stock_out_item_id, soi.total_quantity
+    LOOP
+      IF rec.is_fractionable THEN
+        IF rec.calculated_total IS NULL THEN
+          RAISE EXCEPTION USING
+            ERRCODE = 'P0004',
+            MESSAGE = FORMAT('StockOutItem %s has no packaging rows', rec.stock_out_item_id);
+        ELSIF rec.total_quantity IS DISTINCT FROM rec.calculated_total THEN
+          RAISE EXCEPTION USING
+            ERRCODE = 'P0005',
+            MESSAGE = FORMAT(
+              'StockOutItem %s: packaging total (%s) does not match declared total_quantity (%s)',
+              rec.stock_out_item_id,
+              rec.calculated_total::text,
+              rec.total_quantity::text
+            );
+        END IF;
+      END IF;
+    END LOOP;
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
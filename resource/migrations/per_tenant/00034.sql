@@ -0,0 +1,235 @@
+-- +goose Up
+-- Step 1: Optional per-store approval policy. Without a row (or with enabled
+-- = FALSE) documents are finalized directly, as before.
+CREATE TABLE IF NOT EXISTS tb_approval_policy (
+    store_id INT PRIMARY KEY REFERENCES tb_store(store_id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    require_for_waste BOOLEAN NOT NULL DEFAULT FALSE,
+    require_for_stock_out BOOLEAN NOT NULL DEFAULT FALSE,
+    stock_in_value_threshold NUMERIC(14,2) NULL CHECK (stock_in_value_threshold >= 0),
+    allow_self_approval BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+COMMENT ON COLUMN tb_approval_policy.stock_in_value_threshold IS
+  'Stock-ins whose SUM(buy_price * total_quantity) exceeds this need approval. NULL never requires it.';
+
+DROP TRIGGER IF EXISTS trg_set_updated_at_approval_policy ON tb_approval_policy;
+CREATE TRIGGER trg_set_updated_at_approval_policy
+BEFORE UPDATE ON tb_approval_policy
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Step 2: Approval state next to the draft/finalized status. NULL means the
+-- document was never submitted.
+ALTER TABLE tb_stock_in
+  ADD COLUMN IF NOT EXISTS approval_status TEXT NULL
+    CHECK (approval_status IN ('submitted', 'approved', 'rejected'));
+ALTER TABLE tb_stock_out
+  ADD COLUMN IF NOT EXISTS approval_status TEXT NULL
+    CHECK (approval_status IN ('submitted', 'approved', 'rejected'));
+ALTER TABLE tb_stock_waste
+  ADD COLUMN IF NOT EXISTS approval_status TEXT NULL
+    CHECK (approval_status IN ('submitted', 'approved', 'rejected'));
+
+-- Step 3: Decision history, one row per submission, approval or rejection
+CREATE TABLE IF NOT EXISTS tb_stock_document_approval (
+    approval_id SERIAL PRIMARY KEY,
+    document_type TEXT NOT NULL CHECK (document_type IN ('stock_in', 'stock_out', 'stock_waste')),
+    document_id INT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('submitted', 'approved', 'rejected')),
+    comment TEXT NULL,
+    decided_by INT NOT NULL REFERENCES public.tb_user(user_id),
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_document_approval_document
+  ON tb_stock_document_approval (document_type, document_id, created_at);
+
+-- Step 4: Whether the store's policy requires approval for a document
+CREATE OR REPLACE FUNCTION fn_stock_document_requires_approval(p_document_type TEXT, p_document_id INT)
+RETURNS BOOLEAN AS $$
+DECLARE
+  policy tb_approval_policy%ROWTYPE;
+  doc_store_id INT;
+  doc_value NUMERIC;
+BEGIN
+  IF p_document_type = 'stock_in' THEN
+    SELECT store_id INTO doc_store_id FROM tb_stock_in WHERE stock_in_id = p_document_id;
+  ELSIF p_document_type = 'stock_out' THEN
+    SELECT store_id INTO doc_store_id FROM tb_stock_out WHERE stock_out_id = p_document_id;
+  ELSE
+    SELECT store_id INTO doc_store_id FROM tb_stock_waste WHERE stock_waste_id = p_document_id;
+  END IF;
+
+  SELECT * INTO policy FROM tb_approval_policy WHERE store_id = doc_store_id;
+  IF NOT FOUND OR NOT policy.enabled THEN
+    RETURN FALSE;
+  END IF;
+
+  IF p_document_type = 'stock_waste' THEN
+    RETURN policy.require_for_waste;
+  ELSIF p_document_type = 'stock_out' THEN
+    RETURN policy.require_for_stock_out;
+  END IF;
+
+  IF policy.stock_in_value_threshold IS NULL THEN
+    RETURN FALSE;
+  END IF;
+
+  SELECT COALESCE(SUM(buy_price * total_quantity), 0) INTO doc_value
+  FROM tb_stock_in_item
+  WHERE stock_in_id = p_document_id;
+
+  RETURN doc_value > policy.stock_in_value_threshold;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- Step 5: Finalizing needs an approval when the policy asks for one, or when
+-- the document was submitted for review anyway
+CREATE OR REPLACE FUNCTION fn_require_stock_document_approval()
+RETURNS TRIGGER AS $$
+DECLARE
+  doc_id INT;
+BEGIN
+  IF NEW.status = 'finalized' AND OLD.status IS DISTINCT FROM 'finalized' THEN
+    -- <document_type>_id is the primary key of every document table
+    doc_id := (to_jsonb(NEW) ->> (TG_ARGV[0] || '_id'))::INT;
+
+    IF NEW.approval_status IS DISTINCT FROM 'approved' AND (
+      NEW.approval_status IS NOT NULL OR fn_stock_document_requires_approval(TG_ARGV[0], doc_id)
+    ) THEN
+      RAISE EXCEPTION USING
+        ERRCODE = 'P0012',
+        MESSAGE = FORMAT(
+          'Document %s %s needs an approval before it can be finalized (approval status: %s)',
+          TG_ARGV[0],
+          doc_id,
+          COALESCE(NEW.approval_status, 'not submitted')
+        );
+    END IF;
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_require_stock_in_approval ON tb_stock_in;
+CREATE TRIGGER trg_require_stock_in_approval
+BEFORE UPDATE ON tb_stock_in
+FOR EACH ROW EXECUTE FUNCTION fn_require_stock_document_approval('stock_in');
+
+DROP TRIGGER IF EXISTS trg_require_stock_out_approval ON tb_stock_out;
+CREATE TRIGGER trg_require_stock_out_approval
+BEFORE UPDATE ON tb_stock_out
+FOR EACH ROW EXECUTE FUNCTION fn_require_stock_document_approval('stock_out');
+
+DROP TRIGGER IF EXISTS trg_require_stock_waste_approval ON tb_stock_waste;
+CREATE TRIGGER trg_require_stock_waste_approval
+BEFORE UPDATE ON tb_stock_waste
+FOR EACH ROW EXECUTE FUNCTION fn_require_stock_document_approval('stock_waste');
+
+-- Step 6: Submitted and approved documents are frozen so the approved content
+-- is what gets finalized. Rejected documents can be edited and resubmitted.
+CREATE OR REPLACE FUNCTION fn_lock_stock_in_lines_under_approval()
+RETURNS TRIGGER AS $$
+DECLARE
+  doc_id INT;
+  doc_approval TEXT;
+BEGIN
+  IF TG_TABLE_NAME = 'tb_stock_in_packaging' THEN
+    SELECT sii.stock_in_id INTO doc_id
+    FROM tb_stock_in_item sii
+    WHERE sii.stock_in_item_id = CASE WHEN TG_OP = 'DELETE' THEN OLD.stock_in_item_id ELSE NEW.stock_in_item_id END;
+  ELSIF TG_OP = 'DELETE' THEN
+    doc_id := OLD.stock_in_id;
+  ELSE
+    doc_id := NEW.stock_in_id;
+  END IF;
+
+  SELECT approval_status INTO doc_approval FROM tb_stock_in WHERE stock_in_id = doc_id;
+
+  IF doc_approval IN ('submitted', 'approved') THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0013',
+      MESSAGE = FORMAT('Stock-in %s is %s and cannot be edited', doc_id, doc_approval);
+  END IF;
+
+  IF TG_OP = 'DELETE' THEN
+    RETURN OLD;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_lock_stock_in_item_under_approval ON tb_stock_in_item;
+CREATE TRIGGER trg_lock_stock_in_item_under_approval
+BEFORE INSERT OR UPDATE OR DELETE ON tb_stock_in_item
+FOR EACH ROW EXECUTE FUNCTION fn_lock_stock_in_lines_under_approval();
+
+DROP TRIGGER IF EXISTS trg_lock_stock_in_packaging_under_approval ON tb_stock_in_packaging;
+CREATE TRIGGER trg_lock_stock_in_packaging_under_approval
+BEFORE INSERT OR UPDATE OR DELETE ON tb_stock_in_packaging
+FOR EACH ROW EXECUTE FUNCTION fn_lock_stock_in_lines_under_approval();
+
+CREATE OR REPLACE FUNCTION fn_lock_stock_out_lines_under_approval()
+RETURNS TRIGGER AS $$
+DECLARE
+  doc_id INT;
+  doc_approval TEXT;
+BEGIN
+  IF TG_TABLE_NAME = 'tb_stock_out_packaging' THEN
+    SELECT soi.stock_out_id INTO doc_id
+    FROM tb_stock_out_item soi
+    WHERE soi.stock_out_item_id = CASE WHEN TG_OP = 'DELETE' THEN OLD.stock_out_item_id ELSE NEW.stock_out_item_id END;
+  ELSIF TG_OP = 'DELETE' THEN
+    doc_id := OLD.stock_out_id;
+  ELSE
+    doc_id := NEW.stock_out_id;
+  END IF;
+
+  SELECT approval_status INTO doc_approval FROM tb_stock_out WHERE stock_out_id = doc_id;
+
+  IF doc_approval IN ('submitted', 'approved') THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0013',
+      MESSAGE = FORMAT('Stock-out %s is %s and cannot be edited', doc_id, doc_approval);
+  END IF;
+
+  IF TG_OP = 'DELETE' THEN
+    RETURN OLD;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_lock_stock_out_item_under_approval ON tb_stock_out_item;
+CREATE TRIGGER trg_lock_stock_out_item_under_approval
+BEFORE INSERT OR UPDATE OR DELETE ON tb_stock_out_item
+FOR EACH ROW EXECUTE FUNCTION fn_lock_stock_out_lines_under_approval();
+
+DROP TRIGGER IF EXISTS trg_lock_stock_out_packaging_under_approval ON tb_stock_out_packaging;
+CREATE TRIGGER trg_lock_stock_out_packaging_under_approval
+BEFORE INSERT OR UPDATE OR DELETE ON tb_stock_out_packaging
+FOR EACH ROW EXECUTE FUNCTION fn_lock_stock_out_lines_under_approval();
+
+CREATE OR REPLACE FUNCTION fn_lock_stock_waste_under_approval()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF OLD.approval_status IN ('submitted', 'approved') AND
+     (NEW.item_id, NEW.wasted_quantity, NEW.reason_text, NEW.reason_image_url)
+       IS DISTINCT FROM (OLD.item_id, OLD.wasted_quantity, OLD.reason_text, OLD.reason_image_url)
+  THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0013',
+      MESSAGE = FORMAT('Stock-waste %s is %s and cannot be edited', OLD.stock_waste_id, OLD.approval_status);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_lock_stock_waste_under_approval ON tb_stock_waste;
+CREATE TRIGGER trg_lock_stock_waste_under_approval
+BEFORE UPDATE ON tb_stock_waste
+FOR EACH ROW EXECUTE FUNCTION fn_lock_stock_waste_under_approval();
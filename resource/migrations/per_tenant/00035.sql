@@ -0,0 +1,52 @@
+-- +goose Up
+-- Step 1: Role assignments. A row without store_id is the user's role in the
+-- whole organization; a row with store_id overrides it in that store.
+CREATE TABLE IF NOT EXISTS tb_user_role (
+    user_role_id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES public.tb_user(user_id) ON DELETE CASCADE,
+    store_id INT NULL REFERENCES tb_store(store_id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'manager', 'clerk', 'viewer')),
+    assigned_by INT NULL REFERENCES public.tb_user(user_id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_user_role_organization
+  ON tb_user_role (user_id) WHERE store_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_user_role_store
+  ON tb_user_role (user_id, store_id) WHERE store_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_user_role_store ON tb_user_role (store_id);
+
+DROP TRIGGER IF EXISTS trg_set_updated_at_user_role ON tb_user_role;
+CREATE TRIGGER trg_set_updated_at_user_role
+BEFORE UPDATE ON tb_user_role
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Step 2: Everyone already in the organization keeps full access as an owner
+INSERT INTO tb_user_role (user_id, role)
+SELECT u.user_id, 'owner'
+FROM public.tb_user u
+JOIN public.tb_organization o ON o.organization_id = u.organization_id
+WHERE o.schema_name = current_schema()
+ON CONFLICT DO NOTHING;
+
+-- Step 3: The organization must always keep at least one owner
+CREATE OR REPLACE FUNCTION fn_keep_organization_owner()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF OLD.store_id IS NULL AND OLD.role = 'owner' AND NOT EXISTS (
+    SELECT 1 FROM tb_user_role WHERE store_id IS NULL AND role = 'owner'
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0014',
+      MESSAGE = FORMAT('User %s is the last owner of the organization', OLD.user_id);
+  END IF;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_keep_organization_owner ON tb_user_role;
+CREATE TRIGGER trg_keep_organization_owner
+AFTER UPDATE OR DELETE ON tb_user_role
+FOR EACH ROW EXECUTE FUNCTION fn_keep_organization_owner();
@@ -0,0 +1,57 @@
+-- +goose Up
+-- Step 1: Store membership. Only members of a store (and organization owners)
+-- may send its id in X-Store-ID.
+CREATE TABLE IF NOT EXISTS tb_store_member (
+    store_id INT NOT NULL REFERENCES tb_store(store_id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES public.tb_user(user_id) ON DELETE CASCADE,
+    added_by INT NULL REFERENCES public.tb_user(user_id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (store_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_store_member_user ON tb_store_member (user_id);
+
+-- Step 2: Store creators and users with a store role keep their access
+INSERT INTO tb_store_member (store_id, user_id)
+SELECT store_id, created_by FROM tb_store
+ON CONFLICT DO NOTHING;
+
+INSERT INTO tb_store_member (store_id, user_id, added_by)
+SELECT store_id, user_id, assigned_by FROM tb_user_role WHERE store_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+-- Step 3: The creator of a store is its first member
+CREATE OR REPLACE FUNCTION fn_add_store_creator_member()
+RETURNS TRIGGER AS $$
+BEGIN
+  INSERT INTO tb_store_member (store_id, user_id, added_by)
+  VALUES (NEW.store_id, NEW.created_by, NEW.created_by)
+  ON CONFLICT DO NOTHING;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_add_store_creator_member ON tb_store;
+CREATE TRIGGER trg_add_store_creator_member
+AFTER INSERT ON tb_store
+FOR EACH ROW EXECUTE FUNCTION fn_add_store_creator_member();
+
+-- Step 4: Giving a user a role in a store makes the user a member of it
+CREATE OR REPLACE FUNCTION fn_add_store_role_member()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF NEW.store_id IS NOT NULL THEN
+    INSERT INTO tb_store_member (store_id, user_id, added_by)
+    VALUES (NEW.store_id, NEW.user_id, NEW.assigned_by)
+    ON CONFLICT DO NOTHING;
+  END IF;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_add_store_role_member ON tb_user_role;
+CREATE TRIGGER trg_add_store_role_member
+AFTER INSERT OR UPDATE ON tb_user_role
+FOR EACH ROW EXECUTE FUNCTION fn_add_store_role_member();
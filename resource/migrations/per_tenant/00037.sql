@@ -0,0 +1,181 @@
+-- +goose Up
+-- Step 1: Audit trail of every change to the tenant's data. user_id and
+-- api_key_id carry no foreign keys so entries outlive the actors.
+CREATE TABLE IF NOT EXISTS tb_audit_log (
+    audit_id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    user_id INT NULL,
+    api_key_id INT NULL,
+    entity_type TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete', 'finalize')),
+    before JSONB NULL,
+    after JSONB NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON tb_audit_log (entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_user ON tb_audit_log (user_id, occurred_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_occurred_at ON tb_audit_log (occurred_at);
+
+COMMENT ON COLUMN tb_audit_log.user_id IS
+  'User whose request made the change, from the app.user_id setting. NULL for system changes.';
+COMMENT ON COLUMN tb_audit_log.entity_id IS
+  'Primary key of the changed row; composite keys are joined with '':''.';
+
+-- Step 2: Row trigger writing the audit entries. The first argument is the
+-- entity type and the rest name the key columns. The API sets app.user_id
+-- and app.api_key_id on the tenant connection of each request.
+CREATE OR REPLACE FUNCTION fn_audit_row()
+RETURNS TRIGGER AS $$
+DECLARE
+  v_before JSONB;
+  v_after JSONB;
+  v_row JSONB;
+  v_action TEXT;
+  v_entity_id TEXT := '';
+  i INT;
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    v_after := to_jsonb(NEW);
+    v_action := 'create';
+  ELSIF TG_OP = 'UPDATE' THEN
+    v_before := to_jsonb(OLD);
+    v_after := to_jsonb(NEW);
+    -- Skip updates that only touched updated_at
+    IF v_before - 'updated_at' = v_after - 'updated_at' THEN
+      RETURN NULL;
+    END IF;
+    IF v_after->>'status' = 'finalized' AND v_before->>'status' IS DISTINCT FROM 'finalized' THEN
+      v_action := 'finalize';
+    ELSE
+      v_action := 'update';
+    END IF;
+  ELSE
+    v_before := to_jsonb(OLD);
+    v_action := 'delete';
+  END IF;
+
+  v_row := COALESCE(v_after, v_before);
+  FOR i IN 1 .. TG_NARGS - 1 LOOP
+    IF i > 1 THEN
+      v_entity_id := v_entity_id || ':';
+    END IF;
+    v_entity_id := v_entity_id || COALESCE(v_row->>TG_ARGV[i], '');
+  END LOOP;
+
+  INSERT INTO tb_audit_log (user_id, api_key_id, entity_type, entity_id, action, before, after)
+  VALUES (
+    NULLIF(current_setting('app.user_id', true), '')::INT,
+    NULLIF(current_setting('app.api_key_id', true), '')::INT,
+    TG_ARGV[0],
+    v_entity_id,
+    v_action,
+    v_before,
+    v_after
+  );
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Step 3: Audit the tables users edit. tb_stock and tb_stock_location are
+-- balances derived from the audited documents and are left out.
+DROP TRIGGER IF EXISTS trg_audit_store ON tb_store;
+CREATE TRIGGER trg_audit_store
+AFTER INSERT OR UPDATE OR DELETE ON tb_store
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('store', 'store_id');
+
+DROP TRIGGER IF EXISTS trg_audit_category ON tb_category;
+CREATE TRIGGER trg_audit_category
+AFTER INSERT OR UPDATE OR DELETE ON tb_category
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('category', 'category_id');
+
+DROP TRIGGER IF EXISTS trg_audit_unit_of_measure ON tb_unit_of_measure;
+CREATE TRIGGER trg_audit_unit_of_measure
+AFTER INSERT OR UPDATE OR DELETE ON tb_unit_of_measure
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('unit_of_measure', 'unit_id');
+
+DROP TRIGGER IF EXISTS trg_audit_item ON tb_item;
+CREATE TRIGGER trg_audit_item
+AFTER INSERT OR UPDATE OR DELETE ON tb_item
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('item', 'item_id');
+
+DROP TRIGGER IF EXISTS trg_audit_item_packaging ON tb_item_packaging;
+CREATE TRIGGER trg_audit_item_packaging
+AFTER INSERT OR UPDATE OR DELETE ON tb_item_packaging
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('item_packaging', 'item_packaging_id');
+
+DROP TRIGGER IF EXISTS trg_audit_item_attribute ON tb_item_attribute_definition;
+CREATE TRIGGER trg_audit_item_attribute
+AFTER INSERT OR UPDATE OR DELETE ON tb_item_attribute_definition
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('item_attribute', 'attribute_id');
+
+DROP TRIGGER IF EXISTS trg_audit_item_attribute_value ON tb_item_attribute_value;
+CREATE TRIGGER trg_audit_item_attribute_value
+AFTER INSERT OR UPDATE OR DELETE ON tb_item_attribute_value
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('item_attribute_value', 'item_id', 'attribute_id');
+
+DROP TRIGGER IF EXISTS trg_audit_storage_location ON tb_storage_location;
+CREATE TRIGGER trg_audit_storage_location
+AFTER INSERT OR UPDATE OR DELETE ON tb_storage_location
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('storage_location', 'location_id');
+
+DROP TRIGGER IF EXISTS trg_audit_stock_in ON tb_stock_in;
+CREATE TRIGGER trg_audit_stock_in
+AFTER INSERT OR UPDATE OR DELETE ON tb_stock_in
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('stock_in', 'stock_in_id');
+
+DROP TRIGGER IF EXISTS trg_audit_stock_in_item ON tb_stock_in_item;
+CREATE TRIGGER trg_audit_stock_in_item
+AFTER INSERT OR UPDATE OR DELETE ON tb_stock_in_item
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('stock_in_item', 'stock_in_item_id');
+
+DROP TRIGGER IF EXISTS trg_audit_stock_in_packaging ON tb_stock_in_packaging;
+CREATE TRIGGER trg_audit_stock_in_packaging
+AFTER INSERT OR UPDATE OR DELETE ON tb_stock_in_packaging
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('stock_in_packaging', 'stock_in_packaging_id');
+
+DROP TRIGGER IF EXISTS trg_audit_stock_out ON tb_stock_out;
+CREATE TRIGGER trg_audit_stock_out
+AFTER INSERT OR UPDATE OR DELETE ON tb_stock_out
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('stock_out', 'stock_out_id');
+
+DROP TRIGGER IF EXISTS trg_audit_stock_out_item ON tb_stock_out_item;
+CREATE TRIGGER trg_audit_stock_out_item
+AFTER INSERT OR UPDATE OR DELETE ON tb_stock_out_item
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('stock_out_item', 'stock_out_item_id');
+
+DROP TRIGGER IF EXISTS trg_audit_stock_out_packaging ON tb_stock_out_packaging;
+CREATE TRIGGER trg_audit_stock_out_packaging
+AFTER INSERT OR UPDATE OR DELETE ON tb_stock_out_packaging
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('stock_out_packaging', 'stock_out_packaging_id');
+
+DROP TRIGGER IF EXISTS trg_audit_stock_waste ON tb_stock_waste;
+CREATE TRIGGER trg_audit_stock_waste
+AFTER INSERT OR UPDATE OR DELETE ON tb_stock_waste
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('stock_waste', 'stock_waste_id');
+
+DROP TRIGGER IF EXISTS trg_audit_stock_relocation ON tb_stock_relocation;
+CREATE TRIGGER trg_audit_stock_relocation
+AFTER INSERT OR UPDATE OR DELETE ON tb_stock_relocation
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('stock_relocation', 'relocation_id');
+
+DROP TRIGGER IF EXISTS trg_audit_approval_policy ON tb_approval_policy;
+CREATE TRIGGER trg_audit_approval_policy
+AFTER INSERT OR UPDATE OR DELETE ON tb_approval_policy
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('approval_policy', 'store_id');
+
+DROP TRIGGER IF EXISTS trg_audit_stock_approval ON tb_stock_document_approval;
+CREATE TRIGGER trg_audit_stock_approval
+AFTER INSERT OR UPDATE OR DELETE ON tb_stock_document_approval
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('stock_approval', 'approval_id');
+
+DROP TRIGGER IF EXISTS trg_audit_role_assignment ON tb_user_role;
+CREATE TRIGGER trg_audit_role_assignment
+AFTER INSERT OR UPDATE OR DELETE ON tb_user_role
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('role_assignment', 'user_role_id');
+
+DROP TRIGGER IF EXISTS trg_audit_store_member ON tb_store_member;
+CREATE TRIGGER trg_audit_store_member
+AFTER INSERT OR UPDATE OR DELETE ON tb_store_member
+FOR EACH ROW EXECUTE FUNCTION fn_audit_row('store_member', 'store_id', 'user_id');
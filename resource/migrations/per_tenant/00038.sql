@@ -0,0 +1,338 @@
+-- +goose Up
+-- Step 1: A location never holds a negative quantity. NOT VALID leaves
+-- balances that are already off alone and checks every change from now on.
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1
+    FROM pg_constraint
+    WHERE conname = 'chk_stock_location_quantity'
+      AND conrelid = 'tb_stock_location'::regclass
+  ) THEN
+    ALTER TABLE tb_stock_location
+      ADD CONSTRAINT chk_stock_location_quantity CHECK (quantity >= 0) NOT VALID;
+  END IF;
+END
+$$;
+
+-- Step 2: Stock-waste is taken from a location too. NULL takes it from
+-- unassigned stock.
+ALTER TABLE tb_stock_waste
+ADD COLUMN IF NOT EXISTS location_id INTEGER NULL REFERENCES tb_storage_location(location_id);
+
+COMMENT ON COLUMN tb_stock_waste.location_id IS
+  'Location the waste is taken from on finalization. NULL takes it from unassigned stock.';
+
+CREATE OR REPLACE FUNCTION fn_validate_stock_waste_location()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF NEW.location_id IS NOT NULL AND NOT EXISTS (
+    SELECT 1
+    FROM tb_storage_location l
+    WHERE l.location_id = NEW.location_id
+      AND l.store_id = NEW.store_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0008',
+      MESSAGE = FORMAT('Location %s does not belong to the stock-waste store', NEW.location_id);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_validate_stock_waste_location ON tb_stock_waste;
+CREATE TRIGGER trg_validate_stock_waste_location
+BEFORE INSERT OR UPDATE OF location_id, store_id ON tb_stock_waste
+FOR EACH ROW EXECUTE FUNCTION fn_validate_stock_waste_location();
+
+-- The location is part of what a submitted or approved waste locks
+CREATE OR REPLACE FUNCTION fn_lock_stock_waste_under_approval()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF OLD.approval_status IN ('submitted', 'approved') AND
+     (NEW.item_id, NEW.wasted_quantity, NEW.location_id, NEW.reason_text, NEW.reason_image_url)
+       IS DISTINCT FROM (OLD.item_id, OLD.wasted_quantity, OLD.location_id, OLD.reason_text, OLD.reason_image_url)
+  THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0013',
+      MESSAGE = FORMAT('Stock-waste %s is %s and cannot be edited', OLD.stock_waste_id, OLD.approval_status);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Step 3: Balance changes lock the item's tb_stock row, then its location
+-- rows, always in that order, so concurrent finalizations and relocations of
+-- the same item run one after the other and check up-to-date balances.
+CREATE OR REPLACE FUNCTION fn_lock_item_stock(p_item_ids INTEGER[])
+RETURNS VOID AS $$
+BEGIN
+  PERFORM 1
+  FROM tb_stock
+  WHERE item_id = ANY(p_item_ids)
+  ORDER BY item_id
+  FOR UPDATE;
+
+  PERFORM 1
+  FROM tb_stock_location
+  WHERE item_id = ANY(p_item_ids)
+  ORDER BY item_id, location_id
+  FOR UPDATE;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Step 4: Finalizations. The location triggers run before the tb_stock ones
+-- (triggers fire in name order), so tb_stock still holds the totals from
+-- before the document.
+CREATE OR REPLACE FUNCTION fn_update_stock_location_on_stock_in_finalization()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF (
+    OLD.finalized_at IS NULL AND NEW.finalized_at IS NOT NULL AND
+    OLD.status = 'draft' AND NEW.status = 'finalized'
+  ) THEN
+    PERFORM fn_lock_item_stock(ARRAY(
+      SELECT sii.item_id FROM tb_stock_in_item sii WHERE sii.stock_in_id = NEW.stock_in_id
+    ));
+
+    INSERT INTO tb_stock_location (item_id, location_id, quantity)
+    SELECT sii.item_id, sii.location_id, SUM(sii.total_quantity)
+    FROM tb_stock_in_item sii
+    WHERE sii.stock_in_id = NEW.stock_in_id
+      AND sii.location_id IS NOT NULL
+    GROUP BY sii.item_id, sii.location_id
+    ON CONFLICT (item_id, location_id)
+    DO UPDATE SET quantity = tb_stock_location.quantity + EXCLUDED.quantity,
+                  updated_at = NOW();
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Lines without a location take unassigned stock: what the item holds beyond
+-- its located balances. An item with nothing located has the tb_stock total
+-- as its only limit, and taking more than that stays a validation warning.
+CREATE OR REPLACE FUNCTION fn_update_stock_location_on_stock_out_finalization()
+RETURNS TRIGGER AS $$
+DECLARE
+  shortage RECORD;
+BEGIN
+  IF (
+    OLD.finalized_at IS NULL AND NEW.finalized_at IS NOT NULL AND
+    OLD.status = 'draft' AND NEW.status = 'finalized'
+  ) THEN
+    PERFORM fn_lock_item_stock(ARRAY(
+      SELECT soi.item_id FROM tb_stock_out_item soi WHERE soi.stock_out_id = NEW.stock_out_id
+    ));
+
+    SELECT picked.item_id, picked.location_id, picked.quantity, COALESCE(sl.quantity, 0) AS available
+    INTO shortage
+    FROM (
+      SELECT soi.item_id, soi.location_id, SUM(soi.total_quantity) AS quantity
+      FROM tb_stock_out_item soi
+      WHERE soi.stock_out_id = NEW.stock_out_id
+        AND soi.location_id IS NOT NULL
+      GROUP BY soi.item_id, soi.location_id
+    ) picked
+    LEFT JOIN tb_stock_location sl
+      ON sl.item_id = picked.item_id AND sl.location_id = picked.location_id
+    WHERE picked.quantity > COALESCE(sl.quantity, 0)
+    LIMIT 1;
+
+    IF FOUND THEN
+      RAISE EXCEPTION USING
+        ERRCODE = 'P0009',
+        MESSAGE = FORMAT(
+          'Item %s: picking %s from location %s but only %s available',
+          shortage.item_id,
+          shortage.quantity::text,
+          shortage.location_id,
+          shortage.available::text
+        );
+    END IF;
+
+    SELECT picked.item_id, picked.quantity, COALESCE(s.current_stock, 0) - located.quantity AS available
+    INTO shortage
+    FROM (
+      SELECT soi.item_id, SUM(soi.total_quantity) AS quantity
+      FROM tb_stock_out_item soi
+      WHERE soi.stock_out_id = NEW.stock_out_id
+        AND soi.location_id IS NULL
+      GROUP BY soi.item_id
+    ) picked
+    JOIN (
+      SELECT item_id, SUM(quantity) AS quantity
+      FROM tb_stock_location
+      GROUP BY item_id
+    ) located ON located.item_id = picked.item_id
+    LEFT JOIN tb_stock s ON s.item_id = picked.item_id
+    WHERE located.quantity > 0
+      AND picked.quantity > COALESCE(s.current_stock, 0) - located.quantity
+    LIMIT 1;
+
+    IF FOUND THEN
+      RAISE EXCEPTION USING
+        ERRCODE = 'P0009',
+        MESSAGE = FORMAT(
+          'Item %s: picking %s from unassigned stock but only %s available',
+          shortage.item_id,
+          shortage.quantity::text,
+          GREATEST(shortage.available, 0)::text
+        );
+    END IF;
+
+    UPDATE tb_stock_location sl
+    SET quantity = sl.quantity - picked.quantity,
+        updated_at = NOW()
+    FROM (
+      SELECT soi.item_id, soi.location_id, SUM(soi.total_quantity) AS quantity
+      FROM tb_stock_out_item soi
+      WHERE soi.stock_out_id = NEW.stock_out_id
+        AND soi.location_id IS NOT NULL
+      GROUP BY soi.item_id, soi.location_id
+    ) picked
+    WHERE sl.item_id = picked.item_id
+      AND sl.location_id = picked.location_id;
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION fn_update_stock_location_on_stock_waste_finalization()
+RETURNS TRIGGER AS $$
+DECLARE
+  located NUMERIC;
+  available NUMERIC;
+BEGIN
+  IF (
+    OLD.finalized_at IS NULL AND NEW.finalized_at IS NOT NULL AND
+    OLD.status = 'draft' AND NEW.status = 'finalized'
+  ) THEN
+    PERFORM fn_lock_item_stock(ARRAY[NEW.item_id]);
+
+    IF NEW.location_id IS NOT NULL THEN
+      SELECT COALESCE(SUM(quantity), 0)
+      INTO available
+      FROM tb_stock_location
+      WHERE item_id = NEW.item_id
+        AND location_id = NEW.location_id;
+
+      IF NEW.wasted_quantity > available THEN
+        RAISE EXCEPTION USING
+          ERRCODE = 'P0009',
+          MESSAGE = FORMAT(
+            'Item %s: wasting %s from location %s but only %s available',
+            NEW.item_id,
+            NEW.wasted_quantity::text,
+            NEW.location_id,
+            available::text
+          );
+      END IF;
+
+      UPDATE tb_stock_location
+      SET quantity = quantity - NEW.wasted_quantity,
+          updated_at = NOW()
+      WHERE item_id = NEW.item_id
+        AND location_id = NEW.location_id;
+    ELSE
+      SELECT COALESCE(SUM(quantity), 0)
+      INTO located
+      FROM tb_stock_location
+      WHERE item_id = NEW.item_id;
+
+      SELECT COALESCE(MAX(current_stock), 0) - located
+      INTO available
+      FROM tb_stock
+      WHERE item_id = NEW.item_id;
+
+      IF located > 0 AND NEW.wasted_quantity > available THEN
+        RAISE EXCEPTION USING
+          ERRCODE = 'P0009',
+          MESSAGE = FORMAT(
+            'Item %s: wasting %s from unassigned stock but only %s available',
+            NEW.item_id,
+            NEW.wasted_quantity::text,
+            GREATEST(available, 0)::text
+          );
+      END IF;
+    END IF;
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_update_stock_location_on_waste_finalization ON tb_stock_waste;
+CREATE TRIGGER trg_update_stock_location_on_waste_finalization
+AFTER UPDATE ON tb_stock_waste
+FOR EACH ROW
+WHEN (
+  OLD.finalized_at IS DISTINCT FROM NEW.finalized_at OR
+  OLD.status IS DISTINCT FROM NEW.status
+)
+EXECUTE FUNCTION fn_update_stock_location_on_stock_waste_finalization();
+
+-- Step 5: Relocations check their source under the same locks
+CREATE OR REPLACE FUNCTION fn_apply_stock_relocation()
+RETURNS TRIGGER AS $$
+DECLARE
+  available NUMERIC(10,2);
+BEGIN
+  IF EXISTS (
+    SELECT 1 FROM tb_storage_location
+    WHERE location_id IN (NEW.from_location_id, NEW.to_location_id)
+      AND store_id IS DISTINCT FROM NEW.store_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0008',
+      MESSAGE = 'Relocation locations must belong to the relocation store';
+  END IF;
+
+  PERFORM fn_lock_item_stock(ARRAY[NEW.item_id]);
+
+  IF NEW.from_location_id IS NULL THEN
+    SELECT COALESCE(MAX(s.current_stock), 0) - COALESCE(SUM(sl.quantity), 0)
+    INTO available
+    FROM tb_stock s
+    LEFT JOIN tb_stock_location sl ON sl.item_id = s.item_id
+    WHERE s.item_id = NEW.item_id;
+  ELSE
+    SELECT COALESCE(SUM(quantity), 0)
+    INTO available
+    FROM tb_stock_location
+    WHERE item_id = NEW.item_id
+      AND location_id = NEW.from_location_id;
+  END IF;
+
+  IF COALESCE(available, 0) < NEW.quantity THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0009',
+      MESSAGE = FORMAT(
+        'Item %s: relocating %s but only %s available at the source',
+        NEW.item_id,
+        NEW.quantity::text,
+        COALESCE(available, 0)::text
+      );
+  END IF;
+
+  IF NEW.from_location_id IS NOT NULL THEN
+    UPDATE tb_stock_location
+    SET quantity = quantity - NEW.quantity,
+        updated_at = NOW()
+    WHERE item_id = NEW.item_id
+      AND location_id = NEW.from_location_id;
+  END IF;
+
+  INSERT INTO tb_stock_location (item_id, location_id, quantity)
+  VALUES (NEW.item_id, NEW.to_location_id, NEW.quantity)
+  ON CONFLICT (item_id, location_id)
+  DO UPDATE SET quantity = tb_stock_location.quantity + EXCLUDED.quantity,
+                updated_at = NOW();
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
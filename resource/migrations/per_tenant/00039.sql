@@ -0,0 +1,80 @@
+-- +goose Up
+-- Step 1: Serialize hierarchy changes. The cycle checks walk rows other
+-- transactions may be re-parenting at the same time, so two crossing moves
+-- (A under B, B under A) could both pass. Changes to one tree take a
+-- transaction-scoped advisory lock first; the walk of whichever runs second
+-- then sees the committed move. Keys include the schema because advisory
+-- locks are database-wide, and are taken in order to avoid deadlocks.
+CREATE OR REPLACE FUNCTION fn_lock_hierarchy(p_tree TEXT, p_ids INTEGER[])
+RETURNS VOID AS $$
+DECLARE
+  lock_id INTEGER;
+BEGIN
+  FOR lock_id IN
+    SELECT DISTINCT id FROM unnest(p_ids) AS id WHERE id IS NOT NULL ORDER BY id
+  LOOP
+    PERFORM pg_advisory_xact_lock(hashtext(p_tree || ':' || current_schema() || ':' || lock_id));
+  END LOOP;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Step 2: Category trees are locked per store
+CREATE OR REPLACE FUNCTION fn_validate_category_parent()
+RETURNS TRIGGER AS $$
+DECLARE
+  parent_store_id INTEGER;
+BEGIN
+  IF NEW.parent_category_id IS NULL THEN
+    RETURN NEW;
+  END IF;
+
+  IF NEW.parent_category_id = NEW.category_id THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0007',
+      MESSAGE = FORMAT('Category %s cannot be its own parent', NEW.category_id);
+  END IF;
+
+  IF TG_OP = 'UPDATE' THEN
+    PERFORM fn_lock_hierarchy('category_tree', ARRAY[OLD.store_id, NEW.store_id]);
+  ELSE
+    PERFORM fn_lock_hierarchy('category_tree', ARRAY[NEW.store_id]);
+  END IF;
+
+  SELECT store_id INTO parent_store_id
+  FROM tb_category
+  WHERE category_id = NEW.parent_category_id;
+
+  IF FOUND AND parent_store_id IS DISTINCT FROM NEW.store_id THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0007',
+      MESSAGE = FORMAT(
+        'Parent category %s belongs to another store',
+        NEW.parent_category_id
+      );
+  END IF;
+
+  -- Walking up from the new parent must never reach the row being saved
+  IF TG_OP = 'UPDATE' AND EXISTS (
+    WITH RECURSIVE ancestors AS (
+      SELECT category_id, parent_category_id
+      FROM tb_category
+      WHERE category_id = NEW.parent_category_id
+      UNION ALL
+      SELECT c.category_id, c.parent_category_id
+      FROM tb_category c
+      JOIN ancestors a ON c.category_id = a.parent_category_id
+    )
+    SELECT 1 FROM ancestors WHERE category_id = NEW.category_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0007',
+      MESSAGE = FORMAT(
+        'Moving category %s under %s would create a cycle',
+        NEW.category_id,
+        NEW.parent_category_id
+      );
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
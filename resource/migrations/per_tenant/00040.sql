@@ -0,0 +1,79 @@
+-- +goose Up
+-- Packaging chains are locked per item, like category trees per store
+-- (fn_lock_hierarchy, 00039), so crossing nestings cannot both pass the
+-- containment check.
+CREATE OR REPLACE FUNCTION fn_validate_item_packaging_inner()
+RETURNS TRIGGER AS $$
+DECLARE
+  inner_item_id INTEGER;
+  inner_base NUMERIC;
+BEGIN
+  IF TG_OP = 'UPDATE' THEN
+    PERFORM fn_lock_hierarchy('item_packaging_tree', ARRAY[OLD.item_id, NEW.item_id]);
+  ELSIF NEW.inner_packaging_id IS NOT NULL THEN
+    PERFORM fn_lock_hierarchy('item_packaging_tree', ARRAY[NEW.item_id]);
+  END IF;
+
+  -- Containers must keep holding packagings of their own item
+  IF TG_OP = 'UPDATE' AND NEW.item_id IS DISTINCT FROM OLD.item_id AND EXISTS (
+    SELECT 1 FROM tb_item_packaging WHERE inner_packaging_id = NEW.item_packaging_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0011',
+      MESSAGE = FORMAT(
+        'Packaging %s is contained by other packagings and cannot move to another item',
+        NEW.item_packaging_id
+      );
+  END IF;
+
+  IF NEW.inner_packaging_id IS NULL THEN
+    RETURN NEW;
+  END IF;
+
+  IF NEW.inner_packaging_id = NEW.item_packaging_id THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0011',
+      MESSAGE = FORMAT('Packaging %s cannot contain itself', NEW.item_packaging_id);
+  END IF;
+
+  SELECT item_id INTO inner_item_id
+  FROM tb_item_packaging
+  WHERE item_packaging_id = NEW.inner_packaging_id;
+
+  IF FOUND AND inner_item_id IS DISTINCT FROM NEW.item_id THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0011',
+      MESSAGE = FORMAT(
+        'Inner packaging %s belongs to another item',
+        NEW.inner_packaging_id
+      );
+  END IF;
+
+  -- Walking down from the inner packaging must never reach the row being saved
+  IF TG_OP = 'UPDATE' AND EXISTS (
+    WITH RECURSIVE contents AS (
+      SELECT item_packaging_id, inner_packaging_id
+      FROM tb_item_packaging
+      WHERE item_packaging_id = NEW.inner_packaging_id
+      UNION ALL
+      SELECT p.item_packaging_id, p.inner_packaging_id
+      FROM tb_item_packaging p
+      JOIN contents c ON p.item_packaging_id = c.inner_packaging_id
+    )
+    SELECT 1 FROM contents WHERE item_packaging_id = NEW.item_packaging_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0011',
+      MESSAGE = FORMAT(
+        'Packaging %s cannot contain %s: it would contain itself',
+        NEW.item_packaging_id,
+        NEW.inner_packaging_id
+      );
+  END IF;
+
+  inner_base := fn_item_packaging_base_quantity(NEW.inner_packaging_id);
+  NEW.quantity := NEW.inner_quantity * inner_base;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,43 @@
+-- +goose Up
+-- Only drafts outside of review can be deleted. The line freeze triggers
+-- read the document's approval status, which a document delete removes
+-- before its lines are checked, and a finalized document's stock has been
+-- applied. A finalized document raises P0015, one under review P0013.
+CREATE OR REPLACE FUNCTION fn_protect_stock_document_delete()
+RETURNS TRIGGER AS $$
+DECLARE
+  doc_id INT;
+BEGIN
+  -- <document_type>_id is the primary key of every document table
+  doc_id := (to_jsonb(OLD) ->> (TG_ARGV[0] || '_id'))::INT;
+
+  IF OLD.status IS DISTINCT FROM 'draft' THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0015',
+      MESSAGE = FORMAT('Document %s %s is %s and cannot be deleted', TG_ARGV[0], doc_id, OLD.status);
+  END IF;
+
+  IF OLD.approval_status IN ('submitted', 'approved') THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0013',
+      MESSAGE = FORMAT('Document %s %s is %s and cannot be deleted', TG_ARGV[0], doc_id, OLD.approval_status);
+  END IF;
+
+  RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_protect_stock_in_delete ON tb_stock_in;
+CREATE TRIGGER trg_protect_stock_in_delete
+BEFORE DELETE ON tb_stock_in
+FOR EACH ROW EXECUTE FUNCTION fn_protect_stock_document_delete('stock_in');
+
+DROP TRIGGER IF EXISTS trg_protect_stock_out_delete ON tb_stock_out;
+CREATE TRIGGER trg_protect_stock_out_delete
+BEFORE DELETE ON tb_stock_out
+FOR EACH ROW EXECUTE FUNCTION fn_protect_stock_document_delete('stock_out');
+
+DROP TRIGGER IF EXISTS trg_protect_stock_waste_delete ON tb_stock_waste;
+CREATE TRIGGER trg_protect_stock_waste_delete
+BEFORE DELETE ON tb_stock_waste
+FOR EACH ROW EXECUTE FUNCTION fn_protect_stock_document_delete('stock_waste');
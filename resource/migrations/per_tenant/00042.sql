@@ -0,0 +1,186 @@
+-- +goose Up
+-- Rows only reference catalog entities of their own store. Without these
+-- checks a line of a store A document could point at a store B item, whose
+-- tb_stock row the finalization then changes. Mismatches raise P0016.
+
+-- Step 1: Document lines use items of the document's store
+CREATE OR REPLACE FUNCTION fn_validate_stock_in_item_store()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1
+    FROM tb_stock_in si
+    JOIN tb_item i ON i.store_id = si.store_id
+    WHERE si.stock_in_id = NEW.stock_in_id
+      AND i.item_id = NEW.item_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0016',
+      MESSAGE = FORMAT('Item %s does not belong to the stock-in store', NEW.item_id);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_validate_stock_in_item_store ON tb_stock_in_item;
+CREATE TRIGGER trg_validate_stock_in_item_store
+BEFORE INSERT OR UPDATE OF item_id ON tb_stock_in_item
+FOR EACH ROW EXECUTE FUNCTION fn_validate_stock_in_item_store();
+
+CREATE OR REPLACE FUNCTION fn_validate_stock_out_item_store()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1
+    FROM tb_stock_out so
+    JOIN tb_item i ON i.store_id = so.store_id
+    WHERE so.stock_out_id = NEW.stock_out_id
+      AND i.item_id = NEW.item_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0016',
+      MESSAGE = FORMAT('Item %s does not belong to the stock-out store', NEW.item_id);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_validate_stock_out_item_store ON tb_stock_out_item;
+CREATE TRIGGER trg_validate_stock_out_item_store
+BEFORE INSERT OR UPDATE OF item_id ON tb_stock_out_item
+FOR EACH ROW EXECUTE FUNCTION fn_validate_stock_out_item_store();
+
+CREATE OR REPLACE FUNCTION fn_validate_stock_waste_item_store()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1
+    FROM tb_item i
+    WHERE i.item_id = NEW.item_id
+      AND i.store_id = NEW.store_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0016',
+      MESSAGE = FORMAT('Item %s does not belong to the stock waste store', NEW.item_id);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_validate_stock_waste_item_store ON tb_stock_waste;
+CREATE TRIGGER trg_validate_stock_waste_item_store
+BEFORE INSERT OR UPDATE OF item_id, store_id ON tb_stock_waste
+FOR EACH ROW EXECUTE FUNCTION fn_validate_stock_waste_item_store();
+
+-- Step 2: Packaging lines use packagings of the document's store
+CREATE OR REPLACE FUNCTION fn_validate_stock_in_packaging_store()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1
+    FROM tb_stock_in_item sii
+    JOIN tb_stock_in si ON si.stock_in_id = sii.stock_in_id
+    JOIN tb_item_packaging ip ON ip.store_id = si.store_id
+    WHERE sii.stock_in_item_id = NEW.stock_in_item_id
+      AND ip.item_packaging_id = NEW.item_packaging_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0016',
+      MESSAGE = FORMAT('Packaging %s does not belong to the stock-in store', NEW.item_packaging_id);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_validate_stock_in_packaging_store ON tb_stock_in_packaging;
+CREATE TRIGGER trg_validate_stock_in_packaging_store
+BEFORE INSERT OR UPDATE OF item_packaging_id ON tb_stock_in_packaging
+FOR EACH ROW EXECUTE FUNCTION fn_validate_stock_in_packaging_store();
+
+CREATE OR REPLACE FUNCTION fn_validate_stock_out_packaging_store()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1
+    FROM tb_stock_out_item soi
+    JOIN tb_stock_out so ON so.stock_out_id = soi.stock_out_id
+    JOIN tb_item_packaging ip ON ip.store_id = so.store_id
+    WHERE soi.stock_out_item_id = NEW.stock_out_item_id
+      AND ip.item_packaging_id = NEW.item_packaging_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0016',
+      MESSAGE = FORMAT('Packaging %s does not belong to the stock-out store', NEW.item_packaging_id);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_validate_stock_out_packaging_store ON tb_stock_out_packaging;
+CREATE TRIGGER trg_validate_stock_out_packaging_store
+BEFORE INSERT OR UPDATE OF item_packaging_id ON tb_stock_out_packaging
+FOR EACH ROW EXECUTE FUNCTION fn_validate_stock_out_packaging_store();
+
+-- Step 3: Items use categories and units of their store
+CREATE OR REPLACE FUNCTION fn_validate_item_store()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF NEW.category_id IS NOT NULL AND NOT EXISTS (
+    SELECT 1
+    FROM tb_category c
+    WHERE c.category_id = NEW.category_id
+      AND c.store_id = NEW.store_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0016',
+      MESSAGE = FORMAT('Category %s does not belong to the item store', NEW.category_id);
+  END IF;
+
+  IF NEW.unit_id IS NOT NULL AND NOT EXISTS (
+    SELECT 1
+    FROM tb_unit_of_measure u
+    WHERE u.unit_id = NEW.unit_id
+      AND u.store_id = NEW.store_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0016',
+      MESSAGE = FORMAT('Unit %s does not belong to the item store', NEW.unit_id);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_validate_item_store ON tb_item;
+CREATE TRIGGER trg_validate_item_store
+BEFORE INSERT OR UPDATE OF category_id, unit_id, store_id ON tb_item
+FOR EACH ROW EXECUTE FUNCTION fn_validate_item_store();
+
+-- Step 4: Packagings belong to items of their store
+CREATE OR REPLACE FUNCTION fn_validate_item_packaging_store()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1
+    FROM tb_item i
+    WHERE i.item_id = NEW.item_id
+      AND i.store_id = NEW.store_id
+  ) THEN
+    RAISE EXCEPTION USING
+      ERRCODE = 'P0016',
+      MESSAGE = FORMAT('Item %s does not belong to the packaging store', NEW.item_id);
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_validate_item_packaging_store ON tb_item_packaging;
+CREATE TRIGGER trg_validate_item_packaging_store
+BEFORE INSERT OR UPDATE OF item_id, store_id ON tb_item_packaging
+FOR EACH ROW EXECUTE FUNCTION fn_validate_item_packaging_store();
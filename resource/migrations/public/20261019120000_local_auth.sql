@@ -0,0 +1,55 @@
+-- +goose Up
+-- Local email/password accounts: verified emails, one-time tokens and
+-- login attempts for brute-force throttling
+
+ALTER TABLE public.tb_user
+  ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ NULL;
+
+-- Google already verified the address of every Google account
+UPDATE public.tb_user
+SET email_verified_at = created_at
+WHERE auth_provider = 'google' AND email_verified_at IS NULL;
+
+-- Local accounts have no Google ID, so only enforce uniqueness when one is set
+ALTER TABLE public.tb_user
+  DROP CONSTRAINT IF EXISTS unique_provider_id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_user_provider_google_id
+  ON public.tb_user (auth_provider, google_id)
+  WHERE google_id IS NOT NULL AND google_id <> '';
+
+-- Only a SHA-256 hash of each token is stored, the token itself is mailed
+CREATE TABLE IF NOT EXISTS public.tb_user_token (
+  token_id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES public.tb_user(user_id) ON DELETE CASCADE,
+  purpose TEXT NOT NULL CHECK (purpose IN ('email_verification', 'password_reset')),
+  token_hash TEXT UNIQUE NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_token_user ON public.tb_user_token (user_id, purpose);
+
+CREATE TABLE IF NOT EXISTS public.tb_login_attempt (
+  attempt_id BIGSERIAL PRIMARY KEY,
+  email VARCHAR(255) NOT NULL,
+  ip_address TEXT NULL,
+  succeeded BOOLEAN NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempt_email ON public.tb_login_attempt (lower(email), created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempt_ip ON public.tb_login_attempt (ip_address, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.tb_login_attempt;
+DROP TABLE IF EXISTS public.tb_user_token;
+
+DROP INDEX IF EXISTS public.uq_user_provider_google_id;
+
+ALTER TABLE public.tb_user
+  ADD CONSTRAINT unique_provider_id UNIQUE (auth_provider, google_id);
+
+ALTER TABLE public.tb_user
+  DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,81 @@
+-- +goose Up
+-- Server-side sessions: every login starts a session that its access tokens
+-- reference, and rotating refresh tokens keep it alive
+
+CREATE TABLE IF NOT EXISTS public.tb_auth_session (
+  session_id UUID PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES public.tb_user(user_id) ON DELETE CASCADE,
+  user_agent TEXT NULL,
+  ip_address TEXT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_used_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  expires_at TIMESTAMPTZ NOT NULL,
+  revoked_at TIMESTAMPTZ NULL,
+  revoked_reason TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_session_user ON public.tb_auth_session (user_id) WHERE revoked_at IS NULL;
+
+-- Only a SHA-256 hash of each refresh token is stored. A used token stays
+-- around so presenting it again can be detected as reuse.
+CREATE TABLE IF NOT EXISTS public.tb_refresh_token (
+  refresh_token_id BIGSERIAL PRIMARY KEY,
+  session_id UUID NOT NULL REFERENCES public.tb_auth_session(session_id) ON DELETE CASCADE,
+  token_hash TEXT UNIQUE NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_token_session ON public.tb_refresh_token (session_id);
+
+-- Deactivating a user or an organization revokes its sessions right away
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION public.fn_revoke_user_sessions()
+RETURNS TRIGGER AS $$
+BEGIN
+  UPDATE public.tb_auth_session
+  SET revoked_at = now(), revoked_reason = 'user_deactivated'
+  WHERE user_id = NEW.user_id AND revoked_at IS NULL;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP TRIGGER IF EXISTS trg_revoke_user_sessions ON public.tb_user;
+CREATE TRIGGER trg_revoke_user_sessions
+AFTER UPDATE OF is_active ON public.tb_user
+FOR EACH ROW
+WHEN (OLD.is_active IS DISTINCT FROM NEW.is_active AND NEW.is_active IS NOT TRUE)
+EXECUTE FUNCTION public.fn_revoke_user_sessions();
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION public.fn_revoke_organization_sessions()
+RETURNS TRIGGER AS $$
+BEGIN
+  UPDATE public.tb_auth_session s
+  SET revoked_at = now(), revoked_reason = 'organization_deactivated'
+  FROM public.tb_user u
+  WHERE u.user_id = s.user_id
+    AND u.organization_id = NEW.organization_id
+    AND s.revoked_at IS NULL;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP TRIGGER IF EXISTS trg_revoke_organization_sessions ON public.tb_organization;
+CREATE TRIGGER trg_revoke_organization_sessions
+AFTER UPDATE OF is_active ON public.tb_organization
+FOR EACH ROW
+WHEN (OLD.is_active IS DISTINCT FROM NEW.is_active AND NEW.is_active IS NOT TRUE)
+EXECUTE FUNCTION public.fn_revoke_organization_sessions();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS trg_revoke_organization_sessions ON public.tb_organization;
+DROP TRIGGER IF EXISTS trg_revoke_user_sessions ON public.tb_user;
+DROP FUNCTION IF EXISTS public.fn_revoke_organization_sessions();
+DROP FUNCTION IF EXISTS public.fn_revoke_user_sessions();
+DROP TABLE IF EXISTS public.tb_refresh_token;
+DROP TABLE IF EXISTS public.tb_auth_session;
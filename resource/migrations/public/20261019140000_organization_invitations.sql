@@ -0,0 +1,33 @@
+-- +goose Up
+-- Invitations into an existing organization. Accepting one attaches the user
+-- to the organization with the invited role, in the whole organization or in
+-- one of its stores. store_id points into the organization's schema, so it
+-- has no foreign key.
+
+CREATE TABLE IF NOT EXISTS public.tb_organization_invitation (
+  invitation_id SERIAL PRIMARY KEY,
+  organization_id INT NOT NULL REFERENCES public.tb_organization(organization_id) ON DELETE CASCADE,
+  email VARCHAR(255) NOT NULL,
+  role TEXT NOT NULL CHECK (role IN ('owner', 'manager', 'clerk', 'viewer')),
+  store_id INT NULL,
+  -- Only a SHA-256 hash of the token is stored, the token itself is mailed
+  token_hash TEXT UNIQUE NOT NULL,
+  invited_by INT NULL REFERENCES public.tb_user(user_id) ON DELETE SET NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  last_sent_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  accepted_at TIMESTAMPTZ NULL,
+  accepted_by INT NULL REFERENCES public.tb_user(user_id) ON DELETE SET NULL,
+  revoked_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One open invitation per email and organization, resend it instead
+CREATE UNIQUE INDEX IF NOT EXISTS uq_organization_invitation_open
+  ON public.tb_organization_invitation (organization_id, lower(email))
+  WHERE accepted_at IS NULL AND revoked_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_organization_invitation_organization
+  ON public.tb_organization_invitation (organization_id, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.tb_organization_invitation;
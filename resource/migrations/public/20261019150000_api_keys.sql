@@ -0,0 +1,28 @@
+-- +goose Up
+-- Organization API keys for integrations that cannot log in interactively.
+-- A key acts on behalf of the user who created it, limited to its scopes and
+-- optionally to one store. store_id points into the organization's schema,
+-- so it has no foreign key.
+
+CREATE TABLE IF NOT EXISTS public.tb_api_key (
+  api_key_id SERIAL PRIMARY KEY,
+  organization_id INT NOT NULL REFERENCES public.tb_organization(organization_id) ON DELETE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  -- Start of the key, kept in clear so owners can tell keys apart
+  key_prefix TEXT NOT NULL,
+  -- Only a SHA-256 hash of the key is stored, the key is shown once
+  key_hash TEXT UNIQUE NOT NULL,
+  scopes TEXT[] NOT NULL,
+  store_id INT NULL,
+  created_by INT NULL REFERENCES public.tb_user(user_id) ON DELETE SET NULL,
+  expires_at TIMESTAMPTZ NULL,
+  last_used_at TIMESTAMPTZ NULL,
+  last_used_ip TEXT NULL,
+  revoked_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_key_organization ON public.tb_api_key (organization_id, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.tb_api_key;
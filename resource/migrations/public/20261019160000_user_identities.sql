@@ -0,0 +1,26 @@
+-- +goose Up
+-- External identities users log in with, one per provider account. A user
+-- is found by the provider's subject, which unlike the email never changes.
+-- tb_user.google_id is kept for reference but no longer looked up.
+
+CREATE TABLE IF NOT EXISTS public.tb_user_identity (
+  user_identity_id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES public.tb_user(user_id) ON DELETE CASCADE,
+  provider VARCHAR(50) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  -- Email the provider reported when the identity was linked
+  email VARCHAR(255) NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT uq_user_identity_provider_subject UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identity_user ON public.tb_user_identity (user_id);
+
+INSERT INTO public.tb_user_identity (user_id, provider, subject, email)
+SELECT user_id, 'google', google_id, email
+FROM public.tb_user
+WHERE google_id IS NOT NULL AND google_id <> ''
+ON CONFLICT (provider, subject) DO NOTHING;
+
+-- +goose Down
+DROP TABLE IF EXISTS public.tb_user_identity;
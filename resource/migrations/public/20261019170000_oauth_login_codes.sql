@@ -0,0 +1,32 @@
+-- +goose Up
+-- Server-side record of each OAuth login in flight, looked up by the state
+-- the provider sends back. It carries the PKCE verifier and the ID token
+-- nonce, which never reach the browser. Rows are deleted when used.
+
+CREATE TABLE IF NOT EXISTS public.tb_oauth_state (
+  -- Only a SHA-256 hash of the state is stored
+  state_hash TEXT PRIMARY KEY,
+  provider VARCHAR(50) NOT NULL,
+  nonce TEXT NOT NULL,
+  code_verifier TEXT NOT NULL,
+  is_try_out BOOLEAN NOT NULL DEFAULT FALSE,
+  invitation_token TEXT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Single-use codes the OAuth callback redirects to the frontend with, the
+-- frontend exchanges one for the session tokens, which so stay out of URLs.
+CREATE TABLE IF NOT EXISTS public.tb_oauth_login_code (
+  -- Only a SHA-256 hash of the code is stored
+  code_hash TEXT PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES public.tb_user(user_id) ON DELETE CASCADE,
+  -- Try-out job started by the login, for the frontend to poll
+  tryout_uuid TEXT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.tb_oauth_login_code;
+DROP TABLE IF EXISTS public.tb_oauth_state;
@@ -0,0 +1,52 @@
+-- +goose Up
+-- TOTP two-factor authentication for local accounts. A secret without
+-- totp_enabled_at is an enrollment waiting for its first code.
+
+ALTER TABLE public.tb_user
+  ADD COLUMN IF NOT EXISTS totp_secret TEXT NULL,
+  ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMPTZ NULL,
+  -- Time step of the last accepted code, so each code works once
+  ADD COLUMN IF NOT EXISTS totp_last_counter BIGINT NULL;
+
+ALTER TABLE public.tb_organization
+  ADD COLUMN IF NOT EXISTS require_two_factor BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- One-time backup codes, for when the authenticator is lost. Only a
+-- SHA-256 hash of each code is stored.
+CREATE TABLE IF NOT EXISTS public.tb_user_backup_code (
+  backup_code_id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES public.tb_user(user_id) ON DELETE CASCADE,
+  code_hash TEXT NOT NULL,
+  used_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (user_id, code_hash)
+);
+
+-- Password logins of 2FA users first get a token that is only good for
+-- sending the second factor
+ALTER TABLE public.tb_user_token
+  DROP CONSTRAINT IF EXISTS tb_user_token_purpose_check;
+
+ALTER TABLE public.tb_user_token
+  ADD CONSTRAINT tb_user_token_purpose_check
+  CHECK (purpose IN ('email_verification', 'password_reset', 'two_factor_login'));
+
+-- +goose Down
+DELETE FROM public.tb_user_token WHERE purpose = 'two_factor_login';
+
+ALTER TABLE public.tb_user_token
+  DROP CONSTRAINT IF EXISTS tb_user_token_purpose_check;
+
+ALTER TABLE public.tb_user_token
+  ADD CONSTRAINT tb_user_token_purpose_check
+  CHECK (purpose IN ('email_verification', 'password_reset'));
+
+DROP TABLE IF EXISTS public.tb_user_backup_code;
+
+ALTER TABLE public.tb_organization
+  DROP COLUMN IF EXISTS require_two_factor;
+
+ALTER TABLE public.tb_user
+  DROP COLUMN IF EXISTS totp_last_counter,
+  DROP COLUMN IF EXISTS totp_enabled_at,
+  DROP COLUMN IF EXISTS totp_secret;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Operators of the platform. The role is granted per user here, by hand,
+-- and never follows from a user's membership or role in an organization.
+CREATE TABLE IF NOT EXISTS public.tb_platform_admin (
+  user_id INT PRIMARY KEY REFERENCES public.tb_user(user_id) ON DELETE CASCADE,
+  granted_by INT NULL REFERENCES public.tb_user(user_id) ON DELETE SET NULL,
+  granted_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS public.tb_platform_admin;
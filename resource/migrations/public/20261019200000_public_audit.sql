@@ -0,0 +1,88 @@
+-- +goose Up
+-- The organization's rows in the public schema (its settings, API keys and
+-- invitations) are audited into the tb_audit_log of the organization's own
+-- schema, next to the entries of the per-tenant triggers. The first argument
+-- is the entity type, the second the key column and the rest name columns
+-- left out of the entries: secrets, and bookkeeping an update of which alone
+-- is not logged. The actor comes from app.user_id and app.api_key_id, which
+-- the API sets in the transaction of the change.
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION public.fn_audit_organization_row()
+RETURNS TRIGGER AS $$
+DECLARE
+  v_before JSONB;
+  v_after JSONB;
+  v_action TEXT;
+  v_schema TEXT;
+  i INT;
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    v_after := to_jsonb(NEW);
+    v_action := 'create';
+  ELSIF TG_OP = 'UPDATE' THEN
+    v_before := to_jsonb(OLD);
+    v_after := to_jsonb(NEW);
+    v_action := 'update';
+  ELSE
+    v_before := to_jsonb(OLD);
+    v_action := 'delete';
+  END IF;
+
+  FOR i IN 2 .. TG_NARGS - 1 LOOP
+    v_before := v_before - TG_ARGV[i];
+    v_after := v_after - TG_ARGV[i];
+  END LOOP;
+
+  IF TG_OP = 'UPDATE' AND v_before = v_after THEN
+    RETURN NULL;
+  END IF;
+
+  SELECT schema_name INTO v_schema
+  FROM public.tb_organization
+  WHERE organization_id = (COALESCE(v_after, v_before) ->> 'organization_id')::INT;
+
+  -- The schema is created after its organization and dropped before it
+  IF v_schema IS NULL OR to_regclass(FORMAT('%I.tb_audit_log', v_schema)) IS NULL THEN
+    RETURN NULL;
+  END IF;
+
+  EXECUTE FORMAT(
+    'INSERT INTO %I.tb_audit_log (user_id, api_key_id, entity_type, entity_id, action, before, after)
+     VALUES ($1, $2, $3, $4, $5, $6, $7)', v_schema)
+  USING
+    NULLIF(current_setting('app.user_id', true), '')::INT,
+    NULLIF(current_setting('app.api_key_id', true), '')::INT,
+    TG_ARGV[0],
+    COALESCE(v_after, v_before) ->> TG_ARGV[1],
+    v_action,
+    v_before,
+    v_after;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- Deleting an organization drops its schema, so only updates are logged
+DROP TRIGGER IF EXISTS trg_audit_organization ON public.tb_organization;
+CREATE TRIGGER trg_audit_organization
+AFTER UPDATE ON public.tb_organization
+FOR EACH ROW EXECUTE FUNCTION public.fn_audit_organization_row('organization', 'organization_id', 'organization_key');
+
+-- last_used_at and last_used_ip change on every request made with the key
+DROP TRIGGER IF EXISTS trg_audit_api_key ON public.tb_api_key;
+CREATE TRIGGER trg_audit_api_key
+AFTER INSERT OR UPDATE OR DELETE ON public.tb_api_key
+FOR EACH ROW EXECUTE FUNCTION public.fn_audit_organization_row('api_key', 'api_key_id', 'key_hash', 'last_used_at', 'last_used_ip');
+
+DROP TRIGGER IF EXISTS trg_audit_invitation ON public.tb_organization_invitation;
+CREATE TRIGGER trg_audit_invitation
+AFTER INSERT OR UPDATE OR DELETE ON public.tb_organization_invitation
+FOR EACH ROW EXECUTE FUNCTION public.fn_audit_organization_row('invitation', 'invitation_id', 'token_hash');
+
+-- +goose Down
+DROP TRIGGER IF EXISTS trg_audit_invitation ON public.tb_organization_invitation;
+DROP TRIGGER IF EXISTS trg_audit_api_key ON public.tb_api_key;
+DROP TRIGGER IF EXISTS trg_audit_organization ON public.tb_organization;
+DROP FUNCTION IF EXISTS public.fn_audit_organization_row();